@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/control"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+/**
+	Queries and controls a running poolnode through its control api.
+		poolctl [-addr host:port] status
+		poolctl [-addr host:port] pools
+		poolctl [-addr host:port] epochs [-from n] [-to n]
+		poolctl [-addr host:port] create-pool
+		poolctl [-addr host:port] stake -pool id -gwei n
+		poolctl [-addr host:port] liquidate -pool id
+		poolctl [-addr host:port] join
+		poolctl [-addr host:port] exit
+		poolctl [-addr host:port] shutdown
+	create-pool, stake, liquidate, join and exit send the node's participant's signed request to the network, it
+	applies once a finalized block includes it (see status and pools).
+ */
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: poolctl [-addr host:port] status|pools|epochs [-from n] [-to n]|create-pool|stake -pool id -gwei n|liquidate -pool id|join|exit|shutdown\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8001", "the node's control api address")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+	}
+
+	client := control.NewClient(*addr)
+	var err error
+	switch flag.Arg(0) {
+	case "status":
+		err = status(client)
+	case "pools":
+		err = pools(client)
+	case "epochs":
+		err = epochs(client, flag.Args()[1:])
+	case "create-pool":
+		err = printRequest(client.CreatePool())
+	case "stake":
+		err = stake(client, flag.Args()[1:])
+	case "liquidate":
+		err = liquidate(client, flag.Args()[1:])
+	case "join":
+		err = printRequest(client.Join())
+	case "exit":
+		err = printRequest(client.Exit())
+	case "shutdown":
+		err = client.Shutdown()
+		if err == nil {
+			fmt.Println("shutting down")
+		}
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "poolctl: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func status(client *control.Client) error {
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Participant:\t%d\n", status.ParticipantId)
+	fmt.Fprintf(w, "Address:\t%s\n", status.Address)
+	fmt.Fprintf(w, "Peers:\t%d\n", status.Peers)
+	fmt.Fprintf(w, "Genesis ready:\t%t\n", status.GenesisReady)
+	fmt.Fprintf(w, "Current epoch:\t%d\n", status.CurrentEpoch)
+	fmt.Fprintf(w, "Pools:\t%d\n", status.Pools)
+	fmt.Fprintf(w, "Participant status:\t%s\n", status.ParticipantStatus)
+	fmt.Fprintf(w, "Balance (gwei):\t%d\n", status.Balance)
+	fmt.Fprintf(w, "Slashed:\t%t\n", status.Slashed)
+	fmt.Fprintf(w, "Finalized epoch:\t%s\n", epochOrNone(status.FinalizedEpoch))
+	fmt.Fprintf(w, "Finalized state root:\t%s\n", status.FinalizedStateRoot)
+	return w.Flush()
+}
+
+func pools(client *control.Client) error {
+	pools, err := client.Pools()
+	if err != nil {
+		return err
+	}
+	return printPools(pools)
+}
+
+func printPools(pools []*control.PoolInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tSTATUS\tSTAKE\tACTIVATION\tEXIT\tSIZE\tPK\n")
+	for _, pool := range pools {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%d\t%s\n", pool.Id, pool.Status, pool.Stake, epochOrNone(pool.ActivationEpoch),
+			epochOrNone(pool.ExitEpoch), pool.Size, orNone(pool.Pk))
+	}
+	return w.Flush()
+}
+
+func printRequest(req *control.RequestInfo, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s request %s sent by participant %d in epoch %d", req.Type, req.Id, req.ParticipantId, req.Epoch)
+	if req.PoolId != 0 {
+		fmt.Printf(", pool %d", req.PoolId)
+	}
+	fmt.Println()
+	return nil
+}
+
+func stake(client *control.Client, args []string) error {
+	flags := flag.NewFlagSet("stake", flag.ExitOnError)
+	pool := flags.Uint("pool", 0, "the pending pool's id")
+	gwei := flags.Uint64("gwei", 0, "the deposited amount")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	return printRequest(client.AddPoolStake(uint32(*pool), *gwei))
+}
+
+func liquidate(client *control.Client, args []string) error {
+	flags := flag.NewFlagSet("liquidate", flag.ExitOnError)
+	pool := flags.Uint("pool", 0, "the active pool's id")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	return printRequest(client.LiquidatePool(uint32(*pool)))
+}
+
+func epochs(client *control.Client, args []string) error {
+	flags := flag.NewFlagSet("epochs", flag.ExitOnError)
+	from := flags.Int64("from", -1, "first epoch (default the last 10 epochs)")
+	to := flags.Int64("to", -1, "last epoch (default the current epoch)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var fromPtr, toPtr *uint32
+	if *from >= 0 {
+		v := uint32(*from)
+		fromPtr = &v
+	}
+	if *to >= 0 {
+		v := uint32(*to)
+		toPtr = &v
+	}
+	epochs, err := client.Epochs(fromPtr, toPtr)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "EPOCH\tPOOL\tSHARE\tPUBLIC SHARES\tBAD SIGNERS\tDUTY SIGS\tSIG VERIFIED\tROOT\tSTATE ROOT\tFINALIZED STATE ROOT\n")
+	for _, e := range epochs {
+		bad := make([]string, len(e.BadSigners))
+		for i, id := range e.BadSigners {
+			bad[i] = fmt.Sprintf("%d", id)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%t\t%d\t%s\t%d\t%t\t%s\t%s\t%s\n", e.Number, e.PoolId, e.HasShare, e.PublicShares, orNone(strings.Join(bad, ",")), len(e.Signatures), e.SigVerified,
+			shortRoot(e.Root), shortRoot(e.StateRoot), shortRoot(e.FinalizedStateRoot))
+	}
+	return w.Flush()
+}
+
+func epochOrNone(epoch *uint32) string {
+	if epoch == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *epoch)
+}
+
+// a hex root's first 4 bytes, like an abbreviated commit hash
+func shortRoot(root string) string {
+	if len(root) > 8 {
+		return root[:8]
+	}
+	return orNone(root)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+/**
+	poolnode config file (JSON), every operator runs one participant:
+	{
+		"id": 1,
+		"listen": "0.0.0.0:7001",
+		"control": "127.0.0.1:8001",
+		"data_dir": "/var/lib/poolnode",
+		"genesis_time": 1594000000,
+		"gossip": false,
+		"network": "network.yaml",
+		"peers": [
+			{"id": 2, "address": "node2:7001", "encryption_pk": "..", "identity_pk": ".."},
+			...
+		]
+	}
+	peers must list every other genesis participant of the network, a peer entry is what `poolnode -init` prints.
+	A participant joining after genesis has an id that isn't a genesis one, it's listed as a peer by the nodes it
+	connects to and registers itself with a join request (poolctl join).
+	There's no beacon node setting, pools sign their local duties (eth2.LocalDutySource) and nothing is submitted:
+	pool-chain epochs aren't mapped onto the beacon chain's clock and pools have no deposit assigned validator
+	index yet.
+ */
+
+type PeerConfig struct {
+	Id uint32 `json:"id"`
+	// host:port of the peer's p2p listener
+	Address string `json:"address"`
+	// hex serialized G1 (ECIES key)
+	EncryptionPk string `json:"encryption_pk"`
+	// hex serialized BLS public key
+	IdentityPk string `json:"identity_pk"`
+}
+
+type Config struct {
+	Id uint32 `json:"id"`
+	Listen string `json:"listen"`
+	// the control API (poolctl), should be a localhost address
+	Control string `json:"control"`
+	// keys, shares and the state db are kept here
+	DataDir string `json:"data_dir"`
+	// unix time (sec) at which all participants start the genesis DKG, epoch 0 starts after it
+	GenesisTime int64 `json:"genesis_time"`
+	// gossip messages (relayed by peers) instead of sending them to every peer directly
+	Gossip bool `json:"gossip"`
+	// network config file (see net.LoadNetworkConfig), the test network if empty. Must be the same for all nodes
+	Network string `json:"network"`
+	// keystores scrypt n, 0 for the EIP-2335 default. Lower values are meant for testing only
+	KeystoreScryptN int `json:"keystore_scrypt_n"`
+	Peers []*PeerConfig `json:"peers"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Config{}
+	err = json.Unmarshal(data, ret)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config %s: %s", path, err.Error())
+	}
+	return ret, nil
+}
+
+func (c *Config) NetworkConfig() (*net.NetworkConfig, error) {
+	if c.Network == "" {
+		return net.NewTestNetworkConfig(), nil
+	}
+	ret, err := net.LoadNetworkConfig(c.Network)
+	if err != nil {
+		return nil, fmt.Errorf("could not load network config: %s", err.Error())
+	}
+	return ret, nil
+}
+
+func (c *Config) GenesisTimestamp() time.Time {
+	return time.Unix(c.GenesisTime, 0)
+}
+
+// checks the config against the network, every other genesis participant should be a peer exactly once. The node
+// and its other peers can be participants that join after genesis.
+func (c *Config) Validate(network *net.NetworkConfig) error {
+	if c.Id == 0 {
+		return fmt.Errorf("participant id missing")
+	}
+	if c.Listen == "" {
+		return fmt.Errorf("listen address missing")
+	}
+	if c.DataDir == "" {
+		return fmt.Errorf("data dir missing")
+	}
+	if c.GenesisTime == 0 {
+		return fmt.Errorf("genesis time missing")
+	}
+
+	seen := map[uint32]bool{c.Id: true}
+	for _, peer := range c.Peers {
+		if peer.Id == 0 {
+			return fmt.Errorf("peer id missing")
+		}
+		if seen[peer.Id] {
+			return fmt.Errorf("peer %d listed more than once (or is this node)", peer.Id)
+		}
+		seen[peer.Id] = true
+
+		if peer.Address == "" {
+			return fmt.Errorf("peer %d has no address", peer.Id)
+		}
+		_, err := peer.Participant()
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range network.ParticipantIndexesList() {
+		if !seen[id] {
+			return fmt.Errorf("genesis participant %d is not a peer", id)
+		}
+	}
+	return nil
+}
+
+// true if id is one of the network's genesis participants, the others join with a join request
+func IsGenesisParticipant(network *net.NetworkConfig, id uint32) bool {
+	for _, genesisId := range network.ParticipantIndexesList() {
+		if genesisId == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PeerConfig) Participant() (*state.Participant, error) {
+	data, err := hex.DecodeString(p.EncryptionPk)
+	if err != nil {
+		return nil, fmt.Errorf("peer %d invalid encryption pk: %s", p.Id, err.Error())
+	}
+	encryptionPk := &bls.G1{}
+	err = encryptionPk.Deserialize(data)
+	if err != nil {
+		return nil, fmt.Errorf("peer %d invalid encryption pk: %s", p.Id, err.Error())
+	}
+
+	identityPk := &bls.PublicKey{}
+	err = identityPk.DeserializeHexStr(p.IdentityPk)
+	if err != nil {
+		return nil, fmt.Errorf("peer %d invalid identity pk: %s", p.Id, err.Error())
+	}
+
+	return state.NewParticipant(p.Id, encryptionPk, identityPk), nil
+}
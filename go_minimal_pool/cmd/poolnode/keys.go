@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/**
+	The participant's long term keys, EIP-2335 keystores in <data dir>/keys unlocked with the node's password.
+ */
+
+const (
+	identityKeyFile = "identity.json"
+	encryptionKeyFile = "encryption.json"
+)
+
+type keys struct {
+	identitySk *bls.SecretKey
+	encryptionSk *bls.Fr
+}
+
+// generates new keys, fails if keys already exist so they are never overwritten
+func generateKeys(dataDir string, password string, params crypto.KeystoreParams) (*keys, error) {
+	dir := filepath.Join(dataDir, "keys")
+	for _, f := range []string{identityKeyFile, encryptionKeyFile} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return nil, fmt.Errorf("%s already exists", filepath.Join(dir, f))
+		}
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &keys{identitySk: &bls.SecretKey{}}
+	ret.identitySk.SetByCSPRNG()
+	ret.encryptionSk, _ = crypto.NewEncryptionKey()
+
+	identityPk := ret.identitySk.GetPublicKey()
+	err = writeKeystore(filepath.Join(dir, identityKeyFile), ret.identitySk.Serialize(), identityPk.Serialize(), "identity key", password, params)
+	if err != nil {
+		return nil, err
+	}
+	encryptionPk := bls.CastToSecretKey(ret.encryptionSk).GetPublicKey()
+	err = writeKeystore(filepath.Join(dir, encryptionKeyFile), ret.encryptionSk.Serialize(), encryptionPk.Serialize(), "encryption key", password, params)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func loadKeys(dataDir string, password string) (*keys, error) {
+	dir := filepath.Join(dataDir, "keys")
+
+	identity, err := readKeystore(filepath.Join(dir, identityKeyFile), password)
+	if err != nil {
+		return nil, err
+	}
+	ret := &keys{identitySk: &bls.SecretKey{}, encryptionSk: &bls.Fr{}}
+	err = ret.identitySk.Deserialize(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	encryption, err := readKeystore(filepath.Join(dir, encryptionKeyFile), password)
+	if err != nil {
+		return nil, err
+	}
+	err = ret.encryptionSk.Deserialize(encryption)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// the peer entry other operators add to their config
+func (k *keys) peerConfig(id uint32, address string) *PeerConfig {
+	encryptionPk := bls.CastFromPublicKey(bls.CastToSecretKey(k.encryptionSk).GetPublicKey())
+	return &PeerConfig{
+		Id:           id,
+		Address:      address,
+		EncryptionPk: hex.EncodeToString(encryptionPk.Serialize()),
+		IdentityPk:   k.identitySk.GetPublicKey().SerializeToHexStr(),
+	}
+}
+
+func writeKeystore(path string, secret []byte, pubkey []byte, description string, password string, params crypto.KeystoreParams) error {
+	keystore, err := crypto.EncryptKeystore(secret, pubkey, password, description, params)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(keystore, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func readKeystore(path string, password string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keystore := &crypto.Keystore{}
+	err = json.Unmarshal(data, keystore)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", path, err.Error())
+	}
+	ret, err := keystore.Decrypt(password)
+	if err != nil {
+		return nil, fmt.Errorf("could not unlock %s: %s", path, err.Error())
+	}
+	return ret, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/participant"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/control"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/gossip"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/grpc_net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+/**
+	Runs exactly one participant.
+		poolnode -config node.json -init	generates the participant's keys and prints its peer entry
+		poolnode -config node.json		runs the participant
+		poolnode -config node.json -import-slashing-protection file.json
+							merges an EIP-3076 interchange file into the node's slashing protection
+		poolnode -config node.json -export-slashing-protection file.json
+							writes the node's slashing protection as an interchange file
+	The keystores password is read from the POOLNODE_PASSWORD env var.
+
+	On first run (no pools in the state db) the node waits for the config's genesis time and runs the genesis DKG
+	with its pool. A node that isn't a genesis participant follows the genesis DKG's pool keys and the chain, it
+	joins the pools once a finalized block includes its join request (poolctl join). Epoch 0 starts genesisDKGPhases DKG phases after genesis time, every node derives epoch
+	boundaries from it so independently started nodes process the same epochs. A restarted node keeps its state and
+	shares and joins at the next epoch.
+
+	Slashing protection is kept in the data dir (slashing_protection.json, in the interchange format), the node
+	refuses to release partial signatures conflicting with it.
+ */
+
+const (
+	passwordEnv = "POOLNODE_PASSWORD"
+	// the genesis DKG takes 6 phases, the rest is slack for the pool pk votes
+	genesisDKGPhases = 10
+	slashingProtectionFile = "slashing_protection.json"
+)
+
+func main() {
+	configPath := flag.String("config", "poolnode.json", "config file")
+	initKeys := flag.Bool("init", false, "generate the participant's keys and print its peer entry")
+	importSlashing := flag.String("import-slashing-protection", "", "merge an EIP-3076 interchange file and exit")
+	exportSlashing := flag.String("export-slashing-protection", "", "write an EIP-3076 interchange file and exit")
+	flag.Parse()
+
+	crypto.InitBLS()
+	log.SetFlags(log.Lmicroseconds)
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("could not load config: %s", err.Error())
+	}
+	password := os.Getenv(passwordEnv)
+	if password == "" {
+		log.Fatalf("%s is not set", passwordEnv)
+	}
+	params := crypto.DefaultKeystoreParams()
+	if config.KeystoreScryptN != 0 {
+		params.ScryptN = config.KeystoreScryptN
+	}
+
+	if *initKeys {
+		keys, err := generateKeys(config.DataDir, password, params)
+		if err != nil {
+			log.Fatalf("could not generate keys: %s", err.Error())
+		}
+		data, err := json.MarshalIndent(keys.peerConfig(config.Id, config.Listen), "", "  ")
+		if err != nil {
+			log.Fatalf("could not encode peer entry: %s", err.Error())
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if *importSlashing != "" || *exportSlashing != "" {
+		err = interchangeSlashingProtection(config, *importSlashing, *exportSlashing)
+		if err != nil {
+			log.Fatalf("P %d, %s", config.Id, err.Error())
+		}
+		return
+	}
+
+	err = run(config, password, params)
+	if err != nil {
+		log.Fatalf("P %d, %s", config.Id, err.Error())
+	}
+}
+
+// the node must not be running, it keeps its own copy of the history
+func interchangeSlashingProtection(config *Config, importPath string, exportPath string) error {
+	networkConfig, err := config.NetworkConfig()
+	if err != nil {
+		return err
+	}
+	protection, err := eth2.LoadSlashingProtection(filepath.Join(config.DataDir, slashingProtectionFile), networkConfig.GenesisValidatorsRoot)
+	if err != nil {
+		return fmt.Errorf("could not load slashing protection: %s", err.Error())
+	}
+	if importPath != "" {
+		data, err := ioutil.ReadFile(importPath)
+		if err != nil {
+			return err
+		}
+		err = protection.ImportInterchange(data)
+		if err != nil {
+			return err
+		}
+	}
+	if exportPath != "" {
+		data, err := protection.ExportInterchange()
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(exportPath, data, 0600)
+	}
+	return nil
+}
+
+func run(config *Config, password string, params crypto.KeystoreParams) error {
+	networkConfig, err := config.NetworkConfig()
+	if err != nil {
+		return err
+	}
+	err = config.Validate(networkConfig)
+	if err != nil {
+		return fmt.Errorf("invalid config: %s", err.Error())
+	}
+
+	keys, err := loadKeys(config.DataDir, password)
+	if err != nil {
+		return fmt.Errorf("could not load keys (run with -init first?): %s", err.Error())
+	}
+
+	// state
+	s, err := state.NewPersistentState(filepath.Join(config.DataDir, "state.db"), networkConfig)
+	if err != nil {
+		return fmt.Errorf("could not open state: %s", err.Error())
+	}
+	defer s.Close()
+	shareKeystore, err := state.NewShareKeystore(filepath.Join(config.DataDir, "shares"), password, params)
+	if err != nil {
+		return err
+	}
+	s.SetShareKeystore(shareKeystore)
+
+	// network
+	transport, err := grpc_net.NewGrpcP2P(config.Listen)
+	if err != nil {
+		return fmt.Errorf("could not listen: %s", err.Error())
+	}
+	defer transport.Close()
+	var network net.P2P = transport
+	if config.Gossip {
+		network = gossip.NewGossipP2P(transport, gossip.DefaultConfig())
+	}
+
+	p := participant.NewParticipantWithKeys(config.Id, keys.encryptionSk, keys.identitySk)
+	p.SlashingProtection, err = eth2.LoadSlashingProtection(filepath.Join(config.DataDir, slashingProtectionFile), networkConfig.GenesisValidatorsRoot)
+	if err != nil {
+		return fmt.Errorf("could not load slashing protection: %s", err.Error())
+	}
+	node := pool_chain.NewChainNode(networkConfig, s, network)
+	p.SetNode(node)
+
+	// register the genesis participants' keys and connect to the peers, the others are registered by their join
+	// requests
+	isGenesis := IsGenesisParticipant(networkConfig, p.Id)
+	if isGenesis {
+		err = s.SaveParticipant(state.NewParticipant(p.Id, p.EncryptionPk, p.IdentityPk))
+		if err != nil {
+			return err
+		}
+	}
+	if !config.Gossip {
+		network.AddPeer(network.OwnPeer()) // add to self to receive own messages, gossip delivers them locally
+	}
+	for _, peer := range config.Peers {
+		if IsGenesisParticipant(networkConfig, peer.Id) {
+			registered, err := peer.Participant()
+			if err != nil {
+				return err
+			}
+			err = s.SaveParticipant(registered)
+			if err != nil {
+				return err
+			}
+		}
+		err = network.AddPeer(net.NewRemotePeer(peer.Address))
+		if err != nil {
+			return fmt.Errorf("could not add peer %d: %s", peer.Id, err.Error())
+		}
+	}
+
+	if config.Control != "" {
+		server, err := control.NewServer(config.Control, p.Node, p)
+		if err != nil {
+			return fmt.Errorf("could not start control api: %s", err.Error())
+		}
+		defer server.Close()
+		log.Printf("P %d, control api on %s", p.Id, server.Address())
+	}
+
+	// genesis DKG, only if the pools are not in the state db yet
+	genesis := config.GenesisTimestamp()
+	epochsGenesis := genesis.Add(networkConfig.DKGPhaseSpan * genesisDKGPhases)
+	if !p.Node.GenesisReady() {
+		if time.Now().After(genesis) {
+			return fmt.Errorf("genesis time passed before the genesis DKG was done, can't join")
+		}
+		log.Printf("P %d, waiting for genesis at %s", p.Id, genesis.String())
+		<- time.After(time.Until(genesis))
+
+		if isGenesis {
+			err = p.RunGenesisDKG()
+			if err != nil {
+				return fmt.Errorf("genesis DKG failed: %s", err.Error())
+			}
+		}
+		for !p.Node.GenesisReady() {
+			if time.Now().After(epochsGenesis) {
+				return fmt.Errorf("pools' public keys were not agreed on before epoch 0")
+			}
+			<- time.After(time.Millisecond * 100)
+		}
+	}
+
+	p.StartEpochProcessingAt(epochsGenesis)
+	defer p.Node.StopEpochProcessing()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <- p.KillC():
+		log.Printf("P %d, killed", p.Id)
+	case sig := <- signals:
+		log.Printf("P %d, %s received", p.Id, sig.String())
+	}
+	return nil
+}
@@ -22,6 +22,11 @@ func Sign(secret *bls.Fr, msg []byte) *bls.G2 {
 	return bls.CastFromSign(sig)
 }
 
+// verifies a partial (threshold) signature against the signer's public share, g^share
+func VerifyPartialSig(publicShare *bls.G1, sig *bls.G2, msg []byte) bool {
+	return bls.CastToSign(sig).VerifyByte(bls.CastToPublicKey(publicShare), msg)
+}
+
 
 func agg_g1(a *bls.G1, b *bls.G1) *bls.G1 {
 	out := &bls.G1{}
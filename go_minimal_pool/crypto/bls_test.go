@@ -26,3 +26,25 @@ func TestAggregate(t *testing.T) {
 
 	require.True(t, agg_sig.Verify(agg_pk, "hello"))
 }
+
+func TestVerifyPartialSig(t *testing.T) {
+	InitBLS()
+
+	p,err := NewPolynomial(*frPointerRandom(), 3)
+	require.NoError(t, err)
+	require.NoError(t, p.GenerateRandom())
+
+	idx1, idx2 := frFromInt(1), frFromInt(2)
+	share1,err := p.Evaluate(&idx1)
+	require.NoError(t, err)
+	share2,err := p.Evaluate(&idx2)
+	require.NoError(t, err)
+
+	msg := []byte("hello")
+	sig1 := Sign(share1, msg)
+	require.True(t, VerifyPartialSig(g1FromFr(*share1), sig1, msg))
+	// wrong signer
+	require.False(t, VerifyPartialSig(g1FromFr(*share2), sig1, msg))
+	// wrong message
+	require.False(t, VerifyPartialSig(g1FromFr(*share1), sig1, []byte("bye")))
+}
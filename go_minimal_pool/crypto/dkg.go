@@ -1,6 +1,9 @@
 package crypto
 
-import "github.com/herumi/bls-eth-go-binary/bls"
+import (
+	"fmt"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
 
 /**
 	This builds a polynomial for a particular secret and generates shares for distribution
@@ -45,6 +48,86 @@ func (dkg *DKG) GroupSecrets(indexes []uint32) (map[uint32]*bls.Fr, error) {
 	return dkg.sumShares(ret), nil
 }
 
+// returns the shares a single dealer generated for the given indexes
+func (dkg *DKG) DealerShares(dealer uint32, indexes []uint32) (map[uint32]*bls.Fr, error) {
+	poly, err := dkg.dealerPolynomial(dealer)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[uint32]*bls.Fr)
+	for _, share_idx := range indexes {
+		share_idx_fr := &bls.Fr{}
+		share_idx_fr.SetInt64(int64(share_idx))
+		p,err := poly.Evaluate(share_idx_fr)
+		if err != nil {
+			return nil, err
+		}
+
+		ret[share_idx] = p
+	}
+	return ret, nil
+}
+
+// returns the dealer's G1 commitments to his polynomial coefficients, to be published with the shares
+func (dkg *DKG) Commitments(dealer uint32) ([]bls.G1, error) {
+	poly, err := dkg.dealerPolynomial(dealer)
+	if err != nil {
+		return nil, err
+	}
+	return poly.Commitments(), nil
+}
+
+// verifies a share received from dealer for index against the dealer's commitments.
+// a share that fails verification means the dealer cheated and should be disqualified.
+func (dkg *DKG) VerifyShare(dealer uint32, index uint32, share *bls.Fr) (bool, error) {
+	commitments, err := dkg.Commitments(dealer)
+	if err != nil {
+		return false, err
+	}
+	return VerifyShareWithCommitments(commitments, index, share)
+}
+
+// returns g^sk_i for every index, computed only from the dealers' commitments (no secrets needed).
+func (dkg *DKG) GroupPublicShares(indexes []uint32) (map[uint32]*bls.G1, error) {
+	ret := make(map[uint32]*bls.G1)
+	for _, idx := range indexes {
+		sum := &bls.G1{}
+		sum.Clear()
+		for dealer := range dkg.polynomials {
+			commitments, err := dkg.Commitments(dealer)
+			if err != nil {
+				return nil, err
+			}
+			p, err := EvaluateCommitments(commitments, idx)
+			if err != nil {
+				return nil, err
+			}
+			sum = agg_g1(sum, p)
+		}
+		ret[idx] = sum
+	}
+	return ret, nil
+}
+
+// the group's public key is the product of every qualified dealer's commitment to his secret
+func (dkg *DKG) GroupPKFromCommitments(dealers []uint32) (*bls.PublicKey, error) {
+	if len(dealers) == 0 {
+		return nil, fmt.Errorf("no dealers")
+	}
+
+	res := &bls.G1{}
+	res.Clear()
+	for _, dealer := range dealers {
+		commitments, err := dkg.Commitments(dealer)
+		if err != nil {
+			return nil, err
+		}
+		res = agg_g1(res, &commitments[0])
+	}
+	return bls.CastToPublicKey(res), nil
+}
+
 func (dkg *DKG) GroupPK(sks map[uint32]*bls.Fr) (*bls.PublicKey,error) {
 	points := make([][]interface{},len(sks))
 	i := 0
@@ -83,3 +166,11 @@ func (dkg *DKG) sumShares(shares map[uint32][]*bls.Fr) map[uint32]*bls.Fr {
 
 	return ret
 }
+
+func (dkg *DKG) dealerPolynomial(dealer uint32) (*Polynomial, error) {
+	poly, found := dkg.polynomials[dealer]
+	if !found {
+		return nil, fmt.Errorf("dealer %d not found", dealer)
+	}
+	return poly, nil
+}
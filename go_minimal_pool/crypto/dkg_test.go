@@ -66,3 +66,80 @@ func TestDKG(t *testing.T) {
 
 	require.Equal(t, expectedGroupSk.GetPublicKey().GetHexString(), pk.GetHexString())
 }
+
+func TestDKGVerifyShares(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3}
+	dkg,err := NewDKG(3, indexes)
+	require.NoError(t, err)
+
+	// every honest share should verify
+	for _, dealer := range indexes {
+		shares,err := dkg.DealerShares(dealer, indexes)
+		require.NoError(t, err)
+		require.Len(t, shares, 3)
+
+		for idx, share := range shares {
+			valid,err := dkg.VerifyShare(dealer, idx, share)
+			require.NoError(t, err)
+			require.True(t, valid)
+		}
+	}
+
+	// a tampered share should not verify
+	shares,err := dkg.DealerShares(2, indexes)
+	require.NoError(t, err)
+	bad := &bls.Fr{}
+	bls.FrAdd(bad, shares[1], frPointerFromInt(1))
+	valid,err := dkg.VerifyShare(2, 1, bad)
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// a valid share for one index should not verify for another
+	valid,err = dkg.VerifyShare(2, 3, shares[1])
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// unknown dealer
+	_,err = dkg.VerifyShare(4, 1, shares[1])
+	require.Error(t, err)
+
+	// group pk from commitments should match the interpolated one
+	sks,err := dkg.GroupSecrets(indexes)
+	require.NoError(t, err)
+	pk,err := dkg.GroupPK(sks)
+	require.NoError(t, err)
+	pkFromCommitments,err := dkg.GroupPKFromCommitments(indexes)
+	require.NoError(t, err)
+	require.Equal(t, pk.GetHexString(), pkFromCommitments.GetHexString())
+}
+
+func TestDKGCommitments(t *testing.T) {
+	InitBLS()
+
+	dkg := DKG{polynomials:map[uint32]*Polynomial{
+		1: {
+			Degree: 2,
+			Coefficients: []bls.Fr{
+				frFromInt(6), // free coefficient
+				frFromInt(0), // x^1
+				frFromInt(1), // x^2
+			},
+		},
+	}, degree:2}
+
+	commitments,err := dkg.Commitments(1)
+	require.NoError(t, err)
+	require.Len(t, commitments, 3)
+	require.Equal(t, g1FromFr(frFromInt(6)).GetString(10), commitments[0].GetString(10))
+	require.Equal(t, g1FromFr(frFromInt(1)).GetString(10), commitments[2].GetString(10))
+
+	// f(2) = 10
+	valid,err := dkg.VerifyShare(1, 2, frPointerFromInt(10))
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid,err = dkg.VerifyShare(1, 2, frPointerFromInt(11))
+	require.NoError(t, err)
+	require.False(t, valid)
+}
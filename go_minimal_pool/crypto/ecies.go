@@ -0,0 +1,205 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+/**
+	ECIES over BLS12-381 G1, used to encrypt shares point to point.
+	An ephemeral key r is generated per message, R = g^r is sent with the ciphertext and the AES-256-GCM key is
+	derived from the DH secret pk^r (= R^sk for the recipient).
+	ciphertext = R (48 bytes) | nonce (12 bytes) | AES-GCM sealed message
+	The recipient can prove what a ciphertext decrypts to (e.g. a dealer's bad share) without revealing its key: it
+	reveals the DH secret S = R^sk with a Chaum-Pedersen proof that log_g(pk) = log_R(S).
+	decryption proof = S (48 bytes) | challenge (32 bytes) | response (32 bytes)
+ */
+
+const (
+	g1Size = 48
+	// AES-GCM's
+	nonceSize = 12
+	tagSize = 16
+)
+
+// generates a long term encryption key pair
+func NewEncryptionKey() (*bls.Fr, *bls.G1) {
+	sk := &bls.Fr{}
+	sk.SetByCSPRNG()
+	return sk, frToG1(sk)
+}
+
+// encrypts msg to pk, aad is authenticated but not encrypted (e.g. the message's header)
+func Encrypt(pk *bls.G1, msg []byte, aad []byte) ([]byte, error) {
+	r := &bls.Fr{}
+	r.SetByCSPRNG()
+	R := frToG1(r)
+
+	shared := &bls.G1{}
+	bls.G1Mul(shared, pk, r)
+
+	aead, err := eciesAEAD(shared, R)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := append(R.Serialize(), nonce...)
+	return aead.Seal(ret, nonce, msg, aad), nil
+}
+
+func Decrypt(sk *bls.Fr, ciphertext []byte, aad []byte) ([]byte, error) {
+	R, err := ephemeralKey(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &bls.G1{}
+	bls.G1Mul(shared, R, sk)
+
+	aead, err := eciesAEAD(shared, R)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < g1Size + aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce := ciphertext[g1Size : g1Size + aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[g1Size + aead.NonceSize():], aad)
+}
+
+// key = sha256(shared | R)
+func eciesAEAD(shared *bls.G1, R *bls.G1) (cipher.AEAD, error) {
+	key := sha256.Sum256(append(shared.Serialize(), R.Serialize()...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// the recipient's proof of the ciphertext's DH secret, see DecryptWithProof
+func ProveDecryption(sk *bls.Fr, ciphertext []byte) ([]byte, error) {
+	R, err := ephemeralKey(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	S := &bls.G1{}
+	bls.G1Mul(S, R, sk)
+
+	k := &bls.Fr{}
+	k.SetByCSPRNG()
+	A1 := frToG1(k)
+	A2 := &bls.G1{}
+	bls.G1Mul(A2, R, k)
+	c := decryptionChallenge(frToG1(sk), R, S, A1, A2)
+
+	// z = k + c * sk
+	z := &bls.Fr{}
+	bls.FrMul(z, c, sk)
+	bls.FrAdd(z, z, k)
+
+	ret := S.Serialize()
+	ret = append(ret, c.Serialize()...)
+	return append(ret, z.Serialize()...), nil
+}
+
+// verifies the recipient's proof (ProveDecryption) of a ciphertext sent to pk, the ciphertext could still not
+// decrypt
+func VerifyDecryptionProof(pk *bls.G1, ciphertext []byte, proof []byte) error {
+	_, _, err := verifyDecryptionProof(pk, ciphertext, proof)
+	return err
+}
+
+// decrypts a ciphertext sent to pk with the recipient's proof (ProveDecryption), fails if the proof is invalid
+// or the ciphertext doesn't decrypt
+func DecryptWithProof(pk *bls.G1, ciphertext []byte, aad []byte, proof []byte) ([]byte, error) {
+	R, S, err := verifyDecryptionProof(pk, ciphertext, proof)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := eciesAEAD(S, R)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < g1Size + aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce := ciphertext[g1Size : g1Size + aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[g1Size + aead.NonceSize():], aad)
+}
+
+// returns the ciphertext's ephemeral key R and DH secret S
+func verifyDecryptionProof(pk *bls.G1, ciphertext []byte, proof []byte) (*bls.G1, *bls.G1, error) {
+	R, err := ephemeralKey(ciphertext)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(proof) != g1Size + 32 + 32 {
+		return nil, nil, fmt.Errorf("invalid decryption proof length")
+	}
+	S := &bls.G1{}
+	c := &bls.Fr{}
+	z := &bls.Fr{}
+	if S.Deserialize(proof[:g1Size]) != nil || c.Deserialize(proof[g1Size:g1Size + 32]) != nil || z.Deserialize(proof[g1Size + 32:]) != nil {
+		return nil, nil, fmt.Errorf("invalid decryption proof")
+	}
+
+	// A1 = g^z / pk^c, A2 = R^z / S^c
+	A1 := &bls.G1{}
+	tmp := &bls.G1{}
+	bls.G1Mul(tmp, pk, c)
+	bls.G1Sub(A1, frToG1(z), tmp)
+	A2 := &bls.G1{}
+	bls.G1Mul(A2, R, z)
+	bls.G1Mul(tmp, S, c)
+	bls.G1Sub(A2, A2, tmp)
+	if !decryptionChallenge(pk, R, S, A1, A2).IsEqual(c) {
+		return nil, nil, fmt.Errorf("invalid decryption proof")
+	}
+	return R, S, nil
+}
+
+// checks the ciphertext has a valid ephemeral key and room for a nonce and tag, a malformed one doesn't decrypt
+// for any recipient
+func CheckCiphertext(ciphertext []byte) error {
+	_, err := ephemeralKey(ciphertext)
+	if err != nil {
+		return err
+	}
+	if len(ciphertext) < g1Size + nonceSize + tagSize {
+		return fmt.Errorf("ciphertext too short")
+	}
+	return nil
+}
+
+func ephemeralKey(ciphertext []byte) (*bls.G1, error) {
+	if len(ciphertext) < g1Size {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	R := &bls.G1{}
+	err := R.Deserialize(ciphertext[:g1Size])
+	if err != nil {
+		return nil, err
+	}
+	return R, nil
+}
+
+// c = H(pk | R | S | A1 | A2)
+func decryptionChallenge(pk *bls.G1, R *bls.G1, S *bls.G1, A1 *bls.G1, A2 *bls.G1) *bls.Fr {
+	data := make([]byte, 0)
+	for _, point := range []*bls.G1{pk, R, S, A1, A2} {
+		data = append(data, point.Serialize()...)
+	}
+	ret := &bls.Fr{}
+	ret.SetHashOf(data)
+	return ret
+}
@@ -0,0 +1,89 @@
+package crypto
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	InitBLS()
+
+	sk, pk := NewEncryptionKey()
+	otherSk, _ := NewEncryptionKey()
+	msg := frPointerRandom().Serialize()
+	aad := []byte("header")
+
+	ciphertext, err := Encrypt(pk, msg, aad)
+	require.NoError(t, err)
+	require.NotContains(t, string(ciphertext), string(msg))
+
+	t.Run("recipient decrypts", func(t *testing.T) {
+		res, err := Decrypt(sk, ciphertext, aad)
+		require.NoError(t, err)
+		require.Equal(t, msg, res)
+	})
+
+	t.Run("other key can't decrypt", func(t *testing.T) {
+		_, err := Decrypt(otherSk, ciphertext, aad)
+		require.Error(t, err)
+	})
+
+	t.Run("wrong aad", func(t *testing.T) {
+		_, err := Decrypt(sk, ciphertext, []byte("other header"))
+		require.Error(t, err)
+	})
+
+	t.Run("tampered ciphertext", func(t *testing.T) {
+		tampered := append([]byte{}, ciphertext...)
+		tampered[len(tampered) - 1] ^= 1
+		_, err := Decrypt(sk, tampered, aad)
+		require.Error(t, err)
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		_, err := Decrypt(sk, ciphertext[:10], aad)
+		require.Error(t, err)
+	})
+}
+
+func TestDecryptionProof(t *testing.T) {
+	InitBLS()
+
+	sk, pk := NewEncryptionKey()
+	otherSk, otherPk := NewEncryptionKey()
+	msg := frPointerRandom().Serialize()
+	aad := []byte("header")
+	ciphertext, err := Encrypt(pk, msg, aad)
+	require.NoError(t, err)
+
+	// anyone can decrypt with the recipient's proof
+	proof, err := ProveDecryption(sk, ciphertext)
+	require.NoError(t, err)
+	res, err := DecryptWithProof(pk, ciphertext, aad, proof)
+	require.NoError(t, err)
+	require.Equal(t, msg, res)
+
+	// the proof is of the recipient's key and of the ciphertext's ephemeral key
+	require.Error(t, VerifyDecryptionProof(otherPk, ciphertext, proof))
+	_, err = DecryptWithProof(otherPk, ciphertext, aad, proof)
+	require.Error(t, err)
+	forged, err := ProveDecryption(otherSk, ciphertext)
+	require.NoError(t, err)
+	_, err = DecryptWithProof(pk, ciphertext, aad, forged)
+	require.Error(t, err)
+	other, err := Encrypt(pk, msg, aad)
+	require.NoError(t, err)
+	_, err = DecryptWithProof(pk, other, aad, proof)
+	require.Error(t, err)
+
+	// a valid proof of a ciphertext that doesn't decrypt
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered) - 1] ^= 1
+	proof, err = ProveDecryption(sk, tampered)
+	require.NoError(t, err)
+	require.NoError(t, VerifyDecryptionProof(pk, tampered, proof))
+	_, err = DecryptWithProof(pk, tampered, aad, proof)
+	require.Error(t, err)
+	_, err = DecryptWithProof(pk, ciphertext, aad, proof[:10])
+	require.Error(t, err)
+}
@@ -0,0 +1,702 @@
+package crypto
+
+import (
+	"fmt"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"sort"
+)
+
+/**
+	Gennaro, Jarecki, Krawczyk and Rabin's secure DKG (GJKR), run as a message driven state machine per participant.
+	https://github.com/bloxapp/eth2-staking-pools-research/blob/master/dkg.md#gennaro-jarecki-krawczyk-and-rabins-dkg
+
+	Phases:
+	1) Deal - every dealer broadcasts Pedersen commitments (g^a_k * h^b_k) to 2 random polynomials and sends each
+	   participant its (secret, blinding) shares.
+	2) Complain - every participant complains against dealers that sent it a share not matching the commitments (or none).
+	3) Justify - a dealer answers every complaint against him by publicly revealing the disputed shares.
+	4) Qualify - dealers with an unanswered/ invalid justification, or more than threshold - 1 complaints, are disqualified.
+	   The rest form QUAL, every participant's secret share is the sum of the shares it got from QUAL.
+	5) Extract - every dealer in QUAL broadcasts Feldman commitments (g^a_k), participants complain (revealing their
+	   shares) against dealers whose Feldman commitments do not match their shares.
+	6) Reconstruct - for dealers proven to cheat in extraction, QUAL members reveal their shares so the dealer's
+	   secret is reconstructed publicly and its g^a_0 computed.
+	The group's public key is the product of g^a_0 of every dealer in QUAL.
+
+	Threshold, like everywhere else in this package, is the number of coefficients of the polynomials, i.e. the
+	number of shares needed to reconstruct the secret.
+ */
+
+type GJKRPhase uint8
+
+const (
+	GJKRInit GJKRPhase = iota
+	GJKRDealing
+	GJKRComplaining
+	GJKRJustifying
+	GJKRExtracting
+	GJKRExtractionComplaining
+	GJKRReconstructing
+	GJKRDone
+)
+
+// broadcasted by every dealer
+type GJKRCommitment struct {
+	From uint32
+	Commitments []bls.G1 // Pedersen commitments
+}
+
+// sent privately from dealer to a participant
+type GJKRDeal struct {
+	From uint32
+	To uint32
+	Share *bls.Fr
+	BlindingShare *bls.Fr
+}
+
+// broadcasted by a participant who got an invalid (or no) share from a dealer
+type GJKRComplaint struct {
+	From uint32
+	Against uint32
+}
+
+// broadcasted by a dealer, reveals the disputed share
+type GJKRJustification struct {
+	From uint32
+	To uint32
+	Share *bls.Fr
+	BlindingShare *bls.Fr
+}
+
+// broadcasted by every dealer in QUAL
+type GJKRPublicCoefficients struct {
+	From uint32
+	Coefficients []bls.G1 // Feldman commitments
+}
+
+// broadcasted by a participant whose share does not match the dealer's public coefficients
+type GJKRExtractionComplaint struct {
+	From uint32
+	Against uint32
+	Share *bls.Fr
+	BlindingShare *bls.Fr
+}
+
+// broadcasted by QUAL members to publicly reconstruct a cheating dealer's secret
+type GJKRReconstructionShare struct {
+	From uint32
+	Dealer uint32
+	Share *bls.Fr
+	BlindingShare *bls.Fr
+}
+
+type gjkrShare struct {
+	share *bls.Fr
+	blinding *bls.Fr
+}
+
+type GJKR struct {
+	Id uint32
+	threshold uint32
+	indexes []uint32
+	phase GJKRPhase
+	h *bls.G1
+
+	secret *Polynomial
+	blinding *Polynomial
+
+	commitments map[uint32][]bls.G1
+	received map[uint32]*gjkrShare
+	complaints map[uint32]map[uint32]bool // against -> from
+	justifications map[uint32]map[uint32]*gjkrShare // dealer -> to
+	qual []uint32
+	publicCoefficients map[uint32][]bls.G1
+	extractionCheaters map[uint32]bool
+	reconstructionShares map[uint32]map[uint32]*bls.Fr // dealer -> from
+
+	secretShare *bls.Fr
+	groupPk *bls.G1
+	publicShares map[uint32]*bls.G1
+}
+
+func NewGJKR(id uint32, threshold uint32, indexes []uint32) (*GJKR,error) {
+	if threshold == 0 || int(threshold) > len(indexes) {
+		return nil, fmt.Errorf("invalid threshold %d for %d participants", threshold, len(indexes))
+	}
+	if !containsIndex(indexes, id) {
+		return nil, fmt.Errorf("%d is not a participant", id)
+	}
+
+	secret := &bls.Fr{}
+	secret.SetByCSPRNG()
+	secretPoly, err := NewPolynomial(*secret, threshold)
+	if err != nil {
+		return nil, err
+	}
+	blindingSecret := &bls.Fr{}
+	blindingSecret.SetByCSPRNG()
+	blindingPoly, err := NewPolynomial(*blindingSecret, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GJKR{
+		Id:                   id,
+		threshold:            threshold,
+		indexes:              indexes,
+		phase:                GJKRInit,
+		h:                    PedersenGenerator(),
+		secret:               secretPoly,
+		blinding:             blindingPoly,
+		commitments:          make(map[uint32][]bls.G1),
+		received:             make(map[uint32]*gjkrShare),
+		complaints:           make(map[uint32]map[uint32]bool),
+		justifications:       make(map[uint32]map[uint32]*gjkrShare),
+		publicCoefficients:   make(map[uint32][]bls.G1),
+		extractionCheaters:   make(map[uint32]bool),
+		reconstructionShares: make(map[uint32]map[uint32]*bls.Fr),
+		publicShares:         make(map[uint32]*bls.G1),
+	}, nil
+}
+
+// H, the second G1 generator used for Pedersen commitments. Nobody knows log_g(H) as it's hashed to the curve.
+func PedersenGenerator() *bls.G1 {
+	h := &bls.G1{}
+	h.HashAndMapTo([]byte("eth2 staking pools GJKR pedersen generator H"))
+	return h
+}
+
+func (g *GJKR) Phase() GJKRPhase {
+	return g.phase
+}
+
+/**
+	Phase 1 - deal
+ */
+
+// returns the Pedersen commitments to broadcast and every participant's private deal (including to self)
+func (g *GJKR) Deal() (*GJKRCommitment, map[uint32]*GJKRDeal, error) {
+	if err := g.expectPhase(GJKRInit); err != nil {
+		return nil, nil, err
+	}
+
+	commitments := make([]bls.G1, g.threshold)
+	for i := range commitments {
+		commitments[i] = *g.pedersenCommit(&g.secret.Coefficients[i], &g.blinding.Coefficients[i])
+	}
+
+	deals := make(map[uint32]*GJKRDeal)
+	for _, idx := range g.indexes {
+		x := &bls.Fr{}
+		x.SetInt64(int64(idx))
+		share, err := g.secret.Evaluate(x)
+		if err != nil {
+			return nil, nil, err
+		}
+		blinding, err := g.blinding.Evaluate(x)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		deals[idx] = &GJKRDeal{
+			From:          g.Id,
+			To:            idx,
+			Share:         share,
+			BlindingShare: blinding,
+		}
+	}
+
+	g.phase = GJKRDealing
+	return &GJKRCommitment{From: g.Id, Commitments: commitments}, deals, nil
+}
+
+func (g *GJKR) ProcessCommitment(msg *GJKRCommitment) error {
+	if err := g.expectPhase(GJKRDealing); err != nil {
+		return err
+	}
+	if !containsIndex(g.indexes, msg.From) {
+		return fmt.Errorf("commitment from unknown participant %d", msg.From)
+	}
+	if len(msg.Commitments) != int(g.threshold) {
+		return fmt.Errorf("expected %d commitments from %d, got %d", g.threshold, msg.From, len(msg.Commitments))
+	}
+	if _, found := g.commitments[msg.From]; found {
+		return fmt.Errorf("duplicate commitment from %d", msg.From)
+	}
+
+	g.commitments[msg.From] = msg.Commitments
+	return nil
+}
+
+func (g *GJKR) ProcessDeal(msg *GJKRDeal) error {
+	if err := g.expectPhase(GJKRDealing); err != nil {
+		return err
+	}
+	if msg.To != g.Id {
+		return fmt.Errorf("deal from %d is addressed to %d", msg.From, msg.To)
+	}
+	if !containsIndex(g.indexes, msg.From) {
+		return fmt.Errorf("deal from unknown participant %d", msg.From)
+	}
+	if _, found := g.received[msg.From]; found {
+		return fmt.Errorf("duplicate deal from %d", msg.From)
+	}
+
+	g.received[msg.From] = &gjkrShare{share: msg.Share, blinding: msg.BlindingShare}
+	return nil
+}
+
+/**
+	Phase 2 - complain
+ */
+
+// complains against every dealer that committed but sent no, or an invalid, share. The complaints are registered
+// right away, the participant doesn't depend on getting its own broadcast back.
+func (g *GJKR) Complain() ([]*GJKRComplaint, error) {
+	if err := g.expectPhase(GJKRDealing); err != nil {
+		return nil, err
+	}
+
+	ret := make([]*GJKRComplaint, 0)
+	for _, dealer := range g.sortedDealers() {
+		share, found := g.received[dealer]
+		if found && g.verifyPedersen(dealer, g.Id, share.share, share.blinding) {
+			continue
+		}
+		ret = append(ret, &GJKRComplaint{From: g.Id, Against: dealer})
+	}
+
+	g.phase = GJKRComplaining
+	for _, c := range ret {
+		err := g.ProcessComplaint(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (g *GJKR) ProcessComplaint(msg *GJKRComplaint) error {
+	if err := g.expectPhase(GJKRComplaining); err != nil {
+		return err
+	}
+	if !containsIndex(g.indexes, msg.From) || !containsIndex(g.indexes, msg.Against) {
+		return fmt.Errorf("complaint with unknown participants %d -> %d", msg.From, msg.Against)
+	}
+
+	if g.complaints[msg.Against] == nil {
+		g.complaints[msg.Against] = make(map[uint32]bool)
+	}
+	g.complaints[msg.Against][msg.From] = true
+	return nil
+}
+
+/**
+	Phase 3 - justify
+ */
+
+// reveals the shares of every participant that complained against this dealer
+func (g *GJKR) Justify() ([]*GJKRJustification, error) {
+	if err := g.expectPhase(GJKRComplaining); err != nil {
+		return nil, err
+	}
+
+	ret := make([]*GJKRJustification, 0)
+	for _, complainer := range sortedKeys(g.complaints[g.Id]) {
+		x := &bls.Fr{}
+		x.SetInt64(int64(complainer))
+		share, err := g.secret.Evaluate(x)
+		if err != nil {
+			return nil, err
+		}
+		blinding, err := g.blinding.Evaluate(x)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, &GJKRJustification{
+			From:          g.Id,
+			To:            complainer,
+			Share:         share,
+			BlindingShare: blinding,
+		})
+	}
+
+	g.phase = GJKRJustifying
+	return ret, nil
+}
+
+func (g *GJKR) ProcessJustification(msg *GJKRJustification) error {
+	if err := g.expectPhase(GJKRJustifying); err != nil {
+		return err
+	}
+	if !g.complaints[msg.From][msg.To] {
+		return fmt.Errorf("justification from %d to %d without a complaint", msg.From, msg.To)
+	}
+
+	if g.justifications[msg.From] == nil {
+		g.justifications[msg.From] = make(map[uint32]*gjkrShare)
+	}
+	g.justifications[msg.From][msg.To] = &gjkrShare{share: msg.Share, blinding: msg.BlindingShare}
+	return nil
+}
+
+/**
+	Phase 4 - qualify
+ */
+
+// forms QUAL and calculates this participant's secret share
+func (g *GJKR) Qualify() ([]uint32, error) {
+	if err := g.expectPhase(GJKRJustifying); err != nil {
+		return nil, err
+	}
+
+	qual := make([]uint32, 0)
+	for _, dealer := range g.sortedDealers() {
+		if g.disqualified(dealer) {
+			continue
+		}
+		qual = append(qual, dealer)
+
+		// a valid public justification replaces whatever was received privately
+		if j, found := g.justifications[dealer][g.Id]; found {
+			g.received[dealer] = j
+		}
+	}
+
+	if len(qual) < int(g.threshold) {
+		return nil, fmt.Errorf("only %d qualified dealers, threshold is %d", len(qual), g.threshold)
+	}
+
+	sum := &bls.Fr{}
+	sum.SetInt64(0)
+	for _, dealer := range qual {
+		// can't happen with our own complaints registered, a dealer we didn't get a valid share from either
+		// justified or was disqualified
+		share, found := g.received[dealer]
+		if !found || share.share == nil {
+			return nil, fmt.Errorf("no share from qualified dealer %d", dealer)
+		}
+		bls.FrAdd(sum, sum, share.share)
+	}
+
+	g.qual = qual
+	g.secretShare = sum
+	g.phase = GJKRExtracting
+	return qual, nil
+}
+
+func (g *GJKR) disqualified(dealer uint32) bool {
+	complainers := g.complaints[dealer]
+	if len(complainers) >= int(g.threshold) {
+		return true
+	}
+	for complainer := range complainers {
+		j, found := g.justifications[dealer][complainer]
+		if !found || !g.verifyPedersen(dealer, complainer, j.share, j.blinding) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+	Phase 5 - extract the public key
+ */
+
+// returns this dealer's Feldman commitments, nil if not in QUAL
+func (g *GJKR) PublicCoefficients() (*GJKRPublicCoefficients, error) {
+	if err := g.expectPhase(GJKRExtracting); err != nil {
+		return nil, err
+	}
+	if !containsIndex(g.qual, g.Id) {
+		return nil, nil
+	}
+	return &GJKRPublicCoefficients{From: g.Id, Coefficients: g.secret.Commitments()}, nil
+}
+
+func (g *GJKR) ProcessPublicCoefficients(msg *GJKRPublicCoefficients) error {
+	if err := g.expectPhase(GJKRExtracting); err != nil {
+		return err
+	}
+	if !containsIndex(g.qual, msg.From) {
+		return fmt.Errorf("public coefficients from %d which is not in QUAL", msg.From)
+	}
+	if len(msg.Coefficients) != int(g.threshold) {
+		return fmt.Errorf("expected %d public coefficients from %d, got %d", g.threshold, msg.From, len(msg.Coefficients))
+	}
+
+	g.publicCoefficients[msg.From] = msg.Coefficients
+	return nil
+}
+
+// complains, revealing the share, against every QUAL dealer whose public coefficients do not match the share received
+func (g *GJKR) ExtractionComplain() ([]*GJKRExtractionComplaint, error) {
+	if err := g.expectPhase(GJKRExtracting); err != nil {
+		return nil, err
+	}
+
+	ret := make([]*GJKRExtractionComplaint, 0)
+	for _, dealer := range g.qual {
+		coefficients, found := g.publicCoefficients[dealer]
+		if !found {
+			// everyone sees the missing coefficients, no need to prove anything
+			g.extractionCheaters[dealer] = true
+			continue
+		}
+
+		share := g.received[dealer]
+		valid, err := VerifyShareWithCommitments(coefficients, g.Id, share.share)
+		if err != nil || !valid {
+			ret = append(ret, &GJKRExtractionComplaint{
+				From:          g.Id,
+				Against:       dealer,
+				Share:         share.share,
+				BlindingShare: share.blinding,
+			})
+		}
+	}
+
+	g.phase = GJKRExtractionComplaining
+	return ret, nil
+}
+
+// a complaint is valid if the revealed share matches the dealer's Pedersen commitments but not his public coefficients
+func (g *GJKR) ProcessExtractionComplaint(msg *GJKRExtractionComplaint) error {
+	if err := g.expectPhase(GJKRExtractionComplaining); err != nil {
+		return err
+	}
+	if !containsIndex(g.qual, msg.Against) {
+		return fmt.Errorf("extraction complaint against %d which is not in QUAL", msg.Against)
+	}
+
+	if !g.verifyPedersen(msg.Against, msg.From, msg.Share, msg.BlindingShare) {
+		return fmt.Errorf("extraction complaint from %d against %d has an invalid share", msg.From, msg.Against)
+	}
+	coefficients, found := g.publicCoefficients[msg.Against]
+	if found {
+		valid, err := VerifyShareWithCommitments(coefficients, msg.From, msg.Share)
+		if err == nil && valid {
+			return fmt.Errorf("extraction complaint from %d against %d is unfounded", msg.From, msg.Against)
+		}
+	}
+
+	g.extractionCheaters[msg.Against] = true
+	return nil
+}
+
+/**
+	Phase 6 - reconstruct cheating dealers
+ */
+
+// reveals this participant's shares from every dealer that cheated in the extraction phase
+func (g *GJKR) Reconstruct() ([]*GJKRReconstructionShare, error) {
+	if err := g.expectPhase(GJKRExtractionComplaining); err != nil {
+		return nil, err
+	}
+
+	ret := make([]*GJKRReconstructionShare, 0)
+	if containsIndex(g.qual, g.Id) {
+		for _, dealer := range sortedKeys(g.extractionCheaters) {
+			share := g.received[dealer]
+			ret = append(ret, &GJKRReconstructionShare{
+				From:          g.Id,
+				Dealer:        dealer,
+				Share:         share.share,
+				BlindingShare: share.blinding,
+			})
+		}
+	}
+
+	g.phase = GJKRReconstructing
+	return ret, nil
+}
+
+func (g *GJKR) ProcessReconstructionShare(msg *GJKRReconstructionShare) error {
+	if err := g.expectPhase(GJKRReconstructing); err != nil {
+		return err
+	}
+	if !g.extractionCheaters[msg.Dealer] {
+		return fmt.Errorf("reconstruction share for %d which did not cheat", msg.Dealer)
+	}
+	if !containsIndex(g.qual, msg.From) {
+		return fmt.Errorf("reconstruction share from %d which is not in QUAL", msg.From)
+	}
+	if !g.verifyPedersen(msg.Dealer, msg.From, msg.Share, msg.BlindingShare) {
+		return fmt.Errorf("invalid reconstruction share from %d for %d", msg.From, msg.Dealer)
+	}
+
+	if g.reconstructionShares[msg.Dealer] == nil {
+		g.reconstructionShares[msg.Dealer] = make(map[uint32]*bls.Fr)
+	}
+	g.reconstructionShares[msg.Dealer][msg.From] = msg.Share
+	return nil
+}
+
+// calculates the group's public key and every participant's public share
+func (g *GJKR) Finalize() error {
+	if err := g.expectPhase(GJKRReconstructing); err != nil {
+		return err
+	}
+
+	groupPk := &bls.G1{}
+	groupPk.Clear()
+	publicShares := make(map[uint32]*bls.G1)
+	for _, idx := range g.indexes {
+		p := &bls.G1{}
+		p.Clear()
+		publicShares[idx] = p
+	}
+
+	for _, dealer := range g.qual {
+		if !g.extractionCheaters[dealer] {
+			coefficients := g.publicCoefficients[dealer]
+			groupPk = agg_g1(groupPk, &coefficients[0])
+			for idx := range publicShares {
+				p, err := EvaluateCommitments(coefficients, idx)
+				if err != nil {
+					return err
+				}
+				publicShares[idx] = agg_g1(publicShares[idx], p)
+			}
+			continue
+		}
+
+		// cheating dealer, reconstruct his polynomial from the revealed shares
+		revealed := g.reconstructionShares[dealer]
+		if len(revealed) < int(g.threshold) {
+			return fmt.Errorf("not enough shares to reconstruct dealer %d, got %d", dealer, len(revealed))
+		}
+		points := make([][]bls.Fr, 0)
+		for from := range revealed {
+			x := &bls.Fr{}
+			x.SetInt64(int64(from))
+			points = append(points, []bls.Fr{*x, *revealed[from]})
+		}
+
+		secret, err := interpolateAt(points, 0)
+		if err != nil {
+			return err
+		}
+		groupPk = agg_g1(groupPk, frToG1(secret))
+		for idx := range publicShares {
+			share, err := interpolateAt(points, idx)
+			if err != nil {
+				return err
+			}
+			publicShares[idx] = agg_g1(publicShares[idx], frToG1(share))
+		}
+	}
+
+	g.groupPk = groupPk
+	g.publicShares = publicShares
+	g.phase = GJKRDone
+	return nil
+}
+
+/**
+	Results
+ */
+
+func (g *GJKR) Qualified() []uint32 {
+	return g.qual
+}
+
+func (g *GJKR) SecretShare() (*bls.Fr, error) {
+	if g.secretShare == nil {
+		return nil, fmt.Errorf("secret share not calculated yet")
+	}
+	return g.secretShare, nil
+}
+
+func (g *GJKR) GroupPK() (*bls.PublicKey, error) {
+	if err := g.expectPhase(GJKRDone); err != nil {
+		return nil, err
+	}
+	return bls.CastToPublicKey(g.groupPk), nil
+}
+
+func (g *GJKR) PublicShares() (map[uint32]*bls.G1, error) {
+	if err := g.expectPhase(GJKRDone); err != nil {
+		return nil, err
+	}
+	return g.publicShares, nil
+}
+
+/**
+	Helpers
+ */
+
+func (g *GJKR) expectPhase(phase GJKRPhase) error {
+	if g.phase != phase {
+		return fmt.Errorf("GJKR %d: expected phase %d, current phase %d", g.Id, phase, g.phase)
+	}
+	return nil
+}
+
+func (g *GJKR) pedersenCommit(secret *bls.Fr, blinding *bls.Fr) *bls.G1 {
+	h := &bls.G1{}
+	bls.G1Mul(h, g.h, blinding)
+	return agg_g1(frToG1(secret), h)
+}
+
+// verifies g^share * h^blinding is the dealer's Pedersen commitments evaluated at index
+func (g *GJKR) verifyPedersen(dealer uint32, index uint32, share *bls.Fr, blinding *bls.Fr) bool {
+	commitments, found := g.commitments[dealer]
+	if !found || share == nil || blinding == nil {
+		return false
+	}
+	expected, err := EvaluateCommitments(commitments, index)
+	if err != nil {
+		return false
+	}
+	return g.pedersenCommit(share, blinding).IsEqual(expected)
+}
+
+// dealers that broadcasted commitments, a dealer without commitments is disqualified by everyone.
+func (g *GJKR) sortedDealers() []uint32 {
+	ret := make([]uint32, 0)
+	for _, idx := range g.indexes {
+		if _, found := g.commitments[idx]; found {
+			ret = append(ret, idx)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// evaluates the polynomial going through points at x by shifting the points so that x becomes 0
+func interpolateAt(points [][]bls.Fr, x uint32) (*bls.Fr, error) {
+	xFr := &bls.Fr{}
+	xFr.SetInt64(int64(x))
+
+	shifted := make([][]bls.Fr, len(points))
+	for i := range points {
+		if points[i][0].IsEqual(xFr) {
+			return &points[i][1], nil
+		}
+		sub := bls.Fr{}
+		bls.FrSub(&sub, &points[i][0], xFr)
+		shifted[i] = []bls.Fr{sub, points[i][1]}
+	}
+
+	return NewLagrangeInterpolation(shifted).Interpolate()
+}
+
+func containsIndex(indexes []uint32, idx uint32) bool {
+	for _, i := range indexes {
+		if i == idx {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[uint32]bool) []uint32 {
+	ret := make([]uint32, 0)
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
@@ -0,0 +1,314 @@
+package crypto
+
+import (
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// hooks to simulate malicious dealers, all optional
+type gjkrAdversary struct {
+	deal func(deal *GJKRDeal)
+	// return false to withhold the deal
+	sendDeal func(deal *GJKRDeal) bool
+	// return false to lose the complaint on its way to a participant
+	deliverComplaint func(c *GJKRComplaint, to uint32) bool
+	// return false to drop the justification
+	justify func(j *GJKRJustification) bool
+	publicCoefficients func(c *GJKRPublicCoefficients)
+}
+
+// runs a full GJKR round between all participants, delivering every broadcast to everyone (including the sender)
+func runGJKR(t *testing.T, threshold uint32, indexes []uint32, adversary gjkrAdversary) map[uint32]*GJKR {
+	nodes := make(map[uint32]*GJKR)
+	for _, idx := range indexes {
+		g,err := NewGJKR(idx, threshold, indexes)
+		require.NoError(t, err)
+		nodes[idx] = g
+	}
+
+	// deal
+	commitments := make([]*GJKRCommitment, 0)
+	deals := make([]*GJKRDeal, 0)
+	for _, idx := range indexes {
+		c, d, err := nodes[idx].Deal()
+		require.NoError(t, err)
+		commitments = append(commitments, c)
+		for _, deal := range d {
+			if adversary.deal != nil {
+				adversary.deal(deal)
+			}
+			if adversary.sendDeal != nil && !adversary.sendDeal(deal) {
+				continue
+			}
+			deals = append(deals, deal)
+		}
+	}
+	for _, n := range nodes {
+		for _, c := range commitments {
+			require.NoError(t, n.ProcessCommitment(c))
+		}
+	}
+	for _, d := range deals {
+		require.NoError(t, nodes[d.To].ProcessDeal(d))
+	}
+
+	// complain
+	complaints := make([]*GJKRComplaint, 0)
+	for _, idx := range indexes {
+		c, err := nodes[idx].Complain()
+		require.NoError(t, err)
+		complaints = append(complaints, c...)
+	}
+	for idx, n := range nodes {
+		for _, c := range complaints {
+			if adversary.deliverComplaint != nil && !adversary.deliverComplaint(c, idx) {
+				continue
+			}
+			require.NoError(t, n.ProcessComplaint(c))
+		}
+	}
+
+	// justify
+	justifications := make([]*GJKRJustification, 0)
+	for _, idx := range indexes {
+		j, err := nodes[idx].Justify()
+		require.NoError(t, err)
+		for _, justification := range j {
+			if adversary.justify != nil && !adversary.justify(justification) {
+				continue
+			}
+			justifications = append(justifications, justification)
+		}
+	}
+	for _, n := range nodes {
+		for _, j := range justifications {
+			require.NoError(t, n.ProcessJustification(j))
+		}
+	}
+
+	// qualify
+	for _, idx := range indexes {
+		_, err := nodes[idx].Qualify()
+		require.NoError(t, err)
+	}
+
+	// extract
+	coefficients := make([]*GJKRPublicCoefficients, 0)
+	for _, idx := range indexes {
+		c, err := nodes[idx].PublicCoefficients()
+		require.NoError(t, err)
+		if c == nil {
+			continue
+		}
+		if adversary.publicCoefficients != nil {
+			adversary.publicCoefficients(c)
+		}
+		coefficients = append(coefficients, c)
+	}
+	for _, n := range nodes {
+		for _, c := range coefficients {
+			require.NoError(t, n.ProcessPublicCoefficients(c))
+		}
+	}
+	extractionComplaints := make([]*GJKRExtractionComplaint, 0)
+	for _, idx := range indexes {
+		c, err := nodes[idx].ExtractionComplain()
+		require.NoError(t, err)
+		extractionComplaints = append(extractionComplaints, c...)
+	}
+	for _, n := range nodes {
+		for _, c := range extractionComplaints {
+			require.NoError(t, n.ProcessExtractionComplaint(c))
+		}
+	}
+
+	// reconstruct
+	reconstructionShares := make([]*GJKRReconstructionShare, 0)
+	for _, idx := range indexes {
+		r, err := nodes[idx].Reconstruct()
+		require.NoError(t, err)
+		reconstructionShares = append(reconstructionShares, r...)
+	}
+	for _, n := range nodes {
+		for _, r := range reconstructionShares {
+			require.NoError(t, n.ProcessReconstructionShare(r))
+		}
+		require.NoError(t, n.Finalize())
+	}
+
+	return nodes
+}
+
+// all participants agree on QUAL and the group pk, the secret shares reconstruct the group secret and match
+// their public shares.
+func requireGJKRConsistent(t *testing.T, nodes map[uint32]*GJKR, threshold uint32, expectedQual []uint32) {
+	var groupPk *bls.PublicKey
+	points := make([][]bls.Fr, 0)
+	for idx, n := range nodes {
+		require.Equal(t, GJKRDone, n.Phase())
+		require.Equal(t, expectedQual, n.Qualified())
+
+		pk, err := n.GroupPK()
+		require.NoError(t, err)
+		if groupPk == nil {
+			groupPk = pk
+		}
+		require.Equal(t, groupPk.GetHexString(), pk.GetHexString())
+
+		sk, err := n.SecretShare()
+		require.NoError(t, err)
+		publicShares, err := n.PublicShares()
+		require.NoError(t, err)
+		require.Equal(t, g1FromFr(*sk).GetString(10), publicShares[idx].GetString(10))
+
+		if len(points) < int(threshold) {
+			points = append(points, []bls.Fr{frFromInt(int64(idx)), *sk})
+		}
+	}
+
+	groupSk, err := NewLagrangeInterpolation(points).Interpolate()
+	require.NoError(t, err)
+	require.Equal(t, bls.CastToSecretKey(groupSk).GetPublicKey().GetHexString(), groupPk.GetHexString())
+}
+
+func TestGJKRHonest(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	nodes := runGJKR(t, 3, indexes, gjkrAdversary{})
+	requireGJKRConsistent(t, nodes, 3, indexes)
+}
+
+func TestGJKRBadShareJustified(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	nodes := runGJKR(t, 3, indexes, gjkrAdversary{
+		deal: func(deal *GJKRDeal) {
+			if deal.From == 2 && deal.To == 1 {
+				deal.Share = frPointerRandom()
+			}
+		},
+	})
+
+	// dealer 2 justified with the correct share, stays in QUAL
+	requireGJKRConsistent(t, nodes, 3, indexes)
+}
+
+func TestGJKRRefusesToJustify(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	nodes := runGJKR(t, 3, indexes, gjkrAdversary{
+		deal: func(deal *GJKRDeal) {
+			if deal.From == 2 && deal.To == 1 {
+				deal.Share = frPointerRandom()
+			}
+		},
+		justify: func(j *GJKRJustification) bool {
+			return j.From != 2
+		},
+	})
+
+	requireGJKRConsistent(t, nodes, 3, []uint32{1,3,4})
+}
+
+func TestGJKRInvalidJustification(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	nodes := runGJKR(t, 3, indexes, gjkrAdversary{
+		deal: func(deal *GJKRDeal) {
+			if deal.From == 3 && deal.To == 4 {
+				deal.BlindingShare = frPointerRandom()
+			}
+		},
+		justify: func(j *GJKRJustification) bool {
+			if j.From == 3 {
+				j.Share = frPointerRandom()
+			}
+			return true
+		},
+	})
+
+	requireGJKRConsistent(t, nodes, 3, []uint32{1,2,4})
+}
+
+func TestGJKRTooManyComplaints(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	nodes := runGJKR(t, 3, indexes, gjkrAdversary{
+		deal: func(deal *GJKRDeal) {
+			if deal.From == 3 && deal.To != 3 {
+				deal.Share = frPointerRandom()
+			}
+		},
+	})
+
+	// justifications are valid but 3 complaints reach the threshold
+	requireGJKRConsistent(t, nodes, 3, []uint32{1,2,4})
+}
+
+func TestGJKRBadPublicCoefficients(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	nodes := runGJKR(t, 3, indexes, gjkrAdversary{
+		publicCoefficients: func(c *GJKRPublicCoefficients) {
+			if c.From == 4 {
+				c.Coefficients[0] = *g1FromFr(frFromInt(1))
+			}
+		},
+	})
+
+	// dealer 4 stays in QUAL but his secret is reconstructed publicly
+	requireGJKRConsistent(t, nodes, 3, indexes)
+}
+
+// the complainer doesn't get its own complaint back, it still expects the dealer's justification
+func TestGJKRWithheldDeal(t *testing.T) {
+	InitBLS()
+
+	indexes := []uint32{1,2,3,4}
+	adversary := gjkrAdversary{
+		sendDeal: func(deal *GJKRDeal) bool {
+			return deal.From != 1 || deal.To != 2
+		},
+		deliverComplaint: func(c *GJKRComplaint, to uint32) bool {
+			return c.From != to
+		},
+	}
+	nodes := runGJKR(t, 3, indexes, adversary)
+	// dealer 1 justified with the withheld share
+	requireGJKRConsistent(t, nodes, 3, indexes)
+
+	adversary.justify = func(j *GJKRJustification) bool {
+		return j.From != 1
+	}
+	nodes = runGJKR(t, 3, indexes, adversary)
+	requireGJKRConsistent(t, nodes, 3, []uint32{2,3,4})
+}
+
+func TestGJKRPhaseOrder(t *testing.T) {
+	InitBLS()
+
+	g,err := NewGJKR(1, 2, []uint32{1,2,3})
+	require.NoError(t, err)
+
+	_,err = g.Complain()
+	require.Error(t, err)
+	_,err = g.GroupPK()
+	require.Error(t, err)
+
+	_,_,err = g.Deal()
+	require.NoError(t, err)
+	_,_,err = g.Deal()
+	require.Error(t, err)
+
+	_,err = NewGJKR(4, 2, []uint32{1,2,3})
+	require.Error(t, err)
+	_,err = NewGJKR(1, 4, []uint32{1,2,3})
+	require.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+/**
+	EIP-2335 keystore (https://eips.ethereum.org/EIPS/eip-2335), used to keep secret shares encrypted at rest.
+	The decryption key (DK) is derived from the password with scrypt or pbkdf2, the secret is encrypted with
+	AES-128-CTR under DK[:16] and the checksum sha256(DK[16:32] | cipher message) verifies the password.
+ */
+
+const (
+	KeystoreKDFScrypt = "scrypt"
+	KeystoreKDFPBKDF2 = "pbkdf2"
+
+	keystoreVersion = 4
+	keystoreDKLen = 32
+)
+
+// defaults follow EIP-2335, lighter params are meant for testing only
+type KeystoreParams struct {
+	KDF string
+	ScryptN int
+	PBKDF2C int
+}
+
+func DefaultKeystoreParams() KeystoreParams {
+	return KeystoreParams{
+		KDF:     KeystoreKDFScrypt,
+		ScryptN: 262144,
+		PBKDF2C: 262144,
+	}
+}
+
+type KeystoreModule struct {
+	Function string                 `json:"function"`
+	Params   map[string]interface{} `json:"params"`
+	Message  string                 `json:"message"`
+}
+
+type KeystoreCrypto struct {
+	KDF      KeystoreModule `json:"kdf"`
+	Checksum KeystoreModule `json:"checksum"`
+	Cipher   KeystoreModule `json:"cipher"`
+}
+
+type Keystore struct {
+	Crypto      KeystoreCrypto `json:"crypto"`
+	Description string         `json:"description"`
+	Pubkey      string         `json:"pubkey"`
+	Path        string         `json:"path"`
+	UUID        string         `json:"uuid"`
+	Version     int            `json:"version"`
+}
+
+func EncryptKeystore(secret []byte, pubkey []byte, password string, description string, params KeystoreParams) (*Keystore, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	for _, b := range [][]byte{salt, iv} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+
+	var kdf KeystoreModule
+	switch params.KDF {
+	case KeystoreKDFScrypt:
+		kdf = KeystoreModule{
+			Function: KeystoreKDFScrypt,
+			Params: map[string]interface{}{
+				"dklen": keystoreDKLen,
+				"n":     params.ScryptN,
+				"r":     8,
+				"p":     1,
+				"salt":  hex.EncodeToString(salt),
+			},
+		}
+	case KeystoreKDFPBKDF2:
+		kdf = KeystoreModule{
+			Function: KeystoreKDFPBKDF2,
+			Params: map[string]interface{}{
+				"dklen": keystoreDKLen,
+				"c":     params.PBKDF2C,
+				"prf":   "hmac-sha256",
+				"salt":  hex.EncodeToString(salt),
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unknown kdf %s", params.KDF)
+	}
+
+	dk, err := deriveKeystoreKey(kdf, password)
+	if err != nil {
+		return nil, err
+	}
+	cipherMessage, err := aes128CTR(dk[:16], iv, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keystore{
+		Crypto: KeystoreCrypto{
+			KDF: kdf,
+			Checksum: KeystoreModule{
+				Function: "sha256",
+				Params:   map[string]interface{}{},
+				Message:  hex.EncodeToString(keystoreChecksum(dk, cipherMessage)),
+			},
+			Cipher: KeystoreModule{
+				Function: "aes-128-ctr",
+				Params:   map[string]interface{}{"iv": hex.EncodeToString(iv)},
+				Message:  hex.EncodeToString(cipherMessage),
+			},
+		},
+		Description: description,
+		Pubkey:      hex.EncodeToString(pubkey),
+		Path:        "",
+		UUID:        uuid.New().String(),
+		Version:     keystoreVersion,
+	}, nil
+}
+
+// returns the secret, fails on a wrong password
+func (k *Keystore) Decrypt(password string) ([]byte, error) {
+	if k.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", k.Version)
+	}
+	if k.Crypto.Checksum.Function != "sha256" || k.Crypto.Cipher.Function != "aes-128-ctr" {
+		return nil, fmt.Errorf("unsupported keystore checksum or cipher")
+	}
+
+	dk, err := deriveKeystoreKey(k.Crypto.KDF, password)
+	if err != nil {
+		return nil, err
+	}
+	cipherMessage, err := hex.DecodeString(k.Crypto.Cipher.Message)
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := hex.DecodeString(k.Crypto.Checksum.Message)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(checksum, keystoreChecksum(dk, cipherMessage)) {
+		return nil, fmt.Errorf("invalid password")
+	}
+
+	iv, err := keystoreHexParam(k.Crypto.Cipher, "iv")
+	if err != nil {
+		return nil, err
+	}
+	return aes128CTR(dk[:16], iv, cipherMessage)
+}
+
+func deriveKeystoreKey(kdf KeystoreModule, password string) ([]byte, error) {
+	pass := normalizeKeystorePassword(password)
+	salt, err := keystoreHexParam(kdf, "salt")
+	if err != nil {
+		return nil, err
+	}
+	dkLen, err := keystoreIntParam(kdf, "dklen")
+	if err != nil {
+		return nil, err
+	}
+	if dkLen < keystoreDKLen {
+		return nil, fmt.Errorf("dklen must be at least %d", keystoreDKLen)
+	}
+
+	switch kdf.Function {
+	case KeystoreKDFScrypt:
+		n, err := keystoreIntParam(kdf, "n")
+		if err != nil {
+			return nil, err
+		}
+		r, err := keystoreIntParam(kdf, "r")
+		if err != nil {
+			return nil, err
+		}
+		p, err := keystoreIntParam(kdf, "p")
+		if err != nil {
+			return nil, err
+		}
+		return scrypt.Key(pass, salt, n, r, p, dkLen)
+	case KeystoreKDFPBKDF2:
+		c, err := keystoreIntParam(kdf, "c")
+		if err != nil {
+			return nil, err
+		}
+		if prf, _ := kdf.Params["prf"].(string); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported prf %s", prf)
+		}
+		return pbkdf2.Key(pass, salt, c, dkLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unknown kdf %s", kdf.Function)
+	}
+}
+
+// NFKD and stripped of control codes as EIP-2335 requires
+func normalizeKeystorePassword(password string) []byte {
+	return []byte(strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, norm.NFKD.String(password)))
+}
+
+func keystoreChecksum(dk []byte, cipherMessage []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{}, dk[16:32]...), cipherMessage...))
+	return h[:]
+}
+
+func aes128CTR(key []byte, iv []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(ret, data)
+	return ret, nil
+}
+
+func keystoreHexParam(module KeystoreModule, name string) ([]byte, error) {
+	v, ok := module.Params[name].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing %s param %s", module.Function, name)
+	}
+	return hex.DecodeString(v)
+}
+
+// params are either ints (when encrypting) or float64 (when decoded from JSON)
+func keystoreIntParam(module KeystoreModule, name string) (int, error) {
+	switch v := module.Params[name].(type) {
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("missing %s param %s", module.Function, name)
+	}
+}
@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// EIP-2335 test vectors
+func TestKeystoreVectors(t *testing.T) {
+	secret, _ := hex.DecodeString("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f")
+	password := "𝔱𝔢𝔰𝔱𝔭𝔞𝔰𝔰𝔴𝔬𝔯𝔡🔑"
+
+	tests := []struct{
+		name string
+		kdf string
+	}{
+		{
+			name: "scrypt",
+			kdf: `{"function": "scrypt", "params": {"dklen": 32, "n": 262144, "p": 1, "r": 8, "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"}, "message": ""}`,
+		},
+		{
+			name: "pbkdf2",
+			kdf: `{"function": "pbkdf2", "params": {"dklen": 32, "c": 262144, "prf": "hmac-sha256", "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"}, "message": ""}`,
+		},
+	}
+	checksums := map[string]string{
+		"scrypt": "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484",
+		"pbkdf2": "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1",
+	}
+	messages := map[string]string{
+		"scrypt": "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f",
+		"pbkdf2": "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad",
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			k := &Keystore{Version: 4}
+			require.NoError(t, json.Unmarshal([]byte(test.kdf), &k.Crypto.KDF))
+			k.Crypto.Checksum = KeystoreModule{Function: "sha256", Message: checksums[test.name]}
+			k.Crypto.Cipher = KeystoreModule{
+				Function: "aes-128-ctr",
+				Params: map[string]interface{}{"iv": "264daa3f303d7259501c93d997d84fe6"},
+				Message: messages[test.name],
+			}
+
+			res, err := k.Decrypt(password)
+			require.NoError(t, err)
+			require.Equal(t, secret, res)
+
+			_, err = k.Decrypt("wrong")
+			require.EqualError(t, err, "invalid password")
+		})
+	}
+}
+
+func TestKeystoreEncryptDecrypt(t *testing.T) {
+	secret := []byte("secret share")
+	params := KeystoreParams{KDF: KeystoreKDFScrypt, ScryptN: 2}
+
+	k, err := EncryptKeystore(secret, []byte{1,2}, "password", "share", params)
+	require.NoError(t, err)
+	require.NotContains(t, k.Crypto.Cipher.Message, hex.EncodeToString(secret))
+
+	// survives a JSON round trip
+	data, err := json.Marshal(k)
+	require.NoError(t, err)
+	decoded := &Keystore{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+
+	res, err := decoded.Decrypt("password")
+	require.NoError(t, err)
+	require.Equal(t, secret, res)
+	_, err = decoded.Decrypt("password1")
+	require.Error(t, err)
+
+	params.KDF = KeystoreKDFPBKDF2
+	params.PBKDF2C = 2
+	k, err = EncryptKeystore(secret, nil, "password", "share", params)
+	require.NoError(t, err)
+	res, err = k.Decrypt("password")
+	require.NoError(t, err)
+	require.Equal(t, secret, res)
+}
@@ -1,6 +1,9 @@
 package crypto
 
-import "github.com/herumi/bls-eth-go-binary/bls"
+import (
+	"fmt"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
 
 // responsible for generating shares for redistribution
 // https://github.com/bloxapp/eth2-staking-pools-research/blob/master/pool_rotation.md
@@ -37,4 +40,33 @@ func (distro *Redistribuition)GenerateShares(indexes []uint32) (map[uint32]*bls.
 	}
 
 	return ret, nil
-}
\ No newline at end of file
+}
+
+// commitments to the redistribution polynomial, commitment[0] is g^originalSk which is the
+// dealer's public share for the current epoch.
+func (distro *Redistribuition) Commitments() []bls.G1 {
+	return distro.polynomial.Commitments()
+}
+
+// given the commitments of every (valid) dealer, returns the public share (g^sk) of index after redistribution.
+// It's the lagrange interpolation (in the exponent) of every dealer's committed polynomial evaluated at index.
+func RedistributedPublicShare(commitments map[uint32][]bls.G1, index uint32) (*bls.G1, error) {
+	if len(commitments) == 0 {
+		return nil, fmt.Errorf("no commitments")
+	}
+
+	points := make([][]interface{},0)
+	for dealer, c := range commitments {
+		p, err := EvaluateCommitments(c, index)
+		if err != nil {
+			return nil, err
+		}
+
+		x := &bls.Fr{}
+		x.SetInt64(int64(dealer))
+		points = append(points, []interface{}{*x, p})
+	}
+
+	l := NewG1LagrangeInterpolation(points)
+	return l.interpolate()
+}
@@ -111,3 +111,60 @@ func TestRedistribuition(t *testing.T) {
 		})
 	}
 }
+
+func TestRedistribuitionCommitments(t *testing.T) {
+	InitBLS()
+
+	// original group y=x^2+30 for indexes 1,2,3
+	sks := map[uint32]*bls.Fr{
+		1: frPointerFromInt(31),
+		2: frPointerFromInt(34),
+		3: frPointerFromInt(39),
+	}
+
+	commitments := make(map[uint32][]bls.G1)
+	sharesTo := make(map[uint32][][]bls.Fr)
+	for dealer, sk := range sks {
+		distro,err := NewRedistribuition(3, sk)
+		require.NoError(t,err)
+
+		c := distro.Commitments()
+		require.Len(t, c, 3)
+		// commitment[0] is the dealer's current public share
+		require.Equal(t, g1FromFr(*sk).GetString(10), c[0].GetString(10))
+		commitments[dealer] = c
+
+		shares,err := distro.GenerateShares([]uint32{1,2,3})
+		require.NoError(t,err)
+		for idx, share := range shares {
+			valid,err := VerifyShareWithCommitments(c, idx, share)
+			require.NoError(t,err)
+			require.True(t, valid)
+
+			sharesTo[idx] = append(sharesTo[idx], []bls.Fr{frFromInt(int64(dealer)), *share})
+		}
+
+		// wrong share
+		valid,err := VerifyShareWithCommitments(c, 1, frPointerFromInt(1))
+		require.NoError(t,err)
+		require.False(t, valid)
+	}
+
+	// serialization round trip
+	deserialized,err := DeserializeCommitments(SerializeCommitments(commitments[1]))
+	require.NoError(t,err)
+	require.Len(t, deserialized, 3)
+	for i := range deserialized {
+		require.True(t, deserialized[i].IsEqual(&commitments[1][i]))
+	}
+
+	// public shares derived from commitments match the reconstructed secrets
+	for idx, points := range sharesTo {
+		sk,err := NewLagrangeInterpolation(points).Interpolate()
+		require.NoError(t,err)
+
+		publicShare,err := RedistributedPublicShare(commitments, idx)
+		require.NoError(t,err)
+		require.Equal(t, g1FromFr(*sk).GetString(10), publicShare.GetString(10))
+	}
+}
@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"fmt"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"sort"
+)
+
+/**
+	Threshold reconstruction helpers.
+	Contributions (secret shares or partial signatures) are keyed by the contributor's index, the caller is
+	expected to pass only contributions it verified (e.g. shares against commitments, partial sigs against public
+	shares). Reconstruction always uses the threshold lowest indexes so every participant, given the same
+	contributions, interpolates the same points.
+
+	If crossCheck is set, every redundant contribution (above the threshold) is swapped in for the last point of
+	the base subset and the result compared to the base reconstruction. All contributions must lie on the same
+	polynomial, otherwise reconstruction fails with an InconsistentContributionError. Interpolation alone can't
+	tell which contribution is bad (it could be in the base subset), the error names no offender. Callers find it
+	by verifying every contribution on its own (e.g. against the contributor's public share).
+ */
+
+type InsufficientContributionsError struct {
+	Threshold uint32
+	Received  []uint32
+	// expected contributors that did not contribute (or whose contribution was not valid)
+	Missing []uint32
+}
+
+func (e *InsufficientContributionsError) Error() string {
+	return fmt.Sprintf("insufficient contributions, threshold %d, received %d, missing %v", e.Threshold, len(e.Received), e.Missing)
+}
+
+type InconsistentContributionError struct {
+	// the base subset and the redundant contribution that disagree, any of them could be the bad one
+	Indexes []uint32
+}
+
+func (e *InconsistentContributionError) Error() string {
+	return fmt.Sprintf("contributions %v are inconsistent", e.Indexes)
+}
+
+// reconstructs the secret (the polynomial at 0) from a threshold of shares
+func ReconstructSecret(threshold uint32, expected []uint32, shares map[uint32]*bls.Fr, crossCheck bool) (*bls.Fr, error) {
+	received := make([]uint32, 0)
+	for idx, share := range shares {
+		if share != nil {
+			received = append(received, idx)
+		}
+	}
+
+	interpolate := func(indexes []uint32) (*bls.Fr, error) {
+		points := make([][]bls.Fr, len(indexes))
+		for i, idx := range indexes {
+			points[i] = []bls.Fr{frFromIndex(idx), *shares[idx]}
+		}
+		return NewLagrangeInterpolation(points).Interpolate()
+	}
+
+	base, redundant, err := thresholdSubset(threshold, expected, received)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := interpolate(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if crossCheck {
+		for _, idx := range redundant {
+			res, err := interpolate(swapLast(base, idx))
+			if err != nil {
+				return nil, err
+			}
+			if !res.IsEqual(ret) {
+				return nil, inconsistentContributions(base, idx)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// reconstructs the group signature from a threshold of partial signatures
+func ReconstructSignature(threshold uint32, expected []uint32, sigs map[uint32]*bls.G2, crossCheck bool) (*bls.G2, error) {
+	received := make([]uint32, 0)
+	for idx, sig := range sigs {
+		if sig != nil {
+			received = append(received, idx)
+		}
+	}
+
+	interpolate := func(indexes []uint32) (*bls.G2, error) {
+		points := make([][]interface{}, len(indexes))
+		for i, idx := range indexes {
+			points[i] = []interface{}{frFromIndex(idx), sigs[idx]}
+		}
+		return NewG2LagrangeInterpolation(points).Interpolate()
+	}
+
+	base, redundant, err := thresholdSubset(threshold, expected, received)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := interpolate(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if crossCheck {
+		for _, idx := range redundant {
+			res, err := interpolate(swapLast(base, idx))
+			if err != nil {
+				return nil, err
+			}
+			if !res.IsEqual(ret) {
+				return nil, inconsistentContributions(base, idx)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// returns the threshold lowest received indexes and the rest, fails if less than threshold were received
+func thresholdSubset(threshold uint32, expected []uint32, received []uint32) ([]uint32, []uint32, error) {
+	if threshold == 0 {
+		return nil, nil, fmt.Errorf("threshold must be positive")
+	}
+
+	sorted := make([]uint32, len(received))
+	copy(sorted, received)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	if len(sorted) < int(threshold) {
+		missing := make([]uint32, 0)
+		for _, idx := range expected {
+			if !containsIndex(sorted, idx) {
+				missing = append(missing, idx)
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+		return nil, nil, &InsufficientContributionsError{
+			Threshold: threshold,
+			Received:  sorted,
+			Missing:   missing,
+		}
+	}
+
+	return sorted[:threshold], sorted[threshold:], nil
+}
+
+// the base subset and the redundant index, sorted
+func inconsistentContributions(base []uint32, redundant uint32) *InconsistentContributionError {
+	indexes := append(append(make([]uint32, 0, len(base) + 1), base...), redundant)
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return &InconsistentContributionError{Indexes: indexes}
+}
+
+// returns a copy of the subset with its last index replaced
+func swapLast(subset []uint32, idx uint32) []uint32 {
+	ret := make([]uint32, len(subset))
+	copy(ret, subset)
+	ret[len(ret) - 1] = idx
+	return ret
+}
+
+func frFromIndex(idx uint32) bls.Fr {
+	ret := bls.Fr{}
+	ret.SetInt64(int64(idx))
+	return ret
+}
@@ -0,0 +1,110 @@
+package crypto
+
+import (
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func thresholdShares(t *testing.T, secret bls.Fr, threshold uint32, indexes []uint32) map[uint32]*bls.Fr {
+	p,err := NewPolynomial(secret, threshold)
+	require.NoError(t, err)
+
+	ret := make(map[uint32]*bls.Fr)
+	for _, idx := range indexes {
+		x := frFromIndex(idx)
+		share,err := p.Evaluate(&x)
+		require.NoError(t, err)
+		ret[idx] = share
+	}
+	return ret
+}
+
+func TestReconstructSecret(t *testing.T) {
+	InitBLS()
+
+	secret := *frPointerRandom()
+	indexes := []uint32{1,2,3,4,5}
+	shares := thresholdShares(t, secret, 3, indexes)
+
+	// all shares, redundant ones cross checked
+	res,err := ReconstructSecret(3, indexes, shares, true)
+	require.NoError(t, err)
+	require.True(t, res.IsEqual(&secret))
+
+	// any threshold is enough
+	delete(shares, 1)
+	delete(shares, 4)
+	res,err = ReconstructSecret(3, indexes, shares, true)
+	require.NoError(t, err)
+	require.True(t, res.IsEqual(&secret))
+
+	// missing contributions are named
+	delete(shares, 2)
+	_,err = ReconstructSecret(3, indexes, shares, true)
+	require.Error(t, err)
+	insufficient, ok := err.(*InsufficientContributionsError)
+	require.True(t, ok)
+	require.EqualValues(t, 3, insufficient.Threshold)
+	require.Equal(t, []uint32{3,5}, insufficient.Received)
+	require.Equal(t, []uint32{1,2,4}, insufficient.Missing)
+}
+
+func TestReconstructSecretInconsistent(t *testing.T) {
+	InitBLS()
+
+	secret := *frPointerRandom()
+	indexes := []uint32{1,2,3,4}
+	shares := thresholdShares(t, secret, 3, indexes)
+	shares[4] = frPointerRandom()
+
+	// the base subset is consistent, without a cross check the bad share goes unnoticed
+	res,err := ReconstructSecret(3, indexes, shares, false)
+	require.NoError(t, err)
+	require.True(t, res.IsEqual(&secret))
+
+	_,err = ReconstructSecret(3, indexes, shares, true)
+	require.Error(t, err)
+	inconsistent, ok := err.(*InconsistentContributionError)
+	require.True(t, ok)
+	require.Equal(t, []uint32{1,2,3,4}, inconsistent.Indexes)
+
+	// a bad share in the base subset looks the same, the honest redundant contributor isn't blamed
+	shares = thresholdShares(t, secret, 3, indexes)
+	shares[1] = frPointerRandom()
+	_,err = ReconstructSecret(3, indexes, shares, true)
+	require.Error(t, err)
+	inconsistent, ok = err.(*InconsistentContributionError)
+	require.True(t, ok)
+	require.Equal(t, []uint32{1,2,3,4}, inconsistent.Indexes)
+}
+
+func TestReconstructSignature(t *testing.T) {
+	InitBLS()
+
+	secret := *frPointerRandom()
+	indexes := []uint32{1,2,3,4}
+	shares := thresholdShares(t, secret, 3, indexes)
+
+	msg := []byte("hello")
+	sigs := make(map[uint32]*bls.G2)
+	for idx, share := range shares {
+		sigs[idx] = Sign(share, msg)
+	}
+	expected := Sign(&secret, msg)
+
+	res,err := ReconstructSignature(3, indexes, sigs, true)
+	require.NoError(t, err)
+	require.True(t, res.IsEqual(expected))
+
+	// a bad partial sig is detected by the cross check
+	sigs[4] = Sign(frPointerRandom(), msg)
+	_,err = ReconstructSignature(3, indexes, sigs, true)
+	require.IsType(t, &InconsistentContributionError{}, err)
+
+	delete(sigs, 4)
+	delete(sigs, 2)
+	_,err = ReconstructSignature(3, indexes, sigs, true)
+	require.IsType(t, &InsufficientContributionsError{}, err)
+	require.Equal(t, []uint32{2,4}, err.(*InsufficientContributionsError).Missing)
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"fmt"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+/**
+	Feldman's verifiable secret sharing (VSS).
+	A dealer publishes a G1 commitment to every coefficient of his polynomial, every receiver of a share
+	can then verify the share against the commitments without learning anything about the secret.
+	https://github.com/bloxapp/eth2-staking-pools-research/blob/master/dkg.md#polynomial-committment
+ */
+
+// returns g^c_i for every coefficient of the polynomial, index 0 is the commitment to the free coefficient (the secret)
+func (p *Polynomial) Commitments() []bls.G1 {
+	ret := make([]bls.G1, len(p.Coefficients))
+	for i := range p.Coefficients {
+		ret[i] = *frToG1(&p.Coefficients[i])
+	}
+	return ret
+}
+
+// evaluates the committed polynomial "in the exponent" at index, returns g^f(index)
+func EvaluateCommitments(commitments []bls.G1, index uint32) (*bls.G1,error) {
+	if len(commitments) == 0 {
+		return nil, fmt.Errorf("no commitments")
+	}
+
+	x := &bls.Fr{}
+	x.SetInt64(int64(index))
+
+	res := &bls.G1{}
+	err := bls.G1EvaluatePolynomial(res, commitments, x)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// returns true if g^share is equal to the committed polynomial evaluated at index
+func VerifyShareWithCommitments(commitments []bls.G1, index uint32, share *bls.Fr) (bool,error) {
+	expected, err := EvaluateCommitments(commitments, index)
+	if err != nil {
+		return false, err
+	}
+
+	return frToG1(share).IsEqual(expected), nil
+}
+
+func SerializeCommitments(commitments []bls.G1) [][]byte {
+	ret := make([][]byte, len(commitments))
+	for i := range commitments {
+		ret[i] = commitments[i].Serialize()
+	}
+	return ret
+}
+
+func DeserializeCommitments(data [][]byte) ([]bls.G1,error) {
+	ret := make([]bls.G1, len(data))
+	for i := range data {
+		err := ret[i].Deserialize(data[i])
+		if err != nil {
+			return nil, fmt.Errorf("could not deserialize commitment %d: %s", i, err.Error())
+		}
+	}
+	return ret, nil
+}
+
+func frToG1(fr *bls.Fr) *bls.G1 {
+	sk := bls.CastToSecretKey(fr)
+	return bls.CastFromPublicKey(sk.GetPublicKey())
+}
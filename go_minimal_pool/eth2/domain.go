@@ -0,0 +1,47 @@
+package eth2
+
+/**
+	Signatures are over a signing root, the object's root mixed with a domain. The domain separates the duty types
+	and binds the signature to a network (its fork version and genesis validators root) so it can't be replayed on
+	another one.
+	https://github.com/ethereum/eth2.0-specs/blob/dev/specs/phase0/beacon-chain.md#compute_domain
+ */
+
+var (
+	DomainBeaconProposer = DomainType{0x00, 0x00, 0x00, 0x00}
+	DomainBeaconAttester = DomainType{0x01, 0x00, 0x00, 0x00}
+	DomainRandao = DomainType{0x02, 0x00, 0x00, 0x00}
+	DomainDeposit = DomainType{0x03, 0x00, 0x00, 0x00}
+	DomainVoluntaryExit = DomainType{0x04, 0x00, 0x00, 0x00}
+	DomainSelectionProof = DomainType{0x05, 0x00, 0x00, 0x00}
+	DomainAggregateAndProof = DomainType{0x06, 0x00, 0x00, 0x00}
+)
+
+// the network's fork, there are no fork upgrades (yet) so its version is used for every epoch
+type Fork struct {
+	Version Version
+	GenesisValidatorsRoot Root
+}
+
+// hash_tree_root(ForkData(current_version, genesis_validators_root))
+func ComputeForkDataRoot(version Version, genesisValidatorsRoot Root) Root {
+	return Merkleize(bytes4Root(version), genesisValidatorsRoot)
+}
+
+// the domain type followed by the first 28 bytes of the fork data root
+func ComputeDomain(domainType DomainType, version Version, genesisValidatorsRoot Root) Domain {
+	forkDataRoot := ComputeForkDataRoot(version, genesisValidatorsRoot)
+	ret := Domain{}
+	copy(ret[:4], domainType[:])
+	copy(ret[4:], forkDataRoot[:28])
+	return ret
+}
+
+// hash_tree_root(SigningData(object_root, domain))
+func ComputeSigningRoot(objectRoot Root, domain Domain) Root {
+	return Merkleize(objectRoot, Root(domain))
+}
+
+func (f *Fork) Domain(domainType DomainType) Domain {
+	return ComputeDomain(domainType, f.Version, f.GenesisValidatorsRoot)
+}
@@ -0,0 +1,52 @@
+package eth2
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestComputeDomain(t *testing.T) {
+	// the mainnet deposit domain, deposits are signed with the genesis fork version and a zero validators root
+	domain := ComputeDomain(DomainDeposit, Version{}, Root{})
+	require.Equal(t, "03000000f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9", hex.EncodeToString(domain[:]))
+
+	fork := &Fork{Version: Version{0, 0, 0, 1}, GenesisValidatorsRoot: Root{1}}
+	attester := fork.Domain(DomainBeaconAttester)
+	require.Equal(t, DomainBeaconAttester[:], attester[:4])
+	proposer := fork.Domain(DomainBeaconProposer)
+	require.Equal(t, attester[4:], proposer[4:])
+	require.NotEqual(t, attester, (&Fork{Version: Version{0, 0, 0, 1}}).Domain(DomainBeaconAttester))
+	require.NotEqual(t, attester, (&Fork{GenesisValidatorsRoot: Root{1}}).Domain(DomainBeaconAttester))
+}
+
+func TestHashTreeRoot(t *testing.T) {
+	// sha256 of 64 zero bytes
+	zero := "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"
+	root := (&Checkpoint{}).HashTreeRoot()
+	require.Equal(t, zero, hex.EncodeToString(root[:]))
+	root = (&VoluntaryExit{}).HashTreeRoot()
+	require.Equal(t, zero, hex.EncodeToString(root[:]))
+
+	// 5 fields are padded to 8 chunks
+	header := &BeaconBlockHeader{Slot: 1, ProposerIndex: 2, ParentRoot: Root{3}, StateRoot: Root{4}, BodyRoot: Root{5}}
+	expected := hashPair(
+		hashPair(hashPair(Uint64Root(1), Uint64Root(2)), hashPair(Root{3}, Root{4})),
+		hashPair(hashPair(Root{5}, Root{}), hashPair(Root{}, Root{})),
+		)
+	require.Equal(t, expected, header.HashTreeRoot())
+}
+
+func TestListRoot(t *testing.T) {
+	// an empty list is a zero filled tree mixed in with length 0
+	require.Equal(t, hashPair(hashPair(hashPair(Root{}, Root{}), hashPair(Root{}, Root{})), Root{}), ListRoot(nil, 4))
+
+	roots := []Root{{1}, {2}, {3}}
+	require.Equal(t, hashPair(Merkleize(roots...), Uint64Root(3)), ListRoot(roots, 4))
+	require.Equal(t, hashPair(hashPair(Merkleize(roots...), zeroHashes[2]), Uint64Root(3)), ListRoot(roots, 8))
+
+	// 48 bytes are packed into 2 chunks
+	pk := make([]byte, 48)
+	pk[0], pk[47] = 1, 2
+	require.Equal(t, hashPair(Root{1}, Root{15: 2}), BytesRoot(pk))
+}
@@ -0,0 +1,225 @@
+package eth2
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+type DutyType int
+
+const (
+	DutyAttestation DutyType = iota
+	DutyProposal
+	DutyRandao
+	DutySelectionProof
+	DutyVoluntaryExit
+)
+
+func (t DutyType) String() string {
+	switch t {
+	case DutyAttestation:
+		return "attestation"
+	case DutyProposal:
+		return "proposal"
+	case DutyRandao:
+		return "randao"
+	case DutySelectionProof:
+		return "selection proof"
+	case DutyVoluntaryExit:
+		return "voluntary exit"
+	default:
+		return "unknown"
+	}
+}
+
+// something a validator signs, Attestation, Block or Exit is set according to Type. A RANDAO reveal signs Epoch
+// and a selection proof signs Slot.
+type Duty struct {
+	Type DutyType
+	Slot Slot
+	Epoch Epoch
+	ValidatorIndex ValidatorIndex
+	// an attestation's committee size and the validator's position in it, for the attestation's aggregation bits
+	CommitteeLength uint64
+	ValidatorCommitteeIndex uint64
+
+	Attestation *AttestationData
+	Block *BeaconBlockHeader
+	Exit *VoluntaryExit
+}
+
+func NewVoluntaryExitDuty(exit *VoluntaryExit) *Duty {
+	return &Duty{
+		Type: DutyVoluntaryExit,
+		Slot: EpochStartSlot(exit.Epoch),
+		Epoch: exit.Epoch,
+		ValidatorIndex: exit.ValidatorIndex,
+		Exit: exit,
+	}
+}
+
+func (d *Duty) domainType() (DomainType, error) {
+	switch d.Type {
+	case DutyAttestation:
+		return DomainBeaconAttester, nil
+	case DutyProposal:
+		return DomainBeaconProposer, nil
+	case DutyRandao:
+		return DomainRandao, nil
+	case DutySelectionProof:
+		return DomainSelectionProof, nil
+	case DutyVoluntaryExit:
+		return DomainVoluntaryExit, nil
+	default:
+		return DomainType{}, fmt.Errorf("unknown duty type %d", d.Type)
+	}
+}
+
+func (d *Duty) objectRoot() (Root, error) {
+	switch d.Type {
+	case DutyAttestation:
+		if d.Attestation == nil {
+			return Root{}, fmt.Errorf("attestation duty without attestation data")
+		}
+		return d.Attestation.HashTreeRoot(), nil
+	case DutyProposal:
+		if d.Block == nil {
+			return Root{}, fmt.Errorf("proposal duty without block")
+		}
+		return d.Block.HashTreeRoot(), nil
+	case DutyRandao:
+		return Uint64Root(d.Epoch), nil
+	case DutySelectionProof:
+		return Uint64Root(d.Slot), nil
+	case DutyVoluntaryExit:
+		if d.Exit == nil {
+			return Root{}, fmt.Errorf("voluntary exit duty without exit")
+		}
+		return d.Exit.HashTreeRoot(), nil
+	default:
+		return Root{}, fmt.Errorf("unknown duty type %d", d.Type)
+	}
+}
+
+// the root the validator's key signs for the duty on the fork's network
+func (f *Fork) SigningRoot(duty *Duty) (Root, error) {
+	domainType, err := duty.domainType()
+	if err != nil {
+		return Root{}, err
+	}
+	objectRoot, err := duty.objectRoot()
+	if err != nil {
+		return Root{}, err
+	}
+	return ComputeSigningRoot(objectRoot, f.Domain(domainType)), nil
+}
+
+type DutySource interface {
+	// the validator's duties in epoch, sorted by slot
+	Duties(epoch Epoch, validator ValidatorIndex) ([]*Duty, error)
+}
+
+/**
+	Duties derived from the epoch and the validator's index only, standing in for a beacon node until one is
+	connected. Every validator attests once per epoch, and signs a selection proof for the attestation's slot (it's
+	an aggregator or not depending on the proof), one in ProposalPeriod epochs it also proposes a block and reveals
+	its RANDAO. Block, state and body roots are made up from the slot, every node derives the same ones.
+ */
+type LocalDutySource struct {
+	ProposalPeriod uint64
+}
+
+func NewLocalDutySource() *LocalDutySource {
+	return &LocalDutySource{ProposalPeriod: 4}
+}
+
+func (s *LocalDutySource) Duties(epoch Epoch, validator ValidatorIndex) ([]*Duty, error) {
+	if s.ProposalPeriod == 0 {
+		return nil, fmt.Errorf("proposal period must be positive")
+	}
+
+	seed := localRoot("duties", epoch, validator)
+	attestationSlot := EpochStartSlot(epoch) + uint64(seed[0]) % SlotsPerEpoch
+	proposalSlot := EpochStartSlot(epoch) + uint64(seed[1]) % SlotsPerEpoch
+
+	ret := make([]*Duty, 0)
+	if (epoch + validator) % s.ProposalPeriod == 0 {
+		ret = append(ret, &Duty{
+			Type: DutyRandao,
+			Slot: proposalSlot,
+			Epoch: epoch,
+			ValidatorIndex: validator,
+		}, s.proposal(proposalSlot, validator))
+	}
+	ret = append(ret, s.attestation(attestationSlot, validator), &Duty{
+		Type: DutySelectionProof,
+		Slot: attestationSlot,
+		Epoch: epoch,
+		ValidatorIndex: validator,
+	})
+	// the RANDAO reveal stays before its block
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Slot < ret[j].Slot })
+	return ret, nil
+}
+
+// every validator attesting at slot votes for the same data, justifying the previous epoch
+func (s *LocalDutySource) AttestationData(slot Slot, committeeIndex CommitteeIndex) *AttestationData {
+	epoch := SlotEpoch(slot)
+	source := Epoch(0)
+	if epoch > 0 {
+		source = epoch - 1
+	}
+	return &AttestationData{
+		Slot: slot,
+		Index: committeeIndex,
+		BeaconBlockRoot: localRoot("block", slot, 0),
+		Source: &Checkpoint{Epoch: source, Root: localRoot("block", EpochStartSlot(source), 0)},
+		Target: &Checkpoint{Epoch: epoch, Root: localRoot("block", EpochStartSlot(epoch), 0)},
+	}
+}
+
+// a committee of its own
+func (s *LocalDutySource) attestation(slot Slot, validator ValidatorIndex) *Duty {
+	return &Duty{
+		Type: DutyAttestation,
+		Slot: slot,
+		Epoch: SlotEpoch(slot),
+		ValidatorIndex: validator,
+		CommitteeLength: 1,
+		Attestation: s.AttestationData(slot, 0),
+	}
+}
+
+func (s *LocalDutySource) proposal(slot Slot, validator ValidatorIndex) *Duty {
+	parent := Root{}
+	if slot > 0 {
+		parent = localRoot("block", slot - 1, 0)
+	}
+	return &Duty{
+		Type: DutyProposal,
+		Slot: slot,
+		Epoch: SlotEpoch(slot),
+		ValidatorIndex: validator,
+		Block: &BeaconBlockHeader{
+			Slot: slot,
+			ProposerIndex: validator,
+			ParentRoot: parent,
+			StateRoot: localRoot("state", slot, validator),
+			BodyRoot: localRoot("body", slot, validator),
+		},
+	}
+}
+
+func localRoot(label string, a uint64, b uint64) Root {
+	h := sha256.New()
+	h.Write([]byte(label))
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], a)
+	binary.BigEndian.PutUint64(buf[8:], b)
+	h.Write(buf)
+	ret := Root{}
+	copy(ret[:], h.Sum(nil))
+	return ret
+}
@@ -0,0 +1,70 @@
+package eth2
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLocalDutySource(t *testing.T) {
+	source := NewLocalDutySource()
+	proposals := 0
+	for epoch := Epoch(0) ; epoch < 8 ; epoch++ {
+		duties, err := source.Duties(epoch, 3)
+		require.NoError(t, err)
+		again, err := source.Duties(epoch, 3)
+		require.NoError(t, err)
+		require.Equal(t, duties, again)
+
+		types := make(map[DutyType]bool)
+		for i, duty := range duties {
+			require.EqualValues(t, 3, duty.ValidatorIndex)
+			require.Equal(t, epoch, SlotEpoch(duty.Slot))
+			if i > 0 {
+				require.LessOrEqual(t, duties[i - 1].Slot, duty.Slot)
+			}
+			types[duty.Type] = true
+		}
+		require.True(t, types[DutyAttestation])
+		require.True(t, types[DutySelectionProof])
+		require.Equal(t, types[DutyProposal], types[DutyRandao])
+		if types[DutyProposal] {
+			proposals++
+		}
+	}
+	require.Equal(t, 2, proposals)
+
+	_, err := (&LocalDutySource{}).Duties(0, 1)
+	require.Error(t, err)
+}
+
+func TestDutySigningRoots(t *testing.T) {
+	fork := &Fork{Version: Version{0, 0, 0, 1}, GenesisValidatorsRoot: Root{1}}
+	duties, err := NewLocalDutySource().Duties(1, 3)
+	require.NoError(t, err)
+	duties = append(duties, NewVoluntaryExitDuty(&VoluntaryExit{Epoch: 1, ValidatorIndex: 3}))
+
+	roots := make(map[Root]bool)
+	for _, duty := range duties {
+		root, err := fork.SigningRoot(duty)
+		require.NoError(t, err, duty.Type.String())
+		roots[root] = true
+	}
+	require.Len(t, roots, len(duties))
+
+	// the same epoch's RANDAO reveal and selection proof of its first slot sign the same object
+	randao, err := fork.SigningRoot(&Duty{Type: DutyRandao, Epoch: 1})
+	require.NoError(t, err)
+	selection, err := fork.SigningRoot(&Duty{Type: DutySelectionProof, Slot: 1})
+	require.NoError(t, err)
+	require.NotEqual(t, randao, selection)
+
+	// another network
+	root, err := (&Fork{Version: Version{0, 0, 0, 2}, GenesisValidatorsRoot: Root{1}}).SigningRoot(duties[0])
+	require.NoError(t, err)
+	require.False(t, roots[root])
+
+	_, err = fork.SigningRoot(&Duty{Type: DutyAttestation})
+	require.Error(t, err)
+	_, err = fork.SigningRoot(&Duty{Type: DutyType(9)})
+	require.Error(t, err)
+}
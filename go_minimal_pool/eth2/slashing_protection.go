@@ -0,0 +1,519 @@
+package eth2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+	Slashing protection, checked before any (partial) signature over a block or an attestation is released. A pool
+	key signs if a threshold of its members release their partial signatures, every member refusing conflicting
+	data keeps the pool from being slashed as long as a threshold of them are honest.
+	The rules are EIP-3076's, per validator public key:
+		blocks - refuse a block at a slot already signed (unless it's the same signing root) or at a slot lower
+				than the lowest signed one
+		attestations - refuse source > target, a double vote (a target already signed with another signing
+				root), a surrounding or surrounded vote, a source lower than the lowest signed source or a target
+				lower than the lowest signed target
+	Other duties (RANDAO reveals, selection proofs, exits) aren't slashable.
+	A validator's history keeps a block per slot and an attestation per source and target, only its last
+	historyLength blocks and attestations are kept. The pruned ones are replaced by a low watermark, the highest
+	pruned slot, source and target: a block at or below the slot, a source below the source or a target at or below
+	the target are refused, which is all the checks need from them.
+	History is imported from and exported to the interchange format, the watermarks as records without signing
+	roots.
+	https://eips.ethereum.org/EIPS/eip-3076
+ */
+
+const InterchangeFormatVersion = "5"
+
+// the blocks and attestations kept per validator, the file is saved after every recorded signature
+const historyLength = 64
+
+// a refusal to sign slashable data
+type SlashableError struct {
+	Pubkey []byte
+	Duty DutyType
+	Slot Slot
+	Reason string
+}
+
+func (e *SlashableError) Error() string {
+	return fmt.Sprintf("refusing to sign %s at slot %d for %s: %s", e.Duty.String(), e.Slot, shortKey(e.Pubkey), e.Reason)
+}
+
+type signedBlock struct {
+	Slot Slot
+	// nil if imported without one
+	SigningRoot *Root
+}
+
+type signedAttestation struct {
+	SourceEpoch Epoch
+	TargetEpoch Epoch
+	// nil if imported without one
+	SigningRoot *Root
+}
+
+type signingHistory struct {
+	// sorted by slot
+	blocks []*signedBlock
+	// sorted by target and source
+	attestations []*signedAttestation
+	// the highest pruned slot, source and target, nil until pruned
+	blockWatermark *signedBlock
+	attestationWatermark *signedAttestation
+}
+
+type SlashingProtection struct {
+	genesisValidatorsRoot Root
+	// hex pubkey to its history
+	history map[string]*signingHistory
+	// if set, the interchange file the history is saved to after every recorded signature
+	path string
+	lock sync.Mutex
+}
+
+// an in memory slashing protection for the network with genesisValidatorsRoot
+func NewSlashingProtection(genesisValidatorsRoot Root) *SlashingProtection {
+	return &SlashingProtection{
+		genesisValidatorsRoot: genesisValidatorsRoot,
+		history: make(map[string]*signingHistory),
+	}
+}
+
+// a slashing protection kept in an interchange file at path, loaded if it exists
+func LoadSlashingProtection(path string, genesisValidatorsRoot Root) (*SlashingProtection, error) {
+	ret := NewSlashingProtection(genesisValidatorsRoot)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		err = ret.ImportInterchange(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret.path = path
+	return ret, nil
+}
+
+// checks the duty's signing root is safe to sign with pubkey and records it, blocks and attestations only
+func (s *SlashingProtection) CheckAndRecord(pubkey []byte, duty *Duty, signingRoot Root) error {
+	if duty.Type != DutyProposal && duty.Type != DutyAttestation {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	history := s.validatorHistory(encodeHex(pubkey))
+	var reason string
+	var repeat bool
+	switch duty.Type {
+	case DutyProposal:
+		if duty.Block == nil {
+			return fmt.Errorf("proposal duty without block")
+		}
+		reason, repeat = history.checkBlock(duty.Block.Slot, signingRoot)
+		if reason == "" && !repeat {
+			history.addBlock(&signedBlock{Slot: duty.Block.Slot, SigningRoot: &signingRoot})
+		}
+	case DutyAttestation:
+		if duty.Attestation == nil || duty.Attestation.Source == nil || duty.Attestation.Target == nil {
+			return fmt.Errorf("attestation duty without attestation data")
+		}
+		source, target := duty.Attestation.Source.Epoch, duty.Attestation.Target.Epoch
+		reason, repeat = history.checkAttestation(source, target, signingRoot)
+		if reason == "" && !repeat {
+			history.addAttestation(&signedAttestation{SourceEpoch: source, TargetEpoch: target, SigningRoot: &signingRoot})
+		}
+	}
+	if reason != "" {
+		return &SlashableError{Pubkey: pubkey, Duty: duty.Type, Slot: duty.Slot, Reason: reason}
+	}
+	if repeat {
+		return nil
+	}
+	return s.save()
+}
+
+// returns why the block can't be signed, empty if it can. Signing the same block again is safe.
+func (h *signingHistory) checkBlock(slot Slot, signingRoot Root) (string, bool) {
+	if h.blockWatermark != nil && slot <= h.blockWatermark.Slot {
+		return fmt.Sprintf("slot not after the pruned history's slot %d", h.blockWatermark.Slot), false
+	}
+	var minSlot Slot
+	for _, b := range h.blocks {
+		if b.Slot == slot {
+			if b.SigningRoot != nil && *b.SigningRoot == signingRoot {
+				return "", true
+			}
+			return "double proposal", false
+		}
+	}
+	for i, b := range h.blocks {
+		if i == 0 || b.Slot < minSlot {
+			minSlot = b.Slot
+		}
+	}
+	if len(h.blocks) > 0 && slot < minSlot {
+		return fmt.Sprintf("slot lower than the lowest signed slot %d", minSlot), false
+	}
+	return "", false
+}
+
+// returns why the attestation can't be signed, empty if it can. Signing the same attestation again is safe.
+func (h *signingHistory) checkAttestation(source Epoch, target Epoch, signingRoot Root) (string, bool) {
+	if source > target {
+		return fmt.Sprintf("source %d after target %d", source, target), false
+	}
+	if w := h.attestationWatermark; w != nil {
+		if source < w.SourceEpoch {
+			return fmt.Sprintf("source lower than the pruned history's source %d", w.SourceEpoch), false
+		}
+		if target <= w.TargetEpoch {
+			return fmt.Sprintf("target not after the pruned history's target %d", w.TargetEpoch), false
+		}
+	}
+	for _, a := range h.attestations {
+		if a.TargetEpoch == target {
+			if a.SourceEpoch == source && a.SigningRoot != nil && *a.SigningRoot == signingRoot {
+				return "", true
+			}
+			return fmt.Sprintf("double vote for target %d", target), false
+		}
+	}
+	for _, a := range h.attestations {
+		if source < a.SourceEpoch && target > a.TargetEpoch {
+			return fmt.Sprintf("surrounds vote %d -> %d", a.SourceEpoch, a.TargetEpoch), false
+		}
+		if source > a.SourceEpoch && target < a.TargetEpoch {
+			return fmt.Sprintf("surrounded by vote %d -> %d", a.SourceEpoch, a.TargetEpoch), false
+		}
+	}
+	if len(h.attestations) == 0 {
+		return "", false
+	}
+	minSource, minTarget := h.attestations[0].SourceEpoch, h.attestations[0].TargetEpoch
+	for _, a := range h.attestations {
+		if a.SourceEpoch < minSource {
+			minSource = a.SourceEpoch
+		}
+		if a.TargetEpoch < minTarget {
+			minTarget = a.TargetEpoch
+		}
+	}
+	if source < minSource {
+		return fmt.Sprintf("source lower than the lowest signed source %d", minSource), false
+	}
+	// equal targets are double votes or repeats, checked above
+	if target < minTarget {
+		return fmt.Sprintf("target lower than the lowest signed target %d", minTarget), false
+	}
+	return "", false
+}
+
+// adds a block or merges it with the one at its slot, signing roots that differ merge to none so neither is a repeat
+func (h *signingHistory) addBlock(block *signedBlock) {
+	for _, b := range h.blocks {
+		if b.Slot == block.Slot {
+			b.SigningRoot = mergeRoots(b.SigningRoot, block.SigningRoot)
+			return
+		}
+	}
+	h.blocks = append(h.blocks, block)
+	sort.Slice(h.blocks, func(i, j int) bool { return h.blocks[i].Slot < h.blocks[j].Slot })
+	if len(h.blocks) <= historyLength {
+		return
+	}
+	pruned := h.blocks[:len(h.blocks) - historyLength]
+	h.blocks = append([]*signedBlock{}, h.blocks[len(pruned):]...)
+	// sorted, the last pruned block has the highest slot
+	slot := pruned[len(pruned) - 1].Slot
+	if h.blockWatermark == nil || slot > h.blockWatermark.Slot {
+		h.blockWatermark = &signedBlock{Slot: slot}
+	}
+}
+
+// adds an attestation or merges it with the one with its source and target, like addBlock
+func (h *signingHistory) addAttestation(attestation *signedAttestation) {
+	for _, a := range h.attestations {
+		if a.SourceEpoch == attestation.SourceEpoch && a.TargetEpoch == attestation.TargetEpoch {
+			a.SigningRoot = mergeRoots(a.SigningRoot, attestation.SigningRoot)
+			return
+		}
+	}
+	h.attestations = append(h.attestations, attestation)
+	sort.Slice(h.attestations, func(i, j int) bool {
+		if h.attestations[i].TargetEpoch != h.attestations[j].TargetEpoch {
+			return h.attestations[i].TargetEpoch < h.attestations[j].TargetEpoch
+		}
+		return h.attestations[i].SourceEpoch < h.attestations[j].SourceEpoch
+	})
+	if len(h.attestations) <= historyLength {
+		return
+	}
+	pruned := h.attestations[:len(h.attestations) - historyLength]
+	h.attestations = append([]*signedAttestation{}, h.attestations[len(pruned):]...)
+	w := h.attestationWatermark
+	if w == nil {
+		w = &signedAttestation{SourceEpoch: pruned[0].SourceEpoch, TargetEpoch: pruned[0].TargetEpoch}
+		h.attestationWatermark = w
+	}
+	// an imported history may have a pruned attestation surrounding another, the highest source isn't the last one's
+	for _, a := range pruned {
+		if a.SourceEpoch > w.SourceEpoch {
+			w.SourceEpoch = a.SourceEpoch
+		}
+		if a.TargetEpoch > w.TargetEpoch {
+			w.TargetEpoch = a.TargetEpoch
+		}
+	}
+}
+
+func mergeRoots(a *Root, b *Root) *Root {
+	if a == nil || b == nil || *a != *b {
+		return nil
+	}
+	return a
+}
+
+// callers hold the lock
+func (s *SlashingProtection) validatorHistory(key string) *signingHistory {
+	ret, found := s.history[key]
+	if !found {
+		ret = &signingHistory{}
+		s.history[key] = ret
+	}
+	return ret
+}
+
+// callers hold the lock, the file is replaced atomically
+func (s *SlashingProtection) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := s.exportInterchange()
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return fmt.Errorf("could not save slashing protection: %s", err.Error())
+	}
+	return os.Rename(tmp, s.path)
+}
+
+/**
+	EIP-3076 interchange format (complete), integers are decimal strings and bytes 0x prefixed hex.
+ */
+
+type interchange struct {
+	Metadata interchangeMetadata `json:"metadata"`
+	Data []*interchangeValidator `json:"data"`
+}
+
+type interchangeMetadata struct {
+	InterchangeFormatVersion string `json:"interchange_format_version"`
+	GenesisValidatorsRoot string `json:"genesis_validators_root"`
+}
+
+type interchangeValidator struct {
+	Pubkey string `json:"pubkey"`
+	SignedBlocks []*interchangeBlock `json:"signed_blocks"`
+	SignedAttestations []*interchangeAttestation `json:"signed_attestations"`
+}
+
+type interchangeBlock struct {
+	Slot string `json:"slot"`
+	SigningRoot string `json:"signing_root,omitempty"`
+}
+
+type interchangeAttestation struct {
+	SourceEpoch string `json:"source_epoch"`
+	TargetEpoch string `json:"target_epoch"`
+	SigningRoot string `json:"signing_root,omitempty"`
+}
+
+func (s *SlashingProtection) ExportInterchange() ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.exportInterchange()
+}
+
+// callers hold the lock
+func (s *SlashingProtection) exportInterchange() ([]byte, error) {
+	ret := &interchange{
+		Metadata: interchangeMetadata{
+			InterchangeFormatVersion: InterchangeFormatVersion,
+			GenesisValidatorsRoot: encodeHex(s.genesisValidatorsRoot[:]),
+		},
+		Data: make([]*interchangeValidator, 0),
+	}
+
+	keys := make([]string, 0)
+	for key := range s.history {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		history := s.history[key]
+		validator := &interchangeValidator{
+			Pubkey: key,
+			SignedBlocks: make([]*interchangeBlock, 0),
+			SignedAttestations: make([]*interchangeAttestation, 0),
+		}
+		blocks := history.blocks
+		if history.blockWatermark != nil {
+			blocks = append([]*signedBlock{history.blockWatermark}, blocks...)
+		}
+		for _, b := range blocks {
+			validator.SignedBlocks = append(validator.SignedBlocks, &interchangeBlock{
+				Slot: strconv.FormatUint(b.Slot, 10),
+				SigningRoot: encodeRoot(b.SigningRoot),
+			})
+		}
+		attestations := history.attestations
+		if history.attestationWatermark != nil {
+			attestations = append([]*signedAttestation{history.attestationWatermark}, attestations...)
+		}
+		for _, a := range attestations {
+			validator.SignedAttestations = append(validator.SignedAttestations, &interchangeAttestation{
+				SourceEpoch: strconv.FormatUint(a.SourceEpoch, 10),
+				TargetEpoch: strconv.FormatUint(a.TargetEpoch, 10),
+				SigningRoot: encodeRoot(a.SigningRoot),
+			})
+		}
+		ret.Data = append(ret.Data, validator)
+	}
+	return json.MarshalIndent(ret, "", "  ")
+}
+
+// merges an interchange file's history, the file must be for the same network. Records already in the history
+// aren't duplicated and the merged history is pruned.
+func (s *SlashingProtection) ImportInterchange(data []byte) error {
+	file := &interchange{}
+	err := json.Unmarshal(data, file)
+	if err != nil {
+		return fmt.Errorf("could not parse slashing protection interchange: %s", err.Error())
+	}
+	if file.Metadata.InterchangeFormatVersion != InterchangeFormatVersion {
+		return fmt.Errorf("unsupported interchange format version %s", file.Metadata.InterchangeFormatVersion)
+	}
+	gvr, err := decodeHex(file.Metadata.GenesisValidatorsRoot, 32)
+	if err != nil {
+		return fmt.Errorf("invalid genesis validators root: %s", err.Error())
+	}
+	if !bytes.Equal(gvr, s.genesisValidatorsRoot[:]) {
+		return fmt.Errorf("interchange is for genesis validators root %s, not this network's", file.Metadata.GenesisValidatorsRoot)
+	}
+
+	// decode everything before merging, a broken file doesn't leave a partial import behind
+	imported := make(map[string]*signingHistory)
+	for _, validator := range file.Data {
+		pubkey, err := decodeHex(validator.Pubkey, 48)
+		if err != nil {
+			return fmt.Errorf("invalid pubkey %s: %s", validator.Pubkey, err.Error())
+		}
+		key := encodeHex(pubkey)
+		history, found := imported[key]
+		if !found {
+			history = &signingHistory{}
+			imported[key] = history
+		}
+		for _, b := range validator.SignedBlocks {
+			slot, err := strconv.ParseUint(b.Slot, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid block slot %s: %s", b.Slot, err.Error())
+			}
+			root, err := decodeRoot(b.SigningRoot)
+			if err != nil {
+				return err
+			}
+			history.blocks = append(history.blocks, &signedBlock{Slot: slot, SigningRoot: root})
+		}
+		for _, a := range validator.SignedAttestations {
+			source, err := strconv.ParseUint(a.SourceEpoch, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid source epoch %s: %s", a.SourceEpoch, err.Error())
+			}
+			target, err := strconv.ParseUint(a.TargetEpoch, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid target epoch %s: %s", a.TargetEpoch, err.Error())
+			}
+			root, err := decodeRoot(a.SigningRoot)
+			if err != nil {
+				return err
+			}
+			history.attestations = append(history.attestations, &signedAttestation{SourceEpoch: source, TargetEpoch: target, SigningRoot: root})
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for key, history := range imported {
+		existing := s.validatorHistory(key)
+		for _, b := range history.blocks {
+			existing.addBlock(b)
+		}
+		for _, a := range history.attestations {
+			existing.addAttestation(a)
+		}
+	}
+	return s.save()
+}
+
+func encodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func decodeHex(s string, length int) ([]byte, error) {
+	ret, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s), "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) != length {
+		return nil, fmt.Errorf("expected %d bytes, got %d", length, len(ret))
+	}
+	return ret, nil
+}
+
+func encodeRoot(root *Root) string {
+	if root == nil {
+		return ""
+	}
+	return encodeHex(root[:])
+}
+
+func decodeRoot(s string) (*Root, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := decodeHex(s, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing root %s: %s", s, err.Error())
+	}
+	ret := Root{}
+	copy(ret[:], b)
+	return &ret, nil
+}
+
+func shortKey(pubkey []byte) string {
+	ret := encodeHex(pubkey)
+	if len(ret) > 12 {
+		return ret[:12] + ".."
+	}
+	return ret
+}
@@ -0,0 +1,212 @@
+package eth2
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testPubkey = make([]byte, 48)
+var testGenesisValidatorsRoot = Root{1, 2, 3}
+
+func testAttestation(slot Slot, source Epoch, target Epoch, block byte) *Duty {
+	return &Duty{
+		Type: DutyAttestation,
+		Slot: slot,
+		Epoch: SlotEpoch(slot),
+		Attestation: &AttestationData{
+			Slot: slot,
+			BeaconBlockRoot: Root{block},
+			Source: &Checkpoint{Epoch: source},
+			Target: &Checkpoint{Epoch: target},
+		},
+	}
+}
+
+func testProposal(slot Slot, body byte) *Duty {
+	return &Duty{
+		Type: DutyProposal,
+		Slot: slot,
+		Epoch: SlotEpoch(slot),
+		Block: &BeaconBlockHeader{Slot: slot, BodyRoot: Root{body}},
+	}
+}
+
+func checkAndRecord(s *SlashingProtection, duty *Duty) error {
+	fork := &Fork{GenesisValidatorsRoot: testGenesisValidatorsRoot}
+	root, err := fork.SigningRoot(duty)
+	if err != nil {
+		return err
+	}
+	return s.CheckAndRecord(testPubkey, duty, root)
+}
+
+func requireSlashable(t *testing.T, err error) {
+	require.Error(t, err)
+	_, ok := err.(*SlashableError)
+	require.True(t, ok, err.Error())
+}
+
+func TestSlashableProposals(t *testing.T) {
+	s := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, checkAndRecord(s, testProposal(10, 1)))
+	// a repeat is safe
+	require.NoError(t, checkAndRecord(s, testProposal(10, 1)))
+	requireSlashable(t, checkAndRecord(s, testProposal(10, 2)))
+	requireSlashable(t, checkAndRecord(s, testProposal(9, 1)))
+	require.NoError(t, checkAndRecord(s, testProposal(11, 1)))
+
+	// per pubkey
+	other := make([]byte, 48)
+	other[0] = 1
+	duty := testProposal(10, 2)
+	root, err := (&Fork{}).SigningRoot(duty)
+	require.NoError(t, err)
+	require.NoError(t, s.CheckAndRecord(other, duty, root))
+}
+
+func TestSlashableAttestations(t *testing.T) {
+	s := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, checkAndRecord(s, testAttestation(96, 2, 3, 1)))
+	require.NoError(t, checkAndRecord(s, testAttestation(96, 2, 3, 1)))
+	// double vote
+	requireSlashable(t, checkAndRecord(s, testAttestation(97, 2, 3, 2)))
+	requireSlashable(t, checkAndRecord(s, testAttestation(160, 5, 4, 1)))
+	require.NoError(t, checkAndRecord(s, testAttestation(192, 3, 6, 1)))
+	// surrounds 3 -> 6
+	requireSlashable(t, checkAndRecord(s, testAttestation(224, 2, 7, 1)))
+	// surrounded by 3 -> 6
+	requireSlashable(t, checkAndRecord(s, testAttestation(160, 4, 5, 1)))
+	// lower than the lowest source and target
+	requireSlashable(t, checkAndRecord(s, testAttestation(64, 1, 2, 1)))
+	require.NoError(t, checkAndRecord(s, testAttestation(224, 6, 7, 1)))
+
+	err := checkAndRecord(s, testAttestation(97, 2, 3, 2))
+	require.Contains(t, err.Error(), "double vote")
+}
+
+func TestNotSlashableDuties(t *testing.T) {
+	s := NewSlashingProtection(testGenesisValidatorsRoot)
+	for i := 0 ; i < 2 ; i++ {
+		require.NoError(t, checkAndRecord(s, &Duty{Type: DutyRandao, Slot: 32, Epoch: 1}))
+		require.NoError(t, checkAndRecord(s, &Duty{Type: DutySelectionProof, Slot: 33, Epoch: 1}))
+		require.NoError(t, checkAndRecord(s, NewVoluntaryExitDuty(&VoluntaryExit{Epoch: 1, ValidatorIndex: 2})))
+	}
+	data, err := s.ExportInterchange()
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"data": []`)
+}
+
+func TestInterchange(t *testing.T) {
+	s := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, checkAndRecord(s, testProposal(10, 1)))
+	require.NoError(t, checkAndRecord(s, testAttestation(192, 3, 6, 1)))
+	data, err := s.ExportInterchange()
+	require.NoError(t, err)
+
+	imported := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, imported.ImportInterchange(data))
+	requireSlashable(t, checkAndRecord(imported, testProposal(10, 2)))
+	requireSlashable(t, checkAndRecord(imported, testAttestation(224, 2, 7, 1)))
+	require.NoError(t, checkAndRecord(imported, testAttestation(192, 3, 6, 1)))
+	again, err := imported.ExportInterchange()
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+
+	// another network's history
+	require.Error(t, NewSlashingProtection(Root{4}).ImportInterchange(data))
+
+	// entries without signing roots are never repeats
+	minimal := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, minimal.ImportInterchange([]byte(`{
+		"metadata": {
+			"interchange_format_version": "5",
+			"genesis_validators_root": "0x0102030000000000000000000000000000000000000000000000000000000000"
+		},
+		"data": [{
+			"pubkey": "0x000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+			"signed_blocks": [{"slot": "10"}],
+			"signed_attestations": [{"source_epoch": "3", "target_epoch": "6"}]
+		}]
+	}`)))
+	requireSlashable(t, checkAndRecord(minimal, testProposal(10, 1)))
+	requireSlashable(t, checkAndRecord(minimal, testAttestation(192, 3, 6, 1)))
+
+	require.Error(t, minimal.ImportInterchange([]byte(`{"metadata": {"interchange_format_version": "4"}}`)))
+}
+
+func TestPersistentSlashingProtection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slashing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slashing_protection.json")
+
+	s, err := LoadSlashingProtection(path, testGenesisValidatorsRoot)
+	require.NoError(t, err)
+	require.NoError(t, checkAndRecord(s, testAttestation(192, 3, 6, 1)))
+
+	s, err = LoadSlashingProtection(path, testGenesisValidatorsRoot)
+	require.NoError(t, err)
+	requireSlashable(t, checkAndRecord(s, testAttestation(192, 3, 6, 2)))
+
+	_, err = LoadSlashingProtection(path, Root{4})
+	require.Error(t, err)
+}
+
+func TestInterchangeImportedTwice(t *testing.T) {
+	s := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, checkAndRecord(s, testProposal(10, 1)))
+	require.NoError(t, checkAndRecord(s, testAttestation(192, 3, 6, 1)))
+	data, err := s.ExportInterchange()
+	require.NoError(t, err)
+
+	require.NoError(t, s.ImportInterchange(data))
+	require.NoError(t, s.ImportInterchange(data))
+	again, err := s.ExportInterchange()
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+	// still a repeat
+	require.NoError(t, checkAndRecord(s, testProposal(10, 1)))
+
+	// the same slot with another signing root is no repeat of either
+	other := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, checkAndRecord(other, testProposal(10, 2)))
+	data, err = other.ExportInterchange()
+	require.NoError(t, err)
+	require.NoError(t, s.ImportInterchange(data))
+	requireSlashable(t, checkAndRecord(s, testProposal(10, 1)))
+	requireSlashable(t, checkAndRecord(s, testProposal(10, 2)))
+}
+
+func TestPrunedHistory(t *testing.T) {
+	s := NewSlashingProtection(testGenesisValidatorsRoot)
+	// gaps of 2 epochs leave room for surrounded votes below the last pruned target
+	for i := uint64(1) ; i <= historyLength + 10 ; i++ {
+		require.NoError(t, checkAndRecord(s, testProposal(i * 2, 1)))
+		require.NoError(t, checkAndRecord(s, testAttestation(i * 64, i * 2 - 1, i * 2, 1)))
+	}
+	history := s.history[encodeHex(testPubkey)]
+	require.Len(t, history.blocks, historyLength)
+	require.Len(t, history.attestations, historyLength)
+	require.EqualValues(t, 20, history.blockWatermark.Slot)
+	require.EqualValues(t, 19, history.attestationWatermark.SourceEpoch)
+	require.EqualValues(t, 20, history.attestationWatermark.TargetEpoch)
+
+	// a pruned block and a slot between pruned ones
+	requireSlashable(t, checkAndRecord(s, testProposal(20, 1)))
+	requireSlashable(t, checkAndRecord(s, testProposal(19, 1)))
+	// surrounded by and surrounding pruned votes
+	requireSlashable(t, checkAndRecord(s, testAttestation(1, 10, 11, 1)))
+	requireSlashable(t, checkAndRecord(s, testAttestation(1, 8, 200, 1)))
+	require.NoError(t, checkAndRecord(s, testProposal(200, 1)))
+
+	// the watermarks survive an export
+	data, err := s.ExportInterchange()
+	require.NoError(t, err)
+	imported := NewSlashingProtection(testGenesisValidatorsRoot)
+	require.NoError(t, imported.ImportInterchange(data))
+	requireSlashable(t, checkAndRecord(imported, testProposal(19, 1)))
+	requireSlashable(t, checkAndRecord(imported, testAttestation(1, 8, 200, 1)))
+}
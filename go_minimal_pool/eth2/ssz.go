@@ -0,0 +1,116 @@
+package eth2
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+/**
+	The bits of SSZ merkleization the signed objects and the pool chain's state need:
+		uint64 - little endian, right padded with zeros
+		boolean - 1 or 0, right padded with zeros
+		Bytes4 - right padded with zeros
+		Bytes32/ Root - as is
+		ByteVector[N] - packed into 32 bytes chunks (the last one right padded with zeros) and merkleized
+		container - the merkle root of its fields' roots, padded with zero chunks to the next power of two
+		List[T, limit] of containers - the merkle root of the elements' roots padded with zero chunks to the limit's
+				next power of two, mixed in with the list's length
+	https://github.com/ethereum/eth2.0-specs/blob/dev/ssz/simple-serialize.md#merkleization
+ */
+
+// deep enough for lists of up to 2^maxListDepth elements
+const maxListDepth = 32
+
+// zeroHashes[i] is the root of a zero filled tree of depth i
+var zeroHashes = func() []Root {
+	ret := make([]Root, maxListDepth + 1)
+	for i := 1 ; i <= maxListDepth ; i++ {
+		ret[i] = hashPair(ret[i - 1], ret[i - 1])
+	}
+	return ret
+}()
+
+func Uint64Root(v uint64) Root {
+	ret := Root{}
+	binary.LittleEndian.PutUint64(ret[:], v)
+	return ret
+}
+
+func BoolRoot(b bool) Root {
+	ret := Root{}
+	if b {
+		ret[0] = 1
+	}
+	return ret
+}
+
+// the root of a ByteVector, e.g. a 48 bytes BLS public key or a 96 bytes signature
+func BytesRoot(b []byte) Root {
+	chunks := make([]Root, (len(b) + 31) / 32)
+	for i := range chunks {
+		copy(chunks[i][:], b[i * 32:])
+	}
+	return Merkleize(chunks...)
+}
+
+func bytes4Root(b [4]byte) Root {
+	ret := Root{}
+	copy(ret[:], b[:])
+	return ret
+}
+
+// the merkle root of the chunks, padded with zero chunks to the next power of two
+func Merkleize(chunks ...Root) Root {
+	if len(chunks) == 0 {
+		return Root{}
+	}
+	size := 1
+	for size < len(chunks) {
+		size *= 2
+	}
+	layer := make([]Root, size)
+	copy(layer, chunks)
+
+	for len(layer) > 1 {
+		next := make([]Root, len(layer) / 2)
+		for i := range next {
+			next[i] = hashPair(layer[2 * i], layer[2 * i + 1])
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+// the root of a list of up to limit (at most 2^32) elements with the given roots
+func ListRoot(roots []Root, limit uint64) Root {
+	depth := 0
+	for uint64(1) << uint(depth) < limit {
+		depth++
+	}
+	layer := roots
+	for d := 0 ; d < depth ; d++ {
+		next := make([]Root, (len(layer) + 1) / 2)
+		for i := range next {
+			right := zeroHashes[d]
+			if 2 * i + 1 < len(layer) {
+				right = layer[2 * i + 1]
+			}
+			next[i] = hashPair(layer[2 * i], right)
+		}
+		layer = next
+	}
+	root := zeroHashes[depth]
+	if len(layer) > 0 {
+		root = layer[0]
+	}
+	return hashPair(root, Uint64Root(uint64(len(roots))))
+}
+
+func hashPair(a Root, b Root) Root {
+	h := sha256.New()
+	h.Write(a[:])
+	h.Write(b[:])
+	ret := Root{}
+	copy(ret[:], h.Sum(nil))
+	return ret
+}
@@ -0,0 +1,81 @@
+package eth2
+
+/**
+	Phase 0 beacon chain types a pool signs as a validator.
+	https://github.com/ethereum/eth2.0-specs/blob/dev/specs/phase0/beacon-chain.md#containers
+ */
+
+const SlotsPerEpoch = uint64(32)
+
+type Slot = uint64
+type Epoch = uint64
+type ValidatorIndex = uint64
+type CommitteeIndex = uint64
+type Root [32]byte
+type Version [4]byte
+type DomainType [4]byte
+type Domain [32]byte
+
+func EpochStartSlot(epoch Epoch) Slot {
+	return epoch * SlotsPerEpoch
+}
+
+func SlotEpoch(slot Slot) Epoch {
+	return slot / SlotsPerEpoch
+}
+
+type Checkpoint struct {
+	Epoch Epoch
+	Root Root
+}
+
+func (c *Checkpoint) HashTreeRoot() Root {
+	return Merkleize(Uint64Root(c.Epoch), c.Root)
+}
+
+type AttestationData struct {
+	Slot Slot
+	Index CommitteeIndex
+	BeaconBlockRoot Root
+	Source *Checkpoint
+	Target *Checkpoint
+}
+
+func (a *AttestationData) HashTreeRoot() Root {
+	return Merkleize(
+		Uint64Root(a.Slot),
+		Uint64Root(a.Index),
+		a.BeaconBlockRoot,
+		a.Source.HashTreeRoot(),
+		a.Target.HashTreeRoot(),
+		)
+}
+
+// a proposed block is signed through its header, a block's and its header's roots are equal
+type BeaconBlockHeader struct {
+	Slot Slot
+	ProposerIndex ValidatorIndex
+	ParentRoot Root
+	StateRoot Root
+	BodyRoot Root
+}
+
+func (b *BeaconBlockHeader) HashTreeRoot() Root {
+	return Merkleize(
+		Uint64Root(b.Slot),
+		Uint64Root(b.ProposerIndex),
+		b.ParentRoot,
+		b.StateRoot,
+		b.BodyRoot,
+		)
+}
+
+type VoluntaryExit struct {
+	// the earliest epoch the exit can be processed in
+	Epoch Epoch
+	ValidatorIndex ValidatorIndex
+}
+
+func (e *VoluntaryExit) HashTreeRoot() Root {
+	return Merkleize(Uint64Root(e.Epoch), Uint64Root(e.ValidatorIndex))
+}
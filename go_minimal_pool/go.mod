@@ -8,8 +8,12 @@ require (
 	github.com/herumi/bls v0.0.0-20200625022801-30fdb2875ad3 // indirect
 	github.com/herumi/bls-eth-go-binary v0.0.0-20200624084043-9b7da5962ccb
 	github.com/stretchr/testify v1.6.1
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/text v0.3.3
 	golang.org/x/tools v0.0.0-20200626171337-aa94e735be7f // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
 	google.golang.org/grpc v1.30.0
 	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
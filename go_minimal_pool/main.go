@@ -1,48 +1,81 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/participant"
 	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/gossip"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/grpc_net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/simple_net"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
-	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
-	"github.com/herumi/bls-eth-go-binary/bls"
 	"log"
+	"sync"
+	"time"
 )
 
 var participants []*participant.Participant
 
 func main() {
+	useGrpc := flag.Bool("grpc", false, "connect the participants over gRPC (localhost) instead of in process")
+	useGossip := flag.Bool("gossip", false, "connect the participants in a ring and gossip messages")
+	networkPath := flag.String("network", "", "network config file (.yaml or .json), the test network if empty")
+	flag.Parse()
+
 	crypto.InitBLS()
 	log.SetFlags(log.Lmicroseconds)
 
 	config := net.NewTestNetworkConfig()
-	participants = make([]*participant.Participant, config.TotalNumberOfParticipants())
-
-	// simulate epoch 0 and get initial pool assignments
-	seed,err := crypto.MixSeed(config.GenesisSeed, 0)
-	if err != nil {
-		log.Fatalf(err.Error())
+	if *networkPath != "" {
+		loaded, err := net.LoadNetworkConfig(*networkPath)
+		if err != nil {
+			log.Fatalf("could not load network config: %s", err.Error())
+		}
+		config = loaded
 	}
-	epoch := state.NewEpochInstance(0, seed)
-	poolData,err := epoch.PoolsParticipantIds()
-	if err != nil {
-		log.Fatalf(err.Error())
+	participants = make([]*participant.Participant, 0)
+
+	// create participants and their nodes
+	for _, id := range config.ParticipantIndexesList() {
+		p := participant.NewParticipant(id)
+		var transport net.Transport = simple_net.NewSimpleP2P()
+		if *useGrpc {
+			network, err := grpc_net.NewGrpcP2P("127.0.0.1:0")
+			if err != nil {
+				log.Fatalf("P %d could not listen: %s", id, err.Error())
+			}
+			transport = network
+		}
+		var network net.P2P = transport
+		if *useGossip {
+			network = gossip.NewGossipP2P(transport, gossip.DefaultConfig())
+		}
+		p.SetNode(pool_chain.NewChainNode(config, state.NewInMemoryState(config), network))
+		participants = append(participants, p)
 	}
 
-	// initial DKG for pools
-	participants = runDKGForPools(poolData, config.PoolThreshold - 1)
+	// register every participant's encryption and identity keys
+	for _, p1 := range participants {
+		for _, p2 := range participants {
+			err := p1.Node.State.SaveParticipant(state.NewParticipant(p2.Id, p2.EncryptionPk, p2.IdentityPk))
+			if err != nil {
+				log.Fatalf("P %d could not register %d: %s", p1.Id, p2.Id, err.Error())
+			}
+		}
+	}
 
 	// connect pools to each other
-	for i, p1 := range participants {
-		p1.Node.Net.AddPeer(p1.Node.Net.OwnPeer()) // add to self to receive shares
-		for j := i+1 ; j < len(participants) ; j ++  {
-			p2 := participants[j]
-			net.BiDirectionalConnection(p1.Node.Net,p2.Node.Net)
-		}
+	if *useGossip {
+		connectRing()
+	} else {
+		connectFullMesh()
 	}
+
+	// initial DKG for pools, over the network
+	runGenesisDKG()
+
 	// start epoch processing
 	for _, p := range participants {
 		p.StartEpochProcessing()
@@ -59,63 +92,42 @@ func main() {
 	}
 }
 
-// will run a DKG for every pool inputed, creates the participant and it's node, generated pool shared secret
-// and sets pool data as well
-func runDKGForPools(poolData map[shared.PoolId][]shared.ParticipantId, threshold shared.PoolSize) []*participant.Participant {
-	ret := make([]*participant.Participant,0)
-	pools := make([]*state.Pool, len(poolData))
-	i := 0
-	for poolId, poolParticipants := range poolData {
-		sks, pk, err := runDKGForParticipants(threshold - 1, poolParticipants)
-		if err != nil {
-			log.Fatalf(err.Error())
-		}
-
-		log.Printf("pool %d:", poolId)
-		for k, v := range sks {
-			log.Printf("		p %d, sk: %s", k, v.GetString(10))
-
-			// create the participant
-			p := participant.NewParticipant(k)
-			n := pool_chain.NewTestChainNode()
-			p.SetNode(n)
-			// set secret
-			e := n.State.GetEpoch(0)
-			e.ParticipantShare = v
-			n.State.SaveEpoch(e)
-
-			ret = append(ret, p)
+func connectFullMesh() {
+	for i, p1 := range participants {
+		p1.Node.Net.AddPeer(p1.Node.Net.OwnPeer()) // add to self to receive shares
+		for j := i+1 ; j < len(participants) ; j ++  {
+			p2 := participants[j]
+			net.BiDirectionalConnection(p1.Node.Net,p2.Node.Net)
 		}
-
-		// create pool data
-		pools[i] = state.NewPool(poolId, threshold, pk)
-		i++
 	}
+}
 
-	// for each participant save pool data
-	for _, p := range ret {
-		for _, pool := range pools {
-			p.Node.State.SavePool(pool)
-		}
+// gossip delivers own messages locally and relays messages between participants that are not connected
+func connectRing() {
+	for i, p1 := range participants {
+		p2 := participants[(i + 1) % len(participants)]
+		net.BiDirectionalConnection(p1.Node.Net, p2.Node.Net)
 	}
-
-	return ret
 }
 
-func runDKGForParticipants(degree shared.PoolSize, indexes []shared.ParticipantId) (map[shared.ParticipantId]*bls.Fr, *bls.PublicKey, error) {
-	dkg,err := crypto.NewDKG(degree, indexes)
-	if err != nil {
-		return nil, nil, err
+// every participant runs the genesis DKG for its pool, returns once every node agreed on all pools' public keys
+func runGenesisDKG() {
+	wg := sync.WaitGroup{}
+	for _, p := range participants {
+		wg.Add(1)
+		go func(p *participant.Participant) {
+			defer wg.Done()
+			err := p.RunGenesisDKG()
+			if err != nil {
+				log.Fatalf("P %d genesis DKG failed: %s", p.Id, err.Error())
+			}
+		}(p)
 	}
+	wg.Wait()
 
-	sks, err := dkg.GroupSecrets(indexes)
-	if err != nil {
-		return nil, nil, err
-	}
-	pk,err := dkg.GroupPK(sks)
-	if err != nil {
-		return nil, nil, err
+	for _, p := range participants {
+		for !p.Node.GenesisReady() {
+			<- time.After(time.Millisecond * 100)
+		}
 	}
-
-	return sks, pk, nil
 }
@@ -0,0 +1,192 @@
+package participant
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
+	"sort"
+)
+
+// the contributions of the epoch's participants (see state/rewards.go) that arrived by the epoch's end, sorted by
+// participant. A share is judged by its redistribution commitments, partial signatures by the signer's public
+// share. Participants whose public share we don't know are left out, we can't tell. A duty the signer refused
+// (pool_chain.IsRefusal) isn't due, refusing them all leaves the signatures not due.
+func (p *Participant) epochContributions(epoch *state.Epoch, pools map[shared.PoolId][]shared.ParticipantId) ([]*state.Contribution, error) {
+	nextPools, err := p.Node.State.GetEpoch(epoch.Number + 1).PoolsParticipantIds()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch next epoch's pools: %s", err.Error())
+	}
+
+	ret := make([]*state.Contribution, 0)
+	for _, poolId := range poolIds(pools) {
+		roots,err := p.poolSigningRoots(poolId, epoch.Number)
+		if err != nil {
+			log.Printf("P %d, %s", p.Id, err.Error())
+			continue
+		}
+		for _, id := range pools[poolId] {
+			c := p.memberContribution(epoch, nextPools, poolId, roots, id)
+			if c != nil {
+				ret = append(ret, c)
+			}
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ParticipantId < ret[j].ParticipantId })
+	return ret, nil
+}
+
+// the contribution of one of the pool's members, nil if we don't know its public share
+func (p *Participant) memberContribution(epoch *state.Epoch, nextPools map[shared.PoolId][]shared.ParticipantId, poolId shared.PoolId, roots []eth2.Root, id shared.ParticipantId) *state.Contribution {
+	publicShare, found := epoch.PublicShare(id)
+	if !found {
+		return nil
+	}
+	c := &state.Contribution{ParticipantId: id, Share: state.ContributionNotDue, Signatures: state.ContributionNotDue}
+
+	// a liquidating pool isn't redistributed
+	if target, rotates := nextPools[poolId]; rotates {
+		c.Share = state.ContributionValid
+		commitments := p.Node.DealerCommitments(epoch.Number, id)
+		if commitments == nil {
+			c.Share = state.ContributionMissing
+		} else if _, err := p.verifiedDealerCommitments(epoch, id, commitments, len(target)); err != nil {
+			c.Share = state.ContributionInvalid
+		}
+	}
+
+	if len(roots) > 0 {
+		c.Signatures = state.ContributionValid
+	}
+	refused := 0
+	for _, root := range roots {
+		partial := p.partialSig(epoch, poolId, id, root[:])
+		if partial == nil {
+			if c.Signatures == state.ContributionValid {
+				c.Signatures = state.ContributionMissing
+			}
+			continue
+		}
+		if pool_chain.IsRefusal(partial) {
+			refused++
+			continue
+		}
+		sig := &bls.G2{}
+		err := sig.Deserialize(partial.Sig)
+		if err != nil || !crypto.VerifyPartialSig(publicShare, sig, root[:]) {
+			c.Signatures = state.ContributionInvalid
+		}
+	}
+	if len(roots) > 0 && refused == len(roots) {
+		c.Signatures = state.ContributionNotDue
+	}
+	return c
+}
+
+// the signer's first partial sig (or refusal) of the pool's signing root, nil if none arrived
+func (p *Participant) partialSig(epoch *state.Epoch, poolId shared.PoolId, signer shared.ParticipantId, root []byte) *pb.SignatureDistribution {
+	for _, v := range p.Node.EpochSigs(epoch.Number) {
+		if v.PoolId == poolId && v.FromParticipant.Id == signer && bytes.Equal(v.SigningRoot, root) {
+			return v
+		}
+	}
+	return nil
+}
+
+// checks a proposed block's contributions against ours. Statuses we judged valid or not due by the epoch's end
+// (own's, built then like the proposer's, or the ones we hold now without it) must be the block's, a valid one
+// can't be left out. The proposer could have received later messages than us, a missing status of ours doesn't
+// bind it. An invalid status needs the invalid signed message, we must hold it.
+func (p *Participant) verifyContributions(epoch *state.Epoch, pools map[shared.PoolId][]shared.ParticipantId, own *state.Block, contributions []*state.Contribution) error {
+	var judged []*state.Contribution
+	if own != nil {
+		judged = own.Body.Contributions
+	} else {
+		var err error
+		judged, err = p.epochContributions(epoch, pools)
+		if err != nil {
+			return err
+		}
+	}
+	judgedById := contributionsById(judged)
+
+	listed := make(map[shared.ParticipantId]bool)
+	for _, c := range contributions {
+		listed[c.ParticipantId] = true
+		j := judgedById[c.ParticipantId]
+		if j == nil {
+			j = &state.Contribution{ParticipantId: c.ParticipantId, Share: state.ContributionMissing, Signatures: state.ContributionMissing}
+		}
+		// only an invalid status we didn't judge is worth checking against the messages we hold now
+		held := j
+		if (c.Share == state.ContributionInvalid && j.Share != state.ContributionInvalid) || (c.Signatures == state.ContributionInvalid && j.Signatures != state.ContributionInvalid) {
+			var err error
+			held, err = p.heldContribution(epoch, pools, c.ParticipantId)
+			if err != nil {
+				return err
+			}
+		}
+		err := verifyContributionStatus(c.ParticipantId, "share", c.Share, j.Share, held.Share)
+		if err != nil {
+			return err
+		}
+		err = verifyContributionStatus(c.ParticipantId, "signatures", c.Signatures, j.Signatures, held.Signatures)
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range judged {
+		if !listed[c.ParticipantId] && (c.Share == state.ContributionValid || c.Signatures == state.ContributionValid) {
+			return fmt.Errorf("participant %d's valid contribution is left out", c.ParticipantId)
+		}
+	}
+	return nil
+}
+
+// the participant's contribution by the messages we hold now, missing if we can't tell
+func (p *Participant) heldContribution(epoch *state.Epoch, pools map[shared.PoolId][]shared.ParticipantId, id shared.ParticipantId) (*state.Contribution, error) {
+	nextPools, err := p.Node.State.GetEpoch(epoch.Number + 1).PoolsParticipantIds()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch next epoch's pools: %s", err.Error())
+	}
+	for poolId, members := range pools {
+		for _, member := range members {
+			if member != id {
+				continue
+			}
+			roots,err := p.poolSigningRoots(poolId, epoch.Number)
+			if err != nil {
+				return nil, err
+			}
+			if c := p.memberContribution(epoch, nextPools, poolId, roots, id); c != nil {
+				return c, nil
+			}
+		}
+	}
+	return &state.Contribution{ParticipantId: id, Share: state.ContributionMissing, Signatures: state.ContributionMissing}, nil
+}
+
+func verifyContributionStatus(id shared.ParticipantId, kind string, status state.ContributionStatus, judged state.ContributionStatus, held state.ContributionStatus) error {
+	if (judged == state.ContributionValid || judged == state.ContributionNotDue) && status != judged {
+		return fmt.Errorf("participant %d's %s %s, we judged it %s", id, kind, status.String(), judged.String())
+	}
+	if status == state.ContributionInvalid && held != state.ContributionInvalid {
+		return fmt.Errorf("participant %d's %s invalid, we hold no invalid one", id, kind)
+	}
+	return nil
+}
+
+func contributionsById(contributions []*state.Contribution) map[shared.ParticipantId]*state.Contribution {
+	ret := make(map[shared.ParticipantId]*state.Contribution)
+	for _, c := range contributions {
+		ret[c.ParticipantId] = c
+	}
+	return ret
+}
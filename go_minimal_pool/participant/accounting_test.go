@@ -0,0 +1,130 @@
+package participant
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// the proposer's block with one of its contributions changed, its state root recomputed
+func withContribution(t *testing.T, proposer *Participant, block *state.Block, c *state.Contribution) []byte {
+	body := *block.Body
+	body.Contributions = make([]*state.Contribution, 0)
+	for _, v := range block.Body.Contributions {
+		if v.ParticipantId == c.ParticipantId {
+			v = c
+		}
+		body.Contributions = append(body.Contributions, v)
+	}
+	return withBody(t, proposer, block, &body)
+}
+
+func withDisqualified(t *testing.T, proposer *Participant, block *state.Block, ids []shared.ParticipantId) []byte {
+	body := *block.Body
+	body.Disqualified = ids
+	return withBody(t, proposer, block, &body)
+}
+
+func withBody(t *testing.T, proposer *Participant, block *state.Block, body *state.BlockBody) []byte {
+	changed := *block
+	changed.Body = body
+	head, err := proposer.Node.FinalizedState()
+	require.NoError(t, err)
+	next, err := state.TransitionState(head, &changed)
+	require.NoError(t, err)
+	changed.StateRoot = next.HashTreeRoot()
+	return state.EncodeBlock(&changed)
+}
+
+// a block can't mark a member's partial sigs we hold as missing or invalid, it can mark invalid (and disqualify) the
+// ones we hold invalid. A member refusing a duty its slashing protection refuses isn't missing it.
+func TestEpochBlockContributions(t *testing.T) {
+	crypto.InitBLS()
+
+	config := net.NewTestNetworkConfig()
+	participants := newTestNetwork(t, config)
+	runTestGenesisDKG(t, participants)
+	epoch := participants[0].Node.State.GetEpoch(0)
+	pools, err := epoch.PoolsParticipantIds()
+	require.NoError(t, err)
+	members := pool_chain.SortedParticipants(pools[1])
+	invalid, refusing := members[0], members[1]
+
+	// the invalid signer sends garbage partial sigs, the refusing one refuses the attestation
+	signer := participants[invalid - 1]
+	roots, err := signer.poolSigningRoots(1, 0)
+	require.NoError(t, err)
+	for _, root := range roots {
+		sig := &pb.SignatureDistribution{
+			Id:              uuid.New().String(),
+			FromParticipant: &pb.Participant{Id: invalid},
+			Sig:             []byte{1},
+			PoolId:          1,
+			Epoch:           0,
+			SigningRoot:     append([]byte{}, root[:]...),
+		}
+		sig.Signature = signer.sign(pool_chain.SignatureSigningRoot(sig))
+		require.NoError(t, signer.Node.Net.BroadcastSignature(sig))
+	}
+	duties, err := participants[refusing - 1].poolDuties(1, 0)
+	require.NoError(t, err)
+	for _, duty := range duties {
+		if duty.Type == eth2.DutyAttestation {
+			require.NoError(t, participants[refusing - 1].checkSlashing(1, duty, eth2.Root{1}))
+		}
+	}
+	expected := 0
+	for poolId, poolMembers := range pools {
+		roots, err := participants[0].poolSigningRoots(poolId, 0)
+		require.NoError(t, err)
+		expected += len(roots) * len(poolMembers)
+	}
+	for _, p := range participants {
+		if p.Id != invalid {
+			p.epochMid(p.Node.State.GetEpoch(0))
+		}
+	}
+	for _, p := range participants {
+		require.Eventually(t, func() bool { return len(p.Node.EpochSigs(0)) == expected }, time.Second, time.Millisecond * 10, "P %d", p.Id)
+	}
+
+	others := pool_chain.SortedParticipants(pools[2])
+	proposer, validator := participants[others[0] - 1], participants[others[1] - 1]
+	block, err := proposer.buildEpochBlock(proposer.Node.State.GetEpoch(0), 2)
+	require.NoError(t, err)
+	statuses := contributionsById(block.Body.Contributions)
+	require.Equal(t, state.ContributionInvalid, statuses[invalid].Signatures)
+	require.Equal(t, state.ContributionValid, statuses[refusing].Signatures)
+	require.NoError(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, state.EncodeBlock(block)))
+
+	// a valid member's partial sigs marked missing or invalid, the invalid signer's left out
+	honest := members[2]
+	for _, status := range []state.ContributionStatus{state.ContributionMissing, state.ContributionInvalid} {
+		c := &state.Contribution{ParticipantId: honest, Share: statuses[honest].Share, Signatures: status}
+		require.Error(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, withContribution(t, proposer, block, c)))
+	}
+	c := &state.Contribution{ParticipantId: refusing, Share: statuses[refusing].Share, Signatures: state.ContributionMissing}
+	require.Error(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, withContribution(t, proposer, block, c)))
+	// marking the invalid signer missing penalizes it less, it's still what the proposer could have seen
+	c = &state.Contribution{ParticipantId: invalid, Share: statuses[invalid].Share, Signatures: state.ContributionMissing}
+	require.NoError(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, withContribution(t, proposer, block, c)))
+
+	// the contributions judged at the epoch's end bind, a valid one can't be missing there either
+	own, err := validator.buildEpochBlock(validator.Node.State.GetEpoch(0), 2)
+	require.NoError(t, err)
+	c = &state.Contribution{ParticipantId: honest, Share: statuses[honest].Share, Signatures: state.ContributionMissing}
+	require.Error(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), own, withContribution(t, proposer, block, c)))
+
+	// only members we hold an invalid partial sig of can be disqualified
+	require.NoError(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, withDisqualified(t, proposer, block, []shared.ParticipantId{invalid})))
+	require.Error(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, withDisqualified(t, proposer, block, []shared.ParticipantId{honest})))
+	require.Error(t, validator.verifyEpochBlock(validator.Node.State.GetEpoch(0), nil, withDisqualified(t, proposer, block, []shared.ParticipantId{refusing})))
+}
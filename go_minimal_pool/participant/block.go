@@ -0,0 +1,307 @@
+package participant
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/google/uuid"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
+)
+
+// the epoch's block as proposed by the participant, on top of the latest finalized block. The body has the
+// pending registry requests that apply to the finalized state, the pool keys it doesn't have yet, the epoch's duty
+// signatures, the participants' contributions and the pending evidence of misbehaviour. The other pools'
+// signatures are reconstructed from the partial sigs it received (its own pool's already were) and the signers of
+// invalid ones it found are disqualified.
+func (p *Participant) buildEpochBlock(epoch *state.Epoch, currentPool shared.PoolId) (*state.Block, error) {
+	head, err := p.Node.FinalizedState()
+	if err != nil {
+		return nil, err
+	}
+	pools,err := epoch.PoolsParticipantIds()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch epoch's pools: %s", err.Error())
+	}
+
+	body := state.NewBlockBody()
+	body.Requests = p.Node.PendingRequests(head, epoch.Number)
+	for _, pool := range p.Node.State.Pools() {
+		finalized := head.GetPool(pool.Id)
+		if pool.Pk != nil && (finalized == nil || finalized.Pk == nil) {
+			body.PoolKeys = append(body.PoolKeys, &state.PoolKey{PoolId: pool.Id, Size: pool.Size, Pk: pool.Pk})
+		}
+	}
+
+	badSigners := make(map[shared.ParticipantId]bool)
+	for id := range epoch.BadSigners {
+		badSigners[id] = true
+	}
+	for _, poolId := range pool_chain.SortedParticipants(poolIds(pools)) {
+		pk := p.Node.State.GetPool(poolId).Pk
+		if pk == nil {
+			continue
+		}
+		roots,err := p.poolSigningRoots(poolId, epoch.Number)
+		if err != nil {
+			log.Printf("P %d, %s", p.Id, err.Error())
+			continue
+		}
+		for _, root := range roots {
+			sig, found := epoch.ReconstructedSignatures[root]
+			if poolId != currentPool || !found {
+				sig,err = p.reconstructPoolSignature(epoch, poolId, pools[poolId], root, pk, badSigners)
+				if err != nil {
+					continue
+				}
+			}
+			body.Signatures = append(body.Signatures, &state.PoolSignature{PoolId: poolId, SigningRoot: root, Sig: sig})
+		}
+	}
+	for id := range badSigners {
+		body.Disqualified = append(body.Disqualified, id)
+	}
+	body.Disqualified = pool_chain.SortedParticipants(body.Disqualified)
+	body.SortSignatures()
+	body.Contributions, err = p.epochContributions(epoch, pools)
+	if err != nil {
+		return nil, err
+	}
+	body.Evidence = p.Node.PendingEvidence(head)
+
+	block := &state.Block{
+		ParentRoot: head.LatestBlockRoot(),
+		Epoch: epoch.Number,
+		Proposer: p.Id,
+		Body: body,
+	}
+	next, err := state.TransitionState(head, block)
+	if err != nil {
+		return nil, err
+	}
+	block.StateRoot = next.HashTreeRoot()
+	return block, nil
+}
+
+// another pool's signature is reconstructed from its partial sigs as is and verified once with the pool's pk, only
+// if that fails every partial sig is verified (finding the bad signers)
+func (p *Participant) reconstructPoolSignature(epoch *state.Epoch, poolId shared.PoolId, members []shared.ParticipantId, root eth2.Root, pk *bls.PublicKey, badSigners map[shared.ParticipantId]bool) (*bls.G2, error) {
+	sigs := make(map[shared.ParticipantId]*bls.G2)
+	for _,v := range p.Node.EpochSigs(epoch.Number) {
+		if v.PoolId != poolId || !bytes.Equal(v.SigningRoot, root[:]) || sigs[v.FromParticipant.Id] != nil || pool_chain.IsRefusal(v) {
+			continue
+		}
+		sig := &bls.G2{}
+		if sig.Deserialize(v.Sig) == nil {
+			sigs[v.FromParticipant.Id] = sig
+		}
+	}
+
+	threshold := state.PoolThreshold(shared.PoolSize(len(members)))
+	sig,err := crypto.ReconstructSignature(threshold, members, sigs, false)
+	if err == nil && bls.CastToSign(sig).VerifyByte(pk, root[:]) {
+		return sig, nil
+	}
+	return p.reconstructDutySignature(epoch, poolId, members, root, badSigners)
+}
+
+// agrees on the epoch's block with the epoch's other participants, our block (if we have one) is proposed in our
+// rounds. Participants without a pool in the epoch pass no block, they follow the epoch's participants.
+func (p *Participant) finalizeEpoch(epoch *state.Epoch, block *state.Block) {
+	if p.Node.Consensus == nil {
+		return
+	}
+	validators, err := epochParticipants(epoch)
+	if err != nil {
+		log.Printf("P %d, %s", p.Id, err.Error())
+		return
+	}
+
+	value, err := p.Node.Consensus.Decide(epoch.Number, validators,
+		func() []byte {
+			if block == nil {
+				return nil
+			}
+			return state.EncodeBlock(block)
+		},
+		func(value []byte) bool { return p.validEpochBlock(epoch, block, value) },
+		p.Node.Config.EpochSpanSec,
+		)
+	if err != nil {
+		log.Printf("P %d, epoch %d not finalized: %s", p.Id, epoch.Number, err.Error())
+		return
+	}
+	decided, err := state.DecodeBlock(value)
+	if err != nil {
+		log.Printf("P %d, decided epoch %d block: %s", p.Id, epoch.Number, err.Error())
+		return
+	}
+	err = p.Node.SetFinalizedBlock(decided)
+	if err != nil {
+		log.Printf("P %d, decided epoch %d block: %s", p.Id, epoch.Number, err.Error())
+		return
+	}
+	body := decided.Body
+	log.Printf("P %d, epoch %d finalized, proposer: %d, requests: %d, pool keys: %d, disqualified: %d, signatures: %d, contributions: %d, evidence: %d", p.Id, epoch.Number, decided.Proposer, len(body.Requests), len(body.PoolKeys), len(body.Disqualified), len(body.Signatures), len(body.Contributions), len(body.Evidence))
+}
+
+// a proposed block must apply to the finalized state (see state.ProcessBlock) with registry requests signed by
+// their senders and agree with our pool keys. Other participants could have received different partial sigs so its
+// signatures only need to be valid ones of the pools' duties, and its disqualified participants the epoch's that
+// we hold an invalid partial sig of. Its contributions can't contradict ours (see verifyContributions) and its
+// evidence must prove the offenders' misbehaviour.
+func (p *Participant) validEpochBlock(epoch *state.Epoch, own *state.Block, value []byte) bool {
+	err := p.verifyEpochBlock(epoch, own, value)
+	if err != nil {
+		log.Printf("P %d, rejecting epoch %d block: %s", p.Id, epoch.Number, err.Error())
+		return false
+	}
+	return true
+}
+
+func (p *Participant) verifyEpochBlock(epoch *state.Epoch, own *state.Block, value []byte) error {
+	block, err := state.DecodeBlock(value)
+	if err != nil {
+		return err
+	}
+	if block.Epoch != epoch.Number {
+		return fmt.Errorf("block of epoch %d", block.Epoch)
+	}
+	members, err := epochParticipants(epoch)
+	if err != nil {
+		return err
+	}
+	if !isPoolMember(members, block.Proposer) {
+		return fmt.Errorf("proposer %d is not an epoch participant", block.Proposer)
+	}
+
+	head, err := p.Node.FinalizedState()
+	if err != nil {
+		return err
+	}
+	next, err := state.ProcessBlock(head, block)
+	if err != nil {
+		return err
+	}
+	for _, req := range block.Body.Requests {
+		err = pool_chain.VerifyRegistryRequest(head, req)
+		if err != nil {
+			return fmt.Errorf("request %s: %s", req.Id, err.Error())
+		}
+	}
+	// a pool activated next epoch could have finished its DKG only on some participants
+	for _, key := range block.Body.PoolKeys {
+		local := p.Node.State.GetPool(key.PoolId)
+		if local == nil || (local.Pk != nil && !local.Pk.IsEqual(key.Pk)) {
+			return fmt.Errorf("pool %d pk doesn't match", key.PoolId)
+		}
+	}
+
+	pools,err := epoch.PoolsParticipantIds()
+	if err != nil {
+		return fmt.Errorf("could not fetch epoch's pools: %s", err.Error())
+	}
+	for _, id := range block.Body.Disqualified {
+		if !isPoolMember(members, id) {
+			return fmt.Errorf("disqualified %d is not an epoch participant", id)
+		}
+		if epoch.BadSigners[id] {
+			continue
+		}
+		held, err := p.heldContribution(epoch, pools, id)
+		if err != nil {
+			return err
+		}
+		if held.Signatures != state.ContributionInvalid {
+			return fmt.Errorf("disqualified %d, we hold no invalid partial sig of it", id)
+		}
+	}
+	err = p.verifyContributions(epoch, pools, own, block.Body.Contributions)
+	if err != nil {
+		return err
+	}
+	for i, e := range block.Body.Evidence {
+		err = pool_chain.VerifyEvidence(head, e)
+		if err != nil {
+			return fmt.Errorf("evidence %d: %s", i, err.Error())
+		}
+	}
+
+	// signatures we reconstructed ourselves were verified already
+	reconstructed := make(map[shared.PoolId]map[eth2.Root]*bls.G2)
+	if own != nil {
+		for _, sig := range own.Body.Signatures {
+			if reconstructed[sig.PoolId] == nil {
+				reconstructed[sig.PoolId] = make(map[eth2.Root]*bls.G2)
+			}
+			reconstructed[sig.PoolId][sig.SigningRoot] = sig.Sig
+		}
+	}
+
+	seen := make(map[shared.PoolId]map[eth2.Root]bool)
+	for _, sig := range block.Body.Signatures {
+		pool := next.GetPool(sig.PoolId)
+		if _, found := pools[sig.PoolId]; !found || pool == nil || pool.Pk == nil {
+			return fmt.Errorf("signature of pool %d which isn't signing", sig.PoolId)
+		}
+		if seen[sig.PoolId] == nil {
+			seen[sig.PoolId] = make(map[eth2.Root]bool)
+		}
+		if seen[sig.PoolId][sig.SigningRoot] {
+			return fmt.Errorf("duplicate pool %d signature", sig.PoolId)
+		}
+		seen[sig.PoolId][sig.SigningRoot] = true
+
+		if known := reconstructed[sig.PoolId][sig.SigningRoot]; known != nil && known.IsEqual(sig.Sig) {
+			continue
+		}
+		roots, err := p.poolSigningRoots(sig.PoolId, epoch.Number)
+		if err != nil {
+			return err
+		}
+		isDuty := false
+		for _, root := range roots {
+			isDuty = isDuty || root == sig.SigningRoot
+		}
+		if !isDuty {
+			return fmt.Errorf("pool %d signature isn't of a duty", sig.PoolId)
+		}
+		if !bls.CastToSign(sig.Sig).VerifyByte(pool.Pk, sig.SigningRoot[:]) {
+			return fmt.Errorf("invalid pool %d signature", sig.PoolId)
+		}
+	}
+	return nil
+}
+
+// signs and broadcasts the consensus' messages
+func (p *Participant) broadcastConsensusMessage(msg *pb.ConsensusMessage) error {
+	msg.Id = uuid.New().String()
+	msg.Signature = p.sign(pool_chain.ConsensusSigningRoot(msg))
+	return p.Node.Net.BroadcastConsensusMessage(msg)
+}
+
+// every member of the epoch's pools, sorted
+func epochParticipants(epoch *state.Epoch) ([]shared.ParticipantId, error) {
+	pools,err := epoch.PoolsParticipantIds()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch epoch %d pools: %s", epoch.Number, err.Error())
+	}
+	ret := make([]shared.ParticipantId, 0)
+	for _, members := range pools {
+		ret = append(ret, members...)
+	}
+	return pool_chain.SortedParticipants(ret), nil
+}
+
+func poolIds(pools map[shared.PoolId][]shared.ParticipantId) []shared.PoolId {
+	ret := make([]shared.PoolId, 0)
+	for id := range pools {
+		ret = append(ret, id)
+	}
+	return ret
+}
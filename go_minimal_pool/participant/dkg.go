@@ -0,0 +1,335 @@
+package participant
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/google/uuid"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
+	"time"
+)
+
+// runs the genesis GJKR DKG for the participant's epoch 0 pool over the network.
+// https://github.com/bloxapp/eth2-staking-pools-research/blob/master/dkg.md
+func (p *Participant) RunGenesisDKG() error {
+	poolId, err := p.Node.State.GetEpoch(0).ParticipantPoolAssignment(p.Id)
+	if err != nil {
+		return fmt.Errorf("P %d err fetching genesis pool: %s", p.Id, err.Error())
+	}
+	return p.runPoolDKG(0, poolId)
+}
+
+// runs the GJKR DKG of a pool activated in epoch number, between the pool's members in that epoch.
+// Every phase lasts Config.DKGPhaseSpan, at its end the participant processes the phase's messages and broadcasts
+// its own messages for the next phase. The participant only ever sees its own share, the pool's public key is saved
+// to state by the node once a threshold of the pool's members voted for it.
+func (p *Participant) runPoolDKG(number shared.EpochNumber, poolId shared.PoolId) error {
+	pools, err := p.Node.State.GetEpoch(number).PoolsParticipantIds()
+	if err != nil {
+		return fmt.Errorf("P %d err fetching epoch %d pools: %s", p.Id, number, err.Error())
+	}
+	members := pool_chain.SortedParticipants(pools[poolId])
+
+	dkg, err := crypto.NewGJKR(p.Id, state.PoolThreshold(shared.PoolSize(len(members))), members)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("P %d, DKG for pool %d (epoch %d) started", p.Id, poolId, number)
+
+	// deal
+	commitment, deals, err := dkg.Deal()
+	if err != nil {
+		return err
+	}
+	p.broadcastDKG(poolId, &pb.DKGMessage{
+		Type:        pb.DKGMessageType_DEAL_COMMITMENT,
+		Commitments: crypto.SerializeCommitments(commitment.Commitments),
+	})
+	for to, deal := range deals {
+		p.broadcastDKG(poolId, &pb.DKGMessage{
+			Type:          pb.DKGMessageType_DEAL,
+			ToParticipant: &pb.Participant{Id: to},
+			Share:         deal.Share.Serialize(),
+			BlindingShare: deal.BlindingShare.Serialize(),
+		})
+	}
+	left := p.waitDKGPhase(0, func() bool {
+		return p.receivedDKGMessages(poolId, pb.DKGMessageType_DEAL_COMMITMENT, members, false) &&
+			p.receivedDKGMessages(poolId, pb.DKGMessageType_DEAL, members, true)
+	})
+
+	// complain
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_DEAL_COMMITMENT) {
+		commitments, err := crypto.DeserializeCommitments(msg.Commitments)
+		if err == nil {
+			err = dkg.ProcessCommitment(&crypto.GJKRCommitment{From: msg.FromParticipant.Id, Commitments: commitments})
+		}
+		p.logDKGError(msg, err)
+	}
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_DEAL) {
+		share, blinding, err := dkgShares(msg)
+		if err == nil {
+			err = dkg.ProcessDeal(&crypto.GJKRDeal{From: msg.FromParticipant.Id, To: msg.ToParticipant.Id, Share: share, BlindingShare: blinding})
+		}
+		p.logDKGError(msg, err)
+	}
+	complaints, err := dkg.Complain()
+	if err != nil {
+		return err
+	}
+	for _, c := range complaints {
+		p.broadcastDKG(poolId, &pb.DKGMessage{
+			Type:          pb.DKGMessageType_COMPLAINT,
+			ToParticipant: &pb.Participant{Id: c.Against},
+		})
+	}
+	p.waitDKGPhase(left, nil)
+
+	// justify
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_COMPLAINT) {
+		if msg.ToParticipant == nil {
+			continue
+		}
+		err := dkg.ProcessComplaint(&crypto.GJKRComplaint{From: msg.FromParticipant.Id, Against: msg.ToParticipant.Id})
+		p.logDKGError(msg, err)
+	}
+	justifications, err := dkg.Justify()
+	if err != nil {
+		return err
+	}
+	for _, j := range justifications {
+		p.broadcastDKG(poolId, &pb.DKGMessage{
+			Type:          pb.DKGMessageType_JUSTIFICATION,
+			ToParticipant: &pb.Participant{Id: j.To},
+			Share:         j.Share.Serialize(),
+			BlindingShare: j.BlindingShare.Serialize(),
+		})
+	}
+	p.waitDKGPhase(0, nil)
+
+	// qualify and extract
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_JUSTIFICATION) {
+		share, blinding, err := dkgShares(msg)
+		if err == nil {
+			err = dkg.ProcessJustification(&crypto.GJKRJustification{From: msg.FromParticipant.Id, To: msg.ToParticipant.Id, Share: share, BlindingShare: blinding})
+		}
+		p.logDKGError(msg, err)
+	}
+	qual, err := dkg.Qualify()
+	if err != nil {
+		return err
+	}
+	log.Printf("P %d, pool %d QUAL: %v", p.Id, poolId, qual)
+	coefficients, err := dkg.PublicCoefficients()
+	if err != nil {
+		return err
+	}
+	if coefficients != nil {
+		p.broadcastDKG(poolId, &pb.DKGMessage{
+			Type:        pb.DKGMessageType_PUBLIC_COEFFICIENTS,
+			Commitments: crypto.SerializeCommitments(coefficients.Coefficients),
+		})
+	}
+	left = p.waitDKGPhase(0, func() bool {
+		return p.receivedDKGMessages(poolId, pb.DKGMessageType_PUBLIC_COEFFICIENTS, qual, false)
+	})
+
+	// extraction complaints
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_PUBLIC_COEFFICIENTS) {
+		c, err := crypto.DeserializeCommitments(msg.Commitments)
+		if err == nil {
+			err = dkg.ProcessPublicCoefficients(&crypto.GJKRPublicCoefficients{From: msg.FromParticipant.Id, Coefficients: c})
+		}
+		p.logDKGError(msg, err)
+	}
+	extractionComplaints, err := dkg.ExtractionComplain()
+	if err != nil {
+		return err
+	}
+	for _, c := range extractionComplaints {
+		p.broadcastDKG(poolId, &pb.DKGMessage{
+			Type:          pb.DKGMessageType_EXTRACTION_COMPLAINT,
+			ToParticipant: &pb.Participant{Id: c.Against},
+			Share:         c.Share.Serialize(),
+			BlindingShare: c.BlindingShare.Serialize(),
+		})
+	}
+	p.waitDKGPhase(left, nil)
+
+	// reconstruct
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_EXTRACTION_COMPLAINT) {
+		share, blinding, err := dkgShares(msg)
+		if err == nil {
+			err = dkg.ProcessExtractionComplaint(&crypto.GJKRExtractionComplaint{From: msg.FromParticipant.Id, Against: msg.ToParticipant.Id, Share: share, BlindingShare: blinding})
+		}
+		p.logDKGError(msg, err)
+	}
+	reconstructionShares, err := dkg.Reconstruct()
+	if err != nil {
+		return err
+	}
+	for _, r := range reconstructionShares {
+		p.broadcastDKG(poolId, &pb.DKGMessage{
+			Type:          pb.DKGMessageType_RECONSTRUCTION_SHARE,
+			ToParticipant: &pb.Participant{Id: r.Dealer},
+			Share:         r.Share.Serialize(),
+			BlindingShare: r.BlindingShare.Serialize(),
+		})
+	}
+	p.waitDKGPhase(0, nil)
+
+	// finalize
+	for _, msg := range p.Node.DKGMessagesByType(poolId, pb.DKGMessageType_RECONSTRUCTION_SHARE) {
+		share, blinding, err := dkgShares(msg)
+		if err == nil {
+			err = dkg.ProcessReconstructionShare(&crypto.GJKRReconstructionShare{From: msg.FromParticipant.Id, Dealer: msg.ToParticipant.Id, Share: share, BlindingShare: blinding})
+		}
+		p.logDKGError(msg, err)
+	}
+	err = dkg.Finalize()
+	if err != nil {
+		return err
+	}
+
+	return p.saveDKGResult(number, poolId, members, dkg)
+}
+
+// saves the participant's share and votes for the pool's public key (and public shares)
+func (p *Participant) saveDKGResult(number shared.EpochNumber, poolId shared.PoolId, members []shared.ParticipantId, dkg *crypto.GJKR) error {
+	share, err := dkg.SecretShare()
+	if err != nil {
+		return err
+	}
+	pk, err := dkg.GroupPK()
+	if err != nil {
+		return err
+	}
+	publicShares, err := dkg.PublicShares()
+	if err != nil {
+		return err
+	}
+
+	epoch := p.Node.State.GetEpoch(number)
+	epoch.ParticipantShare = share
+	err = p.Node.State.SaveEpoch(epoch)
+	if err != nil {
+		return err
+	}
+
+	serializedPublicShares := make([][]byte, len(members))
+	for i, id := range members {
+		serializedPublicShares[i] = publicShares[id].Serialize()
+	}
+	p.broadcastDKG(poolId, &pb.DKGMessage{
+		Type:         pb.DKGMessageType_GROUP_PK,
+		GroupPk:      pk.Serialize(),
+		PublicShares: serializedPublicShares,
+	})
+
+	log.Printf("P %d, DKG for pool %d (epoch %d) done", p.Id, poolId, number)
+	return nil
+}
+
+func (p *Participant) broadcastDKG(poolId shared.PoolId, msg *pb.DKGMessage) {
+	msg.Id = uuid.New().String()
+	msg.FromParticipant = &pb.Participant{Id: p.Id}
+	msg.PoolId = poolId
+
+	// deals are private, encrypted to the recipient
+	if msg.Type == pb.DKGMessageType_DEAL {
+		err := p.encryptDKGDeal(msg)
+		if err != nil {
+			log.Printf("P %d, could not encrypt DKG deal to %d: %s", p.Id, msg.ToParticipant.Id, err.Error())
+			return
+		}
+	}
+
+	msg.Signature = p.sign(pool_chain.DKGSigningRoot(msg))
+
+	err := p.Node.Net.BroadcastDKGMessage(msg)
+	if err != nil {
+		log.Printf("broadcasting error: %s", err.Error())
+	}
+}
+
+func (p *Participant) encryptDKGDeal(msg *pb.DKGMessage) error {
+	to := p.Node.State.GetParticipant(msg.ToParticipant.Id)
+	if to == nil {
+		return fmt.Errorf("unknown participant")
+	}
+
+	aad := pool_chain.DKGDealAAD(msg)
+	share, err := crypto.Encrypt(to.EncryptionPk, msg.Share, aad)
+	if err != nil {
+		return err
+	}
+	blinding, err := crypto.Encrypt(to.EncryptionPk, msg.BlindingShare, aad)
+	if err != nil {
+		return err
+	}
+	msg.Share = share
+	msg.BlindingShare = blinding
+	return nil
+}
+
+// waits Config.DKGPhaseSpan and extra. Phases in which every member sends a message end once done is true, a
+// member must then still get the whole phase's time to complain about a missing message: the time left is returned
+// and added to the next phase.
+func (p *Participant) waitDKGPhase(extra time.Duration, done func() bool) time.Duration {
+	span := p.Node.Config.DKGPhaseSpan + extra
+	end := time.Now().Add(span)
+	timeout := time.After(span)
+	for done == nil || !done() {
+		select {
+		case <- timeout:
+			return 0
+		case <- time.After(p.Node.Config.DKGPhaseSpan / 20):
+		}
+	}
+	return time.Until(end)
+}
+
+// true if a message of the type arrived from every one of the other senders, addressed to the participant if toUs
+func (p *Participant) receivedDKGMessages(poolId shared.PoolId, t pb.DKGMessageType, senders []shared.ParticipantId, toUs bool) bool {
+	received := make(map[shared.ParticipantId]bool)
+	for _, msg := range p.Node.DKGMessagesByType(poolId, t) {
+		if toUs && (msg.ToParticipant == nil || msg.ToParticipant.Id != p.Id) {
+			continue
+		}
+		received[msg.FromParticipant.Id] = true
+	}
+	for _, id := range senders {
+		if id != p.Id && !received[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Participant) logDKGError(msg *pb.DKGMessage, err error) {
+	if err != nil {
+		log.Printf("P %d, invalid DKG message %s from %d: %s", p.Id, msg.Type.String(), msg.FromParticipant.Id, err.Error())
+	}
+}
+
+func dkgShares(msg *pb.DKGMessage) (*bls.Fr, *bls.Fr, error) {
+	if msg.ToParticipant == nil {
+		return nil, nil, fmt.Errorf("missing participant")
+	}
+	share := &bls.Fr{}
+	err := share.Deserialize(msg.Share)
+	if err != nil {
+		return nil, nil, err
+	}
+	blinding := &bls.Fr{}
+	err = blinding.Deserialize(msg.BlindingShare)
+	if err != nil {
+		return nil, nil, err
+	}
+	return share, blinding, nil
+}
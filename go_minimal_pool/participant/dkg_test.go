@@ -0,0 +1,88 @@
+package participant
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// drops the dealer's deal to one of its pool's members and, if withholdJustification, its justifications
+type withholdingNet struct {
+	net.P2P
+	to shared.ParticipantId
+	withholdJustification bool
+}
+
+func (n *withholdingNet) BroadcastDKGMessage(msg *pb.DKGMessage) error {
+	if msg.Type == pb.DKGMessageType_DEAL && msg.ToParticipant.Id == n.to {
+		return nil
+	}
+	if msg.Type == pb.DKGMessageType_JUSTIFICATION && n.withholdJustification {
+		return nil
+	}
+	return n.P2P.BroadcastDKGMessage(msg)
+}
+
+// pool 1's first member withholds its deal to the second one, the third one is honest
+func runWithheldDealDKG(t *testing.T, withholdJustification bool) ([]*Participant, []shared.ParticipantId) {
+	config := net.NewTestNetworkConfig()
+	participants := newTestNetwork(t, config)
+
+	pools, err := participants[0].Node.State.GetEpoch(0).PoolsParticipantIds()
+	require.NoError(t, err)
+	members := pools[1]
+	require.Len(t, members, 3)
+	dealer := participants[members[0] - 1]
+	dealer.Node.Net = &withholdingNet{P2P: dealer.Node.Net, to: members[1], withholdJustification: withholdJustification}
+
+	runTestGenesisDKG(t, participants)
+	return participants, members
+}
+
+// every participant saved the same pool key, the members' shares match their public shares and reconstruct the key's
+// secret
+func requireAgreedPoolKey(t *testing.T, participants []*Participant, poolId shared.PoolId, members []shared.ParticipantId) {
+	pk := participants[0].Node.State.GetPool(poolId).Pk
+	require.NotNil(t, pk)
+	for _, p := range participants {
+		require.True(t, pk.IsEqual(p.Node.State.GetPool(poolId).Pk), "P %d", p.Id)
+	}
+
+	shares := make(map[uint32]*bls.Fr)
+	for _, id := range members {
+		p := participants[id - 1]
+		share := p.Node.State.GetEpoch(0).ParticipantShare
+		require.NotNil(t, share, "P %d", id)
+		for _, other := range participants {
+			publicShare, found := other.Node.State.GetEpoch(0).PublicShare(id)
+			require.True(t, found, "P %d, public share of %d", other.Id, id)
+			require.True(t, publicShare.IsEqual(bls.CastFromPublicKey(bls.CastToSecretKey(share).GetPublicKey())), "P %d, public share of %d", other.Id, id)
+		}
+		shares[id] = share
+	}
+	secret, err := crypto.ReconstructSecret(state.PoolThreshold(shared.PoolSize(len(members))), members, shares, true)
+	require.NoError(t, err)
+	require.True(t, pk.IsEqual(bls.CastToSecretKey(secret).GetPublicKey()))
+}
+
+// the complaint about the missing deal is justified, the dealer stays qualified and the whole pool agrees
+func TestDKGWithheldDeal(t *testing.T) {
+	crypto.InitBLS()
+
+	participants, members := runWithheldDealDKG(t, false)
+	requireAgreedPoolKey(t, participants, 1, members)
+}
+
+// the dealer doesn't justify, every member (the dealer included, its justification never got out) disqualifies it
+// and the pool's key is the two other members' secrets
+func TestDKGUnjustifiedDeal(t *testing.T) {
+	crypto.InitBLS()
+
+	participants, members := runWithheldDealDKG(t, true)
+	requireAgreedPoolKey(t, participants, 1, members)
+}
@@ -0,0 +1,94 @@
+package participant
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"log"
+)
+
+// a pool is a validator, every pool chain epoch is an eth2 epoch. The pool's members threshold sign the validator's
+// duties for the epoch and, in its liquidating epoch, the pool's voluntary exit.
+func (p *Participant) poolDuties(poolId shared.PoolId, epoch shared.EpochNumber) ([]*eth2.Duty, error) {
+	p.dutiesLock.Lock()
+	defer p.dutiesLock.Unlock()
+
+	if ret, found := p.duties[epoch][poolId]; found {
+		return ret, nil
+	}
+
+	pool := p.Node.State.GetPool(poolId)
+	if pool == nil {
+		return nil, fmt.Errorf("unknown pool %d", poolId)
+	}
+	ret, err := p.Duties.Duties(eth2.Epoch(epoch), pool.ValidatorIndex())
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch pool %d duties for epoch %d: %s", poolId, epoch, err.Error())
+	}
+	if pool.ExitEpoch == epoch + 1 {
+		ret = append(ret, eth2.NewVoluntaryExitDuty(pool.VoluntaryExit()))
+	}
+
+	if _, found := p.duties[epoch]; !found {
+		p.duties[epoch] = make(map[shared.PoolId][]*eth2.Duty)
+	}
+	p.duties[epoch][poolId] = ret
+	// an epoch's duties are done with at its end
+	for number := range p.duties {
+		if number + 2 < epoch {
+			delete(p.duties, number)
+		}
+	}
+	return ret, nil
+}
+
+// a pool key signing conflicting data gets every staker in the pool slashed, a member refuses to release its partial
+// signature for it (*eth2.SlashableError). Pools are keyed by their pk, a pool without one has nothing to sign with.
+func (p *Participant) checkSlashing(poolId shared.PoolId, duty *eth2.Duty, root eth2.Root) error {
+	pool := p.Node.State.GetPool(poolId)
+	if pool == nil || pool.Pk == nil {
+		return fmt.Errorf("pool %d has no pk", poolId)
+	}
+	return p.SlashingProtection.CheckAndRecord(pool.Pk.Serialize(), duty, root)
+}
+
+// submits the pool's reconstructed duty signatures to the node's beacon node, every member submits
+func (p *Participant) submitEpochSignatures(epoch *state.Epoch, poolId shared.PoolId) {
+	if p.Node.Beacon == nil || !epoch.EpochSigVerified {
+		return
+	}
+	duties, err := p.poolDuties(poolId, epoch.Number)
+	if err != nil {
+		log.Printf("P %d, %s", p.Id, err.Error())
+		return
+	}
+	roots, err := p.poolSigningRoots(poolId, epoch.Number)
+	if err != nil {
+		log.Printf("P %d, %s", p.Id, err.Error())
+		return
+	}
+	for i, duty := range duties {
+		err = p.Node.SubmitDutySignature(duty, epoch.ReconstructedSignatures[roots[i]])
+		if err != nil {
+			log.Printf("P %d, could not submit pool %d %s at slot %d: %s", p.Id, poolId, duty.Type.String(), duty.Slot, err.Error())
+		}
+	}
+}
+
+// the signing roots of the pool's duties in epoch, in the duties' order
+func (p *Participant) poolSigningRoots(poolId shared.PoolId, epoch shared.EpochNumber) ([]eth2.Root, error) {
+	duties, err := p.poolDuties(poolId, epoch)
+	if err != nil {
+		return nil, err
+	}
+	fork := p.Node.Config.Fork()
+	ret := make([]eth2.Root, len(duties))
+	for i, duty := range duties {
+		ret[i], err = fork.SigningRoot(duty)
+		if err != nil {
+			return nil, fmt.Errorf("pool %d %s duty at slot %d: %s", poolId, duty.Type.String(), duty.Slot, err.Error())
+		}
+	}
+	return ret, nil
+}
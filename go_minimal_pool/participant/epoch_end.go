@@ -1,10 +1,13 @@
 package participant
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
-	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
 	"github.com/herumi/bls-eth-go-binary/bls"
 	"log"
 )
@@ -14,41 +17,92 @@ import (
 func (p *Participant) epochEnd(epoch *state.Epoch) {
 	p.epochProcessingLock.Lock()
 	defer p.epochProcessingLock.Unlock()
+	defer func() { p.endedEpochs[epoch.Number] = true }()
 
-	log.Printf("P %d, epoch %d end with %d sigs", p.Id,epoch.Number, len(p.Node.SigsPerEpoch[epoch.Number]))
+	log.Printf("P %d, epoch %d end with %d sigs", p.Id,epoch.Number, len(p.Node.EpochSigs(epoch.Number)))
 
-	err := p.reconstructEpochSignature(epoch)
-	if err != nil {
-		log.Fatalf(err.Error())
+	// pending or exited participants only follow the pools (their public shares)
+	currentPool,err := epoch.ParticipantPoolAssignment(p.Id)
+	_, notInPool := err.(*state.NotInPoolError)
+	if err != nil && !notInPool {
+		log.Printf("P %d err fetching current epoch's pool: %s", p.Id, err.Error())
 		return
 	}
-	err = p.verifyEpochSig(epoch)
-	if err != nil {
-		log.Fatalf(err.Error())
-		return
+
+	// missing or invalid contributions fail the epoch for us (or our pool), the node keeps following the network.
+	// A pool short of valid partial sigs isn't verified, its signatures aren't submitted.
+	if !notInPool {
+		err = p.reconstructEpochSignature(epoch)
+		if err == nil {
+			err = p.verifyEpochSig(epoch)
+		}
+		if err != nil {
+			log.Printf("P %d, epoch %d signature failed: %s", p.Id, epoch.Number, err.Error())
+		} else {
+			p.recordExitSignature(epoch, currentPool)
+			p.submitEpochSignatures(epoch, currentPool)
+		}
 	}
+	// without a share (e.g. a dealer withheld ours) we skip the next epoch's redistribution and signing, the pool's
+	// threshold covers for us
 	err = p.reconstructGroupSecretForNextEpoch(epoch)
 	if err != nil {
-		log.Fatalf(err.Error())
-		return
+		if insufficient, ok := err.(*crypto.InsufficientContributionsError); ok {
+			log.Printf("P %d, no share for epoch %d, missing shares from %v", p.Id, epoch.Number + 1, insufficient.Missing)
+		} else {
+			log.Printf("P %d, no share for epoch %d: %s", p.Id, epoch.Number + 1, err.Error())
+		}
+	}
+	// the share was redistributed, it must not outlive the epoch
+	err = p.Node.State.DeleteParticipantShare(epoch.Number)
+	if err != nil {
+		log.Printf("P %d, could not delete epoch %d share: %s", p.Id, epoch.Number, err.Error())
 	}
 
+	// the registry only changes with finalized blocks, the epoch's requests and updates apply once its block is
+	// finalized (see finalizeEpoch)
+	epoch.StateRoot = p.Node.State.HashTreeRoot()
+	err = p.Node.State.SaveEpoch(epoch)
+	if err != nil {
+		log.Printf("P %d, could not save epoch %d: %s", p.Id, epoch.Number, err.Error())
+	}
 
-	currentPool,_ := epoch.ParticipantPoolAssignment(p.Id)
+	if notInPool {
+		log.Printf("P %d, not in a pool, epoch status: %s", p.Id, epoch.StatusString())
+		go p.finalizeEpoch(epoch, nil)
+		return
+	}
 	log.Printf("P %d, pool: %d, epoch status: %s",p.Id,currentPool, epoch.StatusString())
+
+	// the block is built with the epoch's messages and registry requests, agreeing on it can outlast the epoch.
+	// Without one (e.g. the previous epoch isn't finalized yet) we still vote on the others' blocks.
+	block, err := p.buildEpochBlock(epoch, currentPool)
+	if err != nil {
+		log.Printf("P %d, could not build epoch %d block: %s", p.Id, epoch.Number, err.Error())
+	}
+	go p.finalizeEpoch(epoch, block)
 }
 
 
+// every one of the pool's duties must have a reconstructed signature verified with the pool's key
 func (p *Participant) verifyEpochSig(epoch *state.Epoch) error {
-	config := net.NewTestNetworkConfig()
 	currentPool,err := epoch.ParticipantPoolAssignment(p.Id)
 	if err != nil {
 		return fmt.Errorf("P %d err fetching current epoch's pool: %s", p.Id, err.Error())
 	}
+	roots,err := p.poolSigningRoots(currentPool, epoch.Number)
+	if err != nil {
+		return fmt.Errorf("P %d, %s", p.Id, err.Error())
+	}
 
-	sig := bls.CastToSign(epoch.ReconstructedSignature)
-	pk := p.Node.State.Pools[currentPool].Pk
-	epoch.EpochSigVerified = sig.VerifyByte(pk, config.EpochTestMessage)
+	pk := p.Node.State.GetPool(currentPool).Pk
+	epoch.EpochSigVerified = len(roots) > 0
+	for _, root := range roots {
+		reconstructed, found := epoch.ReconstructedSignatures[root]
+		if !found || !bls.CastToSign(reconstructed).VerifyByte(pk, root[:]) {
+			epoch.EpochSigVerified = false
+		}
+	}
 	p.Node.State.SaveEpoch(epoch)
 	return nil
 }
@@ -58,66 +112,234 @@ func (p *Participant) reconstructEpochSignature(epoch *state.Epoch) error {
 	if err != nil {
 		return fmt.Errorf("P %d err fetching current epoch's pool: %s", p.Id, err.Error())
 	}
+	roots,err := p.poolSigningRoots(currentPool, epoch.Number)
+	if err != nil {
+		return fmt.Errorf("P %d, %s", p.Id, err.Error())
+	}
+	pools,err := epoch.PoolsParticipantIds()
+	if err != nil {
+		return fmt.Errorf("P %d err fetching current epoch's pools: %s", p.Id, err.Error())
+	}
 
-	// filter out relevant sigs
-	points := make([][]interface{},0)
-	for _,v := range p.Node.SigsPerEpoch[epoch.Number] {
-		if v.PoolId == currentPool {
-			sig := &bls.G2{}
-			err := sig.Deserialize(v.Sig)
-			if err != nil {
-				continue
-			}
+	// members refusing slashable data can leave a duty short of a threshold, the epoch then isn't verified
+	for _, root := range roots {
+		sig,err := p.reconstructDutySignature(epoch, currentPool, pools[currentPool], root, epoch.BadSigners)
+		if err != nil {
+			log.Printf("P %d, %s", p.Id, err.Error())
+			continue
+		}
+		epoch.ReconstructedSignatures[root] = sig
+	}
+	p.Node.State.SaveEpoch(epoch)
+	return nil
+}
 
-			id := &bls.Fr{}
-			id.SetInt64(int64(v.FromParticipant.Id))
+// reconstructs any of the epoch's pools' duty signatures, signers of invalid partial sigs are added to badSigners
+func (p *Participant) reconstructDutySignature(epoch *state.Epoch, poolId shared.PoolId, members []shared.ParticipantId, root eth2.Root, badSigners map[shared.ParticipantId]bool) (*bls.G2, error) {
+	// filter out relevant sigs, every partial sig is verified against the signer's public share
+	validSigs := make(map[shared.ParticipantId]*bls.G2)
+	for _,v := range p.Node.EpochSigs(epoch.Number) {
+		if v.PoolId != poolId || !bytes.Equal(v.SigningRoot, root[:]) || pool_chain.IsRefusal(v) {
+			continue
+		}
+		signer := v.FromParticipant.Id
+		if _, found := validSigs[signer]; found {
+			continue
+		}
+
+		publicShare, found := epoch.PublicShare(signer)
+		if !found {
+			log.Printf("P %d, discarding sig from %d: unknown public share for epoch %d", p.Id, signer, epoch.Number)
+			continue
+		}
 
-			points = append(points, []interface{}{*id, sig})
+		sig := &bls.G2{}
+		err := sig.Deserialize(v.Sig)
+		if err != nil || !crypto.VerifyPartialSig(publicShare, sig, root[:]) {
+			log.Printf("P %d, discarding sig from %d: invalid partial signature", p.Id, signer)
+			badSigners[signer] = true
+			continue
 		}
+		validSigs[signer] = sig
 	}
 
-	// reconstruct
-	l := crypto.NewG2LagrangeInterpolation(points)
-	sig,err := l.Interpolate()
+	// reconstruct from any threshold of valid partial sigs, redundant ones are cross checked
+	threshold := state.PoolThreshold(shared.PoolSize(len(members)))
+	sig,err := crypto.ReconstructSignature(threshold, members, validSigs, true)
 	if err != nil {
-		return fmt.Errorf("could not reconstruct group signature for epoch %d: %s", epoch.Number, err.Error())
+		return nil, fmt.Errorf("could not reconstruct pool %d group signature for epoch %d, signing root %x: %s", poolId, epoch.Number, root[:], err.Error())
 	}
-
-	epoch.ReconstructedSignature = sig
-	p.Node.State.SaveEpoch(epoch)
-	return nil
+	return sig, nil
 }
 
 func (p *Participant) reconstructGroupSecretForNextEpoch(epoch *state.Epoch) error {
-	shares := p.Node.SharesPerEpoch[epoch.Number]
-	points := make([][]bls.Fr,0)
-	for _,v := range shares {
-		if v.ToParticipant.Id != p.Id {
-			continue
+	currentPools,err := epoch.PoolsParticipantIds()
+	if err != nil {
+		return fmt.Errorf("P %d err fetching current epoch's pools: %s", p.Id, err.Error())
+	}
+	nextEpoch := p.Node.State.GetEpoch(epoch.Number + 1)
+	nextPools,err := nextEpoch.PoolsParticipantIds()
+	if err != nil {
+		return fmt.Errorf("P %d err fetching next epoch's pools: %s", p.Id, err.Error())
+	}
+	nextPool,err := nextEpoch.ParticipantPoolAssignment(p.Id)
+	_, notInPool := err.(*state.NotInPoolError)
+	if notInPool || (err == nil && isActivatedIn(p.Node.State.GetPool(nextPool), nextEpoch.Number)) {
+		// no share to reconstruct (a new pool's share comes from its DKG), the public shares are still needed to
+		// verify the next redistribution
+		err = p.computeNextEpochPublicShares(epoch, nextEpoch, currentPools, nextPools)
+		if err != nil {
+			return fmt.Errorf("could not compute public shares for next epoch: %s", err.Error())
 		}
+		return p.Node.State.SaveEpoch(nextEpoch)
+	}
+	if err != nil {
+		return fmt.Errorf("P %d err fetching next epoch's pool: %s", p.Id, err.Error())
+	}
+
+	// public shares for next epoch, needed to verify the next redistribution. They're computed even if we fail to
+	// reconstruct our own share.
+	err = p.computeNextEpochPublicShares(epoch, nextEpoch, currentPools, nextPools)
+	if err != nil {
+		return fmt.Errorf("could not compute public shares for next epoch: %s", err.Error())
+	}
+	err = p.Node.State.SaveEpoch(nextEpoch)
+	if err != nil {
+		return fmt.Errorf("could not save public shares for next epoch: %s", err.Error())
+	}
 
-		from := &bls.Fr{}
-		from.SetInt64(int64(v.FromParticipant.Id))
+	// the group secret is interpolated from the shares of the same dealers the public shares are, every dealer
+	// shares with its own random polynomial so a different subset would give a (valid) share of a different sharing
+	dealers, err := p.redistributionDealers(epoch, currentPools[nextPool], len(nextPools[nextPool]))
+	if err != nil {
+		return fmt.Errorf("P %d, could not reconstruct group secret for next epoch: %s", p.Id, err.Error())
+	}
+	points := make(map[shared.ParticipantId]*bls.Fr)
+	for _,v := range p.Node.EpochShares(epoch.Number) {
+		commitments, found := dealers[v.FromParticipant.Id]
+		if v.ToParticipant.Id != p.Id || v.PoolId != nextPool || !found || points[v.FromParticipant.Id] != nil {
+			continue
+		}
 
 		point := &bls.Fr{}
-		point.Deserialize(v.Share)
+		err = point.Deserialize(v.Share)
+		if err != nil {
+			log.Printf("P %d, discarding share from %d: %s", p.Id, v.FromParticipant.Id, err.Error())
+			continue
+		}
 
-		points = append(points, []bls.Fr{*from, *point})
+		valid,err := crypto.VerifyShareWithCommitments(commitments, p.Id, point)
+		if err != nil || !valid {
+			log.Printf("P %d, discarding share from %d: share does not match commitments", p.Id, v.FromParticipant.Id)
+			continue
+		}
+
+		points[v.FromParticipant.Id] = point
 	}
 
-	// reconstruct the group secret from the shares
-	l := crypto.NewLagrangeInterpolation(points)
-	groupSk, err := l.Interpolate()
+	// a missing share of one of the dealers fails the epoch's redistribution for us, there's no other subset
+	ids := make([]shared.ParticipantId, 0)
+	for dealer := range dealers {
+		ids = append(ids, dealer)
+	}
+	groupSk, err := crypto.ReconstructSecret(uint32(len(dealers)), ids, points, false)
 	if err != nil {
-		return fmt.Errorf("could not reconstruct group secret for next epoch: %s", err.Error())
+		// an InsufficientContributionsError names the dealers whose shares are missing
+		return err
+	}
+	if expected, found := nextEpoch.PublicShare(p.Id); found {
+		pk := bls.CastFromPublicKey(bls.CastToSecretKey(groupSk).GetPublicKey())
+		if !pk.IsEqual(expected) {
+			return fmt.Errorf("P %d, reconstructed secret for epoch %d does not match its public share", p.Id, nextEpoch.Number)
+		}
 	}
 
 	// save for next epoch
-	nextEpoch := p.Node.State.GetEpoch(epoch.Number + 1)
 	nextEpoch.ParticipantShare = groupSk
 	err = p.Node.State.SaveEpoch(nextEpoch)
 	if err != nil {
 		return fmt.Errorf("could not save group secret for next epoch: %s", err.Error())
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// deserializes a dealer's redistribution commitments and verifies commitment[0] is the dealer's public share
+// for the current epoch, i.e. the dealer redistributes the share it actually holds, and that the dealer's
+// polynomial has the degree of the target pool's threshold.
+func (p *Participant) verifiedDealerCommitments(epoch *state.Epoch, dealer shared.ParticipantId, data [][]byte, targetPoolSize int) ([]bls.G1, error) {
+	commitments,err := crypto.DeserializeCommitments(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(commitments) == 0 {
+		return nil, fmt.Errorf("no commitments")
+	}
+	expected := state.PoolThreshold(shared.PoolSize(targetPoolSize))
+	if len(commitments) != int(expected) {
+		return nil, fmt.Errorf("expected %d commitments, got %d", expected, len(commitments))
+	}
+
+	publicShare, found := epoch.PublicShare(dealer)
+	if !found {
+		return nil, fmt.Errorf("unknown public share for epoch %d", epoch.Number)
+	}
+	if !commitments[0].IsEqual(publicShare) {
+		return nil, fmt.Errorf("commitment does not match public share for epoch %d", epoch.Number)
+	}
+	return commitments, nil
+}
+
+// the dealers a pool's next epoch sharing is built from (with their commitments), the threshold lowest current
+// members with valid commitments. Every participant picks them from the same public commitments, the
+// recipients' shares and every next epoch public share are interpolated from exactly these dealers.
+func (p *Participant) redistributionDealers(epoch *state.Epoch, members []shared.ParticipantId, targetPoolSize int) (map[shared.ParticipantId][]bls.G1, error) {
+	threshold := state.PoolThreshold(shared.PoolSize(len(members)))
+	ret := make(map[shared.ParticipantId][]bls.G1)
+	for _, dealer := range pool_chain.SortedParticipants(members) {
+		if len(ret) == int(threshold) {
+			break
+		}
+		c,err := p.verifiedDealerCommitments(epoch, dealer, p.Node.DealerCommitments(epoch.Number, dealer), targetPoolSize)
+		if err != nil {
+			continue
+		}
+		ret[dealer] = c
+	}
+	if len(ret) < int(threshold) {
+		return nil, fmt.Errorf("not enough valid commitments in epoch %d, threshold %d, got %d", epoch.Number, threshold, len(ret))
+	}
+	return ret, nil
+}
+
+// every pool's next epoch public shares are derived from the (public) commitments of its redistribution dealers
+func (p *Participant) computeNextEpochPublicShares(epoch *state.Epoch, nextEpoch *state.Epoch, currentPools map[shared.PoolId][]shared.ParticipantId, nextPools map[shared.PoolId][]shared.ParticipantId) error {
+	for poolId, members := range currentPools {
+		// liquidating pools are not redistributed
+		if _, found := nextPools[poolId]; !found {
+			continue
+		}
+		commitments, err := p.redistributionDealers(epoch, members, len(nextPools[poolId]))
+		if err != nil {
+			log.Printf("P %d, pool %d: %s", p.Id, poolId, err.Error())
+			continue
+		}
+
+		for _, m := range nextPools[poolId] {
+			publicShare,err := crypto.RedistributedPublicShare(commitments, m)
+			if err != nil {
+				return err
+			}
+			nextEpoch.SetPublicShare(m, publicShare)
+		}
+	}
+	return nil
+}
+
+func isPoolMember(members []shared.ParticipantId, id shared.ParticipantId) bool {
+	for _, m := range members {
+		if m == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package participant
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// drops the dealer's redistributed share to one recipient, its commitments still get out with the other shares
+type withholdingSharesNet struct {
+	net.P2P
+	to shared.ParticipantId
+}
+
+func (n *withholdingSharesNet) BroadcastShare(share *pb.ShareDistribution) error {
+	if share.ToParticipant.Id == n.to {
+		return nil
+	}
+	return n.P2P.BroadcastShare(share)
+}
+
+// pool 1's lowest dealer withholds one recipient's share. The recipient doesn't fall back to other dealers (its share
+// wouldn't match its public share), it fails the redistribution. Every other recipient's share matches its public
+// share. The victim doesn't exit, it processes the epoch's end without a share for the next epoch.
+func TestRedistributionWithheldShare(t *testing.T) {
+	crypto.InitBLS()
+
+	config := net.NewTestNetworkConfig()
+	participants := newTestNetwork(t, config)
+	current, err := participants[0].Node.State.GetEpoch(0).PoolsParticipantIds()
+	require.NoError(t, err)
+	next, err := participants[0].Node.State.GetEpoch(1).PoolsParticipantIds()
+	require.NoError(t, err)
+	dealer := pool_chain.SortedParticipants(current[1])[0]
+	victim := next[1][0]
+	if victim == dealer {
+		victim = next[1][1]
+	}
+	participants[dealer - 1].Node.Net = &withholdingSharesNet{P2P: participants[dealer - 1].Node.Net, to: victim}
+	runTestGenesisDKG(t, participants)
+
+	for _, p := range participants {
+		p.epochInit(p.Node.State.GetEpoch(0))
+	}
+	for _, p := range participants {
+		pool, err := p.Node.State.GetEpoch(1).ParticipantPoolAssignment(p.Id)
+		require.NoError(t, err)
+		expected := len(current[pool])
+		if p.Id == victim {
+			expected--
+		}
+		require.Eventually(t, func() bool { return len(p.Node.EpochShares(0)) == expected }, time.Second, time.Millisecond * 10, "P %d", p.Id)
+	}
+
+	for _, p := range participants {
+		err := p.reconstructGroupSecretForNextEpoch(p.Node.State.GetEpoch(0))
+		nextEpoch := p.Node.State.GetEpoch(1)
+		if p.Id == victim {
+			_, insufficient := err.(*crypto.InsufficientContributionsError)
+			require.True(t, insufficient, "%v", err)
+			require.Nil(t, nextEpoch.ParticipantShare)
+
+			// the epoch fails for the victim, it still processes the epoch's end
+			p.epochEnd(p.Node.State.GetEpoch(0))
+			require.True(t, p.EpochEnded(0))
+			require.Nil(t, p.Node.State.GetEpoch(1).ParticipantShare)
+			continue
+		}
+		require.NoError(t, err, "P %d", p.Id)
+		publicShare, found := nextEpoch.PublicShare(p.Id)
+		require.True(t, found, "P %d", p.Id)
+		require.True(t, publicShare.IsEqual(bls.CastFromPublicKey(bls.CastToSecretKey(nextEpoch.ParticipantShare).GetPublicKey())), "P %d", p.Id)
+	}
+}
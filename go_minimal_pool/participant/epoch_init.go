@@ -2,9 +2,10 @@ package participant
 
 import (
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
-	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
 	"github.com/google/uuid"
 	"log"
 )
@@ -17,6 +18,12 @@ func (p *Participant) epochInit(epoch *state.Epoch) {
 
 	log.Printf("P %d, epoch %d init", p.Id, epoch.Number)
 
+	// e.g. a restarted node that missed the epoch's redistribution, it gets a share again at the epoch's end
+	if epoch.ParticipantShare == nil {
+		log.Printf("P %d, no share for epoch %d, skipping", p.Id, epoch.Number)
+		return
+	}
+
 	// find share distro target
 	nextEpoch := p.Node.State.GetEpoch(epoch.Number + 1)
 	nextEpochPools,err := nextEpoch.PoolsParticipantIds()
@@ -27,12 +34,17 @@ func (p *Participant) epochInit(epoch *state.Epoch) {
 	if err != nil {
 		log.Fatalf("P %d err fetching current epoch's pool: %s", p.Id, err.Error())
 	}
-	sharePoolTarget := nextEpochPools[currentPool]
+	// a liquidating pool stops rotating, its members sign its exit message at mid
+	sharePoolTarget, rotates := nextEpochPools[currentPool]
+	if !rotates {
+		log.Printf("P %d, pool %d is liquidating, not redistributing", p.Id, currentPool)
+		return
+	}
 
 
-	// generate re-distro shares
-	config := net.NewTestNetworkConfig()
-	distro,err := crypto.NewRedistribuition(config.PoolThreshold -1, epoch.ParticipantShare)
+	// generate re-distro shares, the polynomial has the target pool's threshold of coefficients (as in the DKG)
+	threshold := state.PoolThreshold(shared.PoolSize(len(sharePoolTarget)))
+	distro,err := crypto.NewRedistribuition(threshold, epoch.ParticipantShare)
 	if err != nil {
 		log.Fatalf("P %d err instantiating NewRedistribuition: %s", p.Id, err.Error())
 	}
@@ -41,19 +53,34 @@ func (p *Participant) epochInit(epoch *state.Epoch) {
 		log.Fatalf("P %d err generating re-distro shares: %s", p.Id, err.Error())
 	}
 
+	commitments := crypto.SerializeCommitments(distro.Commitments())
+
 	// broadcast
 	for k,v := range shares {
 		share := &pb.ShareDistribution{
 			Id:              uuid.New().String(),
 			FromParticipant: &pb.Participant{Id: p.Id},
 			ToParticipant:   &pb.Participant{Id: k},
-			Share:           v.Serialize(),
-			Commitments:     nil,
+			Commitments:     commitments,
 			PoolId:          uint32(currentPool),
 			Epoch:           epoch.Number,
 		}
 
-		err := p.Node.Net.BroadcastShare(share)
+		// only the recipient can read the share
+		to := p.Node.State.GetParticipant(k)
+		if to == nil {
+			log.Printf("P %d, unknown participant %d, can't encrypt share", p.Id, k)
+			continue
+		}
+		share.Share, err = crypto.Encrypt(to.EncryptionPk, v.Serialize(), pool_chain.ShareAAD(share))
+		if err != nil {
+			log.Printf("P %d, could not encrypt share to %d: %s", p.Id, k, err.Error())
+			continue
+		}
+
+		share.Signature = p.sign(pool_chain.ShareSigningRoot(share))
+
+		err = p.Node.Net.BroadcastShare(share)
 		if err != nil {
 			log.Printf("broadcasting error: %s", err.Error())
 		}
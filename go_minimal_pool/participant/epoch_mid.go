@@ -2,7 +2,8 @@ package participant
 
 import (
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
-	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
 	"github.com/google/uuid"
@@ -15,24 +16,56 @@ func (p *Participant) epochMid(epoch *state.Epoch) {
 	p.epochProcessingLock.Lock()
 	defer p.epochProcessingLock.Unlock()
 
-	log.Printf("P %d, epoch %d mid with %d shares", p.Id, epoch.Number, len(p.Node.SharesPerEpoch[epoch.Number]))
+	log.Printf("P %d, epoch %d mid with %d shares", p.Id, epoch.Number, len(p.Node.EpochShares(epoch.Number)))
+
+	// e.g. a restarted node that missed the epoch's redistribution, it gets a share again at the epoch's end
+	if epoch.ParticipantShare == nil {
+		log.Printf("P %d, no share for epoch %d, skipping", p.Id, epoch.Number)
+		return
+	}
 
 	currentPool,err := epoch.ParticipantPoolAssignment(p.Id)
 	if err != nil {
 		log.Fatalf("P %d err fetching current epoch's pool: %s", p.Id, err.Error())
 	}
 
-	config := net.NewTestNetworkConfig()
-	sigInG2 := crypto.Sign(epoch.ParticipantShare, config.EpochTestMessage)
-	sig := &pb.SignatureDistribution{
-		Id:              uuid.New().String(),
-		FromParticipant: &pb.Participant{Id: p.Id},
-		Sig:           	 sigInG2.Serialize(),
-		PoolId:          uint32(currentPool),
-		Epoch:           epoch.Number,
+	// a partial sig for every one of the pool's duties
+	duties,err := p.poolDuties(currentPool, epoch.Number)
+	if err != nil {
+		log.Printf("P %d, %s", p.Id, err.Error())
+		return
 	}
-	err = p.Node.Net.BroadcastSignature(sig)
+	roots,err := p.poolSigningRoots(currentPool, epoch.Number)
 	if err != nil {
-		log.Printf("broadcasting error: %s", err.Error())
+		log.Printf("P %d, %s", p.Id, err.Error())
+		return
+	}
+	for i, root := range roots {
+		// the message keeps a slice of it
+		root := root
+		sig := &pb.SignatureDistribution{
+			Id:              uuid.New().String(),
+			FromParticipant: &pb.Participant{Id: p.Id},
+			PoolId:          uint32(currentPool),
+			Epoch:           epoch.Number,
+			SigningRoot:     root[:],
+		}
+		err = p.checkSlashing(currentPool, duties[i], root)
+		if err != nil {
+			if _, slashable := err.(*eth2.SlashableError); !slashable {
+				log.Printf("P %d, pool %d: not signing %s duty: %s", p.Id, currentPool, duties[i].Type.String(), err.Error())
+				continue
+			}
+			// a refusal without a sig, the duty isn't due from us (see state/rewards.go)
+			log.Printf("P %d, pool %d: %s", p.Id, currentPool, err.Error())
+		} else {
+			sig.Sig = crypto.Sign(epoch.ParticipantShare, root[:]).Serialize()
+		}
+		sig.Signature = p.sign(pool_chain.SignatureSigningRoot(sig))
+
+		err = p.Node.Net.BroadcastSignature(sig)
+		if err != nil {
+			log.Printf("broadcasting error: %s", err.Error())
+		}
 	}
 }
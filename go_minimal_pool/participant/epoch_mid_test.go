@@ -0,0 +1,83 @@
+package participant
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// keeps the partial sigs the participant broadcasts
+type signaturesNet struct {
+	net.P2P
+	sigs []*pb.SignatureDistribution
+}
+
+func (n *signaturesNet) BroadcastSignature(sig *pb.SignatureDistribution) error {
+	n.sigs = append(n.sigs, sig)
+	return nil
+}
+
+// a member that already signed a conflicting attestation for the pool's key doesn't release its partial sig for the
+// epoch's attestation, it sends a refusal and still signs the pool's other duties. A member without a conflict signs
+// them all.
+func TestEpochMidSlashingProtection(t *testing.T) {
+	crypto.InitBLS()
+
+	config := net.NewTestNetworkConfig()
+	participants := newTestNetwork(t, config)
+	pools, err := participants[0].Node.State.GetEpoch(0).PoolsParticipantIds()
+	require.NoError(t, err)
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+
+	for i, id := range pools[1][:2] {
+		p := participants[id - 1]
+		require.NoError(t, p.Node.State.SetPoolPk(1, 3, sk.GetPublicKey()))
+		captured := &signaturesNet{P2P: p.Node.Net}
+		p.Node.Net = captured
+		epoch := p.Node.State.GetEpoch(0)
+		epoch.ParticipantShare = &bls.Fr{}
+		epoch.ParticipantShare.SetByCSPRNG()
+
+		duties, err := p.poolDuties(1, 0)
+		require.NoError(t, err)
+		roots, err := p.poolSigningRoots(1, 0)
+		require.NoError(t, err)
+		conflicting := i == 0
+		var attestation eth2.Root
+		for j, duty := range duties {
+			if duty.Type == eth2.DutyAttestation {
+				attestation = roots[j]
+				if conflicting {
+					require.NoError(t, p.checkSlashing(1, duty, eth2.Root{1}))
+				}
+			}
+		}
+
+		p.epochMid(epoch)
+		signed := make(map[eth2.Root]bool)
+		refused := make(map[eth2.Root]bool)
+		for _, sig := range captured.sigs {
+			require.EqualValues(t, 1, sig.PoolId)
+			var root eth2.Root
+			copy(root[:], sig.SigningRoot)
+			if pool_chain.IsRefusal(sig) {
+				refused[root] = true
+			} else {
+				signed[root] = true
+			}
+		}
+		require.Equal(t, !conflicting, signed[attestation], "P %d", p.Id)
+		require.Equal(t, conflicting, refused[attestation], "P %d", p.Id)
+		if conflicting {
+			require.Len(t, signed, len(roots) - 1, "P %d", p.Id)
+		} else {
+			require.Len(t, signed, len(roots), "P %d", p.Id)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package participant
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/beacon"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/simple_net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// in process network, every participant connected to every other one
+func newTestNetwork(t *testing.T, config *net.NetworkConfig) []*Participant {
+	ret := make([]*Participant, 0)
+	for _, id := range config.ParticipantIndexesList() {
+		p := NewParticipant(id)
+		p.SetNode(pool_chain.NewChainNode(config, state.NewInMemoryState(config), simple_net.NewSimpleP2P()))
+		ret = append(ret, p)
+	}
+	for i, p1 := range ret {
+		p1.Node.Net.AddPeer(p1.Node.Net.OwnPeer())
+		for _, p2 := range ret {
+			require.NoError(t, p1.Node.State.SaveParticipant(state.NewParticipant(p2.Id, p2.EncryptionPk, p2.IdentityPk)))
+		}
+		for j := i + 1 ; j < len(ret) ; j++ {
+			net.BiDirectionalConnection(p1.Node.Net, ret[j].Node.Net)
+		}
+	}
+	return ret
+}
+
+func runTestGenesisDKG(t *testing.T, participants []*Participant) {
+	wg := sync.WaitGroup{}
+	for _, p := range participants {
+		wg.Add(1)
+		go func(p *Participant) {
+			defer wg.Done()
+			require.NoError(t, p.RunGenesisDKG())
+		}(p)
+	}
+	wg.Wait()
+
+	for _, p := range participants {
+		require.Eventually(t, p.Node.GenesisReady, time.Second * 2, time.Millisecond * 10)
+	}
+}
+
+// epochs run on the participants' own timers, waits until every one of them processed the epoch's end
+func waitEpochEnd(t *testing.T, participants []*Participant, number shared.EpochNumber) {
+	for _, p := range participants {
+		timeout := p.Node.Config.EpochSpanSec * time.Duration(number + 3)
+		require.Eventually(t, func() bool { return p.EpochEnded(number) }, timeout, time.Millisecond * 50, "P %d, epoch %d", p.Id, number)
+	}
+}
+
+// stops the participants' epochs once the epoch started and waits for its end, later epochs would run into the
+// test's checks (or the next test)
+func stopAfterEpoch(t *testing.T, participants []*Participant, number shared.EpochNumber) {
+	for _, p := range participants {
+		timeout := p.Node.Config.EpochSpanSec * time.Duration(number + 1)
+		require.Eventually(t, func() bool { return p.Node.CurrentEpochNumber() >= number }, timeout, time.Millisecond * 10, "P %d", p.Id)
+		p.Node.StopEpochProcessing()
+	}
+	waitEpochEnd(t, participants, number)
+}
+
+// the epoch's status read under the participant's epoch processing, a late epoch end could still be updating it
+func epochStatus(p *Participant, number uint32) (bool, int) {
+	p.epochProcessingLock.Lock()
+	defer p.epochProcessingLock.Unlock()
+
+	epoch := p.Node.State.GetEpoch(number)
+	return epoch.EpochSigVerified, len(epoch.BadSigners)
+}
+
+// two differently configured networks run side by side, every one with its own pools and thresholds. The end to end
+// test, it runs the networks' epochs on their timers.
+func TestNetworksCoexist(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs 2 epochs of two networks")
+	}
+	crypto.InitBLS()
+
+	small := net.NewTestNetworkConfig()
+	small.EpochSpanSec = time.Second * 4
+
+	// uneven, pools of 4 (threshold 3) and 3 (threshold 2)
+	large := net.NewTestNetworkConfig()
+	large.GenesisParticipants = 7
+	large.EpochSpanSec = time.Second * 4
+	large.GenesisSeed = [32]byte{1,2,3}
+
+	networks := [][]*Participant{newTestNetwork(t, small), newTestNetwork(t, large)}
+	require.Len(t, networks[0], 6)
+	require.Len(t, networks[1], 7)
+	// the small network's pools validate through a beacon node
+	beaconNode := beacon.NewFakeBeaconNode()
+	for _, p := range networks[0] {
+		p.Node.Beacon = beaconNode
+		p.Duties = beaconNode
+	}
+
+	wg := sync.WaitGroup{}
+	for _, network := range networks {
+		wg.Add(1)
+		go func(network []*Participant) {
+			defer wg.Done()
+			runTestGenesisDKG(t, network)
+		}(network)
+	}
+	wg.Wait()
+
+	for i, network := range networks {
+		config := network[0].Node.Config
+		pools, err := network[0].Node.State.GetEpoch(0).PoolsParticipantIds()
+		require.NoError(t, err)
+		require.Len(t, pools, int(config.NumberOfPools), "network %d", i)
+		sizes := make([]int, 0)
+		for _, members := range pools {
+			sizes = append(sizes, len(members))
+		}
+		require.ElementsMatch(t, [][]int{{3, 3}, {4, 3}}[i], sizes, "network %d", i)
+		require.Len(t, network[0].Node.State.Pools(), int(config.NumberOfPools), "network %d", i)
+	}
+
+	// run 2 epochs, the second one signs with redistributed shares
+	for _, network := range networks {
+		for _, p := range network {
+			p.StartEpochProcessing()
+		}
+	}
+	stopAfterEpoch(t, append(networks[0], networks[1]...), 1)
+
+	for i, network := range networks {
+		for _, p := range network {
+			for number := uint32(0) ; number < 2 ; number++ {
+				verified, badSigners := epochStatus(p, number)
+				require.True(t, verified, "network %d, P %d, epoch %d", i, p.Id, number)
+				require.Zero(t, badSigners)
+			}
+		}
+	}
+
+	// every participant of a network finalized the same chain of blocks, with every pool's signatures. The
+	// genesis DKG's pool keys are in block 0.
+	for i, network := range networks {
+		for number := uint32(0) ; number < 2 ; number++ {
+			for _, p := range network {
+				require.Eventually(t, func() bool { return p.Node.FinalizedBlock(number) != nil }, time.Second * 4, time.Millisecond * 50, "network %d, P %d, epoch %d", i, p.Id, number)
+			}
+			block := network[0].Node.FinalizedBlock(number)
+			if number == 0 {
+				require.Len(t, block.Body.PoolKeys, int(network[0].Node.Config.NumberOfPools))
+				require.Equal(t, eth2.Root{}, block.ParentRoot)
+			} else {
+				require.Len(t, block.Body.PoolKeys, 0)
+				require.Equal(t, state.BlockRoot(network[0].Node.FinalizedBlock(number - 1)), block.ParentRoot)
+			}
+			require.Len(t, block.Body.Disqualified, 0)
+			// every participant redistributed its share and signed its pool's duties in time
+			require.Len(t, block.Body.Contributions, len(network))
+			for _, c := range block.Body.Contributions {
+				require.Equal(t, state.ContributionValid, c.Share, "network %d, P %d, epoch %d", i, c.ParticipantId, number)
+				require.Equal(t, state.ContributionValid, c.Signatures, "network %d, P %d, epoch %d", i, c.ParticipantId, number)
+			}
+			pools := make(map[shared.PoolId]bool)
+			for _, sig := range block.Body.Signatures {
+				pools[sig.PoolId] = true
+			}
+			require.Len(t, pools, int(network[0].Node.Config.NumberOfPools))
+			for _, p := range network {
+				require.Equal(t, state.EncodeBlock(block), state.EncodeBlock(p.Node.FinalizedBlock(number)), "network %d, P %d, epoch %d", i, p.Id, number)
+			}
+		}
+		for _, p := range network {
+			finalized, err := p.Node.FinalizedState()
+			require.NoError(t, err)
+			require.Equal(t, network[0].Node.FinalizedBlock(1).StateRoot, finalized.HashTreeRoot(), "network %d, P %d", i, p.Id)
+			// a share and signatures in both epochs
+			reward := 4 * p.Node.Config.ContributionReward
+			require.Equal(t, reward, finalized.GetParticipant(p.Id).Balance, "network %d, P %d", i, p.Id)
+			require.Eventually(t, func() bool { return p.Node.State.GetParticipant(p.Id).Balance == reward }, time.Second, time.Millisecond * 50, "network %d, P %d", i, p.Id)
+		}
+	}
+
+	// every pool's attestation for both epochs, submitted once
+	for _, validator := range []eth2.ValidatorIndex{1, 2} {
+		attestations := make([]eth2.Epoch, 0)
+		for _, submission := range beaconNode.Submissions(validator) {
+			if submission.Duty.Type == eth2.DutyAttestation {
+				attestations = append(attestations, submission.Duty.Epoch)
+			}
+		}
+		require.Equal(t, []eth2.Epoch{0, 1}, attestations, "validator %d", validator)
+	}
+}
+
+// participants 7 and 8 join and participant 1 exits, all take effect from epoch 2. One of the pools grows from 3
+// (threshold 2) to 4 (threshold 3) members.
+func TestRegistryUpdates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs 3 epochs with joiners")
+	}
+	crypto.InitBLS()
+
+	config := net.NewTestNetworkConfig()
+	config.EpochSpanSec = time.Second * 4
+	genesis := newTestNetwork(t, config)
+
+	joiners := []*Participant{NewParticipant(7), NewParticipant(8)}
+	for i, joiner := range joiners {
+		joiner.SetNode(pool_chain.NewChainNode(config, state.NewInMemoryState(config), simple_net.NewSimpleP2P()))
+		joiner.Node.Net.AddPeer(joiner.Node.Net.OwnPeer())
+		for _, p := range genesis {
+			require.NoError(t, joiner.Node.State.SaveParticipant(state.NewParticipant(p.Id, p.EncryptionPk, p.IdentityPk)))
+			net.BiDirectionalConnection(p.Node.Net, joiner.Node.Net)
+		}
+		for _, other := range joiners[:i] {
+			net.BiDirectionalConnection(other.Node.Net, joiner.Node.Net)
+		}
+	}
+	all := append(genesis, joiners...)
+	// signed requests gossiped to every node, not registered anywhere before a block includes them
+	for _, joiner := range joiners {
+		_, err := joiner.RequestJoin()
+		require.NoError(t, err)
+	}
+	_, err := genesis[0].RequestExit()
+	require.NoError(t, err)
+	for _, p := range all {
+		require.Nil(t, p.Node.State.GetParticipant(7))
+		require.False(t, p.Node.State.GetParticipant(1).ExitRequested)
+	}
+
+	// the joiners follow the genesis DKG's pool keys and public shares
+	runTestGenesisDKG(t, genesis)
+	for _, joiner := range joiners {
+		require.Eventually(t, joiner.Node.GenesisReady, time.Second * 2, time.Millisecond * 10)
+	}
+
+	for _, p := range all {
+		p.StartEpochProcessing()
+	}
+	stopAfterEpoch(t, all, 2)
+
+	for _, p := range all {
+		pools, err := p.Node.State.GetEpoch(2).PoolsParticipantIds()
+		require.NoError(t, err)
+		members := make([]shared.ParticipantId, 0)
+		for _, m := range pools {
+			members = append(members, m...)
+		}
+		require.ElementsMatch(t, []shared.ParticipantId{2, 3, 4, 5, 6, 7, 8}, members, "P %d", p.Id)
+		require.ElementsMatch(t, []int{4, 3}, []int{len(pools[1]), len(pools[2])})
+
+		for number := uint32(0) ; number < 3 ; number++ {
+			if !p.Node.State.GetParticipant(p.Id).IsActive(number) {
+				continue
+			}
+			verified, badSigners := epochStatus(p, number)
+			require.True(t, verified, "P %d, epoch %d", p.Id, number)
+			require.Zero(t, badSigners)
+		}
+	}
+	require.Equal(t, state.ParticipantExited, genesis[0].Node.State.GetParticipant(1).Status(2))
+
+	// the changes were agreed on in epoch 0's block, the joiners followed the chain before they joined the pools
+	for _, p := range all {
+		require.Eventually(t, func() bool { return p.Node.FinalizedBlock(0) != nil }, time.Second * 4, time.Millisecond * 50, "P %d", p.Id)
+		joins := make([]shared.ParticipantId, 0)
+		exits := make([]shared.ParticipantId, 0)
+		for _, req := range p.Node.FinalizedBlock(0).Body.Requests {
+			if req.Type == pb.RegistryRequestType_JOIN {
+				joins = append(joins, req.FromParticipant.Id)
+			} else if req.Type == pb.RegistryRequestType_EXIT {
+				exits = append(exits, req.FromParticipant.Id)
+			}
+		}
+		require.ElementsMatch(t, []shared.ParticipantId{7, 8}, joins, "P %d", p.Id)
+		require.Equal(t, []shared.ParticipantId{1}, exits, "P %d", p.Id)
+	}
+	for _, p := range all[1:] {
+		require.Eventually(t, func() bool { return p.Node.FinalizedBlock(2) != nil }, time.Second * 4, time.Millisecond * 50, "P %d", p.Id)
+		require.Len(t, p.Node.FinalizedBlock(2).Body.Requests, 0)
+		finalized, err := p.Node.FinalizedState()
+		require.NoError(t, err)
+		require.EqualValues(t, 2, finalized.GetParticipant(7).ActivationEpoch)
+		require.Equal(t, state.ParticipantExited, finalized.GetParticipant(1).Status(2))
+		require.Equal(t, all[1].Node.FinalizedBlock(2).StateRoot, finalized.HashTreeRoot(), "P %d", p.Id)
+	}
+}
+
+// pool 3 is funded and pool 1 liquidated by requests in epoch 0's block. Pool 1 signs its voluntary exit in epoch 1,
+// its last, pool 3 runs its DKG during epoch 1 and signs from epoch 2.
+func TestPoolLifecycle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs 3 epochs with a new and a liquidated pool")
+	}
+	crypto.InitBLS()
+
+	config := net.NewTestNetworkConfig()
+	config.GenesisParticipants = 9
+	config.EpochSpanSec = time.Second * 5
+	network := newTestNetwork(t, config)
+	require.EqualValues(t, 3, network[0].Node.State.Registry().NextPoolId())
+	_, err := network[0].RequestPoolCreation(3)
+	require.NoError(t, err)
+	_, err = network[0].RequestPoolDeposit(3, state.PoolStake)
+	require.NoError(t, err)
+	_, err = network[1].RequestPoolLiquidation(1)
+	require.NoError(t, err)
+	runTestGenesisDKG(t, network)
+
+	for _, p := range network {
+		p.StartEpochProcessing()
+	}
+	stopAfterEpoch(t, network, 2)
+
+	for _, p := range network {
+		liquidated := p.Node.State.GetPool(1)
+		require.EqualValues(t, 2, liquidated.ExitEpoch, "P %d", p.Id)
+		require.Equal(t, state.PoolLiquidated, liquidated.Status(2))
+		// only the pool's members reconstruct the exit signature
+		poolId, err := p.Node.State.GetEpoch(1).ParticipantPoolAssignment(p.Id)
+		require.NoError(t, err)
+		if poolId == 1 {
+			require.NotNil(t, liquidated.ExitSignature, "P %d", p.Id)
+			root, err := config.Fork().SigningRoot(eth2.NewVoluntaryExitDuty(liquidated.VoluntaryExit()))
+			require.NoError(t, err)
+			require.True(t, liquidated.ExitSignature.VerifyByte(liquidated.Pk, root[:]), "P %d", p.Id)
+		}
+
+		activated := p.Node.State.GetPool(3)
+		require.EqualValues(t, 2, activated.ActivationEpoch, "P %d", p.Id)
+		require.NotNil(t, activated.Pk, "P %d", p.Id)
+
+		pools, err := p.Node.State.GetEpoch(2).PoolsParticipantIds()
+		require.NoError(t, err)
+		require.Len(t, pools, 2)
+		require.Contains(t, pools, shared.PoolId(3))
+
+		for number := uint32(0) ; number < 3 ; number++ {
+			verified, badSigners := epochStatus(p, number)
+			require.True(t, verified, "P %d, epoch %d", p.Id, number)
+			require.Zero(t, badSigners)
+		}
+
+		// the lifecycle is replayed from the blocks, the exit signature was in epoch 1's
+		require.Eventually(t, func() bool { return p.Node.FinalizedBlock(1) != nil }, time.Second * 4, time.Millisecond * 50, "P %d", p.Id)
+		replayed, err := state.Replay(p.Node.State.Genesis(), p.Node.State)
+		require.NoError(t, err)
+		require.NotNil(t, replayed.GetPool(1).ExitSignature, "P %d", p.Id)
+		require.EqualValues(t, 2, replayed.GetPool(3).ActivationEpoch)
+	}
+}
@@ -1,9 +1,13 @@
 package participant
 
 import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
 	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/consensus"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
 	"log"
 	"sync"
 	"time"
@@ -12,22 +16,83 @@ import (
 type Participant struct {
 	Id   shared.ParticipantId
 	Node *pool_chain.PoolChainNode
+	// long term key, shares sent to the participant are encrypted to EncryptionPk
+	EncryptionPk *bls.G1
+	encryptionSk *bls.Fr
+	// long term key, signs every message the participant sends
+	IdentityPk *bls.PublicKey
+	identitySk *bls.SecretKey
+	// the duties the participant's pools sign, every participant must use the same source. The node's beacon node if
+	// it has one
+	Duties eth2.DutySource
+	// duties fetched per epoch and pool, a beacon node could answer differently later in the epoch
+	duties map[shared.EpochNumber]map[shared.PoolId][]*eth2.Duty
+	dutiesLock sync.Mutex
+	// checked before releasing a partial signature, in memory unless set before SetNode
+	SlashingProtection *eth2.SlashingProtection
 
 	epochProcessingLock sync.Mutex
+	// epochs whose end was processed, guarded by epochProcessingLock
+	endedEpochs map[shared.EpochNumber]bool
 }
 
 func NewParticipant(id shared.ParticipantId) *Participant {
+	encryptionSk, _ := crypto.NewEncryptionKey()
+	identitySk := &bls.SecretKey{}
+	identitySk.SetByCSPRNG()
+	return NewParticipantWithKeys(id, encryptionSk, identitySk)
+}
+
+// for participants with long term keys (e.g. loaded from a keystore)
+func NewParticipantWithKeys(id shared.ParticipantId, encryptionSk *bls.Fr, identitySk *bls.SecretKey) *Participant {
 	return &Participant{
 		Id:   id,
+		EncryptionPk: bls.CastFromPublicKey(bls.CastToSecretKey(encryptionSk).GetPublicKey()),
+		encryptionSk: encryptionSk,
+		IdentityPk: identitySk.GetPublicKey(),
+		identitySk: identitySk,
+		Duties: eth2.NewLocalDutySource(),
+		duties: make(map[shared.EpochNumber]map[shared.PoolId][]*eth2.Duty),
+		endedEpochs: make(map[shared.EpochNumber]bool),
 	}
 }
 
 func (p *Participant) SetNode(node *pool_chain.PoolChainNode) {
 	p.Node = node
 	p.Node.FilterId = p.Id
+	p.Node.SetEncryptionKey(p.encryptionSk)
+	if node.Beacon != nil {
+		p.Duties = node.Beacon
+	}
+	if p.SlashingProtection == nil {
+		p.SlashingProtection = eth2.NewSlashingProtection(node.Config.GenesisValidatorsRoot)
+	}
+	if node.Consensus == nil {
+		node.Consensus = consensus.NewTendermint(p.Id, node.Config.EpochSpanSec / 20, p.broadcastConsensusMessage)
+	}
+}
+
+// signs a message's canonical encoding with the participant's identity key
+func (p *Participant) sign(root []byte) []byte {
+	return p.identitySk.SignByte(root).Serialize()
 }
 
 func (p *Participant) StartEpochProcessing() {
+	p.processEpochs()
+	p.Node.StartEpochProcessing()
+
+	log.Printf("Participant %d started", p.Id)
+}
+
+// epochs are aligned to genesis, for participants running in separate processes
+func (p *Participant) StartEpochProcessingAt(genesis time.Time) {
+	p.processEpochs()
+	p.Node.StartEpochProcessingAt(genesis)
+
+	log.Printf("Participant %d started, genesis %s", p.Id, genesis.String())
+}
+
+func (p *Participant) processEpochs() {
 	go func() {
 		for {
 			select {
@@ -37,10 +102,15 @@ func (p *Participant) StartEpochProcessing() {
 			}
 		}
 	}()
+}
 
-	p.Node.StartEpochProcessing()
+// true once the epoch's end was processed, its signatures are then final (its block, and with it the epoch's
+// registry updates, may still be agreed on)
+func (p *Participant) EpochEnded(number shared.EpochNumber) bool {
+	p.epochProcessingLock.Lock()
+	defer p.epochProcessingLock.Unlock()
 
-	log.Printf("Participant %d started", p.Id)
+	return p.endedEpochs[number]
 }
 
 func (p *Participant) KillC() <- chan bool {
@@ -48,6 +118,9 @@ func (p *Participant) KillC() <- chan bool {
 }
 
 func (p *Participant) timeEpoch(epoch *state.Epoch) {
+	// pools activated next epoch run their DKG from the start of the epoch
+	go p.runActivatedPoolDKG(epoch)
+
 	// start happens at 1/4 of the epoch
 	go func() {
 		d := time.Duration(p.Node.Config.EpochSpanSec / 4)
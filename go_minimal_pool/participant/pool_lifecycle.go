@@ -0,0 +1,78 @@
+package participant
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
+	"time"
+)
+
+// a pool activated next epoch gets its key from a fresh DKG between its members of the next epoch, run from the
+// start of the current epoch so the shares are ready for the next epoch's redistribution. The pool is created by the
+// previous epoch's block, the DKG waits for it to be finalized.
+func (p *Participant) runActivatedPoolDKG(epoch *state.Epoch) {
+	if epoch.Number > 0 && !p.waitFinalized(epoch.Number - 1, p.Node.Config.EpochSpanSec * 3 / 4) {
+		return
+	}
+	nextEpoch := p.Node.State.GetEpoch(epoch.Number + 1)
+	poolId, err := nextEpoch.ParticipantPoolAssignment(p.Id)
+	if err != nil {
+		if _, notInPool := err.(*state.NotInPoolError); !notInPool {
+			log.Printf("P %d err fetching next epoch's pool: %s", p.Id, err.Error())
+		}
+		return
+	}
+	if !isActivatedIn(p.Node.State.GetPool(poolId), nextEpoch.Number) {
+		return
+	}
+
+	err = p.runPoolDKG(nextEpoch.Number, poolId)
+	if err != nil {
+		log.Printf("P %d, DKG for pool %d failed: %s", p.Id, poolId, err.Error())
+	}
+}
+
+// true once the epoch's block is finalized, false if it isn't within the timeout
+func (p *Participant) waitFinalized(number shared.EpochNumber, timeout time.Duration) bool {
+	end := time.After(timeout)
+	for p.Node.FinalizedBlock(number) == nil {
+		select {
+		case <- end:
+			return false
+		case <- time.After(timeout / 50):
+		}
+	}
+	return true
+}
+
+// true if epoch is the pool's first, i.e. its share comes from a DKG and not from a redistribution
+func isActivatedIn(pool *state.Pool, epoch shared.EpochNumber) bool {
+	return pool != nil && pool.ActivationEpoch == epoch
+}
+
+// a liquidating pool's verified voluntary exit signature is its exit signature, the pool is liquidated
+func (p *Participant) recordExitSignature(epoch *state.Epoch, poolId shared.PoolId) {
+	pool := p.Node.State.GetPool(poolId)
+	if pool == nil || pool.ExitEpoch != epoch.Number + 1 || !epoch.EpochSigVerified {
+		return
+	}
+
+	root, err := p.Node.Config.Fork().SigningRoot(eth2.NewVoluntaryExitDuty(pool.VoluntaryExit()))
+	if err != nil {
+		log.Printf("P %d, pool %d exit signing root: %s", p.Id, poolId, err.Error())
+		return
+	}
+	sig, found := epoch.ReconstructedSignatures[root]
+	if !found {
+		log.Printf("P %d, pool %d exit signature not reconstructed", p.Id, poolId)
+		return
+	}
+	err = p.Node.State.SetPoolExitSignature(poolId, bls.CastToSign(sig))
+	if err != nil {
+		log.Printf("P %d, could not save pool %d exit signature: %s", p.Id, poolId, err.Error())
+		return
+	}
+	log.Printf("P %d, pool %d liquidated, exit signature: %s", p.Id, poolId, bls.CastToSign(sig).SerializeToHexStr())
+}
@@ -0,0 +1,48 @@
+package participant
+
+import (
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/google/uuid"
+)
+
+// signs and broadcasts a registry request of the current epoch (see state/requests.go), an epoch's proposer
+// includes it in the epoch's block or the next one. Returns the sent request.
+func (p *Participant) SendRegistryRequest(req *pb.RegistryRequest) (*pb.RegistryRequest, error) {
+	req.Id = uuid.New().String()
+	req.FromParticipant = &pb.Participant{Id: p.Id}
+	req.Epoch = p.Node.CurrentEpochNumber()
+	req.Signature = p.sign(pool_chain.RegistryRequestSigningRoot(req))
+	err := p.Node.Net.BroadcastRegistryRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// asks to join the network with the participant's keys
+func (p *Participant) RequestJoin() (*pb.RegistryRequest, error) {
+	return p.SendRegistryRequest(&pb.RegistryRequest{
+		Type: pb.RegistryRequestType_JOIN,
+		EncryptionPk: p.EncryptionPk.Serialize(),
+		IdentityPk: p.IdentityPk.Serialize(),
+	})
+}
+
+func (p *Participant) RequestExit() (*pb.RegistryRequest, error) {
+	return p.SendRegistryRequest(&pb.RegistryRequest{Type: pb.RegistryRequestType_EXIT})
+}
+
+// asks to create a pending pool with an id no pool has (e.g. Registry.NextPoolId)
+func (p *Participant) RequestPoolCreation(id shared.PoolId) (*pb.RegistryRequest, error) {
+	return p.SendRegistryRequest(&pb.RegistryRequest{Type: pb.RegistryRequestType_CREATE_POOL, PoolId: id})
+}
+
+func (p *Participant) RequestPoolDeposit(id shared.PoolId, gwei uint64) (*pb.RegistryRequest, error) {
+	return p.SendRegistryRequest(&pb.RegistryRequest{Type: pb.RegistryRequestType_DEPOSIT, PoolId: id, Gwei: gwei})
+}
+
+func (p *Participant) RequestPoolLiquidation(id shared.PoolId) (*pb.RegistryRequest, error) {
+	return p.SendRegistryRequest(&pb.RegistryRequest{Type: pb.RegistryRequestType_LIQUIDATION, PoolId: id})
+}
@@ -0,0 +1,175 @@
+package pool_chain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+/**
+	Every message is signed by its sender's identity key over a canonical encoding of all of its fields but the
+	signature itself and the gossip ttl (decremented by relays). The encoding starts with a per message type domain, then every field in proto field order:
+		uint32 - 4 bytes big endian
+		uint64 - 8 bytes big endian
+		bytes/ string - 4 bytes big endian length | data
+		repeated bytes - 4 bytes big endian count | every element as bytes
+		participant - 1 byte presence flag | uint32 id
+	Receivers drop messages that are not signed by the registered identity of FromParticipant, and messages
+	addressed to a participant (shares, deals, complaints ...) that don't name it. A join request isn't registered
+	yet, it's signed by the identity key it carries.
+ */
+
+const (
+	shareDomain = "pool-chain share distribution"
+	sigDomain = "pool-chain signature distribution"
+	dkgDomain = "pool-chain dkg message"
+	consensusDomain = "pool-chain consensus message"
+	registryDomain = "pool-chain registry request"
+)
+
+type canonicalEncoder struct {
+	buf bytes.Buffer
+}
+
+func newCanonicalEncoder(domain string) *canonicalEncoder {
+	ret := &canonicalEncoder{}
+	ret.bytes([]byte(domain))
+	return ret
+}
+
+func (e *canonicalEncoder) uint32(v uint32) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	e.buf.Write(b)
+}
+
+func (e *canonicalEncoder) uint64(v uint64) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	e.buf.Write(b)
+}
+
+func (e *canonicalEncoder) bytes(b []byte) {
+	e.uint32(uint32(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *canonicalEncoder) bytesList(l [][]byte) {
+	e.uint32(uint32(len(l)))
+	for _, b := range l {
+		e.bytes(b)
+	}
+}
+
+func (e *canonicalEncoder) participant(participant *pb.Participant) {
+	if participant == nil {
+		e.buf.WriteByte(0)
+		return
+	}
+	e.buf.WriteByte(1)
+	e.uint32(participant.Id)
+}
+
+func ShareSigningRoot(share *pb.ShareDistribution) []byte {
+	e := newCanonicalEncoder(shareDomain)
+	e.bytes([]byte(share.Id))
+	e.participant(share.FromParticipant)
+	e.participant(share.ToParticipant)
+	e.bytes(share.Share)
+	e.bytesList(share.Commitments)
+	e.uint32(share.PoolId)
+	e.uint32(share.Epoch)
+	return e.buf.Bytes()
+}
+
+func SignatureSigningRoot(sig *pb.SignatureDistribution) []byte {
+	e := newCanonicalEncoder(sigDomain)
+	e.bytes([]byte(sig.Id))
+	e.participant(sig.FromParticipant)
+	e.bytes(sig.Sig)
+	e.uint32(sig.PoolId)
+	e.uint32(sig.Epoch)
+	e.bytes(sig.SigningRoot)
+	return e.buf.Bytes()
+}
+
+func DKGSigningRoot(msg *pb.DKGMessage) []byte {
+	e := newCanonicalEncoder(dkgDomain)
+	e.bytes([]byte(msg.Id))
+	e.uint32(uint32(msg.Type))
+	e.participant(msg.FromParticipant)
+	e.participant(msg.ToParticipant)
+	e.uint32(msg.PoolId)
+	e.bytesList(msg.Commitments)
+	e.bytes(msg.Share)
+	e.bytes(msg.BlindingShare)
+	e.bytes(msg.GroupPk)
+	e.bytesList(msg.PublicShares)
+	return e.buf.Bytes()
+}
+
+func ConsensusSigningRoot(msg *pb.ConsensusMessage) []byte {
+	e := newCanonicalEncoder(consensusDomain)
+	e.bytes([]byte(msg.Id))
+	e.uint32(uint32(msg.Type))
+	e.participant(msg.FromParticipant)
+	e.uint32(msg.Height)
+	e.uint32(msg.Round)
+	e.bytes(msg.Value)
+	e.uint32(uint32(msg.ValidRound))
+	e.bytes(msg.ValueHash)
+	return e.buf.Bytes()
+}
+
+func RegistryRequestSigningRoot(req *pb.RegistryRequest) []byte {
+	e := newCanonicalEncoder(registryDomain)
+	e.bytes([]byte(req.Id))
+	e.uint32(uint32(req.Type))
+	e.participant(req.FromParticipant)
+	e.uint32(req.Epoch)
+	e.bytes(req.EncryptionPk)
+	e.bytes(req.IdentityPk)
+	e.uint32(req.PoolId)
+	e.uint64(req.Gwei)
+	return e.buf.Bytes()
+}
+
+// DKG messages about (or to) another member, they carry it in ToParticipant
+func dkgMessageAddressed(t pb.DKGMessageType) bool {
+	switch t {
+	case pb.DKGMessageType_DEAL, pb.DKGMessageType_COMPLAINT, pb.DKGMessageType_JUSTIFICATION,
+		pb.DKGMessageType_EXTRACTION_COMPLAINT, pb.DKGMessageType_RECONSTRUCTION_SHARE:
+		return true
+	}
+	return false
+}
+
+// verifies the signature was produced by the registered identity key of the sender
+func (p *PoolChainNode) verifyMessageSignature(from *pb.Participant, root []byte, signature []byte) error {
+	if from == nil {
+		return fmt.Errorf("missing sender")
+	}
+	participant := p.State.GetParticipant(from.Id)
+	if participant == nil || participant.IdentityPk == nil {
+		return fmt.Errorf("unknown sender %d", from.Id)
+	}
+	return verifyIdentitySignature(participant.IdentityPk, root, signature)
+}
+
+func verifyIdentitySignature(pk *bls.PublicKey, root []byte, signature []byte) error {
+	if len(signature) == 0 {
+		return fmt.Errorf("missing signature")
+	}
+
+	sig := &bls.Sign{}
+	err := sig.Deserialize(signature)
+	if err != nil {
+		return err
+	}
+	if !sig.VerifyByte(pk, root) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package pool_chain
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func registerIdentity(t *testing.T, node *PoolChainNode, id uint32) *bls.SecretKey {
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	_, encryptionPk := crypto.NewEncryptionKey()
+	require.NoError(t, node.State.SaveParticipant(state.NewParticipant(id, encryptionPk, sk.GetPublicKey())))
+	return sk
+}
+
+func TestReceiveSignatureAuthentication(t *testing.T) {
+	crypto.InitBLS()
+
+	node := NewTestChainNode()
+	sk1 := registerIdentity(t, node, 1)
+	sk2 := registerIdentity(t, node, 2)
+
+	newSig := func(id string, from uint32, signer *bls.SecretKey) *pb.SignatureDistribution {
+		sig := &pb.SignatureDistribution{
+			Id:              id,
+			FromParticipant: &pb.Participant{Id: from},
+			Sig:             []byte{1,2,3},
+			PoolId:          1,
+			Epoch:           1,
+			SigningRoot:     []byte{4,5,6},
+		}
+		if signer != nil {
+			sig.Signature = signer.SignByte(SignatureSigningRoot(sig)).Serialize()
+		}
+		return sig
+	}
+
+	// valid
+	node.ReceiveSignature(newSig("valid", 1, sk1))
+	require.NotNil(t, node.SigsPerEpoch[1]["valid"])
+
+	// unsigned
+	node.ReceiveSignature(newSig("unsigned", 1, nil))
+	require.Nil(t, node.SigsPerEpoch[1]["unsigned"])
+
+	// spoofed sender
+	node.ReceiveSignature(newSig("spoofed", 1, sk2))
+	require.Nil(t, node.SigsPerEpoch[1]["spoofed"])
+
+	// unknown sender
+	node.ReceiveSignature(newSig("unknown", 3, sk1))
+	require.Nil(t, node.SigsPerEpoch[1]["unknown"])
+
+	// tampered after signing
+	tampered := newSig("tampered", 2, sk2)
+	tampered.Epoch = 2
+	node.ReceiveSignature(tampered)
+	require.Nil(t, node.SigsPerEpoch[2])
+
+	// another duty's signing root
+	tampered = newSig("tampered root", 2, sk2)
+	tampered.SigningRoot = []byte{7}
+	node.ReceiveSignature(tampered)
+	require.Nil(t, node.SigsPerEpoch[1]["tampered root"])
+}
+
+// the signed encoding allows missing participants, such messages are dropped instead of crashing the node
+func TestReceiveMessagesWithoutParticipants(t *testing.T) {
+	crypto.InitBLS()
+
+	node := NewTestChainNode()
+	sk1 := registerIdentity(t, node, 1)
+
+	share := &pb.ShareDistribution{Id: "share", FromParticipant: &pb.Participant{Id: 1}, Share: []byte{1}, PoolId: 1, Epoch: 1}
+	share.Signature = sk1.SignByte(ShareSigningRoot(share)).Serialize()
+	require.NotPanics(t, func() { node.ReceiveShare(share) })
+	require.Len(t, node.EpochShares(1), 0)
+	require.Nil(t, node.DealerCommitments(1, 1))
+
+	for _, msgType := range []pb.DKGMessageType{pb.DKGMessageType_DEAL, pb.DKGMessageType_COMPLAINT, pb.DKGMessageType_RECONSTRUCTION_SHARE} {
+		msg := &pb.DKGMessage{Id: msgType.String(), Type: msgType, FromParticipant: &pb.Participant{Id: 1}, PoolId: 1, Share: []byte{1}}
+		msg.Signature = sk1.SignByte(DKGSigningRoot(msg)).Serialize()
+		require.NotPanics(t, func() { node.ReceiveDKGMessage(msg) })
+		require.Len(t, node.DKGMessagesByType(1, msgType), 0)
+	}
+
+	sig := &pb.SignatureDistribution{Id: "sig", Sig: []byte{1}, PoolId: 1, Epoch: 1, SigningRoot: []byte{2}}
+	sig.Signature = sk1.SignByte(SignatureSigningRoot(sig)).Serialize()
+	require.NotPanics(t, func() { node.ReceiveSignature(sig) })
+	require.Len(t, node.EpochSigs(1), 0)
+}
+
+func TestSigningRootsAreDomainSeparated(t *testing.T) {
+	share := &pb.ShareDistribution{}
+	sig := &pb.SignatureDistribution{}
+	msg := &pb.DKGMessage{}
+	require.NotEqual(t, ShareSigningRoot(share), SignatureSigningRoot(sig))
+	require.NotEqual(t, ShareSigningRoot(share), DKGSigningRoot(msg))
+	require.NotEqual(t, DKGSigningRoot(msg), ConsensusSigningRoot(&pb.ConsensusMessage{}))
+
+	// a nil vote isn't a vote for an empty value's hash
+	require.NotEqual(t, ConsensusSigningRoot(&pb.ConsensusMessage{ValueHash: nil}), ConsensusSigningRoot(&pb.ConsensusMessage{ValueHash: make([]byte, 32)}))
+
+	// moving bytes between fields changes the encoding
+	a := &pb.DKGMessage{Share: []byte{1,2}, BlindingShare: []byte{3}}
+	b := &pb.DKGMessage{Share: []byte{1}, BlindingShare: []byte{2,3}}
+	require.NotEqual(t, DKGSigningRoot(a), DKGSigningRoot(b))
+
+	// signature and ttl fields are not part of the encoding
+	signed := &pb.DKGMessage{Signature: []byte{1}, Ttl: 3}
+	require.Equal(t, DKGSigningRoot(msg), DKGSigningRoot(signed))
+}
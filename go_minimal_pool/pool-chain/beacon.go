@@ -0,0 +1,40 @@
+package pool_chain
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+/**
+	The beacon node pools validate through, it assigns the pools' duties and takes their reconstructed signatures.
+	Implemented by an in process fake (beacon.FakeBeaconNode) and the standard eth2 Beacon API
+	(beacon.HttpBeaconClient).
+ */
+type BeaconClient interface {
+	eth2.DutySource
+	AttestationData(slot eth2.Slot, committeeIndex eth2.CommitteeIndex) (*eth2.AttestationData, error)
+	SubmitAttestation(duty *eth2.Duty, sig []byte) error
+	SubmitBlock(duty *eth2.Duty, sig []byte) error
+	SubmitVoluntaryExit(duty *eth2.Duty, sig []byte) error
+}
+
+// submits a duty's reconstructed signature to the beacon node, RANDAO reveals and selection proofs are only inputs
+// to other duties and aren't submitted on their own. Without a beacon node signatures are only kept on the epoch.
+func (p *PoolChainNode) SubmitDutySignature(duty *eth2.Duty, sig *bls.G2) error {
+	if p.Beacon == nil {
+		return nil
+	}
+	switch duty.Type {
+	case eth2.DutyAttestation:
+		return p.Beacon.SubmitAttestation(duty, sig.Serialize())
+	case eth2.DutyProposal:
+		return p.Beacon.SubmitBlock(duty, sig.Serialize())
+	case eth2.DutyVoluntaryExit:
+		return p.Beacon.SubmitVoluntaryExit(duty, sig.Serialize())
+	case eth2.DutyRandao, eth2.DutySelectionProof:
+		return nil
+	default:
+		return fmt.Errorf("unknown duty type %d", duty.Type)
+	}
+}
@@ -0,0 +1,81 @@
+package beacon
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"sync"
+)
+
+// a submitted duty and its signature
+type Submission struct {
+	Duty *eth2.Duty
+	Sig []byte
+}
+
+/**
+	An in process beacon node stand-in, duties and attestation data come from eth2.LocalDutySource so every fake
+	(e.g. one per participant) assigns the same ones. Submissions are kept per validator, a duty submitted again
+	(e.g. by every member of the pool) is kept once.
+ */
+type FakeBeaconNode struct {
+	duties *eth2.LocalDutySource
+	submissions map[eth2.ValidatorIndex][]*Submission
+	lock sync.Mutex
+}
+
+func NewFakeBeaconNode() *FakeBeaconNode {
+	return &FakeBeaconNode{
+		duties: eth2.NewLocalDutySource(),
+		submissions: make(map[eth2.ValidatorIndex][]*Submission),
+	}
+}
+
+func (b *FakeBeaconNode) Duties(epoch eth2.Epoch, validator eth2.ValidatorIndex) ([]*eth2.Duty, error) {
+	return b.duties.Duties(epoch, validator)
+}
+
+func (b *FakeBeaconNode) AttestationData(slot eth2.Slot, committeeIndex eth2.CommitteeIndex) (*eth2.AttestationData, error) {
+	return b.duties.AttestationData(slot, committeeIndex), nil
+}
+
+func (b *FakeBeaconNode) SubmitAttestation(duty *eth2.Duty, sig []byte) error {
+	if duty.Type != eth2.DutyAttestation || duty.Attestation == nil {
+		return fmt.Errorf("not an attestation")
+	}
+	return b.submit(duty, sig)
+}
+
+func (b *FakeBeaconNode) SubmitBlock(duty *eth2.Duty, sig []byte) error {
+	if duty.Type != eth2.DutyProposal || duty.Block == nil {
+		return fmt.Errorf("not a block proposal")
+	}
+	return b.submit(duty, sig)
+}
+
+func (b *FakeBeaconNode) SubmitVoluntaryExit(duty *eth2.Duty, sig []byte) error {
+	if duty.Type != eth2.DutyVoluntaryExit || duty.Exit == nil {
+		return fmt.Errorf("not a voluntary exit")
+	}
+	return b.submit(duty, sig)
+}
+
+func (b *FakeBeaconNode) submit(duty *eth2.Duty, sig []byte) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, s := range b.submissions[duty.ValidatorIndex] {
+		if s.Duty.Type == duty.Type && s.Duty.Slot == duty.Slot {
+			return nil
+		}
+	}
+	b.submissions[duty.ValidatorIndex] = append(b.submissions[duty.ValidatorIndex], &Submission{Duty: duty, Sig: sig})
+	return nil
+}
+
+// the validator's submissions, in submission order
+func (b *FakeBeaconNode) Submissions(validator eth2.ValidatorIndex) []*Submission {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return append([]*Submission{}, b.submissions[validator]...)
+}
@@ -0,0 +1,37 @@
+package beacon
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFakeBeaconNode(t *testing.T) {
+	node := NewFakeBeaconNode()
+	duties, err := node.Duties(4, 0)
+	require.NoError(t, err)
+	local, err := eth2.NewLocalDutySource().Duties(4, 0)
+	require.NoError(t, err)
+	require.Equal(t, local, duties)
+
+	for _, duty := range duties {
+		switch duty.Type {
+		case eth2.DutyAttestation:
+			data, err := node.AttestationData(duty.Slot, 0)
+			require.NoError(t, err)
+			require.Equal(t, duty.Attestation, data)
+			require.NoError(t, node.SubmitAttestation(duty, []byte{1}))
+			// every member of the pool submits
+			require.NoError(t, node.SubmitAttestation(duty, []byte{1}))
+			require.Error(t, node.SubmitBlock(duty, []byte{1}))
+		case eth2.DutyProposal:
+			require.NoError(t, node.SubmitBlock(duty, []byte{2}))
+		}
+	}
+	require.NoError(t, node.SubmitVoluntaryExit(eth2.NewVoluntaryExitDuty(&eth2.VoluntaryExit{Epoch: 4}), []byte{3}))
+
+	submissions := node.Submissions(0)
+	require.Len(t, submissions, 3)
+	require.Equal(t, eth2.DutyVoluntaryExit, submissions[2].Duty.Type)
+	require.Len(t, node.Submissions(1), 0)
+}
@@ -0,0 +1,291 @@
+package beacon
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+	A client of the standard eth2 Beacon API (https://ethereum.github.io/eth2.0-APIs), integers are decimal strings
+	and bytes 0x prefixed hex.
+	A proposal needs the beacon node to produce the block from the proposer's RANDAO reveal, which the pool only
+	reconstructs at the epoch's end, so proposer duties are the RANDAO reveal only and SubmitBlock isn't supported.
+	Not usable against a real beacon node yet: duties are asked for the pool-chain epoch and the pool id as if they
+	were the beacon chain's epoch and the pool's validator index, poolnode doesn't expose it.
+ */
+type HttpBeaconClient struct {
+	url string
+	client *http.Client
+}
+
+func NewHttpBeaconClient(url string) *HttpBeaconClient {
+	return &HttpBeaconClient{
+		url: strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+type attesterDuty struct {
+	ValidatorIndex string `json:"validator_index"`
+	CommitteeIndex string `json:"committee_index"`
+	CommitteeLength string `json:"committee_length"`
+	ValidatorCommitteeIndex string `json:"validator_committee_index"`
+	Slot string `json:"slot"`
+}
+
+type proposerDuty struct {
+	ValidatorIndex string `json:"validator_index"`
+	Slot string `json:"slot"`
+}
+
+type checkpointJson struct {
+	Epoch string `json:"epoch"`
+	Root string `json:"root"`
+}
+
+type attestationDataJson struct {
+	Slot string `json:"slot"`
+	Index string `json:"index"`
+	BeaconBlockRoot string `json:"beacon_block_root"`
+	Source *checkpointJson `json:"source"`
+	Target *checkpointJson `json:"target"`
+}
+
+type attestationJson struct {
+	AggregationBits string `json:"aggregation_bits"`
+	Data *attestationDataJson `json:"data"`
+	Signature string `json:"signature"`
+}
+
+type voluntaryExitJson struct {
+	Message struct{
+		Epoch string `json:"epoch"`
+		ValidatorIndex string `json:"validator_index"`
+	} `json:"message"`
+	Signature string `json:"signature"`
+}
+
+func (c *HttpBeaconClient) Duties(epoch eth2.Epoch, validator eth2.ValidatorIndex) ([]*eth2.Duty, error) {
+	ret := make([]*eth2.Duty, 0)
+
+	proposers := make([]*proposerDuty, 0)
+	err := c.do("GET", fmt.Sprintf("/eth/v1/validator/duties/proposer/%d", epoch), nil, &proposers)
+	if err != nil {
+		return nil, err
+	}
+	for _, duty := range proposers {
+		if duty.ValidatorIndex != uintString(validator) {
+			continue
+		}
+		slot, err := parseUint(duty.Slot)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &eth2.Duty{Type: eth2.DutyRandao, Slot: slot, Epoch: epoch, ValidatorIndex: validator})
+	}
+
+	attesters := make([]*attesterDuty, 0)
+	err = c.do("POST", fmt.Sprintf("/eth/v1/validator/duties/attester/%d", epoch), []string{uintString(validator)}, &attesters)
+	if err != nil {
+		return nil, err
+	}
+	for _, duty := range attesters {
+		if duty.ValidatorIndex != uintString(validator) {
+			continue
+		}
+		values, err := parseUints(duty.Slot, duty.CommitteeIndex, duty.CommitteeLength, duty.ValidatorCommitteeIndex)
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.AttestationData(values[0], values[1])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &eth2.Duty{
+			Type: eth2.DutyAttestation,
+			Slot: values[0],
+			Epoch: epoch,
+			ValidatorIndex: validator,
+			CommitteeLength: values[2],
+			ValidatorCommitteeIndex: values[3],
+			Attestation: data,
+		}, &eth2.Duty{
+			Type: eth2.DutySelectionProof,
+			Slot: values[0],
+			Epoch: epoch,
+			ValidatorIndex: validator,
+		})
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Slot < ret[j].Slot })
+	return ret, nil
+}
+
+func (c *HttpBeaconClient) AttestationData(slot eth2.Slot, committeeIndex eth2.CommitteeIndex) (*eth2.AttestationData, error) {
+	data := &attestationDataJson{}
+	err := c.do("GET", fmt.Sprintf("/eth/v1/validator/attestation_data?slot=%d&committee_index=%d", slot, committeeIndex), nil, data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAttestationData(data)
+}
+
+func (c *HttpBeaconClient) SubmitAttestation(duty *eth2.Duty, sig []byte) error {
+	if duty.Type != eth2.DutyAttestation || duty.Attestation == nil {
+		return fmt.Errorf("not an attestation")
+	}
+	if duty.ValidatorCommitteeIndex >= duty.CommitteeLength {
+		return fmt.Errorf("committee index %d out of committee of %d", duty.ValidatorCommitteeIndex, duty.CommitteeLength)
+	}
+	attestation := &attestationJson{
+		AggregationBits: encodeHex(aggregationBits(duty.CommitteeLength, duty.ValidatorCommitteeIndex)),
+		Data: encodeAttestationData(duty.Attestation),
+		Signature: encodeHex(sig),
+	}
+	return c.do("POST", "/eth/v1/beacon/pool/attestations", []*attestationJson{attestation}, nil)
+}
+
+func (c *HttpBeaconClient) SubmitBlock(duty *eth2.Duty, sig []byte) error {
+	return fmt.Errorf("block proposals aren't supported over the beacon API yet")
+}
+
+func (c *HttpBeaconClient) SubmitVoluntaryExit(duty *eth2.Duty, sig []byte) error {
+	if duty.Type != eth2.DutyVoluntaryExit || duty.Exit == nil {
+		return fmt.Errorf("not a voluntary exit")
+	}
+	exit := &voluntaryExitJson{Signature: encodeHex(sig)}
+	exit.Message.Epoch = uintString(duty.Exit.Epoch)
+	exit.Message.ValidatorIndex = uintString(duty.Exit.ValidatorIndex)
+	return c.do("POST", "/eth/v1/beacon/pool/voluntary_exits", exit, nil)
+}
+
+// sends body (if not nil) as JSON and decodes the response's data into ret (if not nil)
+func (c *HttpBeaconClient) do(method string, path string, body interface{}, ret interface{}) error {
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.url + path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("beacon node %s %s: %s", method, path, err.Error())
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := struct{
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("beacon node %s %s: %d %s", method, path, resp.StatusCode, apiErr.Message)
+	}
+	if ret == nil {
+		return nil
+	}
+	wrapped := struct{
+		Data interface{} `json:"data"`
+	}{Data: ret}
+	err = json.Unmarshal(data, &wrapped)
+	if err != nil {
+		return fmt.Errorf("beacon node %s %s: could not parse response: %s", method, path, err.Error())
+	}
+	return nil
+}
+
+// an SSZ bitlist with only the validator's bit set, the highest set bit marks the list's length
+func aggregationBits(committeeLength uint64, position uint64) []byte {
+	ret := make([]byte, committeeLength / 8 + 1)
+	ret[position / 8] |= 1 << (position % 8)
+	ret[committeeLength / 8] |= 1 << (committeeLength % 8)
+	return ret
+}
+
+func encodeAttestationData(data *eth2.AttestationData) *attestationDataJson {
+	return &attestationDataJson{
+		Slot: uintString(data.Slot),
+		Index: uintString(data.Index),
+		BeaconBlockRoot: encodeHex(data.BeaconBlockRoot[:]),
+		Source: &checkpointJson{Epoch: uintString(data.Source.Epoch), Root: encodeHex(data.Source.Root[:])},
+		Target: &checkpointJson{Epoch: uintString(data.Target.Epoch), Root: encodeHex(data.Target.Root[:])},
+	}
+}
+
+func decodeAttestationData(data *attestationDataJson) (*eth2.AttestationData, error) {
+	if data.Source == nil || data.Target == nil {
+		return nil, fmt.Errorf("attestation data without source or target")
+	}
+	values, err := parseUints(data.Slot, data.Index, data.Source.Epoch, data.Target.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	ret := &eth2.AttestationData{
+		Slot: values[0],
+		Index: values[1],
+		Source: &eth2.Checkpoint{Epoch: values[2]},
+		Target: &eth2.Checkpoint{Epoch: values[3]},
+	}
+	for _, root := range []struct{
+		value string
+		to *eth2.Root
+	}{
+		{data.BeaconBlockRoot, &ret.BeaconBlockRoot},
+		{data.Source.Root, &ret.Source.Root},
+		{data.Target.Root, &ret.Target.Root},
+	} {
+		b, err := hex.DecodeString(strings.TrimPrefix(root.value, "0x"))
+		if err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid root %s", root.value)
+		}
+		copy(root.to[:], b)
+	}
+	return ret, nil
+}
+
+func encodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func uintString(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+func parseUint(s string) (uint64, error) {
+	ret, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %s", s)
+	}
+	return ret, nil
+}
+
+func parseUints(values ...string) ([]uint64, error) {
+	ret := make([]uint64, len(values))
+	for i, s := range values {
+		v, err := parseUint(s)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
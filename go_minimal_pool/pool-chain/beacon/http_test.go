@@ -0,0 +1,88 @@
+package beacon
+
+import (
+	"encoding/json"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpBeaconClient(t *testing.T) {
+	local := eth2.NewLocalDutySource()
+	submitted := make([]*attestationJson, 0)
+	exits := make([]*voluntaryExitJson, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/eth/v1/validator/duties/proposer/3", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [
+			{"pubkey": "0x01", "validator_index": "5", "slot": "100"},
+			{"pubkey": "0x02", "validator_index": "7", "slot": "101"}
+		]}`))
+	})
+	mux.HandleFunc("/eth/v1/validator/duties/attester/3", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "POST", r.Method)
+		body, _ := ioutil.ReadAll(r.Body)
+		require.JSONEq(t, `["7"]`, string(body))
+		w.Write([]byte(`{"data": [{"pubkey": "0x02", "validator_index": "7", "committee_index": "2", "committee_length": "10",
+			"committees_at_slot": "4", "validator_committee_index": "9", "slot": "99"}]}`))
+	})
+	mux.HandleFunc("/eth/v1/validator/attestation_data", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "99", r.URL.Query().Get("slot"))
+		require.Equal(t, "2", r.URL.Query().Get("committee_index"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": encodeAttestationData(local.AttestationData(99, 2))})
+	})
+	mux.HandleFunc("/eth/v1/beacon/pool/attestations", func(w http.ResponseWriter, r *http.Request) {
+		attestations := make([]*attestationJson, 0)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&attestations))
+		submitted = append(submitted, attestations...)
+	})
+	mux.HandleFunc("/eth/v1/beacon/pool/voluntary_exits", func(w http.ResponseWriter, r *http.Request) {
+		exit := &voluntaryExitJson{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(exit))
+		if exit.Message.ValidatorIndex != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 400, "message": "unknown validator"}`))
+			return
+		}
+		exits = append(exits, exit)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewHttpBeaconClient(server.URL + "/")
+
+	duties, err := client.Duties(3, 7)
+	require.NoError(t, err)
+	require.Len(t, duties, 3)
+	require.Equal(t, eth2.DutyAttestation, duties[0].Type)
+	require.Equal(t, local.AttestationData(99, 2), duties[0].Attestation)
+	require.EqualValues(t, 10, duties[0].CommitteeLength)
+	require.EqualValues(t, 9, duties[0].ValidatorCommitteeIndex)
+	require.Equal(t, eth2.DutySelectionProof, duties[1].Type)
+	require.Equal(t, eth2.DutyRandao, duties[2].Type)
+	require.EqualValues(t, 101, duties[2].Slot)
+
+	require.NoError(t, client.SubmitAttestation(duties[0], []byte{1, 2}))
+	require.Len(t, submitted, 1)
+	// bit 9 and the length bit 10
+	require.Equal(t, "0x0006", submitted[0].AggregationBits)
+	require.Equal(t, "0x0102", submitted[0].Signature)
+	require.Equal(t, "99", submitted[0].Data.Slot)
+	require.Error(t, client.SubmitAttestation(duties[1], []byte{1, 2}))
+	require.Error(t, client.SubmitBlock(duties[0], []byte{1, 2}))
+
+	require.NoError(t, client.SubmitVoluntaryExit(eth2.NewVoluntaryExitDuty(&eth2.VoluntaryExit{Epoch: 3, ValidatorIndex: 7}), []byte{3}))
+	require.Len(t, exits, 1)
+	require.Equal(t, "3", exits[0].Message.Epoch)
+	err = client.SubmitVoluntaryExit(eth2.NewVoluntaryExitDuty(&eth2.VoluntaryExit{Epoch: 3, ValidatorIndex: 8}), []byte{3})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown validator")
+}
+
+func TestAggregationBits(t *testing.T) {
+	require.Equal(t, []byte{0x03}, aggregationBits(1, 0))
+	require.Equal(t, []byte{0x01, 0x01}, aggregationBits(8, 0))
+	require.Equal(t, []byte{0x80, 0x01}, aggregationBits(8, 7))
+}
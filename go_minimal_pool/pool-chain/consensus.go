@@ -0,0 +1,88 @@
+package pool_chain
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"log"
+	"time"
+)
+
+/**
+	Agreement of an epoch's participants on the epoch's block (state.Block), one instance per height (the epoch's
+	number). Implemented by consensus.Tendermint.
+	Decide proposes with propose when it's the caller's turn, accepts only values valid returns true for and returns
+	the decided value, or an error if none was decided within timeout. A caller that isn't one of validators follows
+	the height without voting.
+	Receive gets the authenticated messages of every height, including ones that didn't start yet.
+ */
+type Consensus interface {
+	Decide(height uint32, validators []shared.ParticipantId, propose func() []byte, valid func(value []byte) bool, timeout time.Duration) ([]byte, error)
+	Receive(msg *pb.ConsensusMessage)
+}
+
+func (p *PoolChainNode) ReceiveConsensusMessage(msg *pb.ConsensusMessage) {
+	err := p.verifyMessageSignature(msg.FromParticipant, ConsensusSigningRoot(msg), msg.Signature)
+	if err != nil {
+		log.Printf("P %d, dropping consensus message %s: %s", p.FilterId, msg.Id, err.Error())
+		return
+	}
+	if p.Consensus != nil {
+		p.Consensus.Receive(msg)
+	}
+}
+
+// processes the decided block on the finalized state and stores it, the node's state takes over the finalized
+// registry (requests, registry updates, balances and slashings)
+func (p *PoolChainNode) SetFinalizedBlock(block *state.Block) error {
+	p.finalizedLock.Lock()
+	defer p.finalizedLock.Unlock()
+
+	head, err := p.finalizedState()
+	if err != nil {
+		return err
+	}
+	next, err := state.ProcessBlock(head, block)
+	if err != nil {
+		return fmt.Errorf("could not process block %d: %s", block.Epoch, err.Error())
+	}
+	err = p.State.SaveBlock(block)
+	if err != nil {
+		return err
+	}
+	p.finalized = next
+
+	p.dropRequests(block.Body.Requests)
+	return p.State.SyncRegistry(next)
+}
+
+// the epoch's block once consensus decided it, nil before
+func (p *PoolChainNode) FinalizedBlock(epoch shared.EpochNumber) *state.Block {
+	block, err := p.State.GetBlock(epoch)
+	if err != nil {
+		log.Printf("P %d, could not fetch block %d: %s", p.FilterId, epoch, err.Error())
+		return nil
+	}
+	return block
+}
+
+// the network state after the latest finalized block, the genesis state before block 0. Blocks are checked
+// against it and applied to it.
+func (p *PoolChainNode) FinalizedState() (*state.State, error) {
+	p.finalizedLock.Lock()
+	defer p.finalizedLock.Unlock()
+	return p.finalizedState()
+}
+
+// callers hold the lock
+func (p *PoolChainNode) finalizedState() (*state.State, error) {
+	if p.finalized == nil {
+		replayed, err := state.Replay(p.State.Genesis(), p.State)
+		if err != nil {
+			return nil, err
+		}
+		p.finalized = replayed
+	}
+	return p.finalized, nil
+}
@@ -0,0 +1,146 @@
+package consensus
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"log"
+	"sync"
+	"time"
+)
+
+/**
+	Runs an Instance per height with real timers, messages go through broadcast (which signs and sends them to the
+	other validators) and come back through Receive. Messages for a height that didn't start yet are kept until
+	it does, messages for a finished height are dropped. A node that isn't one of the height's validators can
+	Decide too, it follows the validators' messages to their decision.
+	A step's timeout is TimeoutBase plus TimeoutDelta for every round, so rounds get longer until the network is
+	fast enough to decide.
+ */
+type Tendermint struct {
+	id shared.ParticipantId
+	TimeoutBase time.Duration
+	TimeoutDelta time.Duration
+	broadcast func(msg *pb.ConsensusMessage) error
+
+	instances map[uint32]*Instance
+	pending map[uint32][]*pb.ConsensusMessage
+	finished map[uint32]bool
+	decided map[uint32]chan []byte
+	lock sync.Mutex
+}
+
+func NewTendermint(id shared.ParticipantId, timeoutBase time.Duration, broadcast func(msg *pb.ConsensusMessage) error) *Tendermint {
+	return &Tendermint{
+		id: id,
+		TimeoutBase: timeoutBase,
+		TimeoutDelta: timeoutBase / 2,
+		broadcast: broadcast,
+		instances: make(map[uint32]*Instance),
+		pending: make(map[uint32][]*pb.ConsensusMessage),
+		finished: make(map[uint32]bool),
+		decided: make(map[uint32]chan []byte),
+	}
+}
+
+func (t *Tendermint) Decide(height uint32, validators []shared.ParticipantId, propose func() []byte, valid func(value []byte) bool, timeout time.Duration) ([]byte, error) {
+	t.lock.Lock()
+	if t.finished[height] || t.instances[height] != nil {
+		t.lock.Unlock()
+		return nil, fmt.Errorf("height %d already started", height)
+	}
+	instance := NewInstance(height, t.id, validators, propose, valid)
+	decided := make(chan []byte, 1)
+	t.instances[height] = instance
+	t.decided[height] = decided
+	for _, msg := range t.pending[height] {
+		instance.Receive(msg)
+	}
+	delete(t.pending, height)
+	instance.Start()
+	t.lock.Unlock()
+	t.flush(instance)
+
+	defer t.finish(height)
+	select {
+	case value := <- decided:
+		return value, nil
+	case <- time.After(timeout):
+		return nil, fmt.Errorf("height %d not decided in %s (round %d)", height, timeout.String(), t.round(height))
+	}
+}
+
+// an authenticated message from a validator
+func (t *Tendermint) Receive(msg *pb.ConsensusMessage) {
+	t.lock.Lock()
+	if t.finished[msg.Height] {
+		t.lock.Unlock()
+		return
+	}
+	instance := t.instances[msg.Height]
+	if instance == nil {
+		t.pending[msg.Height] = append(t.pending[msg.Height], msg)
+		t.lock.Unlock()
+		return
+	}
+	instance.Receive(msg)
+	t.lock.Unlock()
+	// not from within the network's delivery, an in process network could be delivering our own broadcast
+	go t.flush(instance)
+}
+
+func (t *Tendermint) timeout(instance *Instance, timeout Timeout) {
+	t.lock.Lock()
+	if t.instances[instance.Height] != instance {
+		t.lock.Unlock()
+		return
+	}
+	instance.Timeout(timeout)
+	t.lock.Unlock()
+	t.flush(instance)
+}
+
+// sends the instance's messages, schedules its timeouts and reports its decision. Called without holding the
+// lock, a broadcast can deliver back to Receive.
+func (t *Tendermint) flush(instance *Instance) {
+	t.lock.Lock()
+	msgs, timeouts := instance.Drain()
+	decision := instance.Decision()
+	decided := t.decided[instance.Height]
+	if decision != nil {
+		delete(t.decided, instance.Height)
+	}
+	t.lock.Unlock()
+
+	for _, msg := range msgs {
+		err := t.broadcast(msg)
+		if err != nil {
+			log.Printf("P %d, could not broadcast consensus message: %s", t.id, err.Error())
+		}
+	}
+	for _, timeout := range timeouts {
+		timeout := timeout
+		d := t.TimeoutBase + t.TimeoutDelta * time.Duration(timeout.Round)
+		time.AfterFunc(d, func() { t.timeout(instance, timeout) })
+	}
+	if decision != nil && decided != nil {
+		decided <- decision
+	}
+}
+
+func (t *Tendermint) finish(height uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.instances, height)
+	delete(t.decided, height)
+	t.finished[height] = true
+}
+
+func (t *Tendermint) round(height uint32) uint32 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if instance := t.instances[height]; instance != nil {
+		return instance.Round()
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package consensus
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+)
+
+/**
+	Deterministic in process network of instances for tests. Messages are delivered one at a time in the order they
+	were sent, timeouts only expire once no message is in flight (the network is faster than any timeout) and in the
+	order they were scheduled, so a run is the same every time.
+	Crashed validators never start nor receive, Drop filters deliveries (e.g. a partition) and Inject delivers
+	messages a byzantine validator made up.
+ */
+type Harness struct {
+	Instances map[shared.ParticipantId]*Instance
+	Crashed map[shared.ParticipantId]bool
+	// drops msg on its way to a validator if it returns true
+	Drop func(msg *pb.ConsensusMessage, to shared.ParticipantId) bool
+
+	validators []shared.ParticipantId
+	queue []*delivery
+	timeouts []*scheduledTimeout
+}
+
+type delivery struct {
+	to shared.ParticipantId
+	msg *pb.ConsensusMessage
+}
+
+type scheduledTimeout struct {
+	id shared.ParticipantId
+	timeout Timeout
+}
+
+// propose and valid are per validator, e.g. validators with different views
+func NewHarness(height uint32, validators []shared.ParticipantId, propose func(id shared.ParticipantId) []byte, valid func(id shared.ParticipantId, value []byte) bool) *Harness {
+	ret := &Harness{
+		Instances: make(map[shared.ParticipantId]*Instance),
+		Crashed: make(map[shared.ParticipantId]bool),
+		validators: validators,
+	}
+	for _, id := range validators {
+		id := id
+		ret.Instances[id] = NewInstance(height, id, validators,
+			func() []byte { return propose(id) },
+			func(value []byte) bool { return valid(id, value) },
+			)
+	}
+	return ret
+}
+
+func (h *Harness) Start() {
+	for _, id := range h.validators {
+		if !h.Crashed[id] {
+			h.Instances[id].Start()
+			h.collect(id)
+		}
+	}
+}
+
+// delivers msg to every validator but its sender
+func (h *Harness) Inject(msg *pb.ConsensusMessage) {
+	h.send(msg)
+}
+
+// delivers messages and expires timeouts until every validator that didn't crash decided (true) or maxSteps
+// deliveries and timeouts were processed (false)
+func (h *Harness) Run(maxSteps int) bool {
+	for step := 0 ; step < maxSteps ; step++ {
+		if h.decided() {
+			return true
+		}
+		if len(h.queue) > 0 {
+			next := h.queue[0]
+			h.queue = h.queue[1:]
+			if h.Crashed[next.to] || (h.Drop != nil && h.Drop(next.msg, next.to)) {
+				continue
+			}
+			h.Instances[next.to].Receive(next.msg)
+			h.collect(next.to)
+			continue
+		}
+		if len(h.timeouts) > 0 {
+			next := h.timeouts[0]
+			h.timeouts = h.timeouts[1:]
+			h.Instances[next.id].Timeout(next.timeout)
+			h.collect(next.id)
+			continue
+		}
+		return false
+	}
+	return h.decided()
+}
+
+func (h *Harness) decided() bool {
+	for id, instance := range h.Instances {
+		if !h.Crashed[id] && instance.Decision() == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Harness) collect(id shared.ParticipantId) {
+	msgs, timeouts := h.Instances[id].Drain()
+	for _, msg := range msgs {
+		h.send(msg)
+	}
+	for _, timeout := range timeouts {
+		h.timeouts = append(h.timeouts, &scheduledTimeout{id: id, timeout: timeout})
+	}
+}
+
+func (h *Harness) send(msg *pb.ConsensusMessage) {
+	for _, to := range h.validators {
+		if to != msg.FromParticipant.Id {
+			h.queue = append(h.queue, &delivery{to: to, msg: msg})
+		}
+	}
+}
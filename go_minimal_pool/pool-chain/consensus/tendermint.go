@@ -0,0 +1,399 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"sort"
+)
+
+/**
+	Tendermint BFT agreement on one value per height (an epoch's block), Algorithm 1 of
+	"The latest gossip on BFT consensus" (https://arxiv.org/abs/1807.04938).
+	n validators tolerate f = (n-1)/3 byzantine ones, a quorum is n-f votes. Every round has a proposer (validators
+	sorted by id, rotating with height and round) that proposes a value, validators prevote for it if it's valid and
+	doesn't conflict with the value they're locked on, precommit (and lock) once a quorum prevoted it and decide
+	once a quorum precommitted it. Rounds that fail (slow or faulty proposer) move on after timeouts.
+
+	An Instance is a deterministic state machine, it doesn't keep time or talk to the network. Outgoing messages and
+	timeouts to schedule are collected and taken with Drain, a driver (Tendermint, or the test Harness) delivers
+	them and calls Timeout when a scheduled timeout expires.
+	Messages are expected to be authenticated (signed by their sender) before they reach the instance.
+	An instance whose id isn't a validator follows the height, it never sends a message but decides like the
+	validators once it received the proposal and a quorum of precommits for it.
+ */
+
+type Step int
+
+const (
+	StepPropose Step = iota
+	StepPrevote
+	StepPrecommit
+)
+
+// a timeout to deliver back to Instance.Timeout once it expires
+type Timeout struct {
+	Step Step
+	Round uint32
+}
+
+type Instance struct {
+	Height uint32
+	id shared.ParticipantId
+	// sorted
+	validators []shared.ParticipantId
+	// the value to propose when it's the instance's turn and it has no valid value
+	propose func() []byte
+	// the application's validity check of proposed values
+	valid func(value []byte) bool
+	validCache map[string]bool
+
+	started bool
+	round uint32
+	step Step
+	lockedValue []byte
+	lockedRound int32
+	validValue []byte
+	validRound int32
+	decision []byte
+	decisionRound uint32
+
+	// the round proposer's proposal, the first seen
+	proposals map[uint32]*pb.ConsensusMessage
+	// the first vote of every validator per round
+	prevotes map[uint32]map[shared.ParticipantId]*pb.ConsensusMessage
+	precommits map[uint32]map[shared.ParticipantId]*pb.ConsensusMessage
+	// rules that fire only once per round
+	prevoteTimeoutScheduled map[uint32]bool
+	precommitTimeoutScheduled map[uint32]bool
+	quorumPrevoted map[uint32]bool
+
+	outbox []*pb.ConsensusMessage
+	timeouts []Timeout
+}
+
+func NewInstance(height uint32, id shared.ParticipantId, validators []shared.ParticipantId, propose func() []byte, valid func(value []byte) bool) *Instance {
+	sorted := append([]shared.ParticipantId{}, validators...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Instance{
+		Height: height,
+		id: id,
+		validators: sorted,
+		propose: propose,
+		valid: valid,
+		validCache: make(map[string]bool),
+		lockedRound: -1,
+		validRound: -1,
+		proposals: make(map[uint32]*pb.ConsensusMessage),
+		prevotes: make(map[uint32]map[shared.ParticipantId]*pb.ConsensusMessage),
+		precommits: make(map[uint32]map[shared.ParticipantId]*pb.ConsensusMessage),
+		prevoteTimeoutScheduled: make(map[uint32]bool),
+		precommitTimeoutScheduled: make(map[uint32]bool),
+		quorumPrevoted: make(map[uint32]bool),
+	}
+}
+
+func ValueHash(value []byte) []byte {
+	ret := sha256.Sum256(value)
+	return ret[:]
+}
+
+// the round's proposer, rotating so a faulty validator doesn't keep failing the same rounds
+func (i *Instance) Proposer(round uint32) shared.ParticipantId {
+	return i.validators[(uint64(i.Height) + uint64(round)) % uint64(len(i.validators))]
+}
+
+func (i *Instance) Start() {
+	if i.started {
+		return
+	}
+	i.started = true
+	i.startRound(0)
+	i.evaluate()
+}
+
+// the decided value, nil until decided
+func (i *Instance) Decision() []byte {
+	return i.decision
+}
+
+// the quorum of precommits the decision was made on
+func (i *Instance) Commit() []*pb.ConsensusMessage {
+	if i.decision == nil {
+		return nil
+	}
+	ret := make([]*pb.ConsensusMessage, 0)
+	for _, id := range i.validators {
+		msg, found := i.precommits[i.decisionRound][id]
+		if found && bytes.Equal(msg.ValueHash, ValueHash(i.decision)) {
+			ret = append(ret, msg)
+		}
+	}
+	return ret
+}
+
+func (i *Instance) Round() uint32 {
+	return i.round
+}
+
+// messages to broadcast and timeouts to schedule since the last call
+func (i *Instance) Drain() ([]*pb.ConsensusMessage, []Timeout) {
+	msgs, timeouts := i.outbox, i.timeouts
+	i.outbox, i.timeouts = nil, nil
+	return msgs, timeouts
+}
+
+// messages can arrive before Start, they're kept and acted on once started
+func (i *Instance) Receive(msg *pb.ConsensusMessage) {
+	if msg.Height != i.Height || msg.FromParticipant == nil || !i.isValidator(msg.FromParticipant.Id) {
+		return
+	}
+	i.record(msg)
+	i.evaluate()
+}
+
+func (i *Instance) Timeout(timeout Timeout) {
+	if !i.started || i.decision != nil || timeout.Round != i.round {
+		return
+	}
+	switch timeout.Step {
+	case StepPropose:
+		if i.step == StepPropose {
+			i.broadcast(pb.ConsensusMessageType_PREVOTE, nil)
+			i.step = StepPrevote
+		}
+	case StepPrevote:
+		if i.step == StepPrevote {
+			i.broadcast(pb.ConsensusMessageType_PRECOMMIT, nil)
+			i.step = StepPrecommit
+		}
+	case StepPrecommit:
+		i.startRound(i.round + 1)
+	}
+	i.evaluate()
+}
+
+func (i *Instance) isValidator(id shared.ParticipantId) bool {
+	for _, v := range i.validators {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Instance) quorum() int {
+	n := len(i.validators)
+	return n - (n - 1) / 3
+}
+
+// keeps the first message of every sender per round and type, later ones (equivocations) are ignored
+func (i *Instance) record(msg *pb.ConsensusMessage) {
+	from := msg.FromParticipant.Id
+	switch msg.Type {
+	case pb.ConsensusMessageType_PROPOSAL:
+		if from != i.Proposer(msg.Round) || msg.ValidRound < -1 || msg.ValidRound >= int32(msg.Round) {
+			return
+		}
+		if _, found := i.proposals[msg.Round]; !found {
+			i.proposals[msg.Round] = msg
+		}
+	case pb.ConsensusMessageType_PREVOTE:
+		recordVote(i.prevotes, msg)
+	case pb.ConsensusMessageType_PRECOMMIT:
+		recordVote(i.precommits, msg)
+	}
+}
+
+func recordVote(votes map[uint32]map[shared.ParticipantId]*pb.ConsensusMessage, msg *pb.ConsensusMessage) {
+	if votes[msg.Round] == nil {
+		votes[msg.Round] = make(map[shared.ParticipantId]*pb.ConsensusMessage)
+	}
+	if _, found := votes[msg.Round][msg.FromParticipant.Id]; !found {
+		votes[msg.Round][msg.FromParticipant.Id] = msg
+	}
+}
+
+// votes in round for hash, any hash (including nil) if any is set
+func countVotes(votes map[shared.ParticipantId]*pb.ConsensusMessage, hash []byte, any bool) int {
+	ret := 0
+	for _, vote := range votes {
+		if any || bytes.Equal(vote.ValueHash, hash) {
+			ret++
+		}
+	}
+	return ret
+}
+
+func (i *Instance) isValid(value []byte) bool {
+	key := string(ValueHash(value))
+	ret, found := i.validCache[key]
+	if !found {
+		ret = i.valid(value)
+		i.validCache[key] = ret
+	}
+	return ret
+}
+
+// sends the message to the others and records it as received, followers don't vote
+func (i *Instance) broadcast(t pb.ConsensusMessageType, hash []byte) {
+	if !i.isValidator(i.id) {
+		return
+	}
+	msg := &pb.ConsensusMessage{
+		Type: t,
+		FromParticipant: &pb.Participant{Id: i.id},
+		Height: i.Height,
+		Round: i.round,
+		ValidRound: -1,
+		ValueHash: hash,
+	}
+	i.record(msg)
+	i.outbox = append(i.outbox, msg)
+}
+
+func (i *Instance) startRound(round uint32) {
+	i.round = round
+	i.step = StepPropose
+	if i.Proposer(round) != i.id {
+		i.timeouts = append(i.timeouts, Timeout{Step: StepPropose, Round: round})
+		return
+	}
+
+	value := i.validValue
+	if value == nil {
+		value = i.propose()
+	}
+	msg := &pb.ConsensusMessage{
+		Type: pb.ConsensusMessageType_PROPOSAL,
+		FromParticipant: &pb.Participant{Id: i.id},
+		Height: i.Height,
+		Round: round,
+		Value: value,
+		ValidRound: i.validRound,
+	}
+	i.record(msg)
+	i.outbox = append(i.outbox, msg)
+}
+
+// applies the algorithm's upon rules until none applies
+func (i *Instance) evaluate() {
+	if !i.started {
+		return
+	}
+	for i.decision == nil && i.applyRule() {
+	}
+}
+
+// applies the first rule that fires, false if none does
+func (i *Instance) applyRule() bool {
+	round := i.round
+	proposal := i.proposals[round]
+	prevotes := i.prevotes[round]
+
+	// a decision can be reached in any round
+	for r, p := range i.proposals {
+		if countVotes(i.precommits[r], ValueHash(p.Value), false) >= i.quorum() && i.isValid(p.Value) {
+			i.decision = p.Value
+			i.decisionRound = r
+			return true
+		}
+	}
+
+	// f+1 validators are ahead, at least one of them honest
+	if r, found := i.roundAhead(); found {
+		i.startRound(r)
+		return true
+	}
+
+	if proposal != nil && i.step == StepPropose {
+		hash := ValueHash(proposal.Value)
+		vr := proposal.ValidRound
+		if vr == -1 {
+			if i.isValid(proposal.Value) && (i.lockedRound == -1 || bytes.Equal(i.lockedValue, proposal.Value)) {
+				i.broadcast(pb.ConsensusMessageType_PREVOTE, hash)
+			} else {
+				i.broadcast(pb.ConsensusMessageType_PREVOTE, nil)
+			}
+			i.step = StepPrevote
+			return true
+		}
+		if vr >= 0 && uint32(vr) < round && countVotes(i.prevotes[uint32(vr)], hash, false) >= i.quorum() {
+			if i.isValid(proposal.Value) && (i.lockedRound <= vr || bytes.Equal(i.lockedValue, proposal.Value)) {
+				i.broadcast(pb.ConsensusMessageType_PREVOTE, hash)
+			} else {
+				i.broadcast(pb.ConsensusMessageType_PREVOTE, nil)
+			}
+			i.step = StepPrevote
+			return true
+		}
+	}
+
+	if i.step == StepPrevote && !i.prevoteTimeoutScheduled[round] && countVotes(prevotes, nil, true) >= i.quorum() {
+		i.prevoteTimeoutScheduled[round] = true
+		i.timeouts = append(i.timeouts, Timeout{Step: StepPrevote, Round: round})
+		return true
+	}
+
+	if proposal != nil && i.step >= StepPrevote && !i.quorumPrevoted[round] &&
+		countVotes(prevotes, ValueHash(proposal.Value), false) >= i.quorum() && i.isValid(proposal.Value) {
+		i.quorumPrevoted[round] = true
+		if i.step == StepPrevote {
+			i.lockedValue = proposal.Value
+			i.lockedRound = int32(round)
+			i.broadcast(pb.ConsensusMessageType_PRECOMMIT, ValueHash(proposal.Value))
+			i.step = StepPrecommit
+		}
+		i.validValue = proposal.Value
+		i.validRound = int32(round)
+		return true
+	}
+
+	if i.step == StepPrevote && countVotes(prevotes, nil, false) >= i.quorum() {
+		i.broadcast(pb.ConsensusMessageType_PRECOMMIT, nil)
+		i.step = StepPrecommit
+		return true
+	}
+
+	if !i.precommitTimeoutScheduled[round] && countVotes(i.precommits[round], nil, true) >= i.quorum() {
+		i.precommitTimeoutScheduled[round] = true
+		i.timeouts = append(i.timeouts, Timeout{Step: StepPrecommit, Round: round})
+		return true
+	}
+
+	return false
+}
+
+// the highest round after the current one f+1 validators sent messages in, if any
+func (i *Instance) roundAhead() (uint32, bool) {
+	senders := make(map[uint32]map[shared.ParticipantId]bool)
+	add := func(round uint32, id shared.ParticipantId) {
+		if round <= i.round {
+			return
+		}
+		if senders[round] == nil {
+			senders[round] = make(map[shared.ParticipantId]bool)
+		}
+		senders[round][id] = true
+	}
+	for r, p := range i.proposals {
+		add(r, p.FromParticipant.Id)
+	}
+	for _, votes := range []map[uint32]map[shared.ParticipantId]*pb.ConsensusMessage{i.prevotes, i.precommits} {
+		for r, byId := range votes {
+			for id := range byId {
+				add(r, id)
+			}
+		}
+	}
+
+	f := (len(i.validators) - 1) / 3
+	highest, found := uint32(0), false
+	for r, ids := range senders {
+		if len(ids) >= f + 1 && (!found || r > highest) {
+			highest, found = r, true
+		}
+	}
+	return highest, found
+}
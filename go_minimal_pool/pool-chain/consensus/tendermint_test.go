@@ -0,0 +1,174 @@
+package consensus
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testValidators = []shared.ParticipantId{1, 2, 3, 4}
+
+func proposeOwn(id shared.ParticipantId) []byte {
+	return []byte(fmt.Sprintf("block of %d", id))
+}
+
+func allValid(id shared.ParticipantId, value []byte) bool {
+	return true
+}
+
+// every validator that didn't crash decided the same value
+func requireAgreement(t *testing.T, h *Harness) []byte {
+	var decision []byte
+	for id, instance := range h.Instances {
+		if h.Crashed[id] {
+			continue
+		}
+		require.NotNil(t, instance.Decision(), "P %d", id)
+		if decision == nil {
+			decision = instance.Decision()
+		}
+		require.Equal(t, decision, instance.Decision(), "P %d", id)
+		require.GreaterOrEqual(t, len(instance.Commit()), 3)
+	}
+	return decision
+}
+
+func TestHonestValidatorsDecideInFirstRound(t *testing.T) {
+	h := NewHarness(0, testValidators, proposeOwn, allValid)
+	require.EqualValues(t, 1, h.Instances[1].Proposer(0))
+	require.EqualValues(t, 2, h.Instances[1].Proposer(1))
+	h.Start()
+	require.True(t, h.Run(1000))
+	require.Equal(t, proposeOwn(1), requireAgreement(t, h))
+	for _, instance := range h.Instances {
+		require.EqualValues(t, 0, instance.Round())
+	}
+
+	// same run, same result
+	again := NewHarness(0, testValidators, proposeOwn, allValid)
+	again.Start()
+	require.True(t, again.Run(1000))
+	for id, instance := range again.Instances {
+		require.Equal(t, h.Instances[id].Commit(), instance.Commit())
+	}
+}
+
+func TestCrashedProposer(t *testing.T) {
+	// height 2's first proposer is 3
+	h := NewHarness(2, testValidators, proposeOwn, allValid)
+	h.Crashed[3] = true
+	h.Start()
+	require.True(t, h.Run(1000))
+	require.Equal(t, proposeOwn(4), requireAgreement(t, h))
+}
+
+func TestInvalidProposal(t *testing.T) {
+	h := NewHarness(0, testValidators, proposeOwn, func(id shared.ParticipantId, value []byte) bool {
+		return string(value) != string(proposeOwn(1))
+	})
+	h.Start()
+	require.True(t, h.Run(1000))
+	require.Equal(t, proposeOwn(2), requireAgreement(t, h))
+}
+
+// a byzantine proposer sends different blocks to different validators, they still agree
+func TestEquivocatingProposer(t *testing.T) {
+	h := NewHarness(0, testValidators, proposeOwn, allValid)
+	h.Crashed[1] = true
+	h.Drop = func(msg *pb.ConsensusMessage, to shared.ParticipantId) bool {
+		if msg.FromParticipant.Id != 1 {
+			return false
+		}
+		// 2 gets a, 3 and 4 get b
+		return (string(msg.Value) == "a") != (to == 2)
+	}
+	h.Start()
+	for _, value := range []string{"a", "b"} {
+		h.Inject(&pb.ConsensusMessage{Type: pb.ConsensusMessageType_PROPOSAL, FromParticipant: &pb.Participant{Id: 1}, Round: 0, Value: []byte(value), ValidRound: -1})
+	}
+	require.True(t, h.Run(1000))
+	// neither got a quorum, the next proposer's block is decided
+	require.Equal(t, proposeOwn(2), requireAgreement(t, h))
+}
+
+// a quorum can't be reached with 2 of 4 validators down, nobody decides
+func TestNoQuorum(t *testing.T) {
+	h := NewHarness(0, testValidators, proposeOwn, allValid)
+	h.Crashed[3] = true
+	h.Crashed[4] = true
+	h.Start()
+	require.False(t, h.Run(1000))
+	require.Nil(t, h.Instances[1].Decision())
+	require.Nil(t, h.Instances[2].Decision())
+	// waiting for a quorum of prevotes, without one no timeout moves the round on
+	require.EqualValues(t, 0, h.Instances[1].Round())
+}
+
+// messages that arrived before the instance started, or from non validators, or for another height
+func TestEarlyAndForeignMessages(t *testing.T) {
+	instance := NewInstance(5, 1, testValidators, func() []byte { return []byte("mine") }, func(value []byte) bool { return true })
+	// height 5 round 0 is proposed by 2
+	require.EqualValues(t, 2, instance.Proposer(0))
+	instance.Receive(&pb.ConsensusMessage{Type: pb.ConsensusMessageType_PROPOSAL, FromParticipant: &pb.Participant{Id: 2}, Height: 5, Value: []byte("x"), ValidRound: -1})
+	instance.Receive(&pb.ConsensusMessage{Type: pb.ConsensusMessageType_PROPOSAL, FromParticipant: &pb.Participant{Id: 3}, Height: 5, Value: []byte("y"), ValidRound: -1})
+	msgs, _ := instance.Drain()
+	require.Len(t, msgs, 0)
+
+	instance.Start()
+	msgs, _ = instance.Drain()
+	require.Len(t, msgs, 1)
+	require.Equal(t, pb.ConsensusMessageType_PREVOTE, msgs[0].Type)
+	require.Equal(t, ValueHash([]byte("x")), msgs[0].ValueHash)
+
+	for _, from := range []shared.ParticipantId{2, 3, 9} {
+		instance.Receive(&pb.ConsensusMessage{Type: pb.ConsensusMessageType_PREVOTE, FromParticipant: &pb.Participant{Id: from}, Height: 5, ValueHash: ValueHash([]byte("x"))})
+	}
+	instance.Receive(&pb.ConsensusMessage{Type: pb.ConsensusMessageType_PREVOTE, FromParticipant: &pb.Participant{Id: 4}, Height: 6, ValueHash: ValueHash([]byte("x"))})
+	msgs, _ = instance.Drain()
+	require.Len(t, msgs, 1)
+	require.Equal(t, pb.ConsensusMessageType_PRECOMMIT, msgs[0].Type)
+}
+
+// validators connected in process, deciding with real timeouts. 5 isn't a validator, it follows.
+func TestTendermintDriver(t *testing.T) {
+	drivers := make(map[shared.ParticipantId]*Tendermint)
+	for _, id := range append(testValidators, 5) {
+		drivers[id] = NewTendermint(id, time.Millisecond * 50, func(msg *pb.ConsensusMessage) error {
+			require.NotEqual(t, uint32(5), msg.FromParticipant.Id)
+			for to, d := range drivers {
+				// 1 is down
+				if to != msg.FromParticipant.Id && to != 1 {
+					d.Receive(msg)
+				}
+			}
+			return nil
+		})
+	}
+
+	decisions := make(map[shared.ParticipantId][]byte)
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, id := range []shared.ParticipantId{2, 3, 4, 5} {
+		wg.Add(1)
+		go func(id shared.ParticipantId) {
+			defer wg.Done()
+			value, err := drivers[id].Decide(0, testValidators, func() []byte { return proposeOwn(id) }, func(value []byte) bool { return true }, time.Second * 5)
+			require.NoError(t, err)
+			lock.Lock()
+			decisions[id] = value
+			lock.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	require.Len(t, decisions, 4)
+	for _, value := range decisions {
+		require.Equal(t, proposeOwn(2), value)
+	}
+
+	_, err := drivers[2].Decide(0, testValidators, func() []byte { return nil }, func(value []byte) bool { return true }, time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client of the control API, address is the server's host:port
+type Client struct {
+	address string
+	http *http.Client
+}
+
+func NewClient(address string) *Client {
+	return &Client{
+		address: address,
+		http: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (c *Client) Status() (*Status, error) {
+	ret := &Status{}
+	err := c.do(http.MethodGet, "/status", nil, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (c *Client) Pools() ([]*PoolInfo, error) {
+	ret := make([]*PoolInfo, 0)
+	err := c.do(http.MethodGet, "/pools", nil, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// from and to are optional (nil), see Server
+func (c *Client) Epochs(from *uint32, to *uint32) ([]*EpochInfo, error) {
+	query := url.Values{}
+	if from != nil {
+		query.Set("from", fmt.Sprintf("%d", *from))
+	}
+	if to != nil {
+		query.Set("to", fmt.Sprintf("%d", *to))
+	}
+
+	ret := make([]*EpochInfo, 0)
+	err := c.do(http.MethodGet, "/epochs", query, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// asks the network to create a pending pool, see Server
+func (c *Client) CreatePool() (*RequestInfo, error) {
+	return c.request("/pools/create", nil)
+}
+
+// deposits gwei to a pending pool
+func (c *Client) AddPoolStake(id uint32, gwei uint64) (*RequestInfo, error) {
+	query := url.Values{}
+	query.Set("id", fmt.Sprintf("%d", id))
+	query.Set("gwei", fmt.Sprintf("%d", gwei))
+	return c.request("/pools/stake", query)
+}
+
+// requests an active pool's liquidation
+func (c *Client) LiquidatePool(id uint32) (*RequestInfo, error) {
+	query := url.Values{}
+	query.Set("id", fmt.Sprintf("%d", id))
+	return c.request("/pools/liquidate", query)
+}
+
+// asks the network to register the node's participant, see Server
+func (c *Client) Join() (*RequestInfo, error) {
+	return c.request("/participants/join", nil)
+}
+
+// asks the network for the node's participant's voluntary exit
+func (c *Client) Exit() (*RequestInfo, error) {
+	return c.request("/participants/exit", nil)
+}
+
+func (c *Client) request(path string, query url.Values) (*RequestInfo, error) {
+	ret := &RequestInfo{}
+	err := c.do(http.MethodPost, path, query, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// asks the node to shut down, returns once the node accepted the request
+func (c *Client) Shutdown() error {
+	return c.do(http.MethodPost, "/shutdown", nil, nil)
+}
+
+func (c *Client) do(method string, path string, query url.Values, ret interface{}) error {
+	u := url.URL{Scheme: "http", Host: c.address, Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s (%s)", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if ret == nil {
+		return nil
+	}
+	return json.Unmarshal(body, ret)
+}
@@ -0,0 +1,358 @@
+package control
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	net2 "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// epochs listed when no range is given
+const defaultEpochsCount = 10
+// the longest range listed at once, every epoch in it is looked up
+const maxEpochsCount = 100
+
+/**
+	HTTP control API of a single node (poolnode), meant to listen on localhost only.
+		GET /status
+		GET /pools
+		GET /epochs?from=<number>&to=<number> (default the last 10 epochs, at most 100)
+		POST /pools/create
+		POST /pools/stake?id=<pool>&gwei=<amount>
+		POST /pools/liquidate?id=<pool>
+		POST /participants/join
+		POST /participants/exit
+		POST /shutdown
+	Pool requests, join and exit are registry requests signed by the node's participant and sent to every node, the
+	block of the request's epoch or the next one includes it if it applies to the finalized state (see
+	pool-chain/registry.go). The node's state changes once the block is finalized.
+ */
+type Server struct {
+	node *pool_chain.PoolChainNode
+	requester Requester
+	server *http.Server
+	listener net.Listener
+}
+
+// signs and sends the node's participant's registry requests (participant.Participant)
+type Requester interface {
+	RequestJoin() (*pb.RegistryRequest, error)
+	RequestExit() (*pb.RegistryRequest, error)
+	RequestPoolCreation(id shared.PoolId) (*pb.RegistryRequest, error)
+	RequestPoolDeposit(id shared.PoolId, gwei uint64) (*pb.RegistryRequest, error)
+	RequestPoolLiquidation(id shared.PoolId) (*pb.RegistryRequest, error)
+}
+
+// listens on address (e.g. "127.0.0.1:0" for a random port), Address() is the actual address
+func NewServer(address string, node *pool_chain.PoolChainNode, requester Requester) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Server{
+		node: node,
+		requester: requester,
+		listener: listener,
+	}
+	ret.server = &http.Server{Handler: ret.Handler()}
+
+	go func() {
+		err := ret.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("control server on %s stopped: %s", listener.Addr().String(), err.Error())
+		}
+	}()
+
+	return ret, nil
+}
+
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.get(s.status))
+	mux.HandleFunc("/pools", s.get(s.pools))
+	mux.HandleFunc("/epochs", s.get(s.epochs))
+	mux.HandleFunc("/pools/create", s.post(s.createPool))
+	mux.HandleFunc("/pools/stake", s.post(s.addPoolStake))
+	mux.HandleFunc("/pools/liquidate", s.post(s.liquidatePool))
+	mux.HandleFunc("/participants/join", s.post(s.join))
+	mux.HandleFunc("/participants/exit", s.post(s.exit))
+	mux.HandleFunc("/shutdown", s.shutdown)
+	return mux
+}
+
+func (s *Server) status(r *http.Request) (interface{}, error) {
+	peers := -1
+	if network, ok := s.node.Net.(interface{ Peers() []*net2.Peer }); ok {
+		peers = len(network.Peers())
+	}
+
+	finalized, err := s.node.FinalizedState()
+	if err != nil {
+		return nil, err
+	}
+	root := finalized.HashTreeRoot()
+	ret := &Status{
+		ParticipantId: s.node.FilterId,
+		Address:       s.node.Net.OwnPeer().Address,
+		Peers:         peers,
+		GenesisReady:  s.node.GenesisReady(),
+		CurrentEpoch:  s.node.CurrentEpochNumber(),
+		Pools:         len(s.node.State.Pools()),
+		FinalizedStateRoot: hex.EncodeToString(root[:]),
+	}
+	ret.ParticipantStatus = "unregistered"
+	if participant := finalized.GetParticipant(s.node.FilterId); participant != nil {
+		ret.ParticipantStatus = participant.Status(ret.CurrentEpoch).String()
+		ret.Balance = participant.Balance
+		ret.Slashed = participant.Slashed
+	}
+	if block := finalized.LatestBlock(); block != nil {
+		epoch := block.Epoch
+		ret.FinalizedEpoch = &epoch
+	}
+	return ret, nil
+}
+
+func (s *Server) pools(r *http.Request) (interface{}, error) {
+	ret := make([]*PoolInfo, 0)
+	for _, pool := range s.node.State.Pools() {
+		ret = append(ret, s.poolInfo(pool))
+	}
+	return ret, nil
+}
+
+func (s *Server) poolInfo(pool *state.Pool) *PoolInfo {
+	ret := &PoolInfo{
+		Id:                   pool.Id,
+		Size:                 pool.Size,
+		Status:               pool.Status(s.node.CurrentEpochNumber()).String(),
+		Stake:                pool.Stake,
+		LiquidationRequested: pool.LiquidationRequested,
+	}
+	if pool.Pk != nil {
+		ret.Pk = pool.Pk.SerializeToHexStr()
+	}
+	if pool.ActivationEpoch != state.FarFutureEpoch {
+		epoch := pool.ActivationEpoch
+		ret.ActivationEpoch = &epoch
+	}
+	if pool.ExitEpoch != state.FarFutureEpoch {
+		epoch := pool.ExitEpoch
+		ret.ExitEpoch = &epoch
+	}
+	if pool.ExitSignature != nil {
+		ret.ExitSignature = pool.ExitSignature.SerializeToHexStr()
+	}
+	return ret
+}
+
+// the pool gets the next id of the node's registry, a pool created by another request first takes it and this one
+// isn't included
+func (s *Server) createPool(r *http.Request) (interface{}, error) {
+	return requestInfo(s.requester.RequestPoolCreation(s.node.State.Registry().NextPoolId()))
+}
+
+func (s *Server) addPoolStake(r *http.Request) (interface{}, error) {
+	id, err := uintParam(r, "id", 32)
+	if err != nil {
+		return nil, err
+	}
+	gwei, err := uintParam(r, "gwei", 64)
+	if err != nil {
+		return nil, err
+	}
+	return requestInfo(s.requester.RequestPoolDeposit(shared.PoolId(id), gwei))
+}
+
+func (s *Server) liquidatePool(r *http.Request) (interface{}, error) {
+	id, err := uintParam(r, "id", 32)
+	if err != nil {
+		return nil, err
+	}
+	return requestInfo(s.requester.RequestPoolLiquidation(shared.PoolId(id)))
+}
+
+func (s *Server) join(r *http.Request) (interface{}, error) {
+	return requestInfo(s.requester.RequestJoin())
+}
+
+func (s *Server) exit(r *http.Request) (interface{}, error) {
+	return requestInfo(s.requester.RequestExit())
+}
+
+func requestInfo(req *pb.RegistryRequest, err error) (*RequestInfo, error) {
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("P %d, %s request %s sent", req.FromParticipant.Id, req.Type.String(), req.Id)
+	return &RequestInfo{
+		Id:            req.Id,
+		Type:          strings.ToLower(req.Type.String()),
+		ParticipantId: req.FromParticipant.Id,
+		Epoch:         req.Epoch,
+		PoolId:        req.PoolId,
+		Gwei:          req.Gwei,
+	}, nil
+}
+
+// epochs not in the db (not processed yet) are skipped
+func (s *Server) epochs(r *http.Request) (interface{}, error) {
+	to := s.node.CurrentEpochNumber()
+	from := shared.EpochNumber(0)
+	if to >= defaultEpochsCount {
+		from = to - defaultEpochsCount + 1
+	}
+	from, err := epochParam(r, "from", from)
+	if err != nil {
+		return nil, err
+	}
+	to, err = epochParam(r, "to", to)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("from (%d) is after to (%d)", from, to)
+	}
+	if to - from >= maxEpochsCount {
+		return nil, fmt.Errorf("at most %d epochs can be listed at once", maxEpochsCount)
+	}
+
+	ret := make([]*EpochInfo, 0)
+	for number := from ; ; number++ {
+		epoch, err := s.node.State.FindEpoch(number)
+		if err != nil {
+			return nil, err
+		}
+		if epoch != nil {
+			ret = append(ret, s.epochInfo(epoch))
+		}
+		// not number <= to, it would never end for the max epoch number
+		if number == to {
+			break
+		}
+	}
+	return ret, nil
+}
+
+func (s *Server) epochInfo(epoch *state.Epoch) *EpochInfo {
+	ret := &EpochInfo{
+		Number:       epoch.Number,
+		HasShare:     epoch.ParticipantShare != nil,
+		PublicShares: len(epoch.PublicShares),
+		BadSigners:   make([]uint32, 0),
+		Signatures:   make(map[string]string),
+		SigVerified:  epoch.EpochSigVerified,
+	}
+	root := epoch.HashTreeRoot()
+	ret.Root = hex.EncodeToString(root[:])
+	if epoch.StateRoot != (eth2.Root{}) {
+		ret.StateRoot = hex.EncodeToString(epoch.StateRoot[:])
+	}
+	if block := s.node.FinalizedBlock(epoch.Number); block != nil {
+		ret.FinalizedStateRoot = hex.EncodeToString(block.StateRoot[:])
+	}
+	poolId, err := epoch.ParticipantPoolAssignment(s.node.FilterId)
+	if err == nil {
+		ret.PoolId = poolId
+	}
+	for id, bad := range epoch.BadSigners {
+		if bad {
+			ret.BadSigners = append(ret.BadSigners, id)
+		}
+	}
+	sort.Slice(ret.BadSigners, func(i, j int) bool { return ret.BadSigners[i] < ret.BadSigners[j] })
+	for root, sig := range epoch.ReconstructedSignatures {
+		ret.Signatures[hex.EncodeToString(root[:])] = hex.EncodeToString(sig.Serialize())
+	}
+	return ret
+}
+
+// the node's owner (e.g. poolnode's main) reads the kill channel and shuts down
+func (s *Server) shutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log.Printf("P %d, shutdown requested", s.node.FilterId)
+	go func() {
+		s.node.Killed <- true
+	}()
+	writeJSON(w, map[string]bool{"shutting_down": true})
+}
+
+func (s *Server) get(f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return handle(http.MethodGet, f)
+}
+
+func (s *Server) post(f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return handle(http.MethodPost, f)
+}
+
+func handle(method string, f func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ret, err := f(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, ret)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("control api, could not write response: %s", err.Error())
+	}
+}
+
+// a required unsigned integer query param
+func uintParam(r *http.Request, name string, bitSize int) (uint64, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return 0, fmt.Errorf("missing %s", name)
+	}
+	ret, err := strconv.ParseUint(val, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, err.Error())
+	}
+	return ret, nil
+}
+
+func epochParam(r *http.Request, name string, def shared.EpochNumber) (shared.EpochNumber, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return def, nil
+	}
+	ret, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, err.Error())
+	}
+	return shared.EpochNumber(ret), nil
+}
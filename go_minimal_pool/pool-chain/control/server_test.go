@@ -0,0 +1,217 @@
+package control
+
+import (
+	"encoding/hex"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/participant"
+	pool_chain "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the node of genesis participant 1
+func newTestServer(t *testing.T) (*pool_chain.PoolChainNode, *Client, func()) {
+	crypto.InitBLS()
+
+	p := participant.NewParticipant(1)
+	node := pool_chain.NewTestChainNode()
+	require.NoError(t, node.State.SaveParticipant(state.NewParticipant(p.Id, p.EncryptionPk, p.IdentityPk)))
+	client, closeServer := serve(node, p)
+	return node, client, closeServer
+}
+
+func serve(node *pool_chain.PoolChainNode, p *participant.Participant) (*Client, func()) {
+	p.SetNode(node)
+	// receives its own requests
+	node.Net.AddPeer(node.Net.OwnPeer())
+	s := &Server{node: node, requester: p}
+	httpServer := httptest.NewServer(s.Handler())
+	client := NewClient(strings.TrimPrefix(httpServer.URL, "http://"))
+	return client, httpServer.Close
+}
+
+func TestStatusAndPools(t *testing.T) {
+	node, client, closeServer := newTestServer(t)
+	defer closeServer()
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, status.ParticipantId)
+	require.False(t, status.GenesisReady)
+	// the genesis pools are registered, waiting for their keys
+	require.EqualValues(t, 2, status.Pools)
+	// no block yet, the genesis state's root
+	require.Nil(t, status.FinalizedEpoch)
+	require.EqualValues(t, 0, status.Balance)
+	require.False(t, status.Slashed)
+	root := node.State.Genesis().HashTreeRoot()
+	require.Equal(t, hex.EncodeToString(root[:]), status.FinalizedStateRoot)
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	require.NoError(t, node.State.SavePool(state.NewPool(2, 3, sk.GetPublicKey())))
+	require.NoError(t, node.State.SavePool(state.NewPool(1, 3, nil)))
+
+	pools, err := client.Pools()
+	require.NoError(t, err)
+	require.Len(t, pools, 2)
+	require.EqualValues(t, 1, pools[0].Id)
+	require.Equal(t, "", pools[0].Pk)
+	require.EqualValues(t, 2, pools[1].Id)
+	require.Equal(t, sk.GetPublicKey().SerializeToHexStr(), pools[1].Pk)
+	require.Equal(t, "active", pools[1].Status)
+	require.EqualValues(t, 0, *pools[1].ActivationEpoch)
+	require.Nil(t, pools[1].ExitEpoch)
+
+	status, err = client.Status()
+	require.NoError(t, err)
+	require.False(t, status.GenesisReady)
+
+	require.NoError(t, node.State.SavePool(state.NewPool(1, 3, sk.GetPublicKey())))
+	status, err = client.Status()
+	require.NoError(t, err)
+	require.True(t, status.GenesisReady)
+}
+
+func TestPoolLifecycleRequests(t *testing.T) {
+	node, client, closeServer := newTestServer(t)
+	defer closeServer()
+
+	created, err := client.CreatePool()
+	require.NoError(t, err)
+	require.Equal(t, "create_pool", created.Type)
+	require.EqualValues(t, 3, created.PoolId)
+	deposit, err := client.AddPoolStake(3, state.PoolStake)
+	require.NoError(t, err)
+	require.Equal(t, "deposit", deposit.Type)
+	require.Equal(t, state.PoolStake, deposit.Gwei)
+	liquidation, err := client.LiquidatePool(1)
+	require.NoError(t, err)
+	require.Equal(t, "liquidation", liquidation.Type)
+
+	// sent to the network, the node's state changes once a block includes them
+	require.Nil(t, node.State.GetPool(3))
+	require.False(t, node.State.GetPool(1).LiquidationRequested)
+	pending := node.PendingRequests(node.State, 0)
+	require.Len(t, pending, 3)
+	require.Equal(t, []string{created.Id, deposit.Id, liquidation.Id}, []string{pending[0].Id, pending[1].Id, pending[2].Id})
+
+	// a deposit to an unknown pool is sent but never included
+	_, err = client.AddPoolStake(4, state.PoolStake)
+	require.NoError(t, err)
+	require.Len(t, node.PendingRequests(node.State, 0), 3)
+	// missing the pool and amount
+	require.Error(t, client.do("POST", "/pools/stake", nil, nil))
+
+	// pool requests must be a POST
+	require.Error(t, client.do("GET", "/pools/create", nil, nil))
+}
+
+func TestRegistryRequests(t *testing.T) {
+	node, client, closeServer := newTestServer(t)
+	defer closeServer()
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	require.Equal(t, "active", status.ParticipantStatus)
+
+	exit, err := client.Exit()
+	require.NoError(t, err)
+	require.Equal(t, "exit", exit.Type)
+	require.EqualValues(t, 1, exit.ParticipantId)
+	require.EqualValues(t, 0, exit.Epoch)
+	// sent to the network, the state changes once a block includes it
+	pending := node.PendingRequests(node.State, 0)
+	require.Len(t, pending, 1)
+	require.Equal(t, exit.Id, pending[0].Id)
+	require.False(t, node.State.GetParticipant(1).ExitRequested)
+
+	// a participant joining after genesis isn't registered
+	joiner := pool_chain.NewTestChainNode()
+	joinerClient, closeJoiner := serve(joiner, participant.NewParticipant(7))
+	defer closeJoiner()
+	status, err = joinerClient.Status()
+	require.NoError(t, err)
+	require.Equal(t, "unregistered", status.ParticipantStatus)
+
+	join, err := joinerClient.Join()
+	require.NoError(t, err)
+	require.Equal(t, "join", join.Type)
+	require.EqualValues(t, 7, join.ParticipantId)
+	pending = joiner.PendingRequests(joiner.State, 0)
+	require.Len(t, pending, 1)
+	require.Equal(t, join.Id, pending[0].Id)
+	require.Nil(t, joiner.State.GetParticipant(7))
+
+	// requests must be a POST
+	require.Error(t, client.do("GET", "/participants/join", nil, nil))
+}
+
+func TestEpochs(t *testing.T) {
+	node, client, closeServer := newTestServer(t)
+	defer closeServer()
+
+	share := &bls.Fr{}
+	share.SetByCSPRNG()
+	epoch := node.State.GetEpoch(0)
+	epoch.ParticipantShare = share
+	epoch.BadSigners[4] = true
+	epoch.StateRoot = node.State.HashTreeRoot()
+	require.NoError(t, node.State.SaveEpoch(epoch))
+	node.State.GetEpoch(2)
+
+	epochs, err := client.Epochs(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, epochs, 1)
+	require.EqualValues(t, 0, epochs[0].Number)
+	require.True(t, epochs[0].HasShare)
+	require.Equal(t, []uint32{4}, epochs[0].BadSigners)
+	root := epoch.HashTreeRoot()
+	require.Equal(t, hex.EncodeToString(root[:]), epochs[0].Root)
+	require.Equal(t, hex.EncodeToString(epoch.StateRoot[:]), epochs[0].StateRoot)
+	require.Equal(t, "", epochs[0].FinalizedStateRoot)
+
+	// missing epochs are skipped, listing doesn't create them
+	from, to := uint32(0), uint32(3)
+	epochs, err = client.Epochs(&from, &to)
+	require.NoError(t, err)
+	require.Len(t, epochs, 2)
+	require.EqualValues(t, 2, epochs[1].Number)
+	require.False(t, epochs[1].HasShare)
+	require.Equal(t, "", epochs[1].StateRoot)
+	missing, err := node.State.FindEpoch(1)
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	from, to = 3, 1
+	_, err = client.Epochs(&from, &to)
+	require.Error(t, err)
+	// too long a range
+	from, to = 0, 4294967295
+	_, err = client.Epochs(&from, &to)
+	require.Error(t, err)
+	from, to = 0, maxEpochsCount - 1
+	_, err = client.Epochs(&from, &to)
+	require.NoError(t, err)
+}
+
+func TestShutdown(t *testing.T) {
+	node, client, closeServer := newTestServer(t)
+	defer closeServer()
+
+	require.NoError(t, client.Shutdown())
+	select {
+	case killed := <- node.Killed:
+		require.True(t, killed)
+	case <- time.After(time.Second * 2):
+		t.Fatal("node was not killed")
+	}
+
+	// shutdown must be a POST
+	require.Error(t, client.do("GET", "/shutdown", nil, nil))
+}
@@ -0,0 +1,75 @@
+package control
+
+/**
+	JSON responses of the node's control API, used by poolctl.
+	Only public data is exposed, an epoch tells whether the participant holds a share but never the share itself.
+ */
+
+type Status struct {
+	ParticipantId uint32 `json:"participant_id"`
+	// the node's p2p address
+	Address string `json:"address"`
+	// directly connected peers, -1 if the network doesn't tell
+	Peers int `json:"peers"`
+	GenesisReady bool `json:"genesis_ready"`
+	CurrentEpoch uint32 `json:"current_epoch"`
+	Pools int `json:"pools"`
+	// the participant's status (pending, active or exited) in the current epoch, unregistered until a finalized
+	// block includes its join request
+	ParticipantStatus string `json:"participant_status"`
+	// gwei, the participant's balance in the finalized state (rewards minus penalties)
+	Balance uint64 `json:"balance"`
+	// evidence of the participant's misbehaviour was finalized, it's removed from the pools
+	Slashed bool `json:"slashed"`
+	// the latest finalized block's epoch, not set before block 0
+	FinalizedEpoch *uint32 `json:"finalized_epoch,omitempty"`
+	// hex, the hash tree root of the state after the latest finalized block (the genesis state before block 0)
+	FinalizedStateRoot string `json:"finalized_state_root"`
+}
+
+type PoolInfo struct {
+	Id uint32 `json:"id"`
+	Size uint32 `json:"size"`
+	// hex, empty until the pool's DKG is done
+	Pk string `json:"pk"`
+	// pending, active, liquidating or liquidated in the node's current epoch
+	Status string `json:"status"`
+	// gwei
+	Stake uint64 `json:"stake"`
+	// not set until scheduled
+	ActivationEpoch *uint32 `json:"activation_epoch,omitempty"`
+	ExitEpoch *uint32 `json:"exit_epoch,omitempty"`
+	LiquidationRequested bool `json:"liquidation_requested"`
+	// hex, empty until the liquidating pool's exit signature is reconstructed
+	ExitSignature string `json:"exit_signature"`
+}
+
+type EpochInfo struct {
+	Number uint32 `json:"number"`
+	// the participant's pool in the epoch
+	PoolId uint32 `json:"pool_id"`
+	HasShare bool `json:"has_share"`
+	PublicShares int `json:"public_shares"`
+	BadSigners []uint32 `json:"bad_signers"`
+	// the pool's reconstructed duty signatures by the duties' signing roots, hex
+	Signatures map[string]string `json:"signatures"`
+	SigVerified bool `json:"sig_verified"`
+	// hex hash tree roots of the epoch (its seed and public shares), of the participant's state after the epoch's
+	// registry updates (empty until the epoch ended) and of the state after the epoch's finalized block (empty
+	// until finalized). Nodes that agree on the epoch have the same roots.
+	Root string `json:"root"`
+	StateRoot string `json:"state_root"`
+	FinalizedStateRoot string `json:"finalized_state_root"`
+}
+
+// a registry request the node sent, the block of its epoch or the next one includes it if it applies
+type RequestInfo struct {
+	Id string `json:"id"`
+	// join, exit, create_pool, deposit or liquidation
+	Type string `json:"type"`
+	ParticipantId uint32 `json:"participant_id"`
+	Epoch uint32 `json:"epoch"`
+	// pool requests only
+	PoolId uint32 `json:"pool_id,omitempty"`
+	Gwei uint64 `json:"gwei,omitempty"`
+}
@@ -0,0 +1,194 @@
+package pool_chain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
+	"sort"
+)
+
+// DKG messages (of genesis pools and of pools activated later) are kept per pool, the participant driving the DKG
+// pulls them by type at every phase.
+// GROUP_PK messages are votes, once a threshold of a pool's members agree on the pool's public key (and public
+// shares) the pool's key is saved to state.
+func (p *PoolChainNode) ReceiveDKGMessage(msg *pb.DKGMessage) {
+	p.dkgLock.Lock()
+	defer p.dkgLock.Unlock()
+
+	err := p.verifyMessageSignature(msg.FromParticipant, DKGSigningRoot(msg), msg.Signature)
+	if err != nil {
+		log.Printf("P %d, dropping DKG message %s: %s", p.FilterId, msg.Id, err.Error())
+		return
+	}
+	if msg.ToParticipant == nil && dkgMessageAddressed(msg.Type) {
+		log.Printf("P %d, dropping DKG message %s: missing recipient", p.FilterId, msg.Id)
+		return
+	}
+
+	members, err := p.dkgPoolMembers(msg.PoolId)
+	if err != nil {
+		log.Printf("DKG message for unknown pool %d: %s", msg.PoolId, err.Error())
+		return
+	}
+	if !containsParticipant(members, msg.FromParticipant.Id) {
+		return
+	}
+
+	if msg.Type == pb.DKGMessageType_GROUP_PK {
+		p.processGroupPKVote(msg, members)
+		return
+	}
+
+	// deals are private, keep (and decrypt) only the ones addressed to us
+	if msg.Type == pb.DKGMessageType_DEAL {
+		if msg.ToParticipant == nil || msg.ToParticipant.Id != p.FilterId {
+			return
+		}
+		decrypted, err := p.decryptDKGDeal(msg)
+		if err != nil {
+			log.Printf("P %d, could not decrypt DKG deal from %d: %s", p.FilterId, msg.FromParticipant.Id, err.Error())
+			return
+		}
+		msg = decrypted
+	}
+
+	if p.DKGMessages[msg.PoolId] == nil {
+		p.DKGMessages[msg.PoolId] = make(map[string]*pb.DKGMessage)
+	}
+	// do not insert duplicates
+	if p.DKGMessages[msg.PoolId][msg.Id] == nil {
+		p.DKGMessages[msg.PoolId][msg.Id] = msg
+	}
+}
+
+// returns all of the pool's DKG messages of the given type
+func (p *PoolChainNode) DKGMessagesByType(poolId shared.PoolId, t pb.DKGMessageType) []*pb.DKGMessage {
+	p.dkgLock.Lock()
+	defer p.dkgLock.Unlock()
+
+	ret := make([]*pb.DKGMessage, 0)
+	for _, msg := range p.DKGMessages[poolId] {
+		if msg.Type == t {
+			ret = append(ret, msg)
+		}
+	}
+	return ret
+}
+
+// true once every genesis pool's public key was agreed on
+func (p *PoolChainNode) GenesisReady() bool {
+	p.dkgLock.Lock()
+	defer p.dkgLock.Unlock()
+
+	for id := shared.PoolId(1) ; id <= p.Config.NumberOfPools ; id++ {
+		pool := p.State.GetPool(id)
+		if pool == nil || pool.Pk == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PoolChainNode) processGroupPKVote(msg *pb.DKGMessage, members []shared.ParticipantId) {
+	if p.State.GetPool(msg.PoolId).Pk != nil {
+		return
+	}
+
+	h := sha256.New()
+	h.Write(msg.GroupPk)
+	for _, s := range msg.PublicShares {
+		h.Write(s)
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+
+	if p.dkgVotes[msg.PoolId] == nil {
+		p.dkgVotes[msg.PoolId] = make(map[string]map[shared.ParticipantId]bool)
+	}
+	if p.dkgVotes[msg.PoolId][key] == nil {
+		p.dkgVotes[msg.PoolId][key] = make(map[shared.ParticipantId]bool)
+	}
+	p.dkgVotes[msg.PoolId][key][msg.FromParticipant.Id] = true
+
+	if len(p.dkgVotes[msg.PoolId][key]) < int(state.PoolThreshold(shared.PoolSize(len(members)))) {
+		return
+	}
+
+	err := p.savePoolKey(msg, members)
+	if err != nil {
+		log.Printf("could not save pool %d key: %s", msg.PoolId, err.Error())
+	}
+}
+
+// the pool's public key and its members' public shares for the pool's first epoch
+func (p *PoolChainNode) savePoolKey(msg *pb.DKGMessage, members []shared.ParticipantId) error {
+	pk := &bls.PublicKey{}
+	err := pk.Deserialize(msg.GroupPk)
+	if err != nil {
+		return err
+	}
+
+	sorted := SortedParticipants(members)
+	if len(msg.PublicShares) != len(sorted) {
+		return fmt.Errorf("expected %d public shares, got %d", len(sorted), len(msg.PublicShares))
+	}
+	pool := p.State.GetPool(msg.PoolId)
+	epoch := p.State.GetEpoch(pool.ActivationEpoch)
+	for i, id := range sorted {
+		publicShare := &bls.G1{}
+		err := publicShare.Deserialize(msg.PublicShares[i])
+		if err != nil {
+			return err
+		}
+		epoch.SetPublicShare(id, publicShare)
+	}
+	err = p.State.SaveEpoch(epoch)
+	if err != nil {
+		return err
+	}
+
+	err = p.State.SetPoolPk(msg.PoolId, shared.PoolSize(len(members)), pk)
+	if err != nil {
+		return err
+	}
+	log.Printf("P %d, pool %d pk (epoch %d): %s", p.FilterId, msg.PoolId, pool.ActivationEpoch, pk.SerializeToHexStr())
+	return nil
+}
+
+// the DKG is run by the pool's members of its activation epoch
+func (p *PoolChainNode) dkgPoolMembers(poolId shared.PoolId) ([]shared.ParticipantId, error) {
+	pool := p.State.GetPool(poolId)
+	if pool == nil || pool.ActivationEpoch == state.FarFutureEpoch {
+		return nil, fmt.Errorf("pool %d not found or not activated", poolId)
+	}
+	pools, err := p.State.GetEpoch(pool.ActivationEpoch).PoolsParticipantIds()
+	if err != nil {
+		return nil, err
+	}
+	members, found := pools[poolId]
+	if !found {
+		return nil, fmt.Errorf("pool %d not found", poolId)
+	}
+	return members, nil
+}
+
+// returns a sorted copy of the ids, the canonical order for per member lists (e.g. public shares)
+func SortedParticipants(ids []shared.ParticipantId) []shared.ParticipantId {
+	ret := make([]shared.ParticipantId, len(ids))
+	copy(ret, ids)
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+func containsParticipant(ids []shared.ParticipantId, id shared.ParticipantId) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package pool_chain
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// shares are encrypted to the recipient's registered encryption key, the message's header is authenticated as
+// additional data so a ciphertext can't be replayed under a different header. Received messages without participants
+// are dropped before decryption (see auth.go).
+
+func ShareAAD(share *pb.ShareDistribution) []byte {
+	return []byte(fmt.Sprintf("share:%s:%d:%d:%d:%d", share.Id, share.GetFromParticipant().GetId(), share.GetToParticipant().GetId(), share.PoolId, share.Epoch))
+}
+
+func DKGDealAAD(msg *pb.DKGMessage) []byte {
+	return []byte(fmt.Sprintf("dkg deal:%s:%d:%d:%d", msg.Id, msg.GetFromParticipant().GetId(), msg.GetToParticipant().GetId(), msg.PoolId))
+}
+
+// returns a copy of the share with the decrypted share
+func (p *PoolChainNode) decryptShare(share *pb.ShareDistribution) (*pb.ShareDistribution, error) {
+	if p.encryptionSk == nil {
+		return nil, fmt.Errorf("no encryption key")
+	}
+
+	plain, err := crypto.Decrypt(p.encryptionSk, share.Share, ShareAAD(share))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := proto.Clone(share).(*pb.ShareDistribution)
+	ret.Share = plain
+	return ret, nil
+}
+
+// returns a copy of the deal with the decrypted share and blinding share
+func (p *PoolChainNode) decryptDKGDeal(msg *pb.DKGMessage) (*pb.DKGMessage, error) {
+	if p.encryptionSk == nil {
+		return nil, fmt.Errorf("no encryption key")
+	}
+
+	share, err := crypto.Decrypt(p.encryptionSk, msg.Share, DKGDealAAD(msg))
+	if err != nil {
+		return nil, err
+	}
+	blinding, err := crypto.Decrypt(p.encryptionSk, msg.BlindingShare, DKGDealAAD(msg))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := proto.Clone(msg).(*pb.DKGMessage)
+	ret.Share = share
+	ret.BlindingShare = blinding
+	return ret, nil
+}
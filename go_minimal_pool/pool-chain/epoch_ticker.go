@@ -2,12 +2,14 @@ package pool_chain
 
 import (
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"sync/atomic"
 	"time"
 )
 
 type EpochTicker struct {
 	ticker *time.Ticker
 	interval time.Duration
+	// the next epoch to tick, read from other goroutines
 	number shared.EpochNumber
 	tickerChan chan shared.EpochNumber
 	done chan bool
@@ -26,30 +28,54 @@ func (t *EpochTicker) Start () {
 	t.ticker = time.NewTicker(t.interval)
 	go func() {
 		// send first epoch now
-		t.tickerChan <- t.number
-		t.number += 1
+		t.tick()
 		for {
 			select {
 			case <-t.done:
 				return
 			case _ = <-t.ticker.C:
-				t.tickerChan <- t.number
-				t.number += 1
+				t.tick()
 			}
 		}
 	}()
 
 }
 
+// epoch n starts at genesis + n * interval so independent nodes (processes) tick together. If genesis already
+// passed the ticker starts at the next epoch.
+func (t *EpochTicker) StartAt(genesis time.Time) {
+	go func() {
+		elapsed := time.Since(genesis)
+		if elapsed > 0 {
+			atomic.StoreUint32(&t.number, shared.EpochNumber(elapsed / t.interval) + 1)
+		}
+		<- time.After(time.Until(genesis.Add(time.Duration(atomic.LoadUint32(&t.number)) * t.interval)))
+		t.Start()
+	}()
+}
+
 func (t *EpochTicker) Stop () {
+	if t.ticker == nil {
+		return
+	}
 	t.ticker.Stop()
 	t.done <- true
 }
 
+func (t *EpochTicker) tick() {
+	t.tickerChan <- atomic.LoadUint32(&t.number)
+	atomic.AddUint32(&t.number, 1)
+}
+
 func (t *EpochTicker) C() <-chan shared.EpochNumber  {
 	return t.tickerChan
 }
 
+// the last epoch ticked (number is the next one), 0 before the first tick
 func (t *EpochTicker) CurrentEpochNumber() shared.EpochNumber {
-	return t.number
+	number := atomic.LoadUint32(&t.number)
+	if number == 0 {
+		return 0
+	}
+	return number - 1
 }
\ No newline at end of file
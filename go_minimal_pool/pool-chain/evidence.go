@@ -0,0 +1,220 @@
+package pool_chain
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
+	"sort"
+)
+
+/**
+	Slashable misbehaviour (see state/slashing.go) is detected as authenticated shares and signatures arrive:
+		a share addressed to us that doesn't decrypt or doesn't match its commitments is proven bad with our
+			decryption proof, a malformed ciphertext (crypto.CheckCiphertext) is bad for every recipient and needs
+			none. Commitments match if they're the target pool's threshold of coefficients and the share is on them.
+		a sender's share with other commitments than its first share of the epoch's pool is a double share
+		a signature of a duty the sender already signed differently is a double signature, a refusal to sign
+			(IsRefusal) isn't a signature
+	The evidence is kept until a finalized block includes it, the epoch's proposer includes the pending evidence
+	that verifies against its state.
+ */
+
+// verifies the evidence is signed by the offender's identity key and proves the misbehaviour, s is the state the
+// evidence's block is applied to
+func VerifyEvidence(s *state.State, e *state.Evidence) error {
+	err := e.Validate()
+	if err != nil {
+		return err
+	}
+	offender := s.GetParticipant(e.Offender())
+	if offender == nil || offender.IdentityPk == nil {
+		return fmt.Errorf("unknown offender %d", e.Offender())
+	}
+	if offender.Slashed {
+		return fmt.Errorf("participant %d already slashed", offender.Id)
+	}
+	for _, share := range e.Shares {
+		err = verifyIdentitySignature(offender.IdentityPk, ShareSigningRoot(share), share.Signature)
+		if err != nil {
+			return fmt.Errorf("share: %s", err.Error())
+		}
+	}
+	for _, sig := range e.Signatures {
+		err = verifyIdentitySignature(offender.IdentityPk, SignatureSigningRoot(sig), sig.Signature)
+		if err != nil {
+			return fmt.Errorf("signature: %s", err.Error())
+		}
+	}
+
+	switch e.Type {
+	case state.EvidenceBadShare:
+		return verifyBadShare(s, e.Shares[0], e.DecryptionProof)
+	case state.EvidenceDoubleShare:
+		if sameCommitments(e.Shares[0].Commitments, e.Shares[1].Commitments) {
+			return fmt.Errorf("shares have the same commitments")
+		}
+	case state.EvidenceDoubleSignature:
+		if bytes.Equal(e.Signatures[0].Sig, e.Signatures[1].Sig) {
+			return fmt.Errorf("signatures are the same")
+		}
+	}
+	return nil
+}
+
+// the recipient's proof opens the share, it's bad if it doesn't decrypt or doesn't match its commitments. A
+// malformed ciphertext is bad without a proof.
+func verifyBadShare(s *state.State, share *pb.ShareDistribution, proof []byte) error {
+	recipient := s.GetParticipant(share.ToParticipant.Id)
+	if recipient == nil || recipient.EncryptionPk == nil {
+		return fmt.Errorf("unknown recipient %d", share.ToParticipant.Id)
+	}
+	threshold, err := shareThreshold(s, share)
+	if err != nil {
+		return err
+	}
+	if crypto.CheckCiphertext(share.Share) != nil {
+		return nil
+	}
+	err = crypto.VerifyDecryptionProof(recipient.EncryptionPk, share.Share, proof)
+	if err != nil {
+		return err
+	}
+	plain, err := crypto.DecryptWithProof(recipient.EncryptionPk, share.Share, ShareAAD(share), proof)
+	if err != nil {
+		return nil
+	}
+	decrypted := &pb.ShareDistribution{ToParticipant: share.ToParticipant, Share: plain, Commitments: share.Commitments}
+	if shareMatchesCommitments(decrypted, threshold) {
+		return fmt.Errorf("share matches its commitments")
+	}
+	return nil
+}
+
+// the threshold of the pool a redistribution share is sent to, the share's pool in the next epoch
+func shareThreshold(s *state.State, share *pb.ShareDistribution) (uint32, error) {
+	pools, err := s.GetEpoch(share.Epoch + 1).PoolsParticipantIds()
+	if err != nil {
+		return 0, err
+	}
+	members, found := pools[share.PoolId]
+	if !found {
+		return 0, fmt.Errorf("pool %d isn't redistributed in epoch %d", share.PoolId, share.Epoch)
+	}
+	return state.PoolThreshold(shared.PoolSize(len(members))), nil
+}
+
+// the decrypted share is the sender's committed polynomial, of degree threshold - 1, evaluated at the recipient's id
+func shareMatchesCommitments(decrypted *pb.ShareDistribution, threshold uint32) bool {
+	commitments, err := crypto.DeserializeCommitments(decrypted.Commitments)
+	if err != nil || len(commitments) != int(threshold) {
+		return false
+	}
+	point := &bls.Fr{}
+	err = point.Deserialize(decrypted.Share)
+	if err != nil {
+		return false
+	}
+	valid, err := crypto.VerifyShareWithCommitments(commitments, decrypted.ToParticipant.Id, point)
+	return err == nil && valid
+}
+
+func sameCommitments(a [][]byte, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// callers hold sharesLock
+func (p *PoolChainNode) checkDoubleShare(share *pb.ShareDistribution) {
+	if p.firstShares[share.Epoch] == nil {
+		p.firstShares[share.Epoch] = make(map[shared.ParticipantId]*pb.ShareDistribution)
+	}
+	first := p.firstShares[share.Epoch][share.FromParticipant.Id]
+	if first == nil {
+		p.firstShares[share.Epoch][share.FromParticipant.Id] = share
+		return
+	}
+	if first.PoolId == share.PoolId && !sameCommitments(first.Commitments, share.Commitments) {
+		p.addEvidence(&state.Evidence{Type: state.EvidenceDoubleShare, Shares: []*pb.ShareDistribution{first, share}})
+	}
+}
+
+// callers hold sharesLock
+func (p *PoolChainNode) reportBadShare(share *pb.ShareDistribution) {
+	if crypto.CheckCiphertext(share.Share) != nil {
+		p.addEvidence(&state.Evidence{Type: state.EvidenceBadShare, Shares: []*pb.ShareDistribution{share}})
+		return
+	}
+	if p.encryptionSk == nil {
+		return
+	}
+	proof, err := crypto.ProveDecryption(p.encryptionSk, share.Share)
+	if err != nil {
+		log.Printf("P %d, could not prove share from %d bad: %s", p.FilterId, share.FromParticipant.Id, err.Error())
+		return
+	}
+	p.addEvidence(&state.Evidence{Type: state.EvidenceBadShare, Shares: []*pb.ShareDistribution{share}, DecryptionProof: proof})
+}
+
+// callers hold sigsLock
+func (p *PoolChainNode) checkDoubleSignature(sig *pb.SignatureDistribution) {
+	for _, v := range p.SigsPerEpoch[sig.Epoch] {
+		if v.FromParticipant.Id != sig.FromParticipant.Id || v.PoolId != sig.PoolId || !bytes.Equal(v.SigningRoot, sig.SigningRoot) {
+			continue
+		}
+		if IsRefusal(v) || IsRefusal(sig) {
+			continue
+		}
+		if !bytes.Equal(v.Sig, sig.Sig) {
+			p.addEvidence(&state.Evidence{Type: state.EvidenceDoubleSignature, Signatures: []*pb.SignatureDistribution{v, sig}})
+			return
+		}
+	}
+}
+
+func (p *PoolChainNode) addEvidence(e *state.Evidence) {
+	p.evidenceLock.Lock()
+	defer p.evidenceLock.Unlock()
+
+	if p.evidence == nil {
+		p.evidence = make(map[shared.ParticipantId]*state.Evidence)
+	}
+	if p.evidence[e.Offender()] != nil {
+		return
+	}
+	log.Printf("P %d, %s evidence against %d in epoch %d", p.FilterId, e.Type.String(), e.Offender(), e.Epoch())
+	p.evidence[e.Offender()] = e
+}
+
+// the pending evidence that verifies against s, sorted by offender. Evidence of offenders slashed in s is dropped.
+func (p *PoolChainNode) PendingEvidence(s *state.State) []*state.Evidence {
+	p.evidenceLock.Lock()
+	defer p.evidenceLock.Unlock()
+
+	ret := make([]*state.Evidence, 0)
+	for id, e := range p.evidence {
+		if participant := s.GetParticipant(id); participant != nil && participant.Slashed {
+			delete(p.evidence, id)
+			continue
+		}
+		err := VerifyEvidence(s, e)
+		if err != nil {
+			log.Printf("P %d, not including %s evidence against %d: %s", p.FilterId, e.Type.String(), id, err.Error())
+			continue
+		}
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Offender() < ret[j].Offender() })
+	return ret
+}
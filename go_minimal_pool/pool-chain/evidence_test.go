@@ -0,0 +1,196 @@
+package pool_chain
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDoubleSignatureEvidence(t *testing.T) {
+	crypto.InitBLS()
+
+	node := NewTestChainNode()
+	sk1 := registerIdentity(t, node, 1)
+	registerIdentity(t, node, 2)
+
+	newSig := func(id string, sig byte, root byte) *pb.SignatureDistribution {
+		ret := &pb.SignatureDistribution{
+			Id:              id,
+			FromParticipant: &pb.Participant{Id: 1},
+			Sig:             []byte{sig},
+			PoolId:          1,
+			Epoch:           1,
+			SigningRoot:     []byte{root},
+		}
+		ret.Signature = sk1.SignByte(SignatureSigningRoot(ret)).Serialize()
+		return ret
+	}
+
+	// the same sig relayed again or another duty's
+	node.ReceiveSignature(newSig("a", 1, 1))
+	node.ReceiveSignature(newSig("b", 1, 1))
+	node.ReceiveSignature(newSig("c", 2, 2))
+	require.Len(t, node.PendingEvidence(node.State), 0)
+	// a refusal of a signed duty isn't a double signature
+	refusal := newSig("r", 0, 1)
+	refusal.Sig = nil
+	refusal.Signature = sk1.SignByte(SignatureSigningRoot(refusal)).Serialize()
+	node.ReceiveSignature(refusal)
+	require.Len(t, node.PendingEvidence(node.State), 0)
+	require.Error(t, VerifyEvidence(node.State, &state.Evidence{Type: state.EvidenceDoubleSignature, Signatures: []*pb.SignatureDistribution{newSig("a", 1, 1), refusal}}))
+
+	node.ReceiveSignature(newSig("d", 2, 1))
+	evidence := node.PendingEvidence(node.State)
+	require.Len(t, evidence, 1)
+	require.Equal(t, state.EvidenceDoubleSignature, evidence[0].Type)
+	require.EqualValues(t, 1, evidence[0].Offender())
+	require.NoError(t, VerifyEvidence(node.State, evidence[0]))
+
+	// not conflicting or not signed by the offender
+	same := &state.Evidence{Type: state.EvidenceDoubleSignature, Signatures: []*pb.SignatureDistribution{newSig("a", 1, 1), newSig("b", 1, 1)}}
+	require.Error(t, VerifyEvidence(node.State, same))
+	forged := &state.Evidence{Type: state.EvidenceDoubleSignature, Signatures: []*pb.SignatureDistribution{newSig("a", 1, 1), newSig("d", 2, 1)}}
+	forged.Signatures[1].Sig = []byte{3}
+	require.Error(t, VerifyEvidence(node.State, forged))
+
+	// once slashed it's no longer pending
+	require.NoError(t, node.State.ProcessEvidence(1, evidence))
+	require.Error(t, VerifyEvidence(node.State, evidence[0]))
+	require.Len(t, node.PendingEvidence(node.State), 0)
+}
+
+func TestShareEvidence(t *testing.T) {
+	crypto.InitBLS()
+
+	node := NewTestChainNode()
+	node.FilterId = 2
+	sk1 := registerIdentity(t, node, 1)
+	encryptionSk, encryptionPk := crypto.NewEncryptionKey()
+	identitySk := &bls.SecretKey{}
+	identitySk.SetByCSPRNG()
+	require.NoError(t, node.State.SaveParticipant(state.NewParticipant(2, encryptionPk, identitySk.GetPublicKey())))
+	node.SetEncryptionKey(encryptionSk)
+	registerIdentity(t, node, 3)
+
+	secret := &bls.Fr{}
+	secret.SetByCSPRNG()
+	distro, err := crypto.NewRedistribuition(2, secret)
+	require.NoError(t, err)
+	shares, err := distro.GenerateShares([]uint32{2, 3})
+	require.NoError(t, err)
+	commitments := crypto.SerializeCommitments(distro.Commitments())
+
+	newShare := func(id string, to uint32, point *bls.Fr, commitments [][]byte) *pb.ShareDistribution {
+		ret := &pb.ShareDistribution{
+			Id:              id,
+			FromParticipant: &pb.Participant{Id: 1},
+			ToParticipant:   &pb.Participant{Id: to},
+			Commitments:     commitments,
+			PoolId:          1,
+			Epoch:           1,
+		}
+		recipient := node.State.GetParticipant(to)
+		ret.Share, err = crypto.Encrypt(recipient.EncryptionPk, point.Serialize(), ShareAAD(ret))
+		require.NoError(t, err)
+		ret.Signature = sk1.SignByte(ShareSigningRoot(ret)).Serialize()
+		return ret
+	}
+
+	// valid shares of the same polynomial
+	valid := newShare("a", 2, shares[2], commitments)
+	node.ReceiveShare(valid)
+	node.ReceiveShare(newShare("b", 3, shares[3], commitments))
+	require.Len(t, node.PendingEvidence(node.State), 0)
+
+	// a valid share isn't bad
+	proof, err := crypto.ProveDecryption(encryptionSk, valid.Share)
+	require.NoError(t, err)
+	notBad := &state.Evidence{Type: state.EvidenceBadShare, Shares: []*pb.ShareDistribution{valid}, DecryptionProof: proof}
+	require.Error(t, VerifyEvidence(node.State, notBad))
+
+	// a share to us that doesn't match its commitments
+	bad := newShare("c", 2, shares[3], commitments)
+	node.ReceiveShare(bad)
+	evidence := node.PendingEvidence(node.State)
+	require.Len(t, evidence, 1)
+	require.Equal(t, state.EvidenceBadShare, evidence[0].Type)
+	require.NoError(t, VerifyEvidence(node.State, evidence[0]))
+	// only the recipient can prove what it decrypts to
+	otherSk, _ := crypto.NewEncryptionKey()
+	proof, err = crypto.ProveDecryption(otherSk, bad.Share)
+	require.NoError(t, err)
+	forged := &state.Evidence{Type: state.EvidenceBadShare, Shares: []*pb.ShareDistribution{bad}, DecryptionProof: proof}
+	require.Error(t, VerifyEvidence(node.State, forged))
+
+	// another polynomial for the same pool, the first evidence against the offender is kept
+	other, err := crypto.NewRedistribuition(2, secret)
+	require.NoError(t, err)
+	double := newShare("d", 3, shares[3], crypto.SerializeCommitments(other.Commitments()))
+	node.ReceiveShare(double)
+	require.Equal(t, state.EvidenceBadShare, node.PendingEvidence(node.State)[0].Type)
+	node.evidence = nil
+	node.ReceiveShare(newShare("e", 3, shares[3], crypto.SerializeCommitments(other.Commitments())))
+	require.Equal(t, state.EvidenceDoubleShare, node.PendingEvidence(node.State)[0].Type)
+	e := &state.Evidence{Type: state.EvidenceDoubleShare, Shares: []*pb.ShareDistribution{valid, double}}
+	require.NoError(t, VerifyEvidence(node.State, e))
+	e = &state.Evidence{Type: state.EvidenceDoubleShare, Shares: []*pb.ShareDistribution{valid, newShare("f", 3, shares[3], commitments)}}
+	require.Error(t, VerifyEvidence(node.State, e))
+
+	// a share on a polynomial of another degree than the target pool's threshold
+	node.evidence = nil
+	node.firstShares = make(map[uint32]map[uint32]*pb.ShareDistribution)
+	higher, err := crypto.NewRedistribuition(3, secret)
+	require.NoError(t, err)
+	higherShares, err := higher.GenerateShares([]uint32{2, 3})
+	require.NoError(t, err)
+	node.ReceiveShare(newShare("g", 2, higherShares[2], crypto.SerializeCommitments(higher.Commitments())))
+	evidence = node.PendingEvidence(node.State)
+	require.Len(t, evidence, 1)
+	require.Equal(t, state.EvidenceBadShare, evidence[0].Type)
+	require.NoError(t, VerifyEvidence(node.State, evidence[0]))
+}
+
+// a signed ciphertext no recipient can decrypt is evidence without a decryption proof, reported by any node
+func TestMalformedShareEvidence(t *testing.T) {
+	crypto.InitBLS()
+
+	node := NewTestChainNode()
+	node.FilterId = 3
+	sk1 := registerIdentity(t, node, 1)
+	registerIdentity(t, node, 2)
+	registerIdentity(t, node, 3)
+
+	malformed := &pb.ShareDistribution{
+		Id:              "a",
+		FromParticipant: &pb.Participant{Id: 1},
+		ToParticipant:   &pb.Participant{Id: 2},
+		Share:           make([]byte, 100),
+		PoolId:          1,
+		Epoch:           1,
+	}
+	malformed.Share[0] = 0xff
+	malformed.Signature = sk1.SignByte(ShareSigningRoot(malformed)).Serialize()
+	node.ReceiveShare(malformed)
+	evidence := node.PendingEvidence(node.State)
+	require.Len(t, evidence, 1)
+	require.Equal(t, state.EvidenceBadShare, evidence[0].Type)
+	require.Len(t, evidence[0].DecryptionProof, 0)
+	require.NoError(t, VerifyEvidence(node.State, evidence[0]))
+
+	// a well formed ciphertext needs the recipient's proof
+	wellFormed := &pb.ShareDistribution{
+		Id:              "b",
+		FromParticipant: &pb.Participant{Id: 1},
+		ToParticipant:   &pb.Participant{Id: 2},
+		PoolId:          1,
+		Epoch:           1,
+	}
+	var err error
+	wellFormed.Share, err = crypto.Encrypt(node.State.GetParticipant(2).EncryptionPk, []byte{1}, ShareAAD(wellFormed))
+	require.NoError(t, err)
+	wellFormed.Signature = sk1.SignByte(ShareSigningRoot(wellFormed)).Serialize()
+	require.Error(t, VerifyEvidence(node.State, &state.Evidence{Type: state.EvidenceBadShare, Shares: []*pb.ShareDistribution{wellFormed}}))
+}
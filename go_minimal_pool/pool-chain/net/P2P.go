@@ -7,6 +7,9 @@ import (
 type P2PReceiver interface {
 	ReceiveShare(share *pb.ShareDistribution)
 	ReceiveSignature(sig *pb.SignatureDistribution)
+	ReceiveDKGMessage(msg *pb.DKGMessage)
+	ReceiveConsensusMessage(msg *pb.ConsensusMessage)
+	ReceiveRegistryRequest(req *pb.RegistryRequest)
 }
 
 type P2P interface {
@@ -17,8 +20,21 @@ type P2P interface {
 	RemovePeer(peer *Peer) error
 	BroadcastShare(share *pb.ShareDistribution) error
 	BroadcastSignature(sig *pb.SignatureDistribution) error
+	BroadcastDKGMessage(msg *pb.DKGMessage) error
+	BroadcastConsensusMessage(msg *pb.ConsensusMessage) error
+	BroadcastRegistryRequest(req *pb.RegistryRequest) error
 }
 
+// a P2P that can send to a single peer, needed by layers that pick the peers themselves (e.g. gossip)
+type Transport interface {
+	P2P
+	Peers() []*Peer
+	SendShare(peer *Peer, share *pb.ShareDistribution) error
+	SendSignature(peer *Peer, sig *pb.SignatureDistribution) error
+	SendDKGMessage(peer *Peer, msg *pb.DKGMessage) error
+	SendConsensusMessage(peer *Peer, msg *pb.ConsensusMessage) error
+	SendRegistryRequest(peer *Peer, req *pb.RegistryRequest) error
+}
 
 func BiDirectionalConnection(p1 P2P, p2 P2P) {
 	p1.AddPeer(p2.OwnPeer())
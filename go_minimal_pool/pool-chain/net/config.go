@@ -2,19 +2,39 @@ package net
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 type NetworkConfig struct {
+	// minimum number of participants in a pool, active participants are distributed evenly between the pools and
+	// every pool's threshold is derived from its size (see state.PoolThreshold)
 	PoolSize shared.PoolSize
 	NumberOfPools shared.PoolId
-	PoolThreshold shared.PoolSize
+	GenesisParticipants shared.ParticipantId
 
 	SeedShuffleRoudnCount uint8
 
 	EpochSpanSec time.Duration
-	EpochTestMessage []byte
+	DKGPhaseSpan time.Duration
+	// pools sign their validator duties for the network with this fork version and genesis validators root
+	ForkVersion eth2.Version
+	GenesisValidatorsRoot eth2.Root
+
+	// gwei credited to a participant's balance for every valid contribution (redistributed share or duty partial
+	// signatures) to an epoch and debited for every missing or invalid one, see state.Contribution
+	ContributionReward uint64
+	MissingContributionPenalty uint64
+	InvalidContributionPenalty uint64
+	// gwei debited from a slashed participant's balance, see state.Evidence
+	SlashingPenalty uint64
 
 	GenesisSeed [32]byte // used for random beacon
 }
@@ -24,26 +44,182 @@ func NewTestNetworkConfig() *NetworkConfig {
 	var seed [32]byte
 	copy(seed[:], _seed)
 
-
-	_testMsg, _ := hex.DecodeString("292ea14188b703cbd3efde48f0952b15c4cc6c254f221e5669709888ccfbf8bf") // sha256 of 'test epoch msg'
+	_gvr, _ := hex.DecodeString("4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95") // mainnet's
+	var gvr eth2.Root
+	copy(gvr[:], _gvr)
 
 	return &NetworkConfig{
 		PoolSize:      3,
-		PoolThreshold: 3,
 		NumberOfPools: 2,
+		GenesisParticipants: 6,
 		SeedShuffleRoudnCount: 10,
 		EpochSpanSec:  time.Second * 8,
-		EpochTestMessage: _testMsg,
+		DKGPhaseSpan:  time.Millisecond * 500,
+		ForkVersion:   eth2.Version{0x00, 0x00, 0x00, 0x00},
+		GenesisValidatorsRoot: gvr,
+		ContributionReward: 1000,
+		MissingContributionPenalty: 1000,
+		InvalidContributionPenalty: 16000,
+		SlashingPenalty: 1000000,
 		GenesisSeed:   seed,
 	}
 }
 
-func (c *NetworkConfig) TotalNumberOfParticipants() shared.ParticipantId {
-	return shared.ParticipantId(int(c.NumberOfPools) * int(c.PoolSize))
+/**
+	Network config file, YAML or JSON with the same keys:
+		participants: 6
+		pool_size: 3
+		shuffle_round_count: 10
+		epoch_span: 8s
+		dkg_phase_span: 500ms
+		fork_version: <4 bytes hex>
+		genesis_validators_root: <32 bytes hex>
+		genesis_seed: <32 bytes hex>
+		contribution_reward: 1000
+		missing_contribution_penalty: 1000
+		invalid_contribution_penalty: 16000
+		slashing_penalty: 1000000
+	participants is the number of genesis participants, the number of pools is participants / pool_size (rounded
+	down) and pool_size the minimum size of a pool. The rewards and penalties are in gwei, zero if missing.
+ */
+type networkConfigFile struct {
+	Participants uint32 `json:"participants" yaml:"participants"`
+	PoolSize uint32 `json:"pool_size" yaml:"pool_size"`
+	ShuffleRoundCount uint8 `json:"shuffle_round_count" yaml:"shuffle_round_count"`
+	EpochSpan string `json:"epoch_span" yaml:"epoch_span"`
+	DKGPhaseSpan string `json:"dkg_phase_span" yaml:"dkg_phase_span"`
+	ForkVersion string `json:"fork_version" yaml:"fork_version"`
+	GenesisValidatorsRoot string `json:"genesis_validators_root" yaml:"genesis_validators_root"`
+	GenesisSeed string `json:"genesis_seed" yaml:"genesis_seed"`
+	ContributionReward uint64 `json:"contribution_reward" yaml:"contribution_reward"`
+	MissingContributionPenalty uint64 `json:"missing_contribution_penalty" yaml:"missing_contribution_penalty"`
+	InvalidContributionPenalty uint64 `json:"invalid_contribution_penalty" yaml:"invalid_contribution_penalty"`
+	SlashingPenalty uint64 `json:"slashing_penalty" yaml:"slashing_penalty"`
+}
+
+// loads a .yaml/.yml or .json network config file
+func LoadNetworkConfig(path string) (*NetworkConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return NetworkConfigFromYAML(data)
+	case ".json":
+		return NetworkConfigFromJSON(data)
+	default:
+		return nil, fmt.Errorf("unknown network config format %s", path)
+	}
+}
+
+func NetworkConfigFromYAML(data []byte) (*NetworkConfig, error) {
+	file := &networkConfigFile{}
+	err := yaml.Unmarshal(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse network config: %s", err.Error())
+	}
+	return file.networkConfig()
+}
+
+func NetworkConfigFromJSON(data []byte) (*NetworkConfig, error) {
+	file := &networkConfigFile{}
+	err := json.Unmarshal(data, file)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse network config: %s", err.Error())
+	}
+	return file.networkConfig()
+}
+
+func (f *networkConfigFile) networkConfig() (*NetworkConfig, error) {
+	if f.PoolSize == 0 {
+		return nil, fmt.Errorf("pool size must be positive")
+	}
+
+	seed, err := hex.DecodeString(f.GenesisSeed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis seed: %s", err.Error())
+	}
+	if len(seed) != 32 {
+		return nil, fmt.Errorf("genesis seed should be 32 bytes (64 hex chars), got %d bytes", len(seed))
+	}
+	forkVersion, err := hex.DecodeString(f.ForkVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fork version: %s", err.Error())
+	}
+	if len(forkVersion) != 4 {
+		return nil, fmt.Errorf("fork version should be 4 bytes (8 hex chars), got %d bytes", len(forkVersion))
+	}
+	gvr, err := hex.DecodeString(f.GenesisValidatorsRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis validators root: %s", err.Error())
+	}
+	if len(gvr) != 32 {
+		return nil, fmt.Errorf("genesis validators root should be 32 bytes (64 hex chars), got %d bytes", len(gvr))
+	}
+	epochSpan, err := time.ParseDuration(f.EpochSpan)
+	if err != nil {
+		return nil, fmt.Errorf("invalid epoch span: %s", err.Error())
+	}
+	dkgPhaseSpan, err := time.ParseDuration(f.DKGPhaseSpan)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dkg phase span: %s", err.Error())
+	}
+
+	ret := &NetworkConfig{
+		PoolSize:              f.PoolSize,
+		NumberOfPools:         f.Participants / f.PoolSize,
+		GenesisParticipants:   f.Participants,
+		SeedShuffleRoudnCount: f.ShuffleRoundCount,
+		EpochSpanSec:          epochSpan,
+		DKGPhaseSpan:          dkgPhaseSpan,
+		ContributionReward:    f.ContributionReward,
+		MissingContributionPenalty: f.MissingContributionPenalty,
+		InvalidContributionPenalty: f.InvalidContributionPenalty,
+		SlashingPenalty: f.SlashingPenalty,
+	}
+	copy(ret.GenesisSeed[:], seed)
+	copy(ret.ForkVersion[:], forkVersion)
+	copy(ret.GenesisValidatorsRoot[:], gvr)
+
+	err = ret.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (c *NetworkConfig) Validate() error {
+	if c.PoolSize == 0 {
+		return fmt.Errorf("pool size must be positive")
+	}
+	if c.NumberOfPools == 0 {
+		return fmt.Errorf("number of pools must be positive")
+	}
+	if int(c.GenesisParticipants) < int(c.NumberOfPools) * int(c.PoolSize) {
+		return fmt.Errorf("%d genesis participants can't fill %d pools of %d", c.GenesisParticipants, c.NumberOfPools, c.PoolSize)
+	}
+	if c.SeedShuffleRoudnCount == 0 {
+		return fmt.Errorf("shuffle round count must be positive")
+	}
+	if c.EpochSpanSec <= 0 || c.DKGPhaseSpan <= 0 {
+		return fmt.Errorf("epoch span and dkg phase span must be positive")
+	}
+	return nil
+}
+
+// the fork pools sign their duties with
+func (c *NetworkConfig) Fork() *eth2.Fork {
+	return &eth2.Fork{
+		Version:               c.ForkVersion,
+		GenesisValidatorsRoot: c.GenesisValidatorsRoot,
+	}
 }
 
+// ids of the genesis participants, others join through the state's registry
 func (c *NetworkConfig) ParticipantIndexesList() []shared.ParticipantId {
-	s := make([]shared.ParticipantId, c.TotalNumberOfParticipants())
+	s := make([]shared.ParticipantId, c.GenesisParticipants)
 	start := shared.ParticipantId(1)
 	for i := range s {
 		s[i] = start
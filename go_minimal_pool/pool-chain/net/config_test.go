@@ -0,0 +1,120 @@
+package net
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAMLConfig = `
+participants: 12
+pool_size: 4
+shuffle_round_count: 10
+epoch_span: 4s
+dkg_phase_span: 200ms
+fork_version: "00000001"
+genesis_validators_root: 4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95
+genesis_seed: b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a90
+contribution_reward: 1000
+missing_contribution_penalty: 2000
+invalid_contribution_penalty: 16000
+slashing_penalty: 1000000
+`
+
+const testJSONConfig = `{
+	"participants": 12,
+	"pool_size": 4,
+	"shuffle_round_count": 10,
+	"epoch_span": "4s",
+	"dkg_phase_span": "200ms",
+	"fork_version": "00000001",
+	"genesis_validators_root": "4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95",
+	"genesis_seed": "b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a90",
+	"contribution_reward": 1000,
+	"missing_contribution_penalty": 2000,
+	"invalid_contribution_penalty": 16000,
+	"slashing_penalty": 1000000
+}`
+
+func TestLoadNetworkConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, data := range map[string]string{"network.yaml": testYAMLConfig, "network.json": testJSONConfig} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+
+		config, err := LoadNetworkConfig(path)
+		require.NoError(t, err, name)
+		require.EqualValues(t, 4, config.PoolSize)
+		require.EqualValues(t, 3, config.NumberOfPools)
+		require.EqualValues(t, 12, config.GenesisParticipants)
+		require.EqualValues(t, 10, config.SeedShuffleRoudnCount)
+		require.Equal(t, time.Second * 4, config.EpochSpanSec)
+		require.Equal(t, time.Millisecond * 200, config.DKGPhaseSpan)
+		require.Equal(t, eth2.Version{0, 0, 0, 1}, config.ForkVersion)
+		require.EqualValues(t, 0x4b, config.GenesisValidatorsRoot[0])
+		require.EqualValues(t, 0x95, config.GenesisValidatorsRoot[31])
+		require.EqualValues(t, 0xb5, config.GenesisSeed[0])
+		require.EqualValues(t, 0x90, config.GenesisSeed[31])
+		require.EqualValues(t, 1000, config.ContributionReward)
+		require.EqualValues(t, 2000, config.MissingContributionPenalty)
+		require.EqualValues(t, 16000, config.InvalidContributionPenalty)
+		require.EqualValues(t, 1000000, config.SlashingPenalty)
+		require.Len(t, config.ParticipantIndexesList(), 12)
+	}
+
+	_, err = LoadNetworkConfig(filepath.Join(dir, "network.toml"))
+	require.Error(t, err)
+}
+
+func TestNetworkConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		old string
+		new string
+	}{
+		{"less participants than pool size", "participants: 12", "participants: 3"},
+		{"no participants", "participants: 12", "participants: 0"},
+		{"zero pool size", "pool_size: 4", "pool_size: 0"},
+		{"short seed", "genesis_seed: b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a90", "genesis_seed: b581262ce281d1e9"},
+		{"odd seed length", "genesis_seed: b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a90", "genesis_seed: b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a9"},
+		{"invalid seed", "genesis_seed: b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a90", "genesis_seed: x581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a90"},
+		{"invalid epoch span", "epoch_span: 4s", "epoch_span: 4"},
+		{"negative epoch span", "epoch_span: 4s", "epoch_span: -4s"},
+		{"no shuffle rounds", "shuffle_round_count: 10", "shuffle_round_count: 0"},
+		{"long fork version", `fork_version: "00000001"`, `fork_version: "0000000001"`},
+		{"no genesis validators root", "genesis_validators_root: 4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95", ""},
+	}
+
+	_, err := NetworkConfigFromYAML([]byte(testYAMLConfig))
+	require.NoError(t, err)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := strings.Replace(testYAMLConfig, test.old, test.new, 1)
+			require.NotEqual(t, testYAMLConfig, data)
+			_, err := NetworkConfigFromYAML([]byte(data))
+			require.Error(t, err)
+		})
+	}
+
+	require.NoError(t, NewTestNetworkConfig().Validate())
+}
+
+func TestUnevenNetworkConfig(t *testing.T) {
+	// 14 participants, 3 pools of at least 4
+	config, err := NetworkConfigFromYAML([]byte(strings.Replace(testYAMLConfig, "participants: 12", "participants: 14", 1)))
+	require.NoError(t, err)
+	require.EqualValues(t, 3, config.NumberOfPools)
+	require.EqualValues(t, 4, config.PoolSize)
+	require.Len(t, config.ParticipantIndexesList(), 14)
+
+	config.GenesisParticipants = 11
+	require.Error(t, config.Validate())
+}
@@ -0,0 +1,241 @@
+package gossip
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/golang/protobuf/proto"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/**
+	Gossip layer on top of a transport, nodes that are not directly connected receive each other's messages
+	through relays.
+	A broadcast message is delivered locally and sent to at most Fanout random peers with Ttl hops. Every node
+	delivers a message (by Id) only the first time it sees it and relays it, with one hop less, to Fanout random
+	peers of its own until no hops are left. A node relays a message again only if it arrives with more hops than
+	before.
+ */
+
+type Config struct {
+	Fanout int
+	Ttl uint32
+	// max number of message ids remembered, the oldest are forgotten first
+	SeenCacheSize int
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Fanout:        4,
+		Ttl:           6,
+		SeenCacheSize: 10000,
+	}
+}
+
+type GossipP2P struct {
+	transport net.Transport
+	config *Config
+	receiver net.P2PReceiver
+
+	// message id -> the highest ttl it was seen with
+	seen map[string]uint32
+	// message ids in the order they were seen, for eviction
+	seenOrder []string
+	seenLock sync.Mutex
+
+	random *rand.Rand
+	randomLock sync.Mutex
+}
+
+func NewGossipP2P(transport net.Transport, config *Config) *GossipP2P {
+	ret := &GossipP2P{
+		transport: transport,
+		config: config,
+		seen: make(map[string]uint32),
+		seenOrder: make([]string, 0),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	transport.RegisterReceiver(ret)
+	return ret
+}
+
+func (g *GossipP2P) RegisterReceiver(r net.P2PReceiver) {
+	g.receiver = r
+}
+
+func (g *GossipP2P) OwnPeer() *net.Peer {
+	return g.transport.OwnPeer()
+}
+
+func (g *GossipP2P) AddPeer(peer *net.Peer) error {
+	return g.transport.AddPeer(peer)
+}
+
+func (g *GossipP2P) RemovePeer(peer *net.Peer) error {
+	return g.transport.RemovePeer(peer)
+}
+
+// the directly connected peers
+func (g *GossipP2P) Peers() []*net.Peer {
+	return g.transport.Peers()
+}
+
+func (g *GossipP2P) BroadcastShare(share *pb.ShareDistribution) error {
+	share.Ttl = g.config.Ttl
+	g.ReceiveShare(share)
+	return nil
+}
+
+func (g *GossipP2P) BroadcastSignature(sig *pb.SignatureDistribution) error {
+	sig.Ttl = g.config.Ttl
+	g.ReceiveSignature(sig)
+	return nil
+}
+
+func (g *GossipP2P) BroadcastDKGMessage(msg *pb.DKGMessage) error {
+	msg.Ttl = g.config.Ttl
+	g.ReceiveDKGMessage(msg)
+	return nil
+}
+
+func (g *GossipP2P) BroadcastConsensusMessage(msg *pb.ConsensusMessage) error {
+	msg.Ttl = g.config.Ttl
+	g.ReceiveConsensusMessage(msg)
+	return nil
+}
+
+func (g *GossipP2P) BroadcastRegistryRequest(req *pb.RegistryRequest) error {
+	req.Ttl = g.config.Ttl
+	g.ReceiveRegistryRequest(req)
+	return nil
+}
+
+func (g *GossipP2P) ReceiveShare(share *pb.ShareDistribution) {
+	first, relay := g.markSeen(share.Id, share.Ttl)
+	if first && g.receiver != nil {
+		g.receiver.ReceiveShare(share)
+	}
+	if !relay {
+		return
+	}
+	next := proto.Clone(share).(*pb.ShareDistribution)
+	next.Ttl--
+	for _, peer := range g.pickPeers() {
+		err := g.transport.SendShare(peer, next)
+		logRelayError(peer, err)
+	}
+}
+
+func (g *GossipP2P) ReceiveSignature(sig *pb.SignatureDistribution) {
+	first, relay := g.markSeen(sig.Id, sig.Ttl)
+	if first && g.receiver != nil {
+		g.receiver.ReceiveSignature(sig)
+	}
+	if !relay {
+		return
+	}
+	next := proto.Clone(sig).(*pb.SignatureDistribution)
+	next.Ttl--
+	for _, peer := range g.pickPeers() {
+		err := g.transport.SendSignature(peer, next)
+		logRelayError(peer, err)
+	}
+}
+
+func (g *GossipP2P) ReceiveDKGMessage(msg *pb.DKGMessage) {
+	first, relay := g.markSeen(msg.Id, msg.Ttl)
+	if first && g.receiver != nil {
+		g.receiver.ReceiveDKGMessage(msg)
+	}
+	if !relay {
+		return
+	}
+	next := proto.Clone(msg).(*pb.DKGMessage)
+	next.Ttl--
+	for _, peer := range g.pickPeers() {
+		err := g.transport.SendDKGMessage(peer, next)
+		logRelayError(peer, err)
+	}
+}
+
+func (g *GossipP2P) ReceiveConsensusMessage(msg *pb.ConsensusMessage) {
+	first, relay := g.markSeen(msg.Id, msg.Ttl)
+	if first && g.receiver != nil {
+		g.receiver.ReceiveConsensusMessage(msg)
+	}
+	if !relay {
+		return
+	}
+	next := proto.Clone(msg).(*pb.ConsensusMessage)
+	next.Ttl--
+	for _, peer := range g.pickPeers() {
+		err := g.transport.SendConsensusMessage(peer, next)
+		logRelayError(peer, err)
+	}
+}
+
+func (g *GossipP2P) ReceiveRegistryRequest(req *pb.RegistryRequest) {
+	first, relay := g.markSeen(req.Id, req.Ttl)
+	if first && g.receiver != nil {
+		g.receiver.ReceiveRegistryRequest(req)
+	}
+	if !relay {
+		return
+	}
+	next := proto.Clone(req).(*pb.RegistryRequest)
+	next.Ttl--
+	for _, peer := range g.pickPeers() {
+		err := g.transport.SendRegistryRequest(peer, next)
+		logRelayError(peer, err)
+	}
+}
+
+// returns whether the message is seen for the first time (should be delivered) and whether it should be relayed,
+// i.e. it has hops left and arrived with more hops than any previous copy. A copy that took a longer path could
+// arrive first, relaying the later copy again makes sure the message reaches as far as its ttl allows.
+func (g *GossipP2P) markSeen(id string, ttl uint32) (bool, bool) {
+	g.seenLock.Lock()
+	defer g.seenLock.Unlock()
+
+	prevTtl, found := g.seen[id]
+	if found && ttl <= prevTtl {
+		return false, false
+	}
+	g.seen[id] = ttl
+	if !found {
+		g.seenOrder = append(g.seenOrder, id)
+		for len(g.seenOrder) > g.config.SeenCacheSize {
+			delete(g.seen, g.seenOrder[0])
+			g.seenOrder = g.seenOrder[1:]
+		}
+	}
+	return !found, ttl > 1
+}
+
+// at most Fanout random peers, never our own peer
+func (g *GossipP2P) pickPeers() []*net.Peer {
+	own := g.transport.OwnPeer()
+	peers := make([]*net.Peer, 0)
+	for _, peer := range g.transport.Peers() {
+		if peer.Id == own.Id || (own.Address != "" && peer.Address == own.Address) {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+
+	g.randomLock.Lock()
+	g.random.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	g.randomLock.Unlock()
+	if len(peers) > g.config.Fanout {
+		peers = peers[:g.config.Fanout]
+	}
+	return peers
+}
+
+func logRelayError(peer *net.Peer, err error) {
+	if err != nil {
+		log.Printf("gossip relay to %s failed: %s", peer.Id.String(), err.Error())
+	}
+}
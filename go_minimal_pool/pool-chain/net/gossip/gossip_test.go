@@ -0,0 +1,172 @@
+package gossip
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/simple_net"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+type countingReceiver struct {
+	shares map[string]int
+	sigs map[string]int
+	dkg map[string]int
+	consensus map[string]int
+	registry map[string]int
+	lock sync.Mutex
+}
+
+func newCountingReceiver() *countingReceiver {
+	return &countingReceiver{
+		shares: make(map[string]int),
+		sigs: make(map[string]int),
+		dkg: make(map[string]int),
+		consensus: make(map[string]int),
+		registry: make(map[string]int),
+	}
+}
+
+func (r *countingReceiver) ReceiveShare(share *pb.ShareDistribution) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.shares[share.Id]++
+}
+
+func (r *countingReceiver) ReceiveSignature(sig *pb.SignatureDistribution) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sigs[sig.Id]++
+}
+
+func (r *countingReceiver) ReceiveDKGMessage(msg *pb.DKGMessage) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.dkg[msg.Id]++
+}
+
+func (r *countingReceiver) ReceiveConsensusMessage(msg *pb.ConsensusMessage) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.consensus[msg.Id]++
+}
+
+func (r *countingReceiver) ReceiveRegistryRequest(req *pb.RegistryRequest) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.registry[req.Id]++
+}
+
+// gossip nodes over simple_net, deterministic peer picking
+func newTestNodes(cnt int, config *Config) ([]*GossipP2P, []*countingReceiver) {
+	nodes := make([]*GossipP2P, cnt)
+	receivers := make([]*countingReceiver, cnt)
+	for i := range nodes {
+		nodes[i] = NewGossipP2P(simple_net.NewSimpleP2P(), config)
+		nodes[i].random = rand.New(rand.NewSource(int64(i)))
+		receivers[i] = newCountingReceiver()
+		nodes[i].RegisterReceiver(receivers[i])
+	}
+	return nodes, receivers
+}
+
+// a ring, so the graph is connected, plus random chords
+func connectSparseRandomGraph(nodes []*GossipP2P, chords int, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	connected := make(map[string]bool)
+	connect := func(i, j int) {
+		key := fmt.Sprintf("%d-%d", i, j)
+		if i == j || connected[key] {
+			return
+		}
+		connected[key] = true
+		connected[fmt.Sprintf("%d-%d", j, i)] = true
+		net.BiDirectionalConnection(nodes[i], nodes[j])
+	}
+
+	for i := range nodes {
+		connect(i, (i + 1) % len(nodes))
+	}
+	for i := 0 ; i < chords ; i++ {
+		connect(r.Intn(len(nodes)), r.Intn(len(nodes)))
+	}
+}
+
+func TestGossipSparseRandomGraph(t *testing.T) {
+	nodes, receivers := newTestNodes(50, &Config{Fanout: 4, Ttl: 12, SeenCacheSize: 100})
+	connectSparseRandomGraph(nodes, 25, 1)
+
+	// sparse, no node is connected to more than a few others
+	for _, n := range nodes {
+		require.LessOrEqual(t, len(n.transport.Peers()), 6)
+	}
+
+	require.NoError(t, nodes[0].BroadcastShare(&pb.ShareDistribution{Id: "share"}))
+	require.NoError(t, nodes[17].BroadcastSignature(&pb.SignatureDistribution{Id: "sig"}))
+	require.NoError(t, nodes[49].BroadcastDKGMessage(&pb.DKGMessage{Id: "dkg"}))
+	require.NoError(t, nodes[33].BroadcastConsensusMessage(&pb.ConsensusMessage{Id: "vote"}))
+	require.NoError(t, nodes[8].BroadcastRegistryRequest(&pb.RegistryRequest{Id: "join"}))
+
+	// every node got every message exactly once
+	for i, r := range receivers {
+		require.Equal(t, 1, r.shares["share"], "node %d", i)
+		require.Equal(t, 1, r.sigs["sig"], "node %d", i)
+		require.Equal(t, 1, r.dkg["dkg"], "node %d", i)
+		require.Equal(t, 1, r.consensus["vote"], "node %d", i)
+		require.Equal(t, 1, r.registry["join"], "node %d", i)
+	}
+}
+
+func TestGossipTtl(t *testing.T) {
+	nodes, receivers := newTestNodes(6, &Config{Fanout: 4, Ttl: 3, SeenCacheSize: 100})
+	// line
+	for i := 1 ; i < len(nodes) ; i++ {
+		net.BiDirectionalConnection(nodes[i-1], nodes[i])
+	}
+
+	require.NoError(t, nodes[0].BroadcastShare(&pb.ShareDistribution{Id: "share"}))
+
+	// the origin and 2 hops
+	for i, r := range receivers {
+		if i < 3 {
+			require.Equal(t, 1, r.shares["share"], "node %d", i)
+		} else {
+			require.Equal(t, 0, r.shares["share"], "node %d", i)
+		}
+	}
+}
+
+func TestGossipFanout(t *testing.T) {
+	nodes, receivers := newTestNodes(11, &Config{Fanout: 3, Ttl: 5, SeenCacheSize: 100})
+	// star, node 0 in the middle
+	for i := 1 ; i < len(nodes) ; i++ {
+		net.BiDirectionalConnection(nodes[0], nodes[i])
+	}
+
+	require.NoError(t, nodes[0].BroadcastShare(&pb.ShareDistribution{Id: "share"}))
+
+	// leaves can only relay back to the center, which already saw the message
+	cnt := 0
+	for _, r := range receivers[1:] {
+		cnt += r.shares["share"]
+	}
+	require.Equal(t, 3, cnt)
+	require.Equal(t, 1, receivers[0].shares["share"])
+}
+
+func TestGossipSeenCacheEviction(t *testing.T) {
+	nodes, receivers := newTestNodes(1, &Config{Fanout: 1, Ttl: 1, SeenCacheSize: 2})
+
+	nodes[0].ReceiveShare(&pb.ShareDistribution{Id: "1"})
+	nodes[0].ReceiveShare(&pb.ShareDistribution{Id: "1"})
+	require.Equal(t, 1, receivers[0].shares["1"])
+
+	// "1" is evicted
+	nodes[0].ReceiveShare(&pb.ShareDistribution{Id: "2"})
+	nodes[0].ReceiveShare(&pb.ShareDistribution{Id: "3"})
+	nodes[0].ReceiveShare(&pb.ShareDistribution{Id: "1"})
+	require.Equal(t, 2, receivers[0].shares["1"])
+}
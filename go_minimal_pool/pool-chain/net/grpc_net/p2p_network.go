@@ -0,0 +1,332 @@
+package grpc_net
+
+import (
+	"context"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"google.golang.org/grpc"
+	"log"
+	net2 "net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sendTimeout = time.Second * 2
+
+type remotePeer struct {
+	peer *net.Peer
+	conn *grpc.ClientConn
+	shares pb.ShareDistributionServiceClient
+	sigs pb.SignatureDistributionServiceClient
+	dkg pb.DKGServiceClient
+	consensus pb.ConsensusServiceClient
+	registry pb.RegistryServiceClient
+}
+
+// P2P over gRPC, every node listens on a TCP address and dials its peers' addresses.
+// Broadcasting sends the message to every peer concurrently, received messages are handed to the registered
+// receiver.
+type GrpcP2PNetwork struct {
+	myPeer *net.Peer
+	server *grpc.Server
+	listener net2.Listener
+	receiver net.P2PReceiver
+
+	peers map[string]*remotePeer
+	peersLock sync.Mutex
+}
+
+// listens on address (e.g. "127.0.0.1:0" for a random port), OwnPeer().Address is the actual address
+func NewGrpcP2P(address string) (*GrpcP2PNetwork, error) {
+	listener, err := net2.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &GrpcP2PNetwork{
+		myPeer: net.NewRemotePeer(listener.Addr().String()),
+		server: grpc.NewServer(),
+		listener: listener,
+		peers: make(map[string]*remotePeer),
+	}
+	pb.RegisterShareDistributionServiceServer(ret.server, &shareServer{network: ret})
+	pb.RegisterSignatureDistributionServiceServer(ret.server, &sigServer{network: ret})
+	pb.RegisterDKGServiceServer(ret.server, &dkgServer{network: ret})
+	pb.RegisterConsensusServiceServer(ret.server, &consensusServer{network: ret})
+	pb.RegisterRegistryServiceServer(ret.server, &registryServer{network: ret})
+
+	go func() {
+		err := ret.server.Serve(listener)
+		if err != nil {
+			log.Printf("grpc server on %s stopped: %s", listener.Addr().String(), err.Error())
+		}
+	}()
+
+	return ret, nil
+}
+
+func (p *GrpcP2PNetwork) RegisterReceiver(r net.P2PReceiver) {
+	p.receiver = r
+}
+
+// returns this p2p network own peer
+func (p *GrpcP2PNetwork) OwnPeer() *net.Peer {
+	return p.myPeer
+}
+
+func (p *GrpcP2PNetwork) AddPeer(peer *net.Peer) error {
+	if peer.Address == "" {
+		return fmt.Errorf("peer %s has no address", peer.Id.String())
+	}
+
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	if _, found := p.peers[peer.Address]; found {
+		return nil
+	}
+
+	// non blocking, the connection is established (and re-established) in the background
+	conn, err := grpc.Dial(peer.Address, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	p.peers[peer.Address] = &remotePeer{
+		peer: peer,
+		conn: conn,
+		shares: pb.NewShareDistributionServiceClient(conn),
+		sigs: pb.NewSignatureDistributionServiceClient(conn),
+		dkg: pb.NewDKGServiceClient(conn),
+		consensus: pb.NewConsensusServiceClient(conn),
+		registry: pb.NewRegistryServiceClient(conn),
+	}
+	return nil
+}
+
+func (p *GrpcP2PNetwork) RemovePeer(peer *net.Peer) error {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	remote, found := p.peers[peer.Address]
+	if !found {
+		return nil
+	}
+	delete(p.peers, peer.Address)
+	return remote.conn.Close()
+}
+
+// stops the server and closes all peer connections
+func (p *GrpcP2PNetwork) Close() {
+	p.server.Stop()
+
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+	for address, remote := range p.peers {
+		remote.conn.Close()
+		delete(p.peers, address)
+	}
+}
+
+func (p *GrpcP2PNetwork) Peers() []*net.Peer {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	ret := make([]*net.Peer, 0, len(p.peers))
+	for _, remote := range p.peers {
+		ret = append(ret, remote.peer)
+	}
+	return ret
+}
+
+func (p *GrpcP2PNetwork) SendShare(peer *net.Peer, share *pb.ShareDistribution) error {
+	return p.send(peer, func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.shares.NewShare(ctx, share, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) SendSignature(peer *net.Peer, sig *pb.SignatureDistribution) error {
+	return p.send(peer, func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.sigs.NewShare(ctx, sig, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) SendDKGMessage(peer *net.Peer, msg *pb.DKGMessage) error {
+	return p.send(peer, func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.dkg.NewMessage(ctx, msg, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) SendConsensusMessage(peer *net.Peer, msg *pb.ConsensusMessage) error {
+	return p.send(peer, func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.consensus.NewMessage(ctx, msg, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) SendRegistryRequest(peer *net.Peer, req *pb.RegistryRequest) error {
+	return p.send(peer, func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.registry.NewRequest(ctx, req, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) send(peer *net.Peer, send func(ctx context.Context, remote *remotePeer) error) error {
+	p.peersLock.Lock()
+	remote, found := p.peers[peer.Address]
+	p.peersLock.Unlock()
+	if !found {
+		return fmt.Errorf("unknown peer %s", peer.Address)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return send(ctx, remote)
+}
+
+func (p *GrpcP2PNetwork) BroadcastShare(share *pb.ShareDistribution) error {
+	return p.broadcast(func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.shares.NewShare(ctx, share, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) BroadcastSignature(sig *pb.SignatureDistribution) error {
+	return p.broadcast(func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.sigs.NewShare(ctx, sig, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) BroadcastDKGMessage(msg *pb.DKGMessage) error {
+	return p.broadcast(func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.dkg.NewMessage(ctx, msg, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) BroadcastConsensusMessage(msg *pb.ConsensusMessage) error {
+	return p.broadcast(func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.consensus.NewMessage(ctx, msg, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+func (p *GrpcP2PNetwork) BroadcastRegistryRequest(req *pb.RegistryRequest) error {
+	return p.broadcast(func(ctx context.Context, remote *remotePeer) error {
+		_, err := remote.registry.NewRequest(ctx, req, grpc.WaitForReady(true))
+		return err
+	})
+}
+
+// sends to all peers concurrently (waiting up to sendTimeout for a peer to be ready), returns an error listing
+// the peers that could not be reached
+func (p *GrpcP2PNetwork) broadcast(send func(ctx context.Context, remote *remotePeer) error) error {
+	p.peersLock.Lock()
+	remotes := make([]*remotePeer, 0, len(p.peers))
+	for _, remote := range p.peers {
+		remotes = append(remotes, remote)
+	}
+	p.peersLock.Unlock()
+
+	wg := sync.WaitGroup{}
+	errsLock := sync.Mutex{}
+	errs := make([]string, 0)
+	for _, remote := range remotes {
+		wg.Add(1)
+		go func(remote *remotePeer) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			err := send(ctx, remote)
+			if err != nil {
+				errsLock.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %s", remote.peer.Address, err.Error()))
+				errsLock.Unlock()
+			}
+		}(remote)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not reach %d peers: %s", len(errs), strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (p *GrpcP2PNetwork) getReceiver() (net.P2PReceiver, error) {
+	if p.receiver == nil {
+		return nil, fmt.Errorf("no receiver registered")
+	}
+	return p.receiver, nil
+}
+
+type shareServer struct {
+	network *GrpcP2PNetwork
+}
+
+func (s *shareServer) NewShare(ctx context.Context, share *pb.ShareDistribution) (*pb.StatusResponse, error) {
+	r, err := s.network.getReceiver()
+	if err != nil {
+		return nil, err
+	}
+	r.ReceiveShare(share)
+	return &pb.StatusResponse{Status: true}, nil
+}
+
+type sigServer struct {
+	network *GrpcP2PNetwork
+}
+
+func (s *sigServer) NewShare(ctx context.Context, sig *pb.SignatureDistribution) (*pb.StatusResponse, error) {
+	r, err := s.network.getReceiver()
+	if err != nil {
+		return nil, err
+	}
+	r.ReceiveSignature(sig)
+	return &pb.StatusResponse{Status: true}, nil
+}
+
+type dkgServer struct {
+	network *GrpcP2PNetwork
+}
+
+func (s *dkgServer) NewMessage(ctx context.Context, msg *pb.DKGMessage) (*pb.StatusResponse, error) {
+	r, err := s.network.getReceiver()
+	if err != nil {
+		return nil, err
+	}
+	r.ReceiveDKGMessage(msg)
+	return &pb.StatusResponse{Status: true}, nil
+}
+
+type consensusServer struct {
+	network *GrpcP2PNetwork
+}
+
+func (s *consensusServer) NewMessage(ctx context.Context, msg *pb.ConsensusMessage) (*pb.StatusResponse, error) {
+	r, err := s.network.getReceiver()
+	if err != nil {
+		return nil, err
+	}
+	r.ReceiveConsensusMessage(msg)
+	return &pb.StatusResponse{Status: true}, nil
+}
+
+type registryServer struct {
+	network *GrpcP2PNetwork
+}
+
+func (s *registryServer) NewRequest(ctx context.Context, req *pb.RegistryRequest) (*pb.StatusResponse, error) {
+	r, err := s.network.getReceiver()
+	if err != nil {
+		return nil, err
+	}
+	r.ReceiveRegistryRequest(req)
+	return &pb.StatusResponse{Status: true}, nil
+}
\ No newline at end of file
@@ -0,0 +1,112 @@
+package grpc_net
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+type testReceiver struct {
+	shares []*pb.ShareDistribution
+	sigs []*pb.SignatureDistribution
+	dkg []*pb.DKGMessage
+	consensus []*pb.ConsensusMessage
+	registry []*pb.RegistryRequest
+	lock sync.Mutex
+}
+
+func (r *testReceiver) ReceiveShare(share *pb.ShareDistribution) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.shares = append(r.shares, share)
+}
+
+func (r *testReceiver) ReceiveSignature(sig *pb.SignatureDistribution) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sigs = append(r.sigs, sig)
+}
+
+func (r *testReceiver) ReceiveDKGMessage(msg *pb.DKGMessage) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.dkg = append(r.dkg, msg)
+}
+
+func (r *testReceiver) ReceiveConsensusMessage(msg *pb.ConsensusMessage) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.consensus = append(r.consensus, msg)
+}
+
+func (r *testReceiver) ReceiveRegistryRequest(req *pb.RegistryRequest) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.registry = append(r.registry, req)
+}
+
+func TestGrpcBroadcast(t *testing.T) {
+	networks := make([]*GrpcP2PNetwork, 3)
+	receivers := make([]*testReceiver, 3)
+	for i := range networks {
+		n, err := NewGrpcP2P("127.0.0.1:0")
+		require.NoError(t, err)
+		defer n.Close()
+		receivers[i] = &testReceiver{}
+		n.RegisterReceiver(receivers[i])
+		networks[i] = n
+	}
+	for i, n1 := range networks {
+		require.NoError(t, n1.AddPeer(n1.OwnPeer()))
+		for _, n2 := range networks[i+1:] {
+			net.BiDirectionalConnection(n1, n2)
+		}
+	}
+
+	require.NoError(t, networks[0].BroadcastShare(&pb.ShareDistribution{Id: "share", Share: []byte{1,2,3}, Epoch: 2}))
+	require.NoError(t, networks[1].BroadcastSignature(&pb.SignatureDistribution{Id: "sig", Sig: []byte{4}}))
+	require.NoError(t, networks[2].BroadcastDKGMessage(&pb.DKGMessage{Id: "dkg", Type: pb.DKGMessageType_COMPLAINT}))
+	require.NoError(t, networks[0].BroadcastConsensusMessage(&pb.ConsensusMessage{Id: "vote", Type: pb.ConsensusMessageType_PREVOTE, ValidRound: -1}))
+	require.NoError(t, networks[1].BroadcastRegistryRequest(&pb.RegistryRequest{Id: "deposit", Type: pb.RegistryRequestType_DEPOSIT, PoolId: 3, Gwei: 32}))
+
+	for _, r := range receivers {
+		require.Len(t, r.shares, 1)
+		require.Equal(t, "share", r.shares[0].Id)
+		require.Equal(t, []byte{1,2,3}, r.shares[0].Share)
+		require.EqualValues(t, 2, r.shares[0].Epoch)
+		require.Len(t, r.sigs, 1)
+		require.Equal(t, "sig", r.sigs[0].Id)
+		require.Len(t, r.dkg, 1)
+		require.Equal(t, pb.DKGMessageType_COMPLAINT, r.dkg[0].Type)
+		require.Len(t, r.consensus, 1)
+		require.Equal(t, pb.ConsensusMessageType_PREVOTE, r.consensus[0].Type)
+		require.EqualValues(t, -1, r.consensus[0].ValidRound)
+		require.Len(t, r.registry, 1)
+		require.Equal(t, pb.RegistryRequestType_DEPOSIT, r.registry[0].Type)
+		require.EqualValues(t, 32, r.registry[0].Gwei)
+	}
+
+	// removed peers don't receive
+	require.NoError(t, networks[0].RemovePeer(networks[2].OwnPeer()))
+	require.NoError(t, networks[0].BroadcastShare(&pb.ShareDistribution{Id: "share2"}))
+	require.Len(t, receivers[1].shares, 2)
+	require.Len(t, receivers[2].shares, 1)
+}
+
+func TestGrpcUnreachablePeer(t *testing.T) {
+	n, err := NewGrpcP2P("127.0.0.1:0")
+	require.NoError(t, err)
+	defer n.Close()
+
+	require.Error(t, n.AddPeer(net.NewPeer()))
+
+	// listen and close to get an address nothing listens on
+	closed, err := NewGrpcP2P("127.0.0.1:0")
+	require.NoError(t, err)
+	closed.Close()
+
+	require.NoError(t, n.AddPeer(closed.OwnPeer()))
+	require.Error(t, n.BroadcastShare(&pb.ShareDistribution{Id: "share"}))
+}
@@ -0,0 +1,403 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.24.0
+// 	protoc        v3.11.4
+// source: consensus.proto
+
+package pb
+
+import (
+	context "context"
+	proto "github.com/golang/protobuf/proto"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+// tendermint steps, see pool-chain/consensus
+type ConsensusMessageType int32
+
+const (
+	ConsensusMessageType_PROPOSAL  ConsensusMessageType = 0
+	ConsensusMessageType_PREVOTE   ConsensusMessageType = 1
+	ConsensusMessageType_PRECOMMIT ConsensusMessageType = 2
+)
+
+// Enum value maps for ConsensusMessageType.
+var (
+	ConsensusMessageType_name = map[int32]string{
+		0: "PROPOSAL",
+		1: "PREVOTE",
+		2: "PRECOMMIT",
+	}
+	ConsensusMessageType_value = map[string]int32{
+		"PROPOSAL":  0,
+		"PREVOTE":   1,
+		"PRECOMMIT": 2,
+	}
+)
+
+func (x ConsensusMessageType) Enum() *ConsensusMessageType {
+	p := new(ConsensusMessageType)
+	*p = x
+	return p
+}
+
+func (x ConsensusMessageType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ConsensusMessageType) Descriptor() protoreflect.EnumDescriptor {
+	return file_consensus_proto_enumTypes[0].Descriptor()
+}
+
+func (ConsensusMessageType) Type() protoreflect.EnumType {
+	return &file_consensus_proto_enumTypes[0]
+}
+
+func (x ConsensusMessageType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ConsensusMessageType.Descriptor instead.
+func (ConsensusMessageType) EnumDescriptor() ([]byte, []int) {
+	return file_consensus_proto_rawDescGZIP(), []int{0}
+}
+
+type ConsensusMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id              string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type            ConsensusMessageType `protobuf:"varint,2,opt,name=type,proto3,enum=v1.ConsensusMessageType" json:"type,omitempty"`
+	FromParticipant *Participant         `protobuf:"bytes,3,opt,name=from_participant,json=fromParticipant,proto3" json:"from_participant,omitempty"`
+	// the epoch the agreed block is for
+	Height uint32 `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	Round  uint32 `protobuf:"varint,5,opt,name=round,proto3" json:"round,omitempty"`
+	// the proposed block, for PROPOSAL
+	Value []byte `protobuf:"bytes,6,opt,name=value,proto3" json:"value,omitempty"`
+	// the round the proposed value was last prevoted in by a quorum, -1 if none
+	ValidRound int32 `protobuf:"varint,7,opt,name=valid_round,json=validRound,proto3" json:"valid_round,omitempty"`
+	// the voted value's hash, empty for a nil vote
+	ValueHash []byte `protobuf:"bytes,8,opt,name=value_hash,json=valueHash,proto3" json:"value_hash,omitempty"`
+	// sender's identity signature over the message's canonical encoding, see pool-chain/auth.go
+	Signature []byte `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+	// remaining gossip hops, relays decrement it so it's not signed
+	Ttl uint32 `protobuf:"varint,10,opt,name=ttl,proto3" json:"ttl,omitempty"`
+}
+
+func (x *ConsensusMessage) Reset() {
+	*x = ConsensusMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_consensus_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConsensusMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsensusMessage) ProtoMessage() {}
+
+func (x *ConsensusMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_consensus_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsensusMessage.ProtoReflect.Descriptor instead.
+func (*ConsensusMessage) Descriptor() ([]byte, []int) {
+	return file_consensus_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ConsensusMessage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ConsensusMessage) GetType() ConsensusMessageType {
+	if x != nil {
+		return x.Type
+	}
+	return ConsensusMessageType_PROPOSAL
+}
+
+func (x *ConsensusMessage) GetFromParticipant() *Participant {
+	if x != nil {
+		return x.FromParticipant
+	}
+	return nil
+}
+
+func (x *ConsensusMessage) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ConsensusMessage) GetRound() uint32 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *ConsensusMessage) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *ConsensusMessage) GetValidRound() int32 {
+	if x != nil {
+		return x.ValidRound
+	}
+	return 0
+}
+
+func (x *ConsensusMessage) GetValueHash() []byte {
+	if x != nil {
+		return x.ValueHash
+	}
+	return nil
+}
+
+func (x *ConsensusMessage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *ConsensusMessage) GetTtl() uint32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+var File_consensus_proto protoreflect.FileDescriptor
+
+var file_consensus_proto_rawDesc = []byte{
+	0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x02, 0x76, 0x31, 0x1a, 0x11, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61,
+	0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x0e, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2f, 0x61, 0x70, 0x69, 0x2f, 0x61, 0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xc0, 0x02, 0x0a, 0x10, 0x43, 0x6f, 0x6e, 0x73, 0x65,
+	0x6e, 0x73, 0x75, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x2c, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x76, 0x31, 0x2e, 0x43,
+	0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x54,
+	0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x3a, 0x0a, 0x10, 0x66, 0x72, 0x6f,
+	0x6d, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69,
+	0x70, 0x61, 0x6e, 0x74, 0x52, 0x0f, 0x66, 0x72, 0x6f, 0x6d, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63,
+	0x69, 0x70, 0x61, 0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x14, 0x0a,
+	0x05, 0x72, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x72, 0x6f,
+	0x75, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x76, 0x61, 0x6c,
+	0x69, 0x64, 0x5f, 0x72, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a,
+	0x76, 0x61, 0x6c, 0x69, 0x64, 0x52, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x48, 0x61, 0x73, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x74, 0x74, 0x6c, 0x2a, 0x40, 0x0a, 0x14, 0x43, 0x6f, 0x6e,
+	0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x0c, 0x0a, 0x08, 0x50, 0x52, 0x4f, 0x50, 0x4f, 0x53, 0x41, 0x4c, 0x10, 0x00, 0x12,
+	0x0b, 0x0a, 0x07, 0x50, 0x52, 0x45, 0x56, 0x4f, 0x54, 0x45, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09,
+	0x50, 0x52, 0x45, 0x43, 0x4f, 0x4d, 0x4d, 0x49, 0x54, 0x10, 0x02, 0x32, 0x69, 0x0a, 0x10, 0x43,
+	0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x55, 0x0a, 0x0a, 0x4e, 0x65, 0x77, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x2e,
+	0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x1a, 0x12, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x1d, 0x82, 0xd3, 0xe4, 0x93, 0x02, 0x17, 0x12,
+	0x15, 0x2f, 0x76, 0x31, 0x2f, 0x63, 0x6f, 0x6e, 0x73, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x2f, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x42, 0x10, 0x5a, 0x0e, 0x2f, 0x70, 0x6f, 0x6f, 0x6c, 0x2d,
+	0x63, 0x68, 0x61, 0x69, 0x6e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_consensus_proto_rawDescOnce sync.Once
+	file_consensus_proto_rawDescData = file_consensus_proto_rawDesc
+)
+
+func file_consensus_proto_rawDescGZIP() []byte {
+	file_consensus_proto_rawDescOnce.Do(func() {
+		file_consensus_proto_rawDescData = protoimpl.X.CompressGZIP(file_consensus_proto_rawDescData)
+	})
+	return file_consensus_proto_rawDescData
+}
+
+var file_consensus_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_consensus_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_consensus_proto_goTypes = []interface{}{
+	(ConsensusMessageType)(0), // 0: v1.ConsensusMessageType
+	(*ConsensusMessage)(nil),  // 1: v1.ConsensusMessage
+	(*Participant)(nil),       // 2: v1.Participant
+	(*StatusResponse)(nil),    // 3: v1.StatusResponse
+}
+var file_consensus_proto_depIdxs = []int32{
+	0, // 0: v1.ConsensusMessage.type:type_name -> v1.ConsensusMessageType
+	2, // 1: v1.ConsensusMessage.from_participant:type_name -> v1.Participant
+	1, // 2: v1.ConsensusService.NewMessage:input_type -> v1.ConsensusMessage
+	3, // 3: v1.ConsensusService.NewMessage:output_type -> v1.StatusResponse
+	3, // [3:4] is the sub-list for method output_type
+	2, // [2:3] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_consensus_proto_init() }
+func file_consensus_proto_init() {
+	if File_consensus_proto != nil {
+		return
+	}
+	file_participant_proto_init()
+	file_response_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_consensus_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConsensusMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_consensus_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_consensus_proto_goTypes,
+		DependencyIndexes: file_consensus_proto_depIdxs,
+		EnumInfos:         file_consensus_proto_enumTypes,
+		MessageInfos:      file_consensus_proto_msgTypes,
+	}.Build()
+	File_consensus_proto = out.File
+	file_consensus_proto_rawDesc = nil
+	file_consensus_proto_goTypes = nil
+	file_consensus_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// ConsensusServiceClient is the client API for ConsensusService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ConsensusServiceClient interface {
+	NewMessage(ctx context.Context, in *ConsensusMessage, opts ...grpc.CallOption) (*StatusResponse, error)
+}
+
+type consensusServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConsensusServiceClient(cc grpc.ClientConnInterface) ConsensusServiceClient {
+	return &consensusServiceClient{cc}
+}
+
+func (c *consensusServiceClient) NewMessage(ctx context.Context, in *ConsensusMessage, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/v1.ConsensusService/NewMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ConsensusServiceServer is the server API for ConsensusService service.
+type ConsensusServiceServer interface {
+	NewMessage(context.Context, *ConsensusMessage) (*StatusResponse, error)
+}
+
+// UnimplementedConsensusServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedConsensusServiceServer struct {
+}
+
+func (*UnimplementedConsensusServiceServer) NewMessage(context.Context, *ConsensusMessage) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NewMessage not implemented")
+}
+
+func RegisterConsensusServiceServer(s *grpc.Server, srv ConsensusServiceServer) {
+	s.RegisterService(&_ConsensusService_serviceDesc, srv)
+}
+
+func _ConsensusService_NewMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConsensusMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConsensusServiceServer).NewMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.ConsensusService/NewMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConsensusServiceServer).NewMessage(ctx, req.(*ConsensusMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ConsensusService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "v1.ConsensusService",
+	HandlerType: (*ConsensusServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "NewMessage",
+			Handler:    _ConsensusService_NewMessage_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "consensus.proto",
+}
@@ -0,0 +1,446 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.24.0
+// 	protoc        v3.11.4
+// source: dkg.proto
+
+package pb
+
+import (
+	context "context"
+	proto "github.com/golang/protobuf/proto"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+// GJKR phases, see crypto/gjkr.go
+type DKGMessageType int32
+
+const (
+	DKGMessageType_DEAL_COMMITMENT      DKGMessageType = 0
+	DKGMessageType_DEAL                 DKGMessageType = 1
+	DKGMessageType_COMPLAINT            DKGMessageType = 2
+	DKGMessageType_JUSTIFICATION        DKGMessageType = 3
+	DKGMessageType_PUBLIC_COEFFICIENTS  DKGMessageType = 4
+	DKGMessageType_EXTRACTION_COMPLAINT DKGMessageType = 5
+	DKGMessageType_RECONSTRUCTION_SHARE DKGMessageType = 6
+	DKGMessageType_GROUP_PK             DKGMessageType = 7
+)
+
+// Enum value maps for DKGMessageType.
+var (
+	DKGMessageType_name = map[int32]string{
+		0: "DEAL_COMMITMENT",
+		1: "DEAL",
+		2: "COMPLAINT",
+		3: "JUSTIFICATION",
+		4: "PUBLIC_COEFFICIENTS",
+		5: "EXTRACTION_COMPLAINT",
+		6: "RECONSTRUCTION_SHARE",
+		7: "GROUP_PK",
+	}
+	DKGMessageType_value = map[string]int32{
+		"DEAL_COMMITMENT":      0,
+		"DEAL":                 1,
+		"COMPLAINT":            2,
+		"JUSTIFICATION":        3,
+		"PUBLIC_COEFFICIENTS":  4,
+		"EXTRACTION_COMPLAINT": 5,
+		"RECONSTRUCTION_SHARE": 6,
+		"GROUP_PK":             7,
+	}
+)
+
+func (x DKGMessageType) Enum() *DKGMessageType {
+	p := new(DKGMessageType)
+	*p = x
+	return p
+}
+
+func (x DKGMessageType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DKGMessageType) Descriptor() protoreflect.EnumDescriptor {
+	return file_dkg_proto_enumTypes[0].Descriptor()
+}
+
+func (DKGMessageType) Type() protoreflect.EnumType {
+	return &file_dkg_proto_enumTypes[0]
+}
+
+func (x DKGMessageType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DKGMessageType.Descriptor instead.
+func (DKGMessageType) EnumDescriptor() ([]byte, []int) {
+	return file_dkg_proto_rawDescGZIP(), []int{0}
+}
+
+type DKGMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id              string         `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type            DKGMessageType `protobuf:"varint,2,opt,name=type,proto3,enum=v1.DKGMessageType" json:"type,omitempty"`
+	FromParticipant *Participant   `protobuf:"bytes,3,opt,name=from_participant,json=fromParticipant,proto3" json:"from_participant,omitempty"`
+	// the deal's recipient, the complaint's accused dealer, the justification's complainer or the reconstructed dealer
+	ToParticipant *Participant `protobuf:"bytes,4,opt,name=to_participant,json=toParticipant,proto3" json:"to_participant,omitempty"`
+	PoolId        uint32       `protobuf:"varint,5,opt,name=pool_id,json=poolId,proto3" json:"pool_id,omitempty"`
+	// pedersen commitments or public coefficients
+	Commitments   [][]byte `protobuf:"bytes,6,rep,name=commitments,proto3" json:"commitments,omitempty"`
+	Share         []byte   `protobuf:"bytes,7,opt,name=share,proto3" json:"share,omitempty"`
+	BlindingShare []byte   `protobuf:"bytes,8,opt,name=blinding_share,json=blindingShare,proto3" json:"blinding_share,omitempty"`
+	// the pool's public key and the public shares of its members (sorted by id), for GROUP_PK
+	GroupPk      []byte   `protobuf:"bytes,9,opt,name=group_pk,json=groupPk,proto3" json:"group_pk,omitempty"`
+	PublicShares [][]byte `protobuf:"bytes,10,rep,name=public_shares,json=publicShares,proto3" json:"public_shares,omitempty"`
+	// sender's identity signature over the message's canonical encoding, see pool-chain/auth.go
+	Signature []byte `protobuf:"bytes,11,opt,name=signature,proto3" json:"signature,omitempty"`
+	// remaining gossip hops, relays decrement it so it's not signed
+	Ttl uint32 `protobuf:"varint,12,opt,name=ttl,proto3" json:"ttl,omitempty"`
+}
+
+func (x *DKGMessage) Reset() {
+	*x = DKGMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dkg_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DKGMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DKGMessage) ProtoMessage() {}
+
+func (x *DKGMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_dkg_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DKGMessage.ProtoReflect.Descriptor instead.
+func (*DKGMessage) Descriptor() ([]byte, []int) {
+	return file_dkg_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DKGMessage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DKGMessage) GetType() DKGMessageType {
+	if x != nil {
+		return x.Type
+	}
+	return DKGMessageType_DEAL_COMMITMENT
+}
+
+func (x *DKGMessage) GetFromParticipant() *Participant {
+	if x != nil {
+		return x.FromParticipant
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetToParticipant() *Participant {
+	if x != nil {
+		return x.ToParticipant
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetPoolId() uint32 {
+	if x != nil {
+		return x.PoolId
+	}
+	return 0
+}
+
+func (x *DKGMessage) GetCommitments() [][]byte {
+	if x != nil {
+		return x.Commitments
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetShare() []byte {
+	if x != nil {
+		return x.Share
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetBlindingShare() []byte {
+	if x != nil {
+		return x.BlindingShare
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetGroupPk() []byte {
+	if x != nil {
+		return x.GroupPk
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetPublicShares() [][]byte {
+	if x != nil {
+		return x.PublicShares
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *DKGMessage) GetTtl() uint32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+var File_dkg_proto protoreflect.FileDescriptor
+
+var file_dkg_proto_rawDesc = []byte{
+	0x0a, 0x09, 0x64, 0x6b, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x76, 0x31, 0x1a,
+	0x11, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x1a, 0x0e, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x61,
+	0x6e, 0x6e, 0x6f, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x22, 0xa0, 0x03, 0x0a, 0x0a, 0x44, 0x4b, 0x47, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x26, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x4b, 0x47, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x3a, 0x0a, 0x10, 0x66, 0x72, 0x6f, 0x6d, 0x5f,
+	0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0f, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61,
+	0x6e, 0x74, 0x52, 0x0f, 0x66, 0x72, 0x6f, 0x6d, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70,
+	0x61, 0x6e, 0x74, 0x12, 0x36, 0x0a, 0x0e, 0x74, 0x6f, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63,
+	0x69, 0x70, 0x61, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x76, 0x31,
+	0x2e, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x52, 0x0d, 0x74, 0x6f,
+	0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x70,
+	0x6f, 0x6f, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x70, 0x6f,
+	0x6f, 0x6c, 0x49, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x69,
+	0x74, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x65, 0x12, 0x25, 0x0a, 0x0e,
+	0x62, 0x6c, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x5f, 0x73, 0x68, 0x61, 0x72, 0x65, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x62, 0x6c, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x53, 0x68,
+	0x61, 0x72, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x70, 0x6b, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x50, 0x6b, 0x12, 0x23,
+	0x0a, 0x0d, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x73, 0x68, 0x61, 0x72, 0x65, 0x73, 0x18,
+	0x0a, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0c, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x53, 0x68, 0x61,
+	0x72, 0x65, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65,
+	0x18, 0x0b, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03,
+	0x74, 0x74, 0x6c, 0x2a, 0xac, 0x01, 0x0a, 0x0e, 0x44, 0x4b, 0x47, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x13, 0x0a, 0x0f, 0x44, 0x45, 0x41, 0x4c, 0x5f, 0x43,
+	0x4f, 0x4d, 0x4d, 0x49, 0x54, 0x4d, 0x45, 0x4e, 0x54, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x44,
+	0x45, 0x41, 0x4c, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x4f, 0x4d, 0x50, 0x4c, 0x41, 0x49,
+	0x4e, 0x54, 0x10, 0x02, 0x12, 0x11, 0x0a, 0x0d, 0x4a, 0x55, 0x53, 0x54, 0x49, 0x46, 0x49, 0x43,
+	0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x03, 0x12, 0x17, 0x0a, 0x13, 0x50, 0x55, 0x42, 0x4c, 0x49,
+	0x43, 0x5f, 0x43, 0x4f, 0x45, 0x46, 0x46, 0x49, 0x43, 0x49, 0x45, 0x4e, 0x54, 0x53, 0x10, 0x04,
+	0x12, 0x18, 0x0a, 0x14, 0x45, 0x58, 0x54, 0x52, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x43,
+	0x4f, 0x4d, 0x50, 0x4c, 0x41, 0x49, 0x4e, 0x54, 0x10, 0x05, 0x12, 0x18, 0x0a, 0x14, 0x52, 0x45,
+	0x43, 0x4f, 0x4e, 0x53, 0x54, 0x52, 0x55, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x53, 0x48, 0x41,
+	0x52, 0x45, 0x10, 0x06, 0x12, 0x0c, 0x0a, 0x08, 0x47, 0x52, 0x4f, 0x55, 0x50, 0x5f, 0x50, 0x4b,
+	0x10, 0x07, 0x32, 0x57, 0x0a, 0x0a, 0x44, 0x4b, 0x47, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x12, 0x49, 0x0a, 0x0a, 0x4e, 0x65, 0x77, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x0e,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x4b, 0x47, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x12,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x17, 0x82, 0xd3, 0xe4, 0x93, 0x02, 0x11, 0x12, 0x0f, 0x2f, 0x76, 0x31, 0x2f,
+	0x64, 0x6b, 0x67, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x42, 0x10, 0x5a, 0x0e, 0x2f,
+	0x70, 0x6f, 0x6f, 0x6c, 0x2d, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_dkg_proto_rawDescOnce sync.Once
+	file_dkg_proto_rawDescData = file_dkg_proto_rawDesc
+)
+
+func file_dkg_proto_rawDescGZIP() []byte {
+	file_dkg_proto_rawDescOnce.Do(func() {
+		file_dkg_proto_rawDescData = protoimpl.X.CompressGZIP(file_dkg_proto_rawDescData)
+	})
+	return file_dkg_proto_rawDescData
+}
+
+var file_dkg_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_dkg_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_dkg_proto_goTypes = []interface{}{
+	(DKGMessageType)(0),    // 0: v1.DKGMessageType
+	(*DKGMessage)(nil),     // 1: v1.DKGMessage
+	(*Participant)(nil),    // 2: v1.Participant
+	(*StatusResponse)(nil), // 3: v1.StatusResponse
+}
+var file_dkg_proto_depIdxs = []int32{
+	0, // 0: v1.DKGMessage.type:type_name -> v1.DKGMessageType
+	2, // 1: v1.DKGMessage.from_participant:type_name -> v1.Participant
+	2, // 2: v1.DKGMessage.to_participant:type_name -> v1.Participant
+	1, // 3: v1.DKGService.NewMessage:input_type -> v1.DKGMessage
+	3, // 4: v1.DKGService.NewMessage:output_type -> v1.StatusResponse
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_dkg_proto_init() }
+func file_dkg_proto_init() {
+	if File_dkg_proto != nil {
+		return
+	}
+	file_participant_proto_init()
+	file_response_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_dkg_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DKGMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_dkg_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dkg_proto_goTypes,
+		DependencyIndexes: file_dkg_proto_depIdxs,
+		EnumInfos:         file_dkg_proto_enumTypes,
+		MessageInfos:      file_dkg_proto_msgTypes,
+	}.Build()
+	File_dkg_proto = out.File
+	file_dkg_proto_rawDesc = nil
+	file_dkg_proto_goTypes = nil
+	file_dkg_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// DKGServiceClient is the client API for DKGService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type DKGServiceClient interface {
+	NewMessage(ctx context.Context, in *DKGMessage, opts ...grpc.CallOption) (*StatusResponse, error)
+}
+
+type dKGServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDKGServiceClient(cc grpc.ClientConnInterface) DKGServiceClient {
+	return &dKGServiceClient{cc}
+}
+
+func (c *dKGServiceClient) NewMessage(ctx context.Context, in *DKGMessage, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/v1.DKGService/NewMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DKGServiceServer is the server API for DKGService service.
+type DKGServiceServer interface {
+	NewMessage(context.Context, *DKGMessage) (*StatusResponse, error)
+}
+
+// UnimplementedDKGServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedDKGServiceServer struct {
+}
+
+func (*UnimplementedDKGServiceServer) NewMessage(context.Context, *DKGMessage) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NewMessage not implemented")
+}
+
+func RegisterDKGServiceServer(s *grpc.Server, srv DKGServiceServer) {
+	s.RegisterService(&_DKGService_serviceDesc, srv)
+}
+
+func _DKGService_NewMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DKGMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DKGServiceServer).NewMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.DKGService/NewMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DKGServiceServer).NewMessage(ctx, req.(*DKGMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _DKGService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "v1.DKGService",
+	HandlerType: (*DKGServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "NewMessage",
+			Handler:    _DKGService_NewMessage_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dkg.proto",
+}
@@ -7,6 +7,8 @@ import (
 
 type Peer struct {
 	Id uuid.UUID
+	// network address (host:port) of remote peers, empty for in process peers
+	Address string
 	receiver P2PReceiver
 }
 
@@ -16,6 +18,13 @@ func NewPeer() *Peer {
 	}
 }
 
+func NewRemotePeer(address string) *Peer {
+	return &Peer{
+		Id:       uuid.New(),
+		Address:  address,
+	}
+}
+
 func (peer *Peer) RegisterReceiver(r P2PReceiver) {
 	peer.receiver = r
 }
@@ -26,4 +35,16 @@ func (peer *Peer) ReceiveShare(share *pb.ShareDistribution) {
 
 func (peer *Peer) ReceiveSignature(sig *pb.SignatureDistribution) {
 	peer.receiver.ReceiveSignature(sig)
+}
+
+func (peer *Peer) ReceiveDKGMessage(msg *pb.DKGMessage) {
+	peer.receiver.ReceiveDKGMessage(msg)
+}
+
+func (peer *Peer) ReceiveConsensusMessage(msg *pb.ConsensusMessage) {
+	peer.receiver.ReceiveConsensusMessage(msg)
+}
+
+func (peer *Peer) ReceiveRegistryRequest(req *pb.RegistryRequest) {
+	peer.receiver.ReceiveRegistryRequest(req)
 }
\ No newline at end of file
@@ -74,4 +74,71 @@ func (p *SimpleP2PNetwork) BroadcastSignature(sig *pb.SignatureDistribution) err
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (p *SimpleP2PNetwork) BroadcastDKGMessage(msg *pb.DKGMessage) error {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	for _, p := range p.peers {
+		p.ReceiveDKGMessage(msg)
+	}
+
+	return nil
+}
+
+func (p *SimpleP2PNetwork) BroadcastConsensusMessage(msg *pb.ConsensusMessage) error {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	for _, p := range p.peers {
+		p.ReceiveConsensusMessage(msg)
+	}
+
+	return nil
+}
+
+func (p *SimpleP2PNetwork) BroadcastRegistryRequest(req *pb.RegistryRequest) error {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	for _, p := range p.peers {
+		p.ReceiveRegistryRequest(req)
+	}
+
+	return nil
+}
+
+func (p *SimpleP2PNetwork) Peers() []*net.Peer {
+	p.peersLock.Lock()
+	defer p.peersLock.Unlock()
+
+	ret := make([]*net.Peer, len(p.peers))
+	copy(ret, p.peers)
+	return ret
+}
+
+func (p *SimpleP2PNetwork) SendShare(peer *net.Peer, share *pb.ShareDistribution) error {
+	peer.ReceiveShare(share)
+	return nil
+}
+
+func (p *SimpleP2PNetwork) SendSignature(peer *net.Peer, sig *pb.SignatureDistribution) error {
+	peer.ReceiveSignature(sig)
+	return nil
+}
+
+func (p *SimpleP2PNetwork) SendDKGMessage(peer *net.Peer, msg *pb.DKGMessage) error {
+	peer.ReceiveDKGMessage(msg)
+	return nil
+}
+
+func (p *SimpleP2PNetwork) SendConsensusMessage(peer *net.Peer, msg *pb.ConsensusMessage) error {
+	peer.ReceiveConsensusMessage(msg)
+	return nil
+}
+
+func (p *SimpleP2PNetwork) SendRegistryRequest(peer *net.Peer, req *pb.RegistryRequest) error {
+	peer.ReceiveRegistryRequest(req)
+	return nil
+}
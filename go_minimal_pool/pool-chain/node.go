@@ -1,12 +1,16 @@
 package pool_chain
 
 import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
 	net2 "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/simple_net"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"log"
 	"sync"
+	"time"
 )
 
 type PoolChainNode struct {
@@ -14,23 +18,50 @@ type PoolChainNode struct {
 	Net         net2.P2P
 	epochTicker *EpochTicker
 	Config      *net2.NetworkConfig
+	// reconstructed duty signatures are submitted to it if set, it then also assigns the pools' duties
+	Beacon BeaconClient
+	// agrees on every epoch's block, without it epochs aren't finalized
+	Consensus Consensus
+	// the state after the latest finalized block, replayed from State's stored blocks on first use
+	finalized *state.State
+	finalizedLock sync.Mutex
 
 	// just holds all messages for convenience
 	SharesPerEpoch map[shared.EpochNumber]map[string]*pb.ShareDistribution
 	sharesLock sync.Mutex
+	// redistribution commitments of every sender, regardless of the share's recipient
+	CommitmentsPerEpoch map[shared.EpochNumber]map[shared.ParticipantId][][]byte
+	// the first signed share of every sender, a conflicting one is evidence (see evidence.go)
+	firstShares map[shared.EpochNumber]map[shared.ParticipantId]*pb.ShareDistribution
 	SigsPerEpoch map[shared.EpochNumber]map[string]*pb.SignatureDistribution
 	sigsLock sync.Mutex
+	// genesis DKG messages per pool and the group pk votes (pool -> vote -> voters)
+	DKGMessages map[shared.PoolId]map[string]*pb.DKGMessage
+	dkgVotes map[shared.PoolId]map[string]map[shared.ParticipantId]bool
+	dkgLock sync.Mutex
+	// evidence not included in a finalized block yet, one per offender
+	evidence map[shared.ParticipantId]*state.Evidence
+	evidenceLock sync.Mutex
+	// registry requests not included in a finalized block yet, by id
+	requests map[string]*pb.RegistryRequest
+	requestsLock sync.Mutex
 	// messages will be saved only for the specific Id
 	FilterId shared.ParticipantId
+	// decrypts shares addressed to FilterId
+	encryptionSk *bls.Fr
 
 	Killed 		chan bool
 }
 
 func NewTestChainNode() *PoolChainNode {
 	config := net2.NewTestNetworkConfig()
-	state := state.NewInMemoryState(config.GenesisSeed)
+	return NewChainNode(config, state.NewInMemoryState(config), simple_net.NewSimpleP2P())
+}
+
+// state could be persistent (state.NewPersistentState) for the node to survive a restart, net could be in
+// process (simple_net) or over the network (grpc_net)
+func NewChainNode(config *net2.NetworkConfig, state *state.State, net net2.P2P) *PoolChainNode {
 	ticker := NewEpochTicker(config.EpochSpanSec)
-	net := simple_net.NewSimpleP2P()
 
 	ret := &PoolChainNode{
 		State:          state,
@@ -39,7 +70,11 @@ func NewTestChainNode() *PoolChainNode {
 		Config:         config,
 		Killed:         make(chan bool),
 		SharesPerEpoch: make(map[uint32]map[string]*pb.ShareDistribution),
+		CommitmentsPerEpoch: make(map[uint32]map[shared.ParticipantId][][]byte),
+		firstShares: make(map[uint32]map[shared.ParticipantId]*pb.ShareDistribution),
 		SigsPerEpoch: make(map[uint32]map[string]*pb.SignatureDistribution),
+		DKGMessages: make(map[shared.PoolId]map[string]*pb.DKGMessage),
+		dkgVotes: make(map[shared.PoolId]map[string]map[shared.ParticipantId]bool),
 	}
 
 	net.RegisterReceiver(ret)
@@ -47,6 +82,10 @@ func NewTestChainNode() *PoolChainNode {
 	return ret
 }
 
+func (p *PoolChainNode) SetEncryptionKey(sk *bls.Fr) {
+	p.encryptionSk = sk
+}
+
 func (p *PoolChainNode) EpochC () <- chan shared.EpochNumber {
 	return p.epochTicker.C()
 }
@@ -55,23 +94,78 @@ func (p *PoolChainNode) GetCurrentEpoch() *state.Epoch {
 	return p.State.GetEpoch(p.epochTicker.CurrentEpochNumber())
 }
 
+func (p *PoolChainNode) CurrentEpochNumber() shared.EpochNumber {
+	return p.epochTicker.CurrentEpochNumber()
+}
+
 func (p *PoolChainNode) StartEpochProcessing() {
 	p.epochTicker.Start()
 }
 
+// see EpochTicker.StartAt
+func (p *PoolChainNode) StartEpochProcessingAt(genesis time.Time) {
+	p.epochTicker.StartAt(genesis)
+}
+
+func (p *PoolChainNode) StopEpochProcessing() {
+	p.epochTicker.Stop()
+}
+
 func (p *PoolChainNode) ReceiveShare(share *pb.ShareDistribution) {
 	p.sharesLock.Lock()
 	defer p.sharesLock.Unlock()
 
+	err := p.verifyMessageSignature(share.FromParticipant, ShareSigningRoot(share), share.Signature)
+	if err != nil {
+		log.Printf("P %d, dropping share %s: %s", p.FilterId, share.Id, err.Error())
+		return
+	}
+	// the signed encoding allows a missing participant, a share must name its recipient
+	if share.ToParticipant == nil {
+		log.Printf("P %d, dropping share %s: missing recipient", p.FilterId, share.Id)
+		return
+	}
+	threshold, err := shareThreshold(p.State, share)
+	if err != nil {
+		log.Printf("P %d, dropping share %s: %s", p.FilterId, share.Id, err.Error())
+		return
+	}
+
 	if p.SharesPerEpoch[share.Epoch] == nil {
 		p.SharesPerEpoch[share.Epoch] = make(map[string]*pb.ShareDistribution)
 	}
 
+	// commitments are public, keep the first ones seen from every sender
+	if p.CommitmentsPerEpoch[share.Epoch] == nil {
+		p.CommitmentsPerEpoch[share.Epoch] = make(map[shared.ParticipantId][][]byte)
+	}
+	if p.CommitmentsPerEpoch[share.Epoch][share.FromParticipant.Id] == nil {
+		p.CommitmentsPerEpoch[share.Epoch][share.FromParticipant.Id] = share.Commitments
+	}
+	p.checkDoubleShare(share)
+
+	// no recipient can decrypt a malformed share, every node reports it
+	if err := crypto.CheckCiphertext(share.Share); err != nil {
+		log.Printf("P %d, malformed share from %d: %s", p.FilterId, share.FromParticipant.Id, err.Error())
+		p.reportBadShare(share)
+		return
+	}
+
 	// filter only relevant messages
 	if share.ToParticipant.Id == p.FilterId {
 		// do not insert duplicates
 		if p.SharesPerEpoch[share.Epoch][share.Id] == nil {
-			p.SharesPerEpoch[share.Epoch][share.Id] = share
+			decrypted, err := p.decryptShare(share)
+			if err != nil {
+				log.Printf("P %d, could not decrypt share from %d: %s", p.FilterId, share.FromParticipant.Id, err.Error())
+				p.reportBadShare(share)
+				return
+			}
+			if !shareMatchesCommitments(decrypted, threshold) {
+				log.Printf("P %d, share from %d does not match its commitments", p.FilterId, share.FromParticipant.Id)
+				p.reportBadShare(share)
+			}
+			p.SharesPerEpoch[share.Epoch][share.Id] = decrypted
 		}
 	}
 }
@@ -80,12 +174,57 @@ func (p *PoolChainNode) ReceiveSignature(sig *pb.SignatureDistribution) {
 	p.sigsLock.Lock()
 	defer p.sigsLock.Unlock()
 
+	err := p.verifyMessageSignature(sig.FromParticipant, SignatureSigningRoot(sig), sig.Signature)
+	if err != nil {
+		log.Printf("P %d, dropping signature %s: %s", p.FilterId, sig.Id, err.Error())
+		return
+	}
+
 	if p.SigsPerEpoch[sig.Epoch] == nil {
 		p.SigsPerEpoch[sig.Epoch] = make(map[string]*pb.SignatureDistribution)
 	}
+	p.checkDoubleSignature(sig)
 
 	// do not insert duplicates
 	if p.SigsPerEpoch[sig.Epoch][sig.Id] == nil {
 		p.SigsPerEpoch[sig.Epoch][sig.Id] = sig
 	}
-}
\ No newline at end of file
+}
+
+// a partial sig without a sig is its sender's refusal to sign the duty, e.g. conflicting data its slashing protection
+// refused. It's owed no partial sig for the duty and isn't a double signature of it.
+func IsRefusal(sig *pb.SignatureDistribution) bool {
+	return len(sig.Sig) == 0
+}
+
+// returns the epoch's shares addressed to FilterId
+func (p *PoolChainNode) EpochShares(epoch shared.EpochNumber) []*pb.ShareDistribution {
+	p.sharesLock.Lock()
+	defer p.sharesLock.Unlock()
+
+	ret := make([]*pb.ShareDistribution, 0)
+	for _, share := range p.SharesPerEpoch[epoch] {
+		ret = append(ret, share)
+	}
+	return ret
+}
+
+// returns the dealer's redistribution commitments of the epoch, nil if none arrived
+func (p *PoolChainNode) DealerCommitments(epoch shared.EpochNumber, dealer shared.ParticipantId) [][]byte {
+	p.sharesLock.Lock()
+	defer p.sharesLock.Unlock()
+
+	return p.CommitmentsPerEpoch[epoch][dealer]
+}
+
+// returns all of the epoch's partial sigs
+func (p *PoolChainNode) EpochSigs(epoch shared.EpochNumber) []*pb.SignatureDistribution {
+	p.sigsLock.Lock()
+	defer p.sigsLock.Unlock()
+
+	ret := make([]*pb.SignatureDistribution, 0)
+	for _, sig := range p.SigsPerEpoch[epoch] {
+		ret = append(ret, sig)
+	}
+	return ret
+}
@@ -0,0 +1,100 @@
+package pool_chain
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"log"
+)
+
+/**
+	Registry requests (see state/requests.go) are gossiped to every node and kept until a finalized block includes
+	them or they expire, the epoch's proposer includes the pending requests that apply to its state. A join request
+	is signed by the identity key it registers, other requests by their registered sender that isn't slashed.
+ */
+
+// verifies the request is signed by its sender, s is the state the request's block is applied to
+func VerifyRegistryRequest(s *state.State, req *pb.RegistryRequest) error {
+	if req.FromParticipant == nil {
+		return fmt.Errorf("missing sender")
+	}
+	root := RegistryRequestSigningRoot(req)
+	if req.Type == pb.RegistryRequestType_JOIN {
+		_, identityPk, err := state.RequestKeys(req)
+		if err != nil {
+			return err
+		}
+		return verifyIdentitySignature(identityPk, root, req.Signature)
+	}
+
+	sender := s.GetParticipant(req.FromParticipant.Id)
+	if sender == nil || sender.IdentityPk == nil {
+		return fmt.Errorf("unknown sender %d", req.FromParticipant.Id)
+	}
+	if sender.Slashed {
+		return fmt.Errorf("sender %d is slashed", sender.Id)
+	}
+	return verifyIdentitySignature(sender.IdentityPk, root, req.Signature)
+}
+
+func (p *PoolChainNode) ReceiveRegistryRequest(req *pb.RegistryRequest) {
+	err := VerifyRegistryRequest(p.State, req)
+	if err != nil {
+		log.Printf("P %d, dropping registry request %s: %s", p.FilterId, req.Id, err.Error())
+		return
+	}
+
+	p.requestsLock.Lock()
+	defer p.requestsLock.Unlock()
+	if p.requests == nil {
+		p.requests = make(map[string]*pb.RegistryRequest)
+	}
+	p.requests[req.Id] = req
+}
+
+// the pending requests that apply to s in epoch number's block, in the order they're applied (state.SortRequests).
+// Requests that expired or that s's block included are dropped.
+func (p *PoolChainNode) PendingRequests(s *state.State, number shared.EpochNumber) []*pb.RegistryRequest {
+	p.requestsLock.Lock()
+	defer p.requestsLock.Unlock()
+
+	candidates := make([]*pb.RegistryRequest, 0)
+	for id, req := range p.requests {
+		if req.Epoch + 1 < number || (s.LatestBlock() != nil && s.LatestBlock().Body.HasRequest(id)) {
+			delete(p.requests, id)
+			continue
+		}
+		candidates = append(candidates, req)
+	}
+	state.SortRequests(candidates)
+
+	// a request can depend on an earlier one (e.g. a deposit to a created pool) or conflict with it
+	trial := s.Copy()
+	ret := make([]*pb.RegistryRequest, 0)
+	for _, req := range candidates {
+		if req.Epoch > number {
+			continue
+		}
+		err := VerifyRegistryRequest(s, req)
+		if err == nil {
+			err = trial.ProcessRegistryRequest(number, req)
+		}
+		if err != nil {
+			log.Printf("P %d, not including %s request %s: %s", p.FilterId, req.Type.String(), req.Id, err.Error())
+			continue
+		}
+		ret = append(ret, req)
+	}
+	return ret
+}
+
+// drops the requests a finalized block included
+func (p *PoolChainNode) dropRequests(included []*pb.RegistryRequest) {
+	p.requestsLock.Lock()
+	defer p.requestsLock.Unlock()
+
+	for _, req := range included {
+		delete(p.requests, req.Id)
+	}
+}
@@ -0,0 +1,81 @@
+package pool_chain
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/state"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRegistryRequests(t *testing.T) {
+	crypto.InitBLS()
+
+	node := NewTestChainNode()
+	sk1 := registerIdentity(t, node, 1)
+	sk2 := registerIdentity(t, node, 2)
+
+	newRequest := func(id string, reqType pb.RegistryRequestType, from uint32, pool uint32, sk *bls.SecretKey) *pb.RegistryRequest {
+		ret := &pb.RegistryRequest{Id: id, Type: reqType, FromParticipant: &pb.Participant{Id: from}, PoolId: pool}
+		if reqType == pb.RegistryRequestType_DEPOSIT {
+			ret.Gwei = state.PoolStake
+		}
+		ret.Signature = sk.SignByte(RegistryRequestSigningRoot(ret)).Serialize()
+		return ret
+	}
+	// a joiner isn't registered, it signs with the identity key it registers
+	newJoin := func(id string, identitySk *bls.SecretKey, signer *bls.SecretKey) *pb.RegistryRequest {
+		_, encryptionPk := crypto.NewEncryptionKey()
+		ret := &pb.RegistryRequest{
+			Id: id,
+			Type: pb.RegistryRequestType_JOIN,
+			FromParticipant: &pb.Participant{Id: 7},
+			EncryptionPk: encryptionPk.Serialize(),
+			IdentityPk: identitySk.GetPublicKey().Serialize(),
+		}
+		ret.Signature = signer.SignByte(RegistryRequestSigningRoot(ret)).Serialize()
+		return ret
+	}
+	joinerSk := &bls.SecretKey{}
+	joinerSk.SetByCSPRNG()
+
+	join := newJoin("join", joinerSk, joinerSk)
+	require.NoError(t, VerifyRegistryRequest(node.State, join))
+	require.Error(t, VerifyRegistryRequest(node.State, newJoin("forged join", joinerSk, sk1)))
+	deposit := newRequest("deposit", pb.RegistryRequestType_DEPOSIT, 1, 3, sk1)
+	require.NoError(t, VerifyRegistryRequest(node.State, deposit))
+	// another sender's, a changed amount or an unknown sender
+	require.Error(t, VerifyRegistryRequest(node.State, newRequest("exit", pb.RegistryRequestType_EXIT, 2, 0, sk1)))
+	changed := newRequest("changed", pb.RegistryRequestType_DEPOSIT, 1, 3, sk1)
+	changed.Gwei++
+	require.Error(t, VerifyRegistryRequest(node.State, changed))
+	require.Error(t, VerifyRegistryRequest(node.State, newRequest("unknown", pb.RegistryRequestType_EXIT, 9, 0, sk1)))
+
+	// pending requests are ordered so they apply, a pool is created before the deposit to it. Unsigned ones are
+	// dropped on receipt, ones that don't apply aren't included.
+	node.ReceiveRegistryRequest(deposit)
+	node.ReceiveRegistryRequest(newRequest("create", pb.RegistryRequestType_CREATE_POOL, 2, 3, sk2))
+	node.ReceiveRegistryRequest(join)
+	node.ReceiveRegistryRequest(newRequest("forged", pb.RegistryRequestType_EXIT, 2, 0, sk1))
+	node.ReceiveRegistryRequest(newRequest("taken", pb.RegistryRequestType_CREATE_POOL, 1, 1, sk1))
+	pending := node.PendingRequests(node.State, 0)
+	require.Len(t, pending, 3)
+	require.Equal(t, []string{"join", "create", "deposit"}, []string{pending[0].Id, pending[1].Id, pending[2].Id})
+	require.Nil(t, node.State.GetPool(3))
+	require.Len(t, node.PendingRequests(node.State, 1), 3)
+
+	// a slashed participant's requests aren't included
+	slashed := *node.State.GetParticipant(2)
+	slashed.Slashed = true
+	require.NoError(t, node.State.SaveParticipant(&slashed))
+	require.Len(t, node.PendingRequests(node.State, 0), 1)
+
+	// included by a finalized block or expired
+	node.dropRequests([]*pb.RegistryRequest{join})
+	require.Len(t, node.PendingRequests(node.State, 1), 0)
+	require.Len(t, node.PendingRequests(node.State, 2), 0)
+	slashed.Slashed = false
+	require.NoError(t, node.State.SaveParticipant(&slashed))
+	require.Len(t, node.PendingRequests(node.State, 0), 0)
+}
@@ -0,0 +1,204 @@
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"sort"
+)
+
+/**
+	The pool chain, a block per epoch. An epoch's block is agreed on by the epoch's participants (see
+	pool-chain/consensus) once the epoch ended and links to the previous epoch's block by its root, block 0's parent
+	is the zero root.
+	The body holds the signed registry requests (see requests.go) and DKG results of the epoch, the participants
+	disqualified for invalid partial signatures, every pool's reconstructed duty signatures and the
+	participants' contributions the balances are updated with (see rewards.go) and the evidence misbehaving
+	participants are slashed with (see slashing.go).
+	ProcessBlock applies a block to its parent's state, replaying the blocks on the genesis state (State.Genesis)
+	gives every node the same network state.
+
+	block v4:
+		version | parent root (32) | epoch | proposer | state root (32) | requests (see requests.go) |
+		pool keys (pool id | size | pk) | disqualified (id) | signatures (pool id | signing root (32) | G2) |
+		contributions (id | share (1) | signatures (1)) | evidence (see slashing.go)
+	v1 blocks had no contributions, v2 blocks no evidence and v3 blocks unsigned registry operations instead of
+	requests, a block's root is its encoding's so they can't be decoded as v4 ones.
+ */
+
+const blockEncodingVersion = 4
+
+type Block struct {
+	ParentRoot eth2.Root
+	Epoch shared.EpochNumber
+	Proposer shared.ParticipantId
+	Body *BlockBody
+	// the hash tree root of the state after the block (State.HashTreeRoot)
+	StateRoot eth2.Root
+}
+
+// a pool's DKG result
+type PoolKey struct {
+	PoolId shared.PoolId
+	Size shared.PoolSize
+	Pk *bls.PublicKey
+}
+
+// a pool's reconstructed duty signature
+type PoolSignature struct {
+	PoolId shared.PoolId
+	SigningRoot eth2.Root
+	Sig *bls.G2
+}
+
+type BlockBody struct {
+	// signed registry requests, applied in the listed order (see requests.go)
+	Requests []*pb.RegistryRequest
+	PoolKeys []*PoolKey
+	// participants that sent invalid partial signatures in the epoch, only recorded
+	Disqualified []shared.ParticipantId
+	// sorted by pool and signing root, a liquidating pool's voluntary exit signature is recorded on the pool
+	Signatures []*PoolSignature
+	// the epoch participants' contributions as seen by the proposer, sorted by participant
+	Contributions []*Contribution
+	// evidence of misbehaving participants, at most one per offender
+	Evidence []*Evidence
+}
+
+func NewBlockBody() *BlockBody {
+	return &BlockBody{
+		Requests: make([]*pb.RegistryRequest, 0),
+		PoolKeys: make([]*PoolKey, 0),
+		Disqualified: make([]shared.ParticipantId, 0),
+		Signatures: make([]*PoolSignature, 0),
+		Contributions: make([]*Contribution, 0),
+		Evidence: make([]*Evidence, 0),
+	}
+}
+
+func (b *BlockBody) SortSignatures() {
+	sort.Slice(b.Signatures, func(i, j int) bool {
+		if b.Signatures[i].PoolId != b.Signatures[j].PoolId {
+			return b.Signatures[i].PoolId < b.Signatures[j].PoolId
+		}
+		return bytes.Compare(b.Signatures[i].SigningRoot[:], b.Signatures[j].SigningRoot[:]) < 0
+	})
+}
+
+// sha256 of the block's encoding, the next block's parent root
+func BlockRoot(block *Block) eth2.Root {
+	return sha256.Sum256(EncodeBlock(block))
+}
+
+func EncodeBlock(block *Block) []byte {
+	w := &encodingWriter{}
+	w.byte(blockEncodingVersion)
+	w.buf.Write(block.ParentRoot[:])
+	w.uint32(block.Epoch)
+	w.uint32(block.Proposer)
+	w.buf.Write(block.StateRoot[:])
+
+	body := block.Body
+	w.uint32(uint32(len(body.Requests)))
+	for _, req := range body.Requests {
+		w.request(req)
+	}
+	w.uint32(uint32(len(body.PoolKeys)))
+	for _, key := range body.PoolKeys {
+		w.uint32(key.PoolId)
+		w.uint32(key.Size)
+		w.buf.Write(key.Pk.Serialize())
+	}
+	w.ids(body.Disqualified)
+	w.uint32(uint32(len(body.Signatures)))
+	for _, sig := range body.Signatures {
+		w.uint32(sig.PoolId)
+		w.buf.Write(sig.SigningRoot[:])
+		w.buf.Write(sig.Sig.Serialize())
+	}
+	w.uint32(uint32(len(body.Contributions)))
+	for _, c := range body.Contributions {
+		w.uint32(c.ParticipantId)
+		w.byte(byte(c.Share))
+		w.byte(byte(c.Signatures))
+	}
+	w.uint32(uint32(len(body.Evidence)))
+	for _, e := range body.Evidence {
+		w.evidence(e)
+	}
+	return w.buf.Bytes()
+}
+
+func DecodeBlock(data []byte) (*Block, error) {
+	r := &encodingReader{data: data}
+	version := r.byte()
+	if r.err == nil && version != blockEncodingVersion {
+		return nil, fmt.Errorf("unknown block encoding version %d", version)
+	}
+
+	ret := &Block{Body: NewBlockBody()}
+	copy(ret.ParentRoot[:], r.next(32))
+	ret.Epoch = r.uint32()
+	ret.Proposer = r.uint32()
+	copy(ret.StateRoot[:], r.next(32))
+
+	body := ret.Body
+	cnt := r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		body.Requests = append(body.Requests, r.request())
+	}
+	cnt = r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		key := &PoolKey{PoolId: r.uint32(), Size: r.uint32(), Pk: &bls.PublicKey{}}
+		r.deserialize(key.Pk.Deserialize, 48)
+		body.PoolKeys = append(body.PoolKeys, key)
+	}
+	body.Disqualified = r.ids()
+	cnt = r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		sig := &PoolSignature{PoolId: r.uint32(), Sig: &bls.G2{}}
+		copy(sig.SigningRoot[:], r.next(32))
+		r.deserialize(sig.Sig.Deserialize, 96)
+		body.Signatures = append(body.Signatures, sig)
+	}
+	cnt = r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		body.Contributions = append(body.Contributions, &Contribution{
+			ParticipantId: r.uint32(),
+			Share: ContributionStatus(r.byte()),
+			Signatures: ContributionStatus(r.byte()),
+		})
+	}
+	cnt = r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		body.Evidence = append(body.Evidence, r.evidence())
+	}
+
+	if r.err == nil && len(r.data) > 0 {
+		r.err = fmt.Errorf("%d trailing bytes", len(r.data))
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("could not decode block: %s", r.err.Error())
+	}
+	return ret, nil
+}
+
+func (w *encodingWriter) ids(ids []uint32) {
+	w.uint32(uint32(len(ids)))
+	for _, id := range ids {
+		w.uint32(id)
+	}
+}
+
+func (r *encodingReader) ids() []uint32 {
+	cnt := r.uint32()
+	ret := make([]uint32, 0)
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		ret = append(ret, r.uint32())
+	}
+	return ret
+}
@@ -0,0 +1,15 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+)
+
+// finalized blocks, one per epoch. Implemented by both dbs, the state's store is its db (see State.SaveBlock).
+type BlockStore interface {
+	SaveBlock(block *Block) error
+	// will return nil,nil if not found
+	GetBlock(epoch shared.EpochNumber) (*Block, error)
+	// will return nil,nil if not found
+	GetBlockByRoot(root eth2.Root) (*Block, error)
+}
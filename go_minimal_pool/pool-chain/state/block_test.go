@@ -0,0 +1,220 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlockEncoding(t *testing.T) {
+	crypto.InitBLS()
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	body := NewBlockBody()
+	body.Requests = []*pb.RegistryRequest{
+		testJoinRequest("join", 7, 3),
+		testRequest("exit", pb.RegistryRequestType_EXIT, 1, 2),
+		testPoolRequest("deposit", pb.RegistryRequestType_DEPOSIT, 3, PoolStake),
+	}
+	body.Requests[0].Signature = []byte{1, 2}
+	body.PoolKeys = append(body.PoolKeys, &PoolKey{PoolId: 1, Size: 3, Pk: sk.GetPublicKey()})
+	body.Signatures = []*PoolSignature{
+		{PoolId: 2, SigningRoot: eth2.Root{1}, Sig: bls.CastFromSign(sk.SignByte([]byte{1}))},
+		{PoolId: 1, SigningRoot: eth2.Root{2}, Sig: bls.CastFromSign(sk.SignByte([]byte{2}))},
+		{PoolId: 1, SigningRoot: eth2.Root{1}, Sig: bls.CastFromSign(sk.SignByte([]byte{3}))},
+	}
+	body.SortSignatures()
+	body.Contributions = []*Contribution{
+		{ParticipantId: 2, Share: ContributionValid, Signatures: ContributionInvalid},
+		{ParticipantId: 5, Share: ContributionNotDue, Signatures: ContributionMissing},
+	}
+	body.Evidence = append(body.Evidence, testDoubleSignature(2, 1), &Evidence{
+		Type: EvidenceBadShare,
+		Shares: []*pb.ShareDistribution{{
+			Id: "share",
+			FromParticipant: &pb.Participant{Id: 3},
+			ToParticipant: &pb.Participant{Id: 1},
+			Share: []byte{1, 2},
+			Commitments: [][]byte{{3}, {4, 5}},
+			PoolId: 2,
+			Epoch: 1,
+			Signature: []byte{6},
+		}},
+		DecryptionProof: []byte{7},
+	})
+	require.EqualValues(t, 1, body.Signatures[0].PoolId)
+	require.Equal(t, eth2.Root{1}, body.Signatures[0].SigningRoot)
+	block := &Block{ParentRoot: eth2.Root{9}, Epoch: 3, Proposer: 4, Body: body, StateRoot: eth2.Root{8}}
+
+	data := EncodeBlock(block)
+	decoded, err := DecodeBlock(data)
+	require.NoError(t, err)
+	require.Equal(t, block.ParentRoot, decoded.ParentRoot)
+	require.EqualValues(t, 3, decoded.Epoch)
+	require.EqualValues(t, 4, decoded.Proposer)
+	require.Equal(t, block.StateRoot, decoded.StateRoot)
+	require.Len(t, decoded.Body.Requests, 3)
+	require.Equal(t, pb.RegistryRequestType_JOIN, decoded.Body.Requests[0].Type)
+	require.EqualValues(t, 7, decoded.Body.Requests[0].FromParticipant.Id)
+	require.EqualValues(t, 3, decoded.Body.Requests[0].Epoch)
+	require.Equal(t, body.Requests[0].IdentityPk, decoded.Body.Requests[0].IdentityPk)
+	require.Equal(t, []byte{1, 2}, decoded.Body.Requests[0].Signature)
+	require.Equal(t, "exit", decoded.Body.Requests[1].Id)
+	require.EqualValues(t, 3, decoded.Body.Requests[2].PoolId)
+	require.Equal(t, PoolStake, decoded.Body.Requests[2].Gwei)
+	require.True(t, decoded.Body.PoolKeys[0].Pk.IsEqual(sk.GetPublicKey()))
+	require.Len(t, decoded.Body.Disqualified, 0)
+	require.Len(t, decoded.Body.Signatures, 3)
+	require.True(t, decoded.Body.Signatures[2].Sig.IsEqual(body.Signatures[2].Sig))
+	require.Equal(t, body.Contributions, decoded.Body.Contributions)
+	require.Len(t, decoded.Body.Evidence, 2)
+	require.Equal(t, EvidenceDoubleSignature, decoded.Body.Evidence[0].Type)
+	require.Equal(t, []byte{2}, decoded.Body.Evidence[0].Signatures[1].Sig)
+	require.Equal(t, body.Evidence[1].Shares[0].Commitments, decoded.Body.Evidence[1].Shares[0].Commitments)
+	require.EqualValues(t, 1, decoded.Body.Evidence[1].Shares[0].ToParticipant.Id)
+	require.Equal(t, []byte{7}, decoded.Body.Evidence[1].DecryptionProof)
+	// the encoding is canonical, every node decides on the same bytes and block root
+	require.Equal(t, data, EncodeBlock(decoded))
+	require.Equal(t, BlockRoot(block), BlockRoot(decoded))
+
+	// truncated, trailing bytes or unknown version
+	_, err = DecodeBlock(data[:len(data) - 1])
+	require.Error(t, err)
+	_, err = DecodeBlock(append(data, 0))
+	require.Error(t, err)
+	data[0] = 3
+	_, err = DecodeBlock(data)
+	require.Error(t, err)
+}
+
+// a block with the body's requests and results, state root included
+func testBlock(t *testing.T, parent *State, epoch shared.EpochNumber, body *BlockBody) *Block {
+	block := &Block{ParentRoot: parent.LatestBlockRoot(), Epoch: epoch, Proposer: 1, Body: body}
+	next, err := TransitionState(parent, block)
+	require.NoError(t, err)
+	block.StateRoot = next.HashTreeRoot()
+	return block
+}
+
+func TestProcessBlock(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+	genesis := s.Genesis()
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	require.NoError(t, s.SetPoolPk(1, 3, sk.GetPublicKey()))
+	_, identityPk := testParticipantKeys()
+	body := applyRequests(t, s, 0,
+		testJoinRequest("join", 7, 0),
+		testRequest("exit", pb.RegistryRequestType_EXIT, 1, 0),
+		testPoolRequest("create", pb.RegistryRequestType_CREATE_POOL, 3, 0),
+		testPoolRequest("deposit", pb.RegistryRequestType_DEPOSIT, 3, PoolStake),
+	)
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+	body.PoolKeys = append(body.PoolKeys, &PoolKey{PoolId: 1, Size: 3, Pk: sk.GetPublicKey()})
+	block := testBlock(t, genesis, 0, body)
+
+	next, err := ProcessBlock(genesis, block)
+	require.NoError(t, err)
+	// the same registry as the state the requests were applied to
+	for _, p := range s.Registry().Participants() {
+		expected, _ := EncodeParticipant(p)
+		actual, _ := EncodeParticipant(next.GetParticipant(p.Id))
+		require.Equal(t, expected, actual, "participant %d", p.Id)
+	}
+	for _, p := range s.Pools() {
+		expected, _ := EncodePool(p)
+		actual, _ := EncodePool(next.GetPool(p.Id))
+		require.Equal(t, expected, actual, "pool %d", p.Id)
+	}
+	require.Equal(t, BlockRoot(block), next.LatestBlockRoot())
+	// genesis didn't change
+	require.Nil(t, genesis.GetParticipant(7))
+	require.Equal(t, eth2.Root{}, genesis.LatestBlockRoot())
+
+	// wrong state root, parent or epoch
+	wrong := *block
+	wrong.StateRoot = eth2.Root{1}
+	_, err = ProcessBlock(genesis, &wrong)
+	require.Error(t, err)
+	_, err = ProcessBlock(next, block)
+	require.Error(t, err)
+	wrong = *block
+	wrong.Epoch = 1
+	_, err = ProcessBlock(genesis, &wrong)
+	require.Error(t, err)
+
+	// invalid requests or results
+	invalid := NewBlockBody()
+	invalid.Requests = []*pb.RegistryRequest{testRequest("exit", pb.RegistryRequestType_EXIT, 9, 1)}
+	_, err = TransitionState(next, &Block{ParentRoot: next.LatestBlockRoot(), Epoch: 1, Body: invalid})
+	require.Error(t, err)
+	invalid = NewBlockBody()
+	invalid.PoolKeys = append(invalid.PoolKeys, &PoolKey{PoolId: 1, Size: 3, Pk: identityPk})
+	_, err = TransitionState(next, &Block{ParentRoot: next.LatestBlockRoot(), Epoch: 1, Body: invalid})
+	require.Error(t, err)
+	invalid = NewBlockBody()
+	invalid.Requests = []*pb.RegistryRequest{testPoolRequest("taken", pb.RegistryRequestType_CREATE_POOL, 3, 0)}
+	_, err = TransitionState(next, &Block{ParentRoot: next.LatestBlockRoot(), Epoch: 1, Body: invalid})
+	require.Error(t, err)
+}
+
+func TestReplayBlocks(t *testing.T) {
+	crypto.InitBLS()
+
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.db")
+	config := net.NewTestNetworkConfig()
+
+	s, err := NewPersistentState(path, config)
+	require.NoError(t, err)
+	newTestRegistryState(t, s)
+	genesis := s.Genesis()
+
+	body := NewBlockBody()
+	body.Requests = append(body.Requests, testJoinRequest("join", 7, 0))
+	block0 := testBlock(t, genesis, 0, body)
+	head, err := ProcessBlock(genesis, block0)
+	require.NoError(t, err)
+	body = NewBlockBody()
+	body.Contributions = append(body.Contributions, &Contribution{ParticipantId: 2, Share: ContributionValid, Signatures: ContributionValid})
+	block1 := testBlock(t, head, 1, body)
+	head, err = ProcessBlock(head, block1)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveBlock(block0))
+	require.NoError(t, s.SaveBlock(block1))
+	require.NoError(t, s.Close())
+
+	// restart, the blocks give the same state
+	s, err = NewPersistentState(path, config)
+	require.NoError(t, err)
+	defer s.Close()
+	stored, err := s.GetBlock(1)
+	require.NoError(t, err)
+	require.Equal(t, EncodeBlock(block1), EncodeBlock(stored))
+	stored, err = s.GetBlockByRoot(block1.ParentRoot)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, stored.Epoch)
+	stored, err = s.GetBlock(2)
+	require.NoError(t, err)
+	require.Nil(t, stored)
+
+	replayed, err := Replay(s.Genesis(), s)
+	require.NoError(t, err)
+	require.Equal(t, head.HashTreeRoot(), replayed.HashTreeRoot())
+	require.EqualValues(t, 2, replayed.GetParticipant(7).ActivationEpoch)
+	require.Equal(t, 2 * config.ContributionReward, replayed.GetParticipant(2).Balance)
+}
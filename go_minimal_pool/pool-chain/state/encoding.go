@@ -0,0 +1,353 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"sort"
+)
+
+/**
+	Versioned binary encoding of epochs, pools and participants for the persistent db.
+	Every value starts with a 1 byte version, integers are big endian, optional fields are prefixed with a 1 byte
+	presence flag and maps are encoded as a 4 bytes count followed by their entries sorted by key.
+
+	epoch v4:
+		version | number | seed (32) | public shares (id | G1) | bad signers (id) |
+		reconstructed sigs (signing root (32) | G2) | sig verified (1) | state root (32)
+	epoch v3 (decoding only) had no state root, it's left zero.
+	epoch v2 (decoding only) had a single, optional, reconstructed sig over a fixed message instead of the duties'
+	sigs, it's dropped. v1 also had the participant's share, in the clear, after the seed. Shares are now kept in
+	the ShareKeystore.
+	pool v2:
+		version | id | size | pk? | stake (8) | activation epoch | exit epoch | liquidation requested (1) | exit sig?
+	pool v1 (decoding only) had no lifecycle, it's decoded as a genesis pool.
+	participant v3:
+		version | id | encryption pk? | identity pk? | activation epoch | exit epoch | exit requested (1) |
+		balance (8) | slashed (1)
+	participant v2 (decoding only) wasn't slashed, v1 had no balance either, it's left zero.
+ */
+
+const (
+	epochEncodingVersion = 4
+	poolEncodingVersion = 2
+	participantEncodingVersion = 3
+)
+
+func EncodeEpoch(epoch *Epoch) ([]byte, error) {
+	w := &encodingWriter{}
+	w.byte(epochEncodingVersion)
+	w.uint32(epoch.Number)
+	w.buf.Write(epoch.epochSeed[:])
+
+	epoch.publicSharesLock.RLock()
+	defer epoch.publicSharesLock.RUnlock()
+	ids := make([]shared.ParticipantId, 0)
+	for id := range epoch.PublicShares {
+		ids = append(ids, id)
+	}
+	sortIds(ids)
+	w.uint32(uint32(len(ids)))
+	for _, id := range ids {
+		w.uint32(id)
+		w.buf.Write(epoch.PublicShares[id].Serialize())
+	}
+
+	ids = make([]shared.ParticipantId, 0)
+	for id, bad := range epoch.BadSigners {
+		if bad {
+			ids = append(ids, id)
+		}
+	}
+	sortIds(ids)
+	w.uint32(uint32(len(ids)))
+	for _, id := range ids {
+		w.uint32(id)
+	}
+
+	roots := make([]eth2.Root, 0)
+	for root := range epoch.ReconstructedSignatures {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(i, j int) bool { return bytes.Compare(roots[i][:], roots[j][:]) < 0 })
+	w.uint32(uint32(len(roots)))
+	for _, root := range roots {
+		w.buf.Write(root[:])
+		w.buf.Write(epoch.ReconstructedSignatures[root].Serialize())
+	}
+
+	if epoch.EpochSigVerified {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+	w.buf.Write(epoch.StateRoot[:])
+
+	return w.buf.Bytes(), nil
+}
+
+// the network config and registry aren't encoded, the decoded epoch belongs to their network
+func DecodeEpoch(data []byte, config *net.NetworkConfig, registry *Registry) (*Epoch, error) {
+	r := &encodingReader{data: data}
+	version := r.byte()
+	if r.err == nil && (version == 0 || version > epochEncodingVersion) {
+		return nil, fmt.Errorf("unknown epoch encoding version %d", version)
+	}
+
+	number := r.uint32()
+	var seed [32]byte
+	copy(seed[:], r.next(32))
+	ret := NewEpochInstance(number, seed, config, registry)
+
+	if version == 1 && r.byte() == 1 {
+		share := &bls.Fr{}
+		r.deserialize(share.Deserialize, 32)
+		ret.ParticipantShare = share
+	}
+
+	cnt := r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		id := r.uint32()
+		publicShare := &bls.G1{}
+		r.deserialize(publicShare.Deserialize, 48)
+		ret.PublicShares[id] = publicShare
+	}
+
+	cnt = r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		ret.BadSigners[r.uint32()] = true
+	}
+
+	if version < 3 {
+		if r.byte() == 1 {
+			r.next(96)
+		}
+	} else {
+		cnt = r.uint32()
+		for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+			var root eth2.Root
+			copy(root[:], r.next(32))
+			sig := &bls.G2{}
+			r.deserialize(sig.Deserialize, 96)
+			ret.ReconstructedSignatures[root] = sig
+		}
+	}
+
+	ret.EpochSigVerified = r.byte() == 1
+	if version >= 4 {
+		copy(ret.StateRoot[:], r.next(32))
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("could not decode epoch: %s", r.err.Error())
+	}
+	return ret, nil
+}
+
+func EncodePool(pool *Pool) ([]byte, error) {
+	w := &encodingWriter{}
+	w.byte(poolEncodingVersion)
+	w.uint32(pool.Id)
+	w.uint32(pool.Size)
+	if pool.Pk != nil {
+		w.byte(1)
+		w.buf.Write(pool.Pk.Serialize())
+	} else {
+		w.byte(0)
+	}
+	w.uint64(pool.Stake)
+	w.uint32(pool.ActivationEpoch)
+	w.uint32(pool.ExitEpoch)
+	if pool.LiquidationRequested {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+	if pool.ExitSignature != nil {
+		w.byte(1)
+		w.buf.Write(pool.ExitSignature.Serialize())
+	} else {
+		w.byte(0)
+	}
+	return w.buf.Bytes(), nil
+}
+
+func DecodePool(data []byte) (*Pool, error) {
+	r := &encodingReader{data: data}
+	version := r.byte()
+	if r.err == nil && version != poolEncodingVersion && version != 1 {
+		return nil, fmt.Errorf("unknown pool encoding version %d", version)
+	}
+
+	id := r.uint32()
+	size := r.uint32()
+	var pk *bls.PublicKey
+	if r.byte() == 1 {
+		pk = &bls.PublicKey{}
+		r.deserialize(pk.Deserialize, 48)
+	}
+	ret := NewPool(id, size, pk)
+	if version != 1 {
+		ret.Stake = r.uint64()
+		ret.ActivationEpoch = r.uint32()
+		ret.ExitEpoch = r.uint32()
+		ret.LiquidationRequested = r.byte() == 1
+		if r.byte() == 1 {
+			ret.ExitSignature = &bls.Sign{}
+			r.deserialize(ret.ExitSignature.Deserialize, 96)
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("could not decode pool: %s", r.err.Error())
+	}
+	return ret, nil
+}
+
+func EncodeParticipant(participant *Participant) ([]byte, error) {
+	w := &encodingWriter{}
+	w.byte(participantEncodingVersion)
+	w.uint32(participant.Id)
+	if participant.EncryptionPk != nil {
+		w.byte(1)
+		w.buf.Write(participant.EncryptionPk.Serialize())
+	} else {
+		w.byte(0)
+	}
+	if participant.IdentityPk != nil {
+		w.byte(1)
+		w.buf.Write(participant.IdentityPk.Serialize())
+	} else {
+		w.byte(0)
+	}
+	w.uint32(participant.ActivationEpoch)
+	w.uint32(participant.ExitEpoch)
+	if participant.ExitRequested {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+	w.uint64(participant.Balance)
+	if participant.Slashed {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+	return w.buf.Bytes(), nil
+}
+
+func DecodeParticipant(data []byte) (*Participant, error) {
+	r := &encodingReader{data: data}
+	version := r.byte()
+	if r.err == nil && (version == 0 || version > participantEncodingVersion) {
+		return nil, fmt.Errorf("unknown participant encoding version %d", version)
+	}
+
+	ret := &Participant{Id: r.uint32()}
+	if r.byte() == 1 {
+		ret.EncryptionPk = &bls.G1{}
+		r.deserialize(ret.EncryptionPk.Deserialize, 48)
+	}
+	if r.byte() == 1 {
+		ret.IdentityPk = &bls.PublicKey{}
+		r.deserialize(ret.IdentityPk.Deserialize, 48)
+	}
+	ret.ActivationEpoch = r.uint32()
+	ret.ExitEpoch = r.uint32()
+	ret.ExitRequested = r.byte() == 1
+	if version >= 2 {
+		ret.Balance = r.uint64()
+	}
+	if version >= 3 {
+		ret.Slashed = r.byte() == 1
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("could not decode participant: %s", r.err.Error())
+	}
+	return ret, nil
+}
+
+type encodingWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *encodingWriter) byte(b byte) {
+	w.buf.WriteByte(b)
+}
+
+func (w *encodingWriter) uint32(v uint32) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	w.buf.Write(b)
+}
+
+func (w *encodingWriter) uint64(v uint64) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	w.buf.Write(b)
+}
+
+// 4 bytes length | data
+func (w *encodingWriter) bytes(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.buf.Write(b)
+}
+
+// reads until the first error, every read after it returns zero values
+type encodingReader struct {
+	data []byte
+	err error
+}
+
+func (r *encodingReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.data) < n {
+		r.err = fmt.Errorf("unexpected end of data")
+		return make([]byte, n)
+	}
+	ret := r.data[:n]
+	r.data = r.data[n:]
+	return ret
+}
+
+func (r *encodingReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *encodingReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *encodingReader) uint64() uint64 {
+	return binary.BigEndian.Uint64(r.next(8))
+}
+
+func (r *encodingReader) bytes() []byte {
+	n := r.uint32()
+	if r.err == nil && int(n) > len(r.data) {
+		r.err = fmt.Errorf("unexpected end of data")
+	}
+	if r.err != nil {
+		return nil
+	}
+	return append([]byte{}, r.next(int(n))...)
+}
+
+func (r *encodingReader) deserialize(f func([]byte) error, n int) {
+	data := r.next(n)
+	if r.err != nil {
+		return
+	}
+	r.err = f(data)
+}
+
+func sortIds(ids []shared.ParticipantId) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
@@ -3,43 +3,82 @@ package state
 import (
 	"fmt"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
 	"github.com/herumi/bls-eth-go-binary/bls"
+	"sync"
 )
 
-func shufflePools(input []shared.ParticipantId, seed [32]byte, roundCount uint8, numberOfPools shared.PoolId, poolSize shared.PoolSize) (map[shared.PoolId][]shared.ParticipantId, error) {
+// distributes the shuffled input as evenly as possible, pool sizes differ by at most one (the first
+// len(input) % numberOfPools pools get the extra participant)
+func shufflePools(input []shared.ParticipantId, seed [32]byte, roundCount uint8, numberOfPools shared.PoolId) (map[shared.PoolId][]shared.ParticipantId, error) {
+	if numberOfPools == 0 || len(input) < int(numberOfPools) {
+		return nil, fmt.Errorf("can't distribute %d participants to %d pools", len(input), numberOfPools)
+	}
 	shuffled, err := crypto.ShuffleList(input, seed, roundCount)
 	if err != nil {
 		return nil, err
 	}
 
 	ret := make(map[shared.PoolId][]shared.ParticipantId)
+	size := len(shuffled) / int(numberOfPools)
+	extra := len(shuffled) % int(numberOfPools)
+	start := 0
 	for p_id := shared.PoolId(1) ; p_id <= numberOfPools ; p_id ++ {
-		start := int(p_id - 1) * int(poolSize)
-		end := start + int(poolSize)
+		end := start + size
+		if int(p_id) <= extra {
+			end++
+		}
 		ret[p_id] = shuffled[start: end]
+		start = end
 	}
 
 	return ret, nil
 }
 
+// the participant is pending or exited in the epoch
+type NotInPoolError struct {
+	Id shared.ParticipantId
+	Epoch shared.EpochNumber
+}
+
+func (e *NotInPoolError) Error() string {
+	return fmt.Sprintf("participant %d is not in any pool in epoch %d", e.Id, e.Epoch)
+}
+
 type Epoch struct {
 	Number shared.EpochNumber
 	epochSeed [32]byte
+	// the network the epoch belongs to, its pools are shuffled from the registry's participants active in the epoch
+	config *net.NetworkConfig
+	registry *Registry
 
 	// every participant will use this var to store his epoch's secret.
 	ParticipantShare *bls.Fr
-	// used to store the epoch's reconstructed signature (that will get broadcasted to eth2)
-	ReconstructedSignature *bls.G2
-	//
+	// public shares (g^share) of every participant for this epoch, used to verify redistribution commitments.
+	// Set with SetPublicShare once the epoch is processed, a new pool's DKG sets them concurrently.
+	PublicShares map[shared.ParticipantId]*bls.G1
+	publicSharesLock sync.RWMutex
+	// participants that broadcasted a partial signature not matching their public share
+	BadSigners map[shared.ParticipantId]bool
+	// the pool's reconstructed duty signatures (that will get broadcasted to eth2) by the duties' signing roots
+	ReconstructedSignatures map[eth2.Root]*bls.G2
+	// every duty's signature was reconstructed and verified against the pool's key
 	EpochSigVerified bool
+	// the hash tree root of the participant's state after the epoch's registry updates, zero until the epoch ended
+	StateRoot eth2.Root
 }
 
-func NewEpochInstance(number uint32, seed [32]byte) *Epoch {
+func NewEpochInstance(number uint32, seed [32]byte, config *net.NetworkConfig, registry *Registry) *Epoch {
 	return &Epoch{
 		Number:number,
 		epochSeed: seed,
+		config: config,
+		registry: registry,
+		PublicShares: make(map[shared.ParticipantId]*bls.G1),
+		BadSigners: make(map[shared.ParticipantId]bool),
+		ReconstructedSignatures: make(map[eth2.Root]*bls.G2),
 		EpochSigVerified: false,
 	}
 }
@@ -59,20 +98,51 @@ func (epoch *Epoch) ParticipantPoolAssignment(id shared.ParticipantId) (shared.P
 		}
 	}
 
-	return 0,fmt.Errorf("can't find %d", id)
+	return 0, &NotInPoolError{Id: id, Epoch: epoch.Number}
 }
 
+// the epoch's active participants are distributed evenly between the pools with members in the epoch (active or
+// liquidating). The registry keeps at least PoolSize in every pool, only removing slashed participants can leave
+// less (see slashing.go).
 func (epoch *Epoch) PoolsParticipantIds() (map[shared.PoolId][]shared.ParticipantId,error) {
-	config := net.NewTestNetworkConfig()
-	return shufflePools(
-		config.ParticipantIndexesList(),
+	poolIds := epoch.registry.PoolIds(epoch.Number)
+	if len(poolIds) == 0 {
+		return nil, fmt.Errorf("epoch %d has no pools", epoch.Number)
+	}
+	active := epoch.registry.ActiveIds(epoch.Number)
+	shuffled, err := shufflePools(
+		active,
 		epoch.epochSeed,
-		config.SeedShuffleRoudnCount,
-		config.NumberOfPools,
-		config.PoolSize,
+		epoch.config.SeedShuffleRoudnCount,
+		shared.PoolId(len(poolIds)),
 		)
+	if err != nil {
+		return nil, err
+	}
+
+	// shufflePools numbers the pools from 1
+	ret := make(map[shared.PoolId][]shared.ParticipantId)
+	for i, id := range poolIds {
+		ret[id] = shuffled[shared.PoolId(i + 1)]
+	}
+	return ret, nil
+}
+
+func (epoch *Epoch) PublicShare(id shared.ParticipantId) (*bls.G1, bool) {
+	epoch.publicSharesLock.RLock()
+	defer epoch.publicSharesLock.RUnlock()
+
+	ret, found := epoch.PublicShares[id]
+	return ret, found
+}
+
+func (epoch *Epoch) SetPublicShare(id shared.ParticipantId, share *bls.G1) {
+	epoch.publicSharesLock.Lock()
+	defer epoch.publicSharesLock.Unlock()
+
+	epoch.PublicShares[id] = share
 }
 
 func (epoch *Epoch)StatusString() string {
-	return fmt.Sprintf("Epoch number: %d, Sig Verified: %t",epoch.Number,epoch.EpochSigVerified)
+	return fmt.Sprintf("Epoch number: %d, Sig Verified: %t, Bad Signers: %d, State Root: %x",epoch.Number,epoch.EpochSigVerified,len(epoch.BadSigners),epoch.StateRoot[:4])
 }
\ No newline at end of file
@@ -19,10 +19,9 @@ func TestShufflePools(t *testing.T) {
 		testName string
 		seed [32]byte
 		input []uint32
-		cntPools uint8
-		poolSize uint8
+		cntPools uint32
 		roundCount uint8
-		expectedShufflee map[uint8][]uint32
+		expectedShufflee map[uint32][]uint32
 	}{
 		{
 			testName: "1 pool of 3",
@@ -31,9 +30,8 @@ func TestShufflePools(t *testing.T) {
 				1,2,3,
 			},
 			cntPools: 1,
-			poolSize:3,
 			roundCount: 10,
-			expectedShufflee: map[uint8][]uint32{
+			expectedShufflee: map[uint32][]uint32{
 				1: []uint32{2,1,3},
 			},
 		},
@@ -44,9 +42,8 @@ func TestShufflePools(t *testing.T) {
 				1,2,3,4,5,6,
 			},
 			cntPools: 2,
-			poolSize:3,
 			roundCount: 10,
-			expectedShufflee: map[uint8][]uint32{
+			expectedShufflee: map[uint32][]uint32{
 				1: []uint32{6,1,4},
 				2: []uint32{2,3,5},
 			},
@@ -58,14 +55,40 @@ func TestShufflePools(t *testing.T) {
 				1,2,3,4,5,6,7,8,9,
 			},
 			cntPools: 3,
-			poolSize:3,
 			roundCount: 10,
-			expectedShufflee: map[uint8][]uint32{
+			expectedShufflee: map[uint32][]uint32{
 				1: []uint32{7,3,2},
 				2: []uint32{8,1,9},
 				3: []uint32{5,4,6},
 			},
 		},
+		{
+			testName: "7 in 2 pools",
+			seed: getSeed("b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a9"),
+			input: []uint32{
+				1,2,3,4,5,6,7,
+			},
+			cntPools: 2,
+			roundCount: 10,
+			expectedShufflee: map[uint32][]uint32{
+				1: []uint32{3,1,6,4},
+				2: []uint32{7,5,2},
+			},
+		},
+		{
+			testName: "11 in 3 pools",
+			seed: getSeed("b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a9"),
+			input: []uint32{
+				1,2,3,4,5,6,7,8,9,10,11,
+			},
+			cntPools: 3,
+			roundCount: 10,
+			expectedShufflee: map[uint32][]uint32{
+				1: []uint32{3,10,2,6},
+				2: []uint32{4,11,7,5},
+				3: []uint32{1,8,9},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -75,10 +98,20 @@ func TestShufflePools(t *testing.T) {
 					test.seed,
 					test.roundCount,
 					test.cntPools,
-					test.poolSize,
 				)
 			require.NoError(t, err)
 			require.Equal(t, test.expectedShufflee, res)
 		})
 	}
+
+	// every pool needs at least one participant
+	_,err := shufflePools([]uint32{1,2}, getSeed("b581262ce281d1e9deaf2f0158d7cd05217f1196d95956c5f55d837ccc3c8a9"), 10, 3)
+	require.Error(t, err)
+}
+
+func TestPoolThreshold(t *testing.T) {
+	expected := map[uint32]uint32{1: 1, 2: 2, 3: 2, 4: 3, 5: 4, 6: 4, 7: 5, 9: 6, 10: 7}
+	for size, threshold := range expected {
+		require.EqualValues(t, threshold, PoolThreshold(size), "size %d", size)
+	}
 }
\ No newline at end of file
@@ -1,26 +1,112 @@
 package state
 
-import "github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"sync"
+)
 
 type InMemStateDb struct {
 	epochs map[shared.EpochNumber]*Epoch
+	pools map[shared.PoolId]*Pool
+	participants map[shared.ParticipantId]*Participant
+	blocks map[shared.EpochNumber]*Block
+	blockRoots map[eth2.Root]shared.EpochNumber
+	// epochs are fetched and saved from the epoch processing and DKG goroutines at once
+	lock sync.RWMutex
 }
 
 func NewInMemoryDb() *InMemStateDb {
 	return &InMemStateDb{
 		epochs: make(map[shared.EpochNumber]*Epoch),
+		pools: make(map[shared.PoolId]*Pool),
+		participants: make(map[shared.ParticipantId]*Participant),
+		blocks: make(map[shared.EpochNumber]*Block),
+		blockRoots: make(map[eth2.Root]shared.EpochNumber),
 	}
 }
 
 func (db *InMemStateDb) SaveEpoch(epoch *Epoch) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
 	db.epochs[epoch.Number] = epoch
 	return nil
 }
 
 func (db *InMemStateDb) GetEpoch(number shared.EpochNumber) (*Epoch,error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
 	if val, ok := db.epochs[number]; ok {
 		return val, nil
 	}
 
 	return nil, nil
 }
+
+func (db *InMemStateDb) SavePool(pool *Pool) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.pools[pool.Id] = pool
+	return nil
+}
+
+func (db *InMemStateDb) GetPools() (map[shared.PoolId]*Pool, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	ret := make(map[shared.PoolId]*Pool)
+	for k, v := range db.pools {
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+func (db *InMemStateDb) SaveParticipant(participant *Participant) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.participants[participant.Id] = participant
+	return nil
+}
+
+func (db *InMemStateDb) GetParticipants() (map[shared.ParticipantId]*Participant, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	ret := make(map[shared.ParticipantId]*Participant)
+	for k, v := range db.participants {
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+func (db *InMemStateDb) SaveBlock(block *Block) error {
+	root := BlockRoot(block)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.blocks[block.Epoch] = block
+	db.blockRoots[root] = block.Epoch
+	return nil
+}
+
+func (db *InMemStateDb) GetBlock(epoch shared.EpochNumber) (*Block, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.blocks[epoch], nil
+}
+
+func (db *InMemStateDb) GetBlockByRoot(root eth2.Root) (*Block, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if epoch, ok := db.blockRoots[root]; ok {
+		return db.blocks[epoch], nil
+	}
+	return nil, nil
+}
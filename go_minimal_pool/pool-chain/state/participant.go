@@ -0,0 +1,86 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"math"
+)
+
+// activation or exit epoch that is not scheduled (yet)
+const FarFutureEpoch = shared.EpochNumber(math.MaxUint32)
+
+type ParticipantStatus int
+
+const (
+	// joined but not active yet
+	ParticipantPending ParticipantStatus = iota
+	// shuffled into the epoch's pools
+	ParticipantActive
+	ParticipantExited
+)
+
+func (s ParticipantStatus) String() string {
+	switch s {
+	case ParticipantPending:
+		return "pending"
+	case ParticipantActive:
+		return "active"
+	case ParticipantExited:
+		return "exited"
+	default:
+		return "unknown"
+	}
+}
+
+// public, long term, data of a participant
+type Participant struct {
+	Id shared.ParticipantId
+	// shares sent to the participant are encrypted to this key
+	EncryptionPk *bls.G1
+	// every message sent by the participant is signed with this key
+	IdentityPk *bls.PublicKey
+
+	// first epoch the participant is active in, FarFutureEpoch while its join request is not processed
+	ActivationEpoch shared.EpochNumber
+	// first epoch the participant is no longer active in, FarFutureEpoch if it didn't exit
+	ExitEpoch shared.EpochNumber
+	// a voluntary exit waiting to be processed at an epoch boundary
+	ExitRequested bool
+	// gwei, the rewards for the participant's contributions to the finalized epochs minus its penalties (see
+	// rewards.go), never below zero
+	Balance uint64
+	// evidence of the participant's misbehaviour was finalized, it's removed from the pools (see slashing.go)
+	Slashed bool
+}
+
+// a genesis participant, active from epoch 0
+func NewParticipant(id shared.ParticipantId, encryptionPk *bls.G1, identityPk *bls.PublicKey) *Participant {
+	return &Participant{
+		Id: id,
+		EncryptionPk: encryptionPk,
+		IdentityPk: identityPk,
+		ActivationEpoch: 0,
+		ExitEpoch: FarFutureEpoch,
+	}
+}
+
+// a participant asking to join the network, see State.RequestJoin
+func NewPendingParticipant(id shared.ParticipantId, encryptionPk *bls.G1, identityPk *bls.PublicKey) *Participant {
+	ret := NewParticipant(id, encryptionPk, identityPk)
+	ret.ActivationEpoch = FarFutureEpoch
+	return ret
+}
+
+func (p *Participant) Status(epoch shared.EpochNumber) ParticipantStatus {
+	if epoch >= p.ExitEpoch {
+		return ParticipantExited
+	}
+	if epoch >= p.ActivationEpoch {
+		return ParticipantActive
+	}
+	return ParticipantPending
+}
+
+func (p *Participant) IsActive(epoch shared.EpochNumber) bool {
+	return p.Status(epoch) == ParticipantActive
+}
@@ -0,0 +1,218 @@
+package state
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	bolt "go.etcd.io/bbolt"
+	"sync"
+)
+
+var (
+	epochsBucket = []byte("epochs")
+	poolsBucket = []byte("pools")
+	participantsBucket = []byte("participants")
+	blocksBucket = []byte("blocks")
+	// block root -> epoch
+	blockRootsBucket = []byte("block_roots")
+)
+
+// bbolt backed db, every save is a single (fsynced) transaction so a crash leaves either the old or the new value.
+// Epochs are cached in memory once loaded, GetEpoch returns the same instance every time like InMemStateDb.
+type PersistentDb struct {
+	db *bolt.DB
+	// loaded epochs belong to config's network and registry
+	config *net.NetworkConfig
+	registry *Registry
+	epochs map[shared.EpochNumber]*Epoch
+	lock sync.Mutex
+}
+
+func NewPersistentDb(path string, config *net.NetworkConfig, registry *Registry) (*PersistentDb, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{epochsBucket, poolsBucket, participantsBucket, blocksBucket, blockRootsBucket} {
+			_, err := tx.CreateBucketIfNotExists(b)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PersistentDb{
+		db: db,
+		config: config,
+		registry: registry,
+		epochs: make(map[shared.EpochNumber]*Epoch),
+	}, nil
+}
+
+func (db *PersistentDb) Close() error {
+	return db.db.Close()
+}
+
+func (db *PersistentDb) SaveEpoch(epoch *Epoch) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	data, err := EncodeEpoch(epoch)
+	if err != nil {
+		return err
+	}
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(epochsBucket).Put(uint32Key(epoch.Number), data)
+	})
+	if err != nil {
+		return err
+	}
+
+	db.epochs[epoch.Number] = epoch
+	return nil
+}
+
+func (db *PersistentDb) GetEpoch(number shared.EpochNumber) (*Epoch,error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if val, ok := db.epochs[number]; ok {
+		return val, nil
+	}
+
+	var ret *Epoch
+	err := db.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(epochsBucket).Get(uint32Key(number))
+		if data == nil {
+			return nil
+		}
+		e, err := DecodeEpoch(data, db.config, db.registry)
+		if err != nil {
+			return err
+		}
+		ret = e
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load epoch %d: %s", number, err.Error())
+	}
+
+	if ret != nil {
+		db.epochs[number] = ret
+	}
+	return ret, nil
+}
+
+func (db *PersistentDb) SavePool(pool *Pool) error {
+	data, err := EncodePool(pool)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(poolsBucket).Put(uint32Key(pool.Id), data)
+	})
+}
+
+func (db *PersistentDb) GetPools() (map[shared.PoolId]*Pool, error) {
+	ret := make(map[shared.PoolId]*Pool)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(poolsBucket).ForEach(func(k, v []byte) error {
+			pool, err := DecodePool(v)
+			if err != nil {
+				return err
+			}
+			ret[pool.Id] = pool
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (db *PersistentDb) SaveParticipant(participant *Participant) error {
+	data, err := EncodeParticipant(participant)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(participantsBucket).Put(uint32Key(participant.Id), data)
+	})
+}
+
+func (db *PersistentDb) GetParticipants() (map[shared.ParticipantId]*Participant, error) {
+	ret := make(map[shared.ParticipantId]*Participant)
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(participantsBucket).ForEach(func(k, v []byte) error {
+			participant, err := DecodeParticipant(v)
+			if err != nil {
+				return err
+			}
+			ret[participant.Id] = participant
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (db *PersistentDb) SaveBlock(block *Block) error {
+	root := BlockRoot(block)
+	return db.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(blocksBucket).Put(uint32Key(block.Epoch), EncodeBlock(block))
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(blockRootsBucket).Put(root[:], uint32Key(block.Epoch))
+	})
+}
+
+func (db *PersistentDb) GetBlock(epoch shared.EpochNumber) (*Block, error) {
+	var ret *Block
+	err := db.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(blocksBucket).Get(uint32Key(epoch))
+		if data == nil {
+			return nil
+		}
+		block, err := DecodeBlock(data)
+		ret = block
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load block %d: %s", epoch, err.Error())
+	}
+	return ret, nil
+}
+
+func (db *PersistentDb) GetBlockByRoot(root eth2.Root) (*Block, error) {
+	var epoch []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(blockRootsBucket).Get(root[:]); data != nil {
+			epoch = append([]byte{}, data...)
+		}
+		return nil
+	})
+	if err != nil || epoch == nil {
+		return nil, err
+	}
+	return db.GetBlock(binary.BigEndian.Uint32(epoch))
+}
+
+// big endian so keys are iterated in order
+func uint32Key(v uint32) []byte {
+	ret := make([]byte, 4)
+	binary.BigEndian.PutUint32(ret, v)
+	return ret
+}
@@ -0,0 +1,161 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEpochEncoding(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+
+	epoch := NewEpochInstance(5, [32]byte{1,2,3}, config, NewRegistry())
+	empty, err := EncodeEpoch(epoch)
+	require.NoError(t, err)
+	decoded, err := DecodeEpoch(empty, config, NewRegistry())
+	require.NoError(t, err)
+	require.Equal(t, epoch.epochSeed, decoded.epochSeed)
+	require.Nil(t, decoded.ParticipantShare)
+
+	share := &bls.Fr{}
+	share.SetByCSPRNG()
+	epoch.ParticipantShare = share
+	sk := bls.CastToSecretKey(share)
+	epoch.PublicShares[3] = bls.CastFromPublicKey(sk.GetPublicKey())
+	epoch.PublicShares[1] = bls.CastFromPublicKey(sk.GetPublicKey())
+	epoch.BadSigners[2] = true
+	epoch.ReconstructedSignatures[eth2.Root{1}] = crypto.Sign(share, []byte("hello"))
+	epoch.ReconstructedSignatures[eth2.Root{2}] = crypto.Sign(share, []byte("world"))
+	epoch.EpochSigVerified = true
+	epoch.StateRoot = eth2.Root{9}
+
+	data, err := EncodeEpoch(epoch)
+	require.NoError(t, err)
+	decoded, err = DecodeEpoch(data, config, NewRegistry())
+	require.NoError(t, err)
+	require.EqualValues(t, 5, decoded.Number)
+	// shares are kept in the share keystore only
+	require.Nil(t, decoded.ParticipantShare)
+	require.Len(t, decoded.PublicShares, 2)
+	require.True(t, decoded.PublicShares[3].IsEqual(epoch.PublicShares[3]))
+	require.Equal(t, epoch.BadSigners, decoded.BadSigners)
+	require.Len(t, decoded.ReconstructedSignatures, 2)
+	require.True(t, decoded.ReconstructedSignatures[eth2.Root{2}].IsEqual(epoch.ReconstructedSignatures[eth2.Root{2}]))
+	require.True(t, decoded.EpochSigVerified)
+	require.Equal(t, eth2.Root{9}, decoded.StateRoot)
+
+	// v3 had no state root
+	v3 := append([]byte{3}, data[1:len(data) - 32]...)
+	decoded, err = DecodeEpoch(v3, config, NewRegistry())
+	require.NoError(t, err)
+	require.True(t, decoded.EpochSigVerified)
+	require.Equal(t, eth2.Root{}, decoded.StateRoot)
+
+	// truncated or unknown version
+	_, err = DecodeEpoch(data[:len(data) - 1], config, NewRegistry())
+	require.Error(t, err)
+	data[0] = 5
+	_, err = DecodeEpoch(data, config, NewRegistry())
+	require.Error(t, err)
+}
+
+// an epoch saved before duties were signed, its single reconstructed sig is dropped
+func TestDecodeV2Epoch(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+
+	share := &bls.Fr{}
+	share.SetByCSPRNG()
+	w := &encodingWriter{}
+	w.byte(2)
+	w.uint32(7)
+	w.buf.Write(make([]byte, 32))
+	w.uint32(0)
+	w.uint32(1)
+	w.uint32(4)
+	w.byte(1)
+	w.buf.Write(crypto.Sign(share, []byte("hello")).Serialize())
+	w.byte(1)
+
+	epoch, err := DecodeEpoch(w.buf.Bytes(), config, NewRegistry())
+	require.NoError(t, err)
+	require.EqualValues(t, 7, epoch.Number)
+	require.True(t, epoch.BadSigners[4])
+	require.Len(t, epoch.ReconstructedSignatures, 0)
+	require.True(t, epoch.EpochSigVerified)
+}
+
+func TestPersistentStateRestart(t *testing.T) {
+	crypto.InitBLS()
+
+	dir, err := ioutil.TempDir("", "state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.db")
+	config := net.NewTestNetworkConfig()
+
+	params := crypto.KeystoreParams{KDF: crypto.KeystoreKDFScrypt, ScryptN: 2}
+	keystore, err := NewShareKeystore(filepath.Join(dir, "shares"), "password", params)
+	require.NoError(t, err)
+
+	s, err := NewPersistentState(path, config)
+	require.NoError(t, err)
+	s.SetShareKeystore(keystore)
+	epoch := s.GetEpoch(1)
+	share := &bls.Fr{}
+	share.SetByCSPRNG()
+	epoch.ParticipantShare = share
+	require.NoError(t, s.SaveEpoch(epoch))
+	pk := bls.CastToSecretKey(share).GetPublicKey()
+	require.NoError(t, s.SavePool(NewPool(1, 3, pk)))
+	require.NoError(t, s.Close())
+
+	// restart, a wrong password can't unlock the shares
+	_, err = NewShareKeystore(filepath.Join(dir, "shares"), "wrong", params)
+	require.Error(t, err)
+	keystore, err = NewShareKeystore(filepath.Join(dir, "shares"), "password", params)
+	require.NoError(t, err)
+	s, err = NewPersistentState(path, config)
+	require.NoError(t, err)
+	defer s.Close()
+	s.SetShareKeystore(keystore)
+	restored := s.GetEpoch(1)
+	require.True(t, restored.ParticipantShare.IsEqual(share))
+	require.Equal(t, epoch.epochSeed, restored.epochSeed)
+	require.NotNil(t, s.GetPool(1))
+	require.True(t, s.GetPool(1).Pk.IsEqual(pk))
+	require.EqualValues(t, 3, s.GetPool(1).Size)
+}
+
+func TestDeleteParticipantShare(t *testing.T) {
+	crypto.InitBLS()
+
+	dir, err := ioutil.TempDir("", "shares")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keystore, err := NewShareKeystore(dir, "password", crypto.KeystoreParams{KDF: crypto.KeystoreKDFPBKDF2, PBKDF2C: 2})
+	require.NoError(t, err)
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	s.SetShareKeystore(keystore)
+
+	epoch := s.GetEpoch(1)
+	epoch.ParticipantShare = &bls.Fr{}
+	epoch.ParticipantShare.SetByCSPRNG()
+	share := epoch.ParticipantShare
+	require.NoError(t, s.SaveEpoch(epoch))
+	require.FileExists(t, filepath.Join(dir, "epoch_1.json"))
+
+	require.NoError(t, s.DeleteParticipantShare(1))
+	require.Nil(t, s.GetEpoch(1).ParticipantShare)
+	require.True(t, share.IsZero())
+	_, err = os.Stat(filepath.Join(dir, "epoch_1.json"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -1,20 +1,124 @@
 package state
 
 import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
 	"github.com/herumi/bls-eth-go-binary/bls"
 )
 
+// gwei needed to activate a pool, a 32 ETH validator
+const PoolStake = uint64(32000000000)
+
+/**
+	Pool lifecycle:
+		pending - created (State.CreatePool), waits for PoolStake to be deposited
+		active - funded, activated at an epoch boundary (see Registry.processUpdates). The members of its first epoch
+				run a fresh DKG during the epoch before it, from then on the pool's key is rotated every epoch
+		liquidating - its last epoch, liquidation was requested (State.RequestPoolLiquidation). The members don't
+				redistribute their shares, they sign the pool's voluntary exit along with its duties
+		liquidated - the exit signature was reconstructed (or the liquidating epoch passed), the pool has no members
+ */
+type PoolStatus int
+
+const (
+	PoolPending PoolStatus = iota
+	PoolActive
+	PoolLiquidating
+	PoolLiquidated
+)
+
+func (s PoolStatus) String() string {
+	switch s {
+	case PoolPending:
+		return "pending"
+	case PoolActive:
+		return "active"
+	case PoolLiquidating:
+		return "liquidating"
+	case PoolLiquidated:
+		return "liquidated"
+	default:
+		return "unknown"
+	}
+}
+
 type Pool struct {
 	Id shared.PoolId
+	// members of the epoch the pool's key was generated in
 	Size shared.PoolSize
+	// nil until the pool's DKG is done
 	Pk *bls.PublicKey
+
+	// deposited gwei
+	Stake uint64
+	// first epoch the pool has members in, FarFutureEpoch while pending
+	ActivationEpoch shared.EpochNumber
+	// first epoch the pool has no members in, the epoch before it is the liquidating one. FarFutureEpoch if not
+	// liquidated
+	ExitEpoch shared.EpochNumber
+	// a liquidation waiting to be processed at an epoch boundary
+	LiquidationRequested bool
+	// the pool's signature over VoluntaryExit, nil until reconstructed
+	ExitSignature *bls.Sign
 }
 
+// a genesis pool, funded and active from epoch 0
 func NewPool(id shared.PoolId, size shared.PoolSize, pk *bls.PublicKey) *Pool {
 	return &Pool{
 		Id: id,
 		Size:size,
 		Pk: pk,
+		Stake: PoolStake,
+		ActivationEpoch: 0,
+		ExitEpoch: FarFutureEpoch,
+	}
+}
+
+// a pool waiting for stake, see State.CreatePool
+func NewPendingPool(id shared.PoolId) *Pool {
+	ret := NewPool(id, 0, nil)
+	ret.Stake = 0
+	ret.ActivationEpoch = FarFutureEpoch
+	return ret
+}
+
+func (pool *Pool) Status(epoch shared.EpochNumber) PoolStatus {
+	if epoch < pool.ActivationEpoch {
+		return PoolPending
+	}
+	if pool.ExitEpoch == FarFutureEpoch || epoch + 1 < pool.ExitEpoch {
+		return PoolActive
 	}
-}
\ No newline at end of file
+	if epoch + 1 == pool.ExitEpoch && pool.ExitSignature == nil {
+		return PoolLiquidating
+	}
+	return PoolLiquidated
+}
+
+// true if the pool has members in epoch, i.e. it's active or liquidating
+func (pool *Pool) HasMembers(epoch shared.EpochNumber) bool {
+	return epoch >= pool.ActivationEpoch && epoch < pool.ExitEpoch
+}
+
+// true if the pool's key is handed from epoch to epoch + 1
+func (pool *Pool) Rotates(epoch shared.EpochNumber) bool {
+	return pool.HasMembers(epoch) && pool.HasMembers(epoch + 1)
+}
+
+// the pool's validator index, pools aren't registered with a beacon chain (yet) so it's the pool's id
+func (pool *Pool) ValidatorIndex() eth2.ValidatorIndex {
+	return eth2.ValidatorIndex(pool.Id)
+}
+
+// the exit the pool's members sign in its liquidating epoch, processable from that epoch
+func (pool *Pool) VoluntaryExit() *eth2.VoluntaryExit {
+	return &eth2.VoluntaryExit{
+		Epoch:          eth2.Epoch(pool.ExitEpoch - 1),
+		ValidatorIndex: pool.ValidatorIndex(),
+	}
+}
+
+// the number of a pool's members needed to sign or to redistribute its key, ⌈2/3·size⌉
+func PoolThreshold(size shared.PoolSize) shared.PoolSize {
+	return (2 * size + 2) / 3
+}
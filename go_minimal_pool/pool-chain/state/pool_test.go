@@ -0,0 +1,223 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPoolLifecycle(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+	require.Len(t, s.Pools(), 2)
+
+	require.EqualValues(t, 3, s.Registry().NextPoolId())
+	pool, err := s.CreatePool(3)
+	require.NoError(t, err)
+	require.Equal(t, PoolPending, pool.Status(0))
+	_, err = s.CreatePool(3)
+	require.Error(t, err)
+	require.EqualValues(t, 4, s.Registry().NextPoolId())
+	require.Error(t, s.AddPoolStake(4, PoolStake))
+	require.Error(t, s.RequestPoolLiquidation(3))
+	require.NoError(t, s.AddPoolStake(3, PoolStake / 2))
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+	require.Equal(t, FarFutureEpoch, s.GetPool(3).ActivationEpoch)
+
+	// funded, but 6 participants can't fill another pool
+	require.NoError(t, s.AddPoolStake(3, PoolStake / 2))
+	require.NoError(t, s.ProcessRegistryUpdates(1))
+	require.Equal(t, FarFutureEpoch, s.GetPool(3).ActivationEpoch)
+
+	for _, id := range []shared.ParticipantId{7, 8, 9} {
+		encryptionPk, identityPk := testParticipantKeys()
+		require.NoError(t, s.RequestJoin(NewPendingParticipant(id, encryptionPk, identityPk)))
+	}
+	require.NoError(t, s.ProcessRegistryUpdates(2))
+	require.EqualValues(t, 4, s.GetPool(3).ActivationEpoch)
+	require.Error(t, s.AddPoolStake(3, 1))
+	require.Equal(t, []shared.PoolId{1, 2}, s.Registry().PoolIds(3))
+	require.Equal(t, []shared.PoolId{1, 2, 3}, s.Registry().PoolIds(4))
+	pools, err := s.GetEpoch(4).PoolsParticipantIds()
+	require.NoError(t, err)
+	require.Len(t, pools, 3)
+	for id, members := range pools {
+		require.Len(t, members, 3, "pool %d", id)
+	}
+
+	// pool 1's last epoch is 5
+	require.NoError(t, s.RequestPoolLiquidation(1))
+	require.Error(t, s.RequestPoolLiquidation(1))
+	require.NoError(t, s.ProcessRegistryUpdates(4))
+	require.EqualValues(t, 6, s.GetPool(1).ExitEpoch)
+	require.Equal(t, PoolActive, s.GetPool(1).Status(4))
+	require.Equal(t, PoolLiquidating, s.GetPool(1).Status(5))
+	require.Equal(t, PoolLiquidated, s.GetPool(1).Status(6))
+	require.False(t, s.GetPool(1).Rotates(5))
+	require.Equal(t, []shared.PoolId{2, 3}, s.Registry().PoolIds(6))
+	require.Len(t, poolMembers(t, s, 6), 9)
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	// the exit is processable from the liquidating epoch
+	require.Equal(t, &eth2.VoluntaryExit{Epoch: 5, ValidatorIndex: 1}, s.GetPool(1).VoluntaryExit())
+	require.Error(t, s.SetPoolExitSignature(2, sk.SignByte([]byte("exit"))))
+	require.NoError(t, s.SetPoolExitSignature(1, sk.SignByte([]byte("exit"))))
+	require.Equal(t, PoolLiquidated, s.GetPool(1).Status(5))
+}
+
+func TestLastPoolIsNotLiquidated(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+
+	require.NoError(t, s.RequestPoolLiquidation(1))
+	require.NoError(t, s.RequestPoolLiquidation(2))
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+	require.EqualValues(t, 2, s.GetPool(1).ExitEpoch)
+	require.Equal(t, FarFutureEpoch, s.GetPool(2).ExitEpoch)
+	require.True(t, s.GetPool(2).LiquidationRequested)
+
+	// every participant left moves to the last pool
+	pools, err := s.GetEpoch(2).PoolsParticipantIds()
+	require.NoError(t, err)
+	require.Len(t, pools, 1)
+	require.Len(t, pools[2], 6)
+}
+
+func TestPersistentPools(t *testing.T) {
+	crypto.InitBLS()
+
+	dir, err := ioutil.TempDir("", "pools")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.db")
+	config := net.NewTestNetworkConfig()
+
+	s, err := NewPersistentState(path, config)
+	require.NoError(t, err)
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	require.NoError(t, s.SetPoolPk(1, 3, sk.GetPublicKey()))
+	require.NoError(t, s.RequestPoolLiquidation(1))
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+	exitSig := sk.SignByte([]byte("exit"))
+	require.NoError(t, s.SetPoolExitSignature(1, exitSig))
+	_, err = s.CreatePool(3)
+	require.NoError(t, err)
+	require.NoError(t, s.AddPoolStake(3, 5))
+	require.NoError(t, s.Close())
+
+	s, err = NewPersistentState(path, config)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Len(t, s.Pools(), 3)
+	liquidated := s.GetPool(1)
+	require.True(t, liquidated.Pk.IsEqual(sk.GetPublicKey()))
+	require.EqualValues(t, 3, liquidated.Size)
+	require.EqualValues(t, 2, liquidated.ExitEpoch)
+	require.True(t, liquidated.ExitSignature.IsEqual(exitSig))
+	require.Nil(t, s.GetPool(2).Pk)
+	require.Equal(t, PoolActive, s.GetPool(2).Status(2))
+	pending := s.GetPool(3)
+	require.EqualValues(t, 5, pending.Stake)
+	require.Equal(t, FarFutureEpoch, pending.ActivationEpoch)
+	require.Nil(t, pending.ExitSignature)
+}
+
+// pools saved before the lifecycle are genesis pools
+func TestDecodeV1Pool(t *testing.T) {
+	crypto.InitBLS()
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	w := &encodingWriter{}
+	w.byte(1)
+	w.uint32(2)
+	w.uint32(3)
+	w.byte(1)
+	w.buf.Write(sk.GetPublicKey().Serialize())
+
+	pool, err := DecodePool(w.buf.Bytes())
+	require.NoError(t, err)
+	require.EqualValues(t, 2, pool.Id)
+	require.True(t, pool.Pk.IsEqual(sk.GetPublicKey()))
+	require.Equal(t, PoolStake, pool.Stake)
+	require.EqualValues(t, 0, pool.ActivationEpoch)
+	require.Equal(t, FarFutureEpoch, pool.ExitEpoch)
+}
+
+// pool 3 is funded and pool 1 liquidated in epoch 0, pool 1's last epoch is 1 and pool 3 signs from epoch 2. The
+// exit signature is recorded from epoch 1's block, replaying the blocks gives the same state.
+func TestPoolReplacedByNewPool(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+	config.GenesisParticipants = 9
+	s := NewInMemoryState(config)
+	newTestRegistryState(t, s)
+	genesis := s.Genesis()
+
+	body := applyRequests(t, s, 0,
+		testPoolRequest("create", pb.RegistryRequestType_CREATE_POOL, 3, 0),
+		testPoolRequest("deposit", pb.RegistryRequestType_DEPOSIT, 3, PoolStake),
+		testPoolRequest("liquidation", pb.RegistryRequestType_LIQUIDATION, 1, 0),
+	)
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+	require.EqualValues(t, 2, s.GetPool(1).ExitEpoch)
+	require.EqualValues(t, 2, s.GetPool(3).ActivationEpoch)
+	require.Equal(t, []shared.PoolId{1, 2}, s.Registry().PoolIds(1))
+	require.Equal(t, []shared.PoolId{2, 3}, s.Registry().PoolIds(2))
+	pools, err := s.GetEpoch(2).PoolsParticipantIds()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int{5, 4}, []int{len(pools[2]), len(pools[3])})
+	require.Len(t, poolMembers(t, s, 2), 9)
+
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	pools, err = s.GetEpoch(0).PoolsParticipantIds()
+	require.NoError(t, err)
+	body.PoolKeys = append(body.PoolKeys, &PoolKey{PoolId: 1, Size: shared.PoolSize(len(pools[1])), Pk: sk.GetPublicKey()})
+	block0 := testBlock(t, genesis, 0, body)
+	head, err := ProcessBlock(genesis, block0)
+	require.NoError(t, err)
+
+	// only pool 1's exit signature, by its key, is recorded
+	root, err := config.Fork().SigningRoot(eth2.NewVoluntaryExitDuty(head.GetPool(1).VoluntaryExit()))
+	require.NoError(t, err)
+	other := &bls.SecretKey{}
+	other.SetByCSPRNG()
+	invalid := NewBlockBody()
+	invalid.Signatures = []*PoolSignature{{PoolId: 1, SigningRoot: root, Sig: bls.CastFromSign(other.SignByte(root[:]))}}
+	_, err = TransitionState(head, &Block{ParentRoot: head.LatestBlockRoot(), Epoch: 1, Body: invalid})
+	require.Error(t, err)
+
+	body = NewBlockBody()
+	body.Signatures = []*PoolSignature{
+		{PoolId: 1, SigningRoot: root, Sig: bls.CastFromSign(sk.SignByte(root[:]))},
+		{PoolId: 2, SigningRoot: eth2.Root{1}, Sig: bls.CastFromSign(other.SignByte([]byte{1}))},
+	}
+	body.SortSignatures()
+	block1 := testBlock(t, head, 1, body)
+	head, err = ProcessBlock(head, block1)
+	require.NoError(t, err)
+	require.True(t, head.GetPool(1).ExitSignature.VerifyByte(sk.GetPublicKey(), root[:]))
+	require.Equal(t, PoolLiquidated, head.GetPool(1).Status(1))
+	require.Nil(t, head.GetPool(2).ExitSignature)
+
+	require.NoError(t, s.SaveBlock(block0))
+	require.NoError(t, s.SaveBlock(block1))
+	replayed, err := Replay(genesis, s)
+	require.NoError(t, err)
+	require.Equal(t, head.HashTreeRoot(), replayed.HashTreeRoot())
+	require.NotNil(t, replayed.GetPool(1).ExitSignature)
+	require.EqualValues(t, 2, replayed.GetPool(3).ActivationEpoch)
+}
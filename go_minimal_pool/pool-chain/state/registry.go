@@ -0,0 +1,312 @@
+package state
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"sync"
+)
+
+/**
+	Participant and pool registry, every participant that ever registered (genesis, joined or exited) and every pool
+	that was ever created.
+	Join, voluntary exit, pool activation and liquidation requests are processed at the end of an epoch and take
+	effect RegistryUpdateDelay epochs later. During epoch N the pools of N + 1 are already fixed (N's shares are
+	redistributed to them), so the earliest epoch a request can change is N + 2.
+	An epoch's participants are shuffled between the pools that have members in it, see Epoch.PoolsParticipantIds.
+ */
+
+const RegistryUpdateDelay = 2
+
+type Registry struct {
+	participants map[shared.ParticipantId]*Participant
+	pools map[shared.PoolId]*Pool
+	lock sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		participants: make(map[shared.ParticipantId]*Participant),
+		pools: make(map[shared.PoolId]*Pool),
+	}
+}
+
+func (r *Registry) Get(id shared.ParticipantId) *Participant {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.participants[id]
+}
+
+func (r *Registry) save(participant *Participant) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.participants[participant.Id] = participant
+}
+
+// all participants sorted by id
+func (r *Registry) Participants() []*Participant {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.sorted()
+}
+
+// the ids, sorted, of the participants active in epoch
+func (r *Registry) ActiveIds(epoch shared.EpochNumber) []shared.ParticipantId {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ret := make([]shared.ParticipantId, 0)
+	for _, p := range r.sorted() {
+		if p.IsActive(epoch) {
+			ret = append(ret, p.Id)
+		}
+	}
+	return ret
+}
+
+// the ids, sorted, of the participants whose activation or exit takes effect in epoch
+func (r *Registry) UpdatedIds(epoch shared.EpochNumber) ([]shared.ParticipantId, []shared.ParticipantId) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	activated := make([]shared.ParticipantId, 0)
+	exited := make([]shared.ParticipantId, 0)
+	for _, p := range r.sorted() {
+		if p.ActivationEpoch == epoch {
+			activated = append(activated, p.Id)
+		}
+		if p.ExitEpoch == epoch {
+			exited = append(exited, p.Id)
+		}
+	}
+	return activated, exited
+}
+
+func (r *Registry) GetPool(id shared.PoolId) *Pool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.pools[id]
+}
+
+func (r *Registry) savePool(pool *Pool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.pools[pool.Id] = pool
+}
+
+// applies f to the participant under the registry's lock
+func (r *Registry) updateParticipant(id shared.ParticipantId, f func(participant *Participant) error) (*Participant, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	participant := r.participants[id]
+	if participant == nil {
+		return nil, fmt.Errorf("unknown participant %d", id)
+	}
+	return participant, f(participant)
+}
+
+// applies f to the pool under the registry's lock
+func (r *Registry) updatePool(id shared.PoolId, f func(pool *Pool) error) (*Pool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	pool := r.pools[id]
+	if pool == nil {
+		return nil, fmt.Errorf("unknown pool %d", id)
+	}
+	return pool, f(pool)
+}
+
+// all pools sorted by id
+func (r *Registry) Pools() []*Pool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.sortedPools()
+}
+
+// the ids, sorted, of the pools with members (active or liquidating) in epoch
+func (r *Registry) PoolIds(epoch shared.EpochNumber) []shared.PoolId {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ret := make([]shared.PoolId, 0)
+	for _, pool := range r.sortedPools() {
+		if pool.HasMembers(epoch) {
+			ret = append(ret, pool.Id)
+		}
+	}
+	return ret
+}
+
+// a pending pool with the next unused id
+func (r *Registry) createPool(id shared.PoolId) (*Pool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if id == 0 || r.pools[id] != nil {
+		return nil, fmt.Errorf("pool id %d is taken", id)
+	}
+	ret := NewPendingPool(id)
+	r.pools[id] = ret
+	return ret, nil
+}
+
+// the lowest id above every registered pool's
+func (r *Registry) NextPoolId() shared.PoolId {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	id := shared.PoolId(1)
+	for existing := range r.pools {
+		if existing >= id {
+			id = existing + 1
+		}
+	}
+	return id
+}
+
+func (r *Registry) requestExit(id shared.ParticipantId) (*Participant, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	p := r.participants[id]
+	if p == nil {
+		return nil, fmt.Errorf("unknown participant %d", id)
+	}
+	if p.ActivationEpoch == FarFutureEpoch {
+		return nil, fmt.Errorf("participant %d join request is not processed yet", id)
+	}
+	if p.ExitEpoch != FarFutureEpoch || p.ExitRequested {
+		return nil, fmt.Errorf("participant %d already exited or requested to", id)
+	}
+	p.ExitRequested = true
+	return p, nil
+}
+
+func (r *Registry) requestLiquidation(id shared.PoolId) (*Pool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	pool := r.pools[id]
+	if pool == nil {
+		return nil, fmt.Errorf("unknown pool %d", id)
+	}
+	if pool.ActivationEpoch == FarFutureEpoch {
+		return nil, fmt.Errorf("pool %d is not active", id)
+	}
+	if pool.ExitEpoch != FarFutureEpoch || pool.LiquidationRequested {
+		return nil, fmt.Errorf("pool %d already liquidated or requested to", id)
+	}
+	pool.LiquidationRequested = true
+	return pool, nil
+}
+
+// schedules pending joins, liquidations, activations of funded pools and exits at the end of epoch number, in
+// that order. At least one pool is kept and every pool keeps minPoolSize participants, activations and exits that
+// would break it are delayed. Returns the participants and pools that changed.
+func (r *Registry) processUpdates(number shared.EpochNumber, minPoolSize int) ([]*Participant, []*Pool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	effective := number + RegistryUpdateDelay
+	changed := make([]*Participant, 0)
+	changedPools := make([]*Pool, 0)
+	for _, p := range r.sorted() {
+		if p.ActivationEpoch == FarFutureEpoch {
+			p.ActivationEpoch = effective
+			changed = append(changed, p)
+		}
+	}
+
+	active := 0
+	for _, p := range r.participants {
+		if p.IsActive(effective) {
+			active++
+		}
+	}
+	pools := 0
+	for _, pool := range r.pools {
+		if pool.HasMembers(effective) {
+			pools++
+		}
+	}
+
+	for _, pool := range r.sortedPools() {
+		if !pool.LiquidationRequested || pools == 1 {
+			continue
+		}
+		// its last epoch (effective - 1) is the liquidating one
+		pool.ExitEpoch = effective
+		pool.LiquidationRequested = false
+		pools--
+		changedPools = append(changedPools, pool)
+	}
+	for _, pool := range r.sortedPools() {
+		if pool.ActivationEpoch != FarFutureEpoch || pool.Stake < PoolStake {
+			continue
+		}
+		if active < (pools + 1) * minPoolSize {
+			continue
+		}
+		pool.ActivationEpoch = effective
+		pools++
+		changedPools = append(changedPools, pool)
+	}
+
+	for _, p := range r.sorted() {
+		if !p.ExitRequested {
+			continue
+		}
+		// slashed, exiting by then anyway
+		if p.ExitEpoch <= effective {
+			p.ExitRequested = false
+			changed = append(changed, p)
+			continue
+		}
+		if active - 1 < pools * minPoolSize {
+			continue
+		}
+		// exits can only be requested once the activation is scheduled, i.e. activation < effective
+		p.ExitEpoch = effective
+		p.ExitRequested = false
+		active--
+		changed = append(changed, p)
+	}
+	return changed, changedPools
+}
+
+// callers hold the lock
+func (r *Registry) sorted() []*Participant {
+	ids := make([]shared.ParticipantId, 0, len(r.participants))
+	for id := range r.participants {
+		ids = append(ids, id)
+	}
+	sortIds(ids)
+
+	ret := make([]*Participant, len(ids))
+	for i, id := range ids {
+		ret[i] = r.participants[id]
+	}
+	return ret
+}
+
+// callers hold the lock
+func (r *Registry) sortedPools() []*Pool {
+	ids := make([]shared.PoolId, 0, len(r.pools))
+	for id := range r.pools {
+		ids = append(ids, id)
+	}
+	sortIds(ids)
+
+	ret := make([]*Pool, len(ids))
+	for i, id := range ids {
+		ret[i] = r.pools[id]
+	}
+	return ret
+}
@@ -0,0 +1,156 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testParticipantKeys() (*bls.G1, *bls.PublicKey) {
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	return bls.CastFromPublicKey(sk.GetPublicKey()), sk.GetPublicKey()
+}
+
+func newTestRegistryState(t *testing.T, s *State) {
+	for _, id := range s.config.ParticipantIndexesList() {
+		encryptionPk, identityPk := testParticipantKeys()
+		require.NoError(t, s.SaveParticipant(NewParticipant(id, encryptionPk, identityPk)))
+	}
+}
+
+func poolMembers(t *testing.T, s *State, number shared.EpochNumber) map[shared.ParticipantId]bool {
+	pools, err := s.GetEpoch(number).PoolsParticipantIds()
+	require.NoError(t, err)
+	ret := make(map[shared.ParticipantId]bool)
+	for _, members := range pools {
+		for _, id := range members {
+			ret[id] = true
+		}
+	}
+	return ret
+}
+
+func TestRegistryJoinAndExit(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+
+	encryptionPk, identityPk := testParticipantKeys()
+	require.NoError(t, s.RequestJoin(NewPendingParticipant(7, encryptionPk, identityPk)))
+	require.Error(t, s.RequestJoin(NewPendingParticipant(7, encryptionPk, identityPk)))
+	require.Error(t, s.RequestJoin(NewParticipant(8, encryptionPk, identityPk)))
+	// can't exit before the join is processed
+	require.Error(t, s.RequestExit(7))
+	require.Error(t, s.RequestExit(9))
+
+	require.NoError(t, s.RequestExit(1))
+	require.Error(t, s.RequestExit(1))
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+
+	// epoch 1 pools were fixed before the requests were processed
+	require.Equal(t, ParticipantPending, s.GetParticipant(7).Status(1))
+	require.Equal(t, ParticipantActive, s.GetParticipant(1).Status(1))
+	require.True(t, poolMembers(t, s, 1)[1])
+	require.False(t, poolMembers(t, s, 1)[7])
+
+	require.Equal(t, ParticipantActive, s.GetParticipant(7).Status(2))
+	require.Equal(t, ParticipantExited, s.GetParticipant(1).Status(2))
+	require.Equal(t, []shared.ParticipantId{2, 3, 4, 5, 6, 7}, s.Registry().ActiveIds(2))
+	require.False(t, poolMembers(t, s, 2)[1])
+	require.True(t, poolMembers(t, s, 2)[7])
+	require.Error(t, s.RequestExit(1))
+}
+
+// participants 7 and 8 join as participant 1 exits, one of epoch 2's pools grows to 4 members (threshold 3)
+func TestRegistryJoinsAndExitRepool(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+
+	for _, id := range []shared.ParticipantId{7, 8} {
+		encryptionPk, identityPk := testParticipantKeys()
+		require.NoError(t, s.RequestJoin(NewPendingParticipant(id, encryptionPk, identityPk)))
+	}
+	require.NoError(t, s.RequestExit(1))
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+
+	require.Equal(t, []shared.ParticipantId{2, 3, 4, 5, 6, 7, 8}, s.Registry().ActiveIds(2))
+	pools, err := s.GetEpoch(2).PoolsParticipantIds()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int{4, 3}, []int{len(pools[1]), len(pools[2])})
+	members := poolMembers(t, s, 2)
+	require.Len(t, members, 7)
+	require.False(t, members[1])
+	require.ElementsMatch(t, []uint32{3, 2}, []uint32{PoolThreshold(uint32(len(pools[1]))), PoolThreshold(uint32(len(pools[2])))})
+}
+
+func TestRegistryExitDelayedBelowPoolSeats(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+
+	// every participant is needed to fill the pools
+	require.NoError(t, s.RequestExit(2))
+	require.NoError(t, s.ProcessRegistryUpdates(0))
+	require.Equal(t, FarFutureEpoch, s.GetParticipant(2).ExitEpoch)
+	require.True(t, s.GetParticipant(2).ExitRequested)
+
+	// another participant joins, the exit goes through
+	encryptionPk, identityPk := testParticipantKeys()
+	require.NoError(t, s.RequestJoin(NewPendingParticipant(7, encryptionPk, identityPk)))
+	require.NoError(t, s.ProcessRegistryUpdates(1))
+	require.EqualValues(t, 3, s.GetParticipant(7).ActivationEpoch)
+	require.EqualValues(t, 3, s.GetParticipant(2).ExitEpoch)
+	require.False(t, s.GetParticipant(2).ExitRequested)
+	require.Len(t, s.Registry().ActiveIds(3), 6)
+
+	// every active participant is in a pool, pool sizes differ by at most one
+	encryptionPk, identityPk = testParticipantKeys()
+	require.NoError(t, s.RequestJoin(NewPendingParticipant(8, encryptionPk, identityPk)))
+	require.NoError(t, s.ProcessRegistryUpdates(2))
+	require.Len(t, s.Registry().ActiveIds(4), 7)
+	require.Len(t, poolMembers(t, s, 4), 7)
+	pools, err := s.GetEpoch(4).PoolsParticipantIds()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int{4, 3}, []int{len(pools[1]), len(pools[2])})
+}
+
+func TestPersistentRegistry(t *testing.T) {
+	crypto.InitBLS()
+
+	dir, err := ioutil.TempDir("", "registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.db")
+	config := net.NewTestNetworkConfig()
+
+	s, err := NewPersistentState(path, config)
+	require.NoError(t, err)
+	newTestRegistryState(t, s)
+	encryptionPk, identityPk := testParticipantKeys()
+	require.NoError(t, s.RequestJoin(NewPendingParticipant(7, encryptionPk, identityPk)))
+	require.NoError(t, s.RequestExit(1))
+	require.NoError(t, s.ProcessRegistryUpdates(4))
+	require.NoError(t, s.SetParticipantBalance(2, 3000))
+	require.NoError(t, s.Close())
+
+	s, err = NewPersistentState(path, config)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Len(t, s.Registry().Participants(), 7)
+	joined := s.GetParticipant(7)
+	require.EqualValues(t, 6, joined.ActivationEpoch)
+	require.Equal(t, FarFutureEpoch, joined.ExitEpoch)
+	require.True(t, joined.EncryptionPk.IsEqual(encryptionPk))
+	require.True(t, joined.IdentityPk.IsEqual(identityPk))
+	require.EqualValues(t, 6, s.GetParticipant(1).ExitEpoch)
+	require.EqualValues(t, 3000, s.GetParticipant(2).Balance)
+	require.Equal(t, []shared.ParticipantId{2, 3, 4, 5, 6, 7}, s.Registry().ActiveIds(6))
+}
@@ -0,0 +1,135 @@
+package state
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"sort"
+)
+
+/**
+	Registry requests (pb.RegistryRequest), signed by the requesting participant's identity key (see
+	pool_chain.VerifyRegistryRequest) and gossiped to every node. The proposer of an epoch's block includes the
+	requests it got, the block applies them in the listed order:
+		join - registers the sender as a pending participant with the keys the request carries, it signs with the
+			identity key
+		exit - requests the sender's voluntary exit
+		create pool - creates a pending pool with the request's, unused, pool id
+		deposit - deposits gwei to a pending pool
+		liquidation - requests an active pool's liquidation
+	Pool requests are made by any registered participant that isn't slashed.
+	A request is made for the epoch it's sent in, it's valid in that epoch's block and the next one's unless the
+	previous block included it already. A signed request can't be replayed in a later block.
+
+	request:
+		id (bytes) | type (1) | from | epoch | encryption pk (bytes) | identity pk (bytes) | pool id | gwei (8) |
+		signature (bytes)
+ */
+
+// checks the request can be included in epoch number's block on top of s, its signature is checked by
+// pool_chain.VerifyRegistryRequest
+func (s *State) ValidateRegistryRequest(number shared.EpochNumber, req *pb.RegistryRequest) error {
+	if req.FromParticipant == nil {
+		return fmt.Errorf("request %s has no sender", req.Id)
+	}
+	if req.Epoch != number && req.Epoch + 1 != number {
+		return fmt.Errorf("request %s of epoch %d in block %d", req.Id, req.Epoch, number)
+	}
+	if s.latestBlock != nil && s.latestBlock.Body.HasRequest(req.Id) {
+		return fmt.Errorf("request %s included in block %d already", req.Id, s.latestBlock.Epoch)
+	}
+	return nil
+}
+
+// applies a request of epoch number's block
+func (s *State) ProcessRegistryRequest(number shared.EpochNumber, req *pb.RegistryRequest) error {
+	err := s.ValidateRegistryRequest(number, req)
+	if err != nil {
+		return err
+	}
+
+	switch req.Type {
+	case pb.RegistryRequestType_JOIN:
+		encryptionPk, identityPk, err := RequestKeys(req)
+		if err != nil {
+			return err
+		}
+		return s.RequestJoin(NewPendingParticipant(req.FromParticipant.Id, encryptionPk, identityPk))
+	case pb.RegistryRequestType_EXIT:
+		return s.RequestExit(req.FromParticipant.Id)
+	case pb.RegistryRequestType_CREATE_POOL:
+		_, err := s.CreatePool(req.PoolId)
+		return err
+	case pb.RegistryRequestType_DEPOSIT:
+		return s.AddPoolStake(req.PoolId, req.Gwei)
+	case pb.RegistryRequestType_LIQUIDATION:
+		return s.RequestPoolLiquidation(req.PoolId)
+	default:
+		return fmt.Errorf("unknown request type %d", req.Type)
+	}
+}
+
+// the keys a join request registers
+func RequestKeys(req *pb.RegistryRequest) (*bls.G1, *bls.PublicKey, error) {
+	// deserializing an empty slice panics
+	if len(req.EncryptionPk) == 0 || len(req.IdentityPk) == 0 {
+		return nil, nil, fmt.Errorf("join request %s has no keys", req.Id)
+	}
+	encryptionPk := &bls.G1{}
+	err := encryptionPk.Deserialize(req.EncryptionPk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("join request %s encryption pk: %s", req.Id, err.Error())
+	}
+	identityPk := &bls.PublicKey{}
+	err = identityPk.Deserialize(req.IdentityPk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("join request %s identity pk: %s", req.Id, err.Error())
+	}
+	return encryptionPk, identityPk, nil
+}
+
+// sorts by type, pools are created before they're deposited to, then by id
+func SortRequests(requests []*pb.RegistryRequest) {
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].Type != requests[j].Type {
+			return requests[i].Type < requests[j].Type
+		}
+		return requests[i].Id < requests[j].Id
+	})
+}
+
+func (b *BlockBody) HasRequest(id string) bool {
+	for _, req := range b.Requests {
+		if req.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *encodingWriter) request(req *pb.RegistryRequest) {
+	w.bytes([]byte(req.Id))
+	w.byte(byte(req.Type))
+	w.participant(req.FromParticipant)
+	w.uint32(req.Epoch)
+	w.bytes(req.EncryptionPk)
+	w.bytes(req.IdentityPk)
+	w.uint32(req.PoolId)
+	w.uint64(req.Gwei)
+	w.bytes(req.Signature)
+}
+
+func (r *encodingReader) request() *pb.RegistryRequest {
+	return &pb.RegistryRequest{
+		Id: string(r.bytes()),
+		Type: pb.RegistryRequestType(r.byte()),
+		FromParticipant: r.participant(),
+		Epoch: r.uint32(),
+		EncryptionPk: r.bytes(),
+		IdentityPk: r.bytes(),
+		PoolId: r.uint32(),
+		Gwei: r.uint64(),
+		Signature: r.bytes(),
+	}
+}
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// an unsigned request, the state doesn't check signatures
+func testRequest(id string, t pb.RegistryRequestType, from shared.ParticipantId, epoch shared.EpochNumber) *pb.RegistryRequest {
+	return &pb.RegistryRequest{Id: id, Type: t, FromParticipant: &pb.Participant{Id: from}, Epoch: epoch}
+}
+
+func testJoinRequest(id string, from shared.ParticipantId, epoch shared.EpochNumber) *pb.RegistryRequest {
+	ret := testRequest(id, pb.RegistryRequestType_JOIN, from, epoch)
+	encryptionPk, identityPk := testParticipantKeys()
+	ret.EncryptionPk = encryptionPk.Serialize()
+	ret.IdentityPk = identityPk.Serialize()
+	return ret
+}
+
+func testPoolRequest(id string, t pb.RegistryRequestType, pool shared.PoolId, gwei uint64) *pb.RegistryRequest {
+	ret := testRequest(id, t, 2, 0)
+	ret.PoolId = pool
+	ret.Gwei = gwei
+	return ret
+}
+
+// applies the requests to s like a block of epoch number does, returns a body including them
+func applyRequests(t *testing.T, s *State, number shared.EpochNumber, requests ...*pb.RegistryRequest) *BlockBody {
+	body := NewBlockBody()
+	for _, req := range requests {
+		require.NoError(t, s.ProcessRegistryRequest(number, req))
+		body.Requests = append(body.Requests, req)
+	}
+	return body
+}
+
+func TestRegistryRequests(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+
+	join := testJoinRequest("join", 7, 0)
+	require.NoError(t, s.ProcessRegistryRequest(0, join))
+	require.Equal(t, ParticipantPending, s.GetParticipant(7).Status(0))
+	require.Equal(t, join.IdentityPk, s.GetParticipant(7).IdentityPk.Serialize())
+	require.NoError(t, s.ProcessRegistryRequest(0, testRequest("exit", pb.RegistryRequestType_EXIT, 1, 0)))
+	require.True(t, s.GetParticipant(1).ExitRequested)
+	require.NoError(t, s.ProcessRegistryRequest(0, testPoolRequest("create", pb.RegistryRequestType_CREATE_POOL, 3, 0)))
+	require.NoError(t, s.ProcessRegistryRequest(0, testPoolRequest("deposit", pb.RegistryRequestType_DEPOSIT, 3, PoolStake)))
+	require.Equal(t, PoolStake, s.GetPool(3).Stake)
+	require.NoError(t, s.ProcessRegistryRequest(0, testPoolRequest("liquidation", pb.RegistryRequestType_LIQUIDATION, 1, 0)))
+	require.True(t, s.GetPool(1).LiquidationRequested)
+
+	// a taken pool id, a join without keys, a request without sender
+	require.Error(t, s.ProcessRegistryRequest(0, testPoolRequest("a", pb.RegistryRequestType_CREATE_POOL, 3, 0)))
+	require.Error(t, s.ProcessRegistryRequest(0, testRequest("b", pb.RegistryRequestType_JOIN, 8, 0)))
+	require.Error(t, s.ProcessRegistryRequest(0, &pb.RegistryRequest{Id: "c", Type: pb.RegistryRequestType_EXIT}))
+	// made in epoch 0, it's valid in blocks 0 and 1 only
+	require.NoError(t, s.ValidateRegistryRequest(1, testRequest("d", pb.RegistryRequestType_EXIT, 2, 0)))
+	require.Error(t, s.ValidateRegistryRequest(2, testRequest("d", pb.RegistryRequestType_EXIT, 2, 0)))
+	require.Error(t, s.ValidateRegistryRequest(0, testRequest("d", pb.RegistryRequestType_EXIT, 2, 1)))
+
+	requests := []*pb.RegistryRequest{
+		testPoolRequest("b", pb.RegistryRequestType_DEPOSIT, 3, 1),
+		testPoolRequest("c", pb.RegistryRequestType_CREATE_POOL, 3, 0),
+		testPoolRequest("a", pb.RegistryRequestType_DEPOSIT, 3, 1),
+	}
+	SortRequests(requests)
+	require.Equal(t, []string{"c", "a", "b"}, []string{requests[0].Id, requests[1].Id, requests[2].Id})
+}
+
+// a request is included once, in its epoch's block or the next one
+func TestRegistryRequestsNotReplayed(t *testing.T) {
+	crypto.InitBLS()
+	s := NewInMemoryState(net.NewTestNetworkConfig())
+	newTestRegistryState(t, s)
+	genesis := s.Genesis()
+
+	create := testPoolRequest("create", pb.RegistryRequestType_CREATE_POOL, 3, 0)
+	deposit := testPoolRequest("deposit", pb.RegistryRequestType_DEPOSIT, 3, 1)
+	twice := NewBlockBody()
+	twice.Requests = []*pb.RegistryRequest{create, deposit, deposit}
+	_, err := TransitionState(genesis, &Block{ParentRoot: genesis.LatestBlockRoot(), Epoch: 0, Body: twice})
+	require.Error(t, err)
+
+	body := NewBlockBody()
+	body.Requests = []*pb.RegistryRequest{create, deposit}
+	block0 := testBlock(t, genesis, 0, body)
+	head, err := ProcessBlock(genesis, block0)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, head.GetPool(3).Stake)
+
+	// the pool is still pending, only the inclusion in block 0 stops the deposit
+	replayed := NewBlockBody()
+	replayed.Requests = []*pb.RegistryRequest{deposit}
+	_, err = TransitionState(head, &Block{ParentRoot: head.LatestBlockRoot(), Epoch: 1, Body: replayed})
+	require.Error(t, err)
+	late := NewBlockBody()
+	late.Requests = []*pb.RegistryRequest{testPoolRequest("late", pb.RegistryRequestType_DEPOSIT, 3, 1)}
+	late.Requests[0].Epoch = 1
+	block1 := testBlock(t, head, 1, late)
+	head, err = ProcessBlock(head, block1)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, head.GetPool(3).Stake)
+	_, err = TransitionState(head, &Block{ParentRoot: head.LatestBlockRoot(), Epoch: 2, Body: late})
+	require.Error(t, err)
+}
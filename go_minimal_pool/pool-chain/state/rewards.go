@@ -0,0 +1,138 @@
+package state
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+)
+
+/**
+	Rewards and penalties of block producers. Every member of an epoch's pools contributes the redistribution of its
+	share to the next epoch's pool and a partial signature for every one of its pool's duties. The epoch's block
+	records the members' contributions as its proposer saw them at the epoch's end, processing the block credits
+	ContributionReward for every valid contribution and debits MissingContributionPenalty or
+	InvalidContributionPenalty for every missing or invalid one (see net.NetworkConfig). Balances don't go below zero.
+	A share is only readable by its recipient, its validity is its commitments' (see participant/accounting.go).
+	A member refusing to sign a duty its slashing protection refuses sends a signed refusal instead of its partial
+	signature (pool_chain.IsRefusal), the duty isn't due. Validators check the proposer's contributions against
+	their own view before accepting its block.
+ */
+
+type ContributionStatus byte
+
+const (
+	// nothing was due, e.g. a liquidating pool's members don't redistribute their shares and a member refusing
+	// every duty signs none
+	ContributionNotDue ContributionStatus = iota
+	ContributionValid
+	// didn't arrive before the epoch's end
+	ContributionMissing
+	ContributionInvalid
+)
+
+func (s ContributionStatus) String() string {
+	switch s {
+	case ContributionNotDue:
+		return "not due"
+	case ContributionValid:
+		return "valid"
+	case ContributionMissing:
+		return "missing"
+	case ContributionInvalid:
+		return "invalid"
+	default:
+		return "unknown"
+	}
+}
+
+// an epoch participant's contributions, the signatures are valid only if every one of the pool's duties was signed
+type Contribution struct {
+	ParticipantId shared.ParticipantId
+	Share ContributionStatus
+	Signatures ContributionStatus
+}
+
+// applies the contributions to epoch number to the participants' balances. Contributions are sorted by participant,
+// at most one per member of the epoch's pools, members without one are neither rewarded nor penalized.
+func (s *State) ProcessContributions(number shared.EpochNumber, contributions []*Contribution) error {
+	if len(contributions) == 0 {
+		return nil
+	}
+	members, err := s.epochMembers(number)
+	if err != nil {
+		return err
+	}
+	for i, c := range contributions {
+		if i > 0 && c.ParticipantId <= contributions[i - 1].ParticipantId {
+			return fmt.Errorf("contributions are not sorted by participant")
+		}
+		if !members[c.ParticipantId] {
+			return fmt.Errorf("contribution of %d which is not an epoch %d participant", c.ParticipantId, number)
+		}
+		if c.Share > ContributionInvalid || c.Signatures > ContributionInvalid {
+			return fmt.Errorf("unknown participant %d contribution status", c.ParticipantId)
+		}
+
+		participant, err := s.registry.updateParticipant(c.ParticipantId, func(participant *Participant) error {
+			for _, status := range []ContributionStatus{c.Share, c.Signatures} {
+				participant.Balance = s.applyContribution(participant.Balance, status)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = s.db.SaveParticipant(participant)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sets a participant's balance as is, e.g. to the finalized state's
+func (s *State) SetParticipantBalance(id shared.ParticipantId, gwei uint64) error {
+	participant, err := s.registry.updateParticipant(id, func(participant *Participant) error {
+		participant.Balance = gwei
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.SaveParticipant(participant)
+}
+
+func (s *State) applyContribution(balance uint64, status ContributionStatus) uint64 {
+	penalty := uint64(0)
+	switch status {
+	case ContributionValid:
+		return balance + s.config.ContributionReward
+	case ContributionMissing:
+		penalty = s.config.MissingContributionPenalty
+	case ContributionInvalid:
+		penalty = s.config.InvalidContributionPenalty
+	}
+	if penalty > balance {
+		return 0
+	}
+	return balance - penalty
+}
+
+// the members of epoch number's pools, shuffled from the state's registry like the epoch's
+func (s *State) epochMembers(number shared.EpochNumber) (map[shared.ParticipantId]bool, error) {
+	seed, err := crypto.MixSeed(s.config.GenesisSeed, number)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := NewEpochInstance(number, seed, s.config, s.registry).PoolsParticipantIds()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[shared.ParticipantId]bool)
+	for _, members := range pools {
+		for _, id := range members {
+			ret[id] = true
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestProcessContributions(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+	s := NewInMemoryState(config)
+	newTestRegistryState(t, s)
+
+	require.NoError(t, s.ProcessContributions(0, []*Contribution{
+		{ParticipantId: 1, Share: ContributionValid, Signatures: ContributionValid},
+		{ParticipantId: 2, Share: ContributionNotDue, Signatures: ContributionValid},
+		{ParticipantId: 3, Share: ContributionValid, Signatures: ContributionMissing},
+		{ParticipantId: 4, Share: ContributionValid, Signatures: ContributionInvalid},
+	}))
+	require.Equal(t, 2 * config.ContributionReward, s.GetParticipant(1).Balance)
+	require.Equal(t, config.ContributionReward, s.GetParticipant(2).Balance)
+	require.Equal(t, config.ContributionReward - config.MissingContributionPenalty, s.GetParticipant(3).Balance)
+	// balances don't go below zero
+	require.EqualValues(t, 0, s.GetParticipant(4).Balance)
+	require.EqualValues(t, 0, s.GetParticipant(5).Balance)
+
+	require.NoError(t, s.ProcessContributions(1, []*Contribution{
+		{ParticipantId: 1, Share: ContributionMissing, Signatures: ContributionNotDue},
+	}))
+	require.Equal(t, 2 * config.ContributionReward - config.MissingContributionPenalty, s.GetParticipant(1).Balance)
+
+	// unsorted, duplicate, not an epoch participant or unknown status
+	for _, contributions := range [][]*Contribution{
+		{{ParticipantId: 2}, {ParticipantId: 1}},
+		{{ParticipantId: 1}, {ParticipantId: 1}},
+		{{ParticipantId: 9}},
+		{{ParticipantId: 1, Share: ContributionInvalid + 1}},
+	} {
+		require.Error(t, s.ProcessContributions(2, contributions))
+	}
+
+	// a joined participant only contributes once active
+	encryptionPk, identityPk := testParticipantKeys()
+	require.NoError(t, s.RequestJoin(NewPendingParticipant(7, encryptionPk, identityPk)))
+	require.NoError(t, s.ProcessRegistryUpdates(2))
+	joined := []*Contribution{{ParticipantId: 7, Share: ContributionValid, Signatures: ContributionValid}}
+	require.Error(t, s.ProcessContributions(3, joined))
+	require.NoError(t, s.ProcessContributions(4, joined))
+	require.Equal(t, 2 * config.ContributionReward, s.GetParticipant(7).Balance)
+}
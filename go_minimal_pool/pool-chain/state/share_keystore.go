@@ -0,0 +1,170 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// the participant's per epoch shares, every share is an EIP-2335 keystore file (epoch_<number>.json) in dir.
+// Shares never touch the state db, once a share was redistributed it's deleted (see pool_rotation.md).
+type ShareKeystore struct {
+	dir string
+	password string
+	params crypto.KeystoreParams
+
+	// hashes of the shares already written, SaveEpoch is called often and the kdf is slow by design
+	written map[shared.EpochNumber][32]byte
+	lock sync.Mutex
+}
+
+// opens (or creates) the keystore dir and unlocks it, fails if the password doesn't decrypt the existing shares
+func NewShareKeystore(dir string, password string, params crypto.KeystoreParams) (*ShareKeystore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &ShareKeystore{
+		dir: dir,
+		password: password,
+		params: params,
+		written: make(map[shared.EpochNumber][32]byte),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "epoch_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		var number shared.EpochNumber
+		_, err := fmt.Sscanf(filepath.Base(f), "epoch_%d.json", &number)
+		if err != nil {
+			continue
+		}
+		share, err := ret.LoadShare(number)
+		if err != nil {
+			return nil, fmt.Errorf("could not unlock share keystore: %s", err.Error())
+		}
+		ret.written[number] = sha256.Sum256(share.Serialize())
+		share.Clear()
+	}
+
+	return ret, nil
+}
+
+func (k *ShareKeystore) SaveShare(number shared.EpochNumber, share *bls.Fr) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	h := sha256.Sum256(share.Serialize())
+	if written, found := k.written[number]; found && written == h {
+		return nil
+	}
+
+	pk := bls.CastToSecretKey(share).GetPublicKey()
+	keystore, err := crypto.EncryptKeystore(share.Serialize(), pk.Serialize(), k.password, fmt.Sprintf("epoch %d share", number), k.params)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(keystore, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file and rename so a crash never leaves a partial keystore
+	tmp, err := ioutil.TempFile(k.dir, "tmp_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), k.path(number))
+	if err != nil {
+		return err
+	}
+
+	k.written[number] = h
+	return nil
+}
+
+// will return nil,nil if the share is not found
+func (k *ShareKeystore) LoadShare(number shared.EpochNumber) (*bls.Fr, error) {
+	data, err := ioutil.ReadFile(k.path(number))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keystore := &crypto.Keystore{}
+	err = json.Unmarshal(data, keystore)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := keystore.Decrypt(k.password)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &bls.Fr{}
+	err = ret.Deserialize(secret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// overwrites the keystore file before removing it
+func (k *ShareKeystore) DeleteShare(number shared.EpochNumber) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	delete(k.written, number)
+
+	path := k.path(number)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(make([]byte, info.Size()))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (k *ShareKeystore) path(number shared.EpochNumber) string {
+	return filepath.Join(k.dir, fmt.Sprintf("epoch_%d.json", number))
+}
@@ -0,0 +1,246 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+)
+
+/**
+	Slashing of provably malicious participants. Evidence is made of the offender's own signed messages (see
+	pool-chain/auth.go) and verified with pool_chain.VerifyEvidence before a block including it is accepted:
+		bad share: a share that doesn't decrypt to a point on the dealer's signed commitments, with the recipient's
+			decryption proof (crypto.ProveDecryption). A malformed ciphertext needs no proof.
+		double share: two shares of the same epoch and pool with different commitments, a dealer redistributes its
+			share with a single polynomial
+		double signature: two different partial signatures of the same pool duty
+	Processing a block's evidence debits SlashingPenalty from the offender's balance and removes it from the pools.
+	Epoch N's block is finalized during N + 1 when the pools of N + 2 are fixed already, the offender exits at
+	N + RegistryUpdateDelay + 1 (unless it exits earlier). Unlike voluntary exits removals aren't delayed, pools
+	can be left with less than PoolSize participants until others join.
+
+	evidence:
+		type (1) | shares (share) | signatures (signature) | decryption proof (bytes)
+		share: id (bytes) | from | to | share (bytes) | commitments (bytes) | pool id | epoch | signature (bytes)
+		signature: id (bytes) | from | sig (bytes) | pool id | epoch | signing root (bytes) | signature (bytes)
+	participants are encoded as their id, the messages' gossip ttl isn't.
+ */
+
+type EvidenceType byte
+
+const (
+	EvidenceBadShare EvidenceType = iota + 1
+	EvidenceDoubleShare
+	EvidenceDoubleSignature
+)
+
+func (t EvidenceType) String() string {
+	switch t {
+	case EvidenceBadShare:
+		return "bad share"
+	case EvidenceDoubleShare:
+		return "double share"
+	case EvidenceDoubleSignature:
+		return "double signature"
+	default:
+		return "unknown"
+	}
+}
+
+type Evidence struct {
+	Type EvidenceType
+	// the offender's signed messages, one share for a bad share and two conflicting shares or signatures otherwise
+	Shares []*pb.ShareDistribution
+	Signatures []*pb.SignatureDistribution
+	// bad share only, the recipient's proof of the share's decryption, empty for a malformed ciphertext
+	DecryptionProof []byte
+}
+
+// checks the evidence's messages are of the same offender, epoch and pool. That they're signed and conflict is
+// checked by pool_chain.VerifyEvidence.
+func (e *Evidence) Validate() error {
+	switch e.Type {
+	case EvidenceBadShare:
+		if len(e.Shares) != 1 || len(e.Signatures) != 0 {
+			return fmt.Errorf("bad share evidence needs a share")
+		}
+	case EvidenceDoubleShare:
+		if len(e.Shares) != 2 || len(e.Signatures) != 0 {
+			return fmt.Errorf("double share evidence needs 2 shares")
+		}
+	case EvidenceDoubleSignature:
+		if len(e.Shares) != 0 || len(e.Signatures) != 2 {
+			return fmt.Errorf("double signature evidence needs 2 signatures")
+		}
+		if !bytes.Equal(e.Signatures[0].SigningRoot, e.Signatures[1].SigningRoot) {
+			return fmt.Errorf("signatures of different duties")
+		}
+	default:
+		return fmt.Errorf("unknown evidence type %d", e.Type)
+	}
+
+	for _, share := range e.Shares {
+		if share.FromParticipant == nil || share.ToParticipant == nil {
+			return fmt.Errorf("share without participants")
+		}
+		if share.FromParticipant.Id != e.Offender() || share.Epoch != e.Epoch() || share.PoolId != e.PoolId() {
+			return fmt.Errorf("shares of different senders, epochs or pools")
+		}
+	}
+	for _, sig := range e.Signatures {
+		if sig.FromParticipant == nil {
+			return fmt.Errorf("signature without sender")
+		}
+		if sig.FromParticipant.Id != e.Offender() || sig.Epoch != e.Epoch() || sig.PoolId != e.PoolId() {
+			return fmt.Errorf("signatures of different senders, epochs or pools")
+		}
+		// a refusal to sign (pool_chain.IsRefusal)
+		if len(sig.Sig) == 0 {
+			return fmt.Errorf("signature without sig")
+		}
+	}
+	return nil
+}
+
+// the sender of the evidence's first message, call Validate first
+func (e *Evidence) Offender() shared.ParticipantId {
+	if len(e.Shares) > 0 {
+		return e.Shares[0].FromParticipant.Id
+	}
+	return e.Signatures[0].FromParticipant.Id
+}
+
+func (e *Evidence) Epoch() shared.EpochNumber {
+	if len(e.Shares) > 0 {
+		return e.Shares[0].Epoch
+	}
+	return e.Signatures[0].Epoch
+}
+
+func (e *Evidence) PoolId() shared.PoolId {
+	if len(e.Shares) > 0 {
+		return e.Shares[0].PoolId
+	}
+	return e.Signatures[0].PoolId
+}
+
+// slashes the offenders of the evidence included in epoch number's block, a participant is slashed once
+func (s *State) ProcessEvidence(number shared.EpochNumber, evidence []*Evidence) error {
+	for _, e := range evidence {
+		err := e.Validate()
+		if err != nil {
+			return err
+		}
+		if e.Epoch() > number {
+			return fmt.Errorf("evidence of future epoch %d", e.Epoch())
+		}
+
+		exit := number + RegistryUpdateDelay + 1
+		participant, err := s.registry.updateParticipant(e.Offender(), func(participant *Participant) error {
+			if participant.Slashed {
+				return fmt.Errorf("participant %d already slashed", participant.Id)
+			}
+			participant.Slashed = true
+			if participant.Balance > s.config.SlashingPenalty {
+				participant.Balance -= s.config.SlashingPenalty
+			} else {
+				participant.Balance = 0
+			}
+			// a requested exit can still take effect earlier
+			if participant.ExitEpoch > exit {
+				participant.ExitEpoch = exit
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = s.db.SaveParticipant(participant)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *encodingWriter) evidence(e *Evidence) {
+	w.byte(byte(e.Type))
+	w.uint32(uint32(len(e.Shares)))
+	for _, share := range e.Shares {
+		w.bytes([]byte(share.Id))
+		w.participant(share.FromParticipant)
+		w.participant(share.ToParticipant)
+		w.bytes(share.Share)
+		w.uint32(uint32(len(share.Commitments)))
+		for _, c := range share.Commitments {
+			w.bytes(c)
+		}
+		w.uint32(share.PoolId)
+		w.uint32(share.Epoch)
+		w.bytes(share.Signature)
+	}
+	w.uint32(uint32(len(e.Signatures)))
+	for _, sig := range e.Signatures {
+		w.bytes([]byte(sig.Id))
+		w.participant(sig.FromParticipant)
+		w.bytes(sig.Sig)
+		w.uint32(sig.PoolId)
+		w.uint32(sig.Epoch)
+		w.bytes(sig.SigningRoot)
+		w.bytes(sig.Signature)
+	}
+	w.bytes(e.DecryptionProof)
+}
+
+func (r *encodingReader) evidence() *Evidence {
+	ret := &Evidence{Type: EvidenceType(r.byte())}
+	cnt := r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		share := &pb.ShareDistribution{
+			Id: string(r.bytes()),
+			FromParticipant: r.participant(),
+			ToParticipant: r.participant(),
+			Share: r.bytes(),
+		}
+		commitments := r.uint32()
+		for j := uint32(0) ; j < commitments && r.err == nil ; j++ {
+			share.Commitments = append(share.Commitments, r.bytes())
+		}
+		share.PoolId = r.uint32()
+		share.Epoch = r.uint32()
+		share.Signature = r.bytes()
+		ret.Shares = append(ret.Shares, share)
+	}
+	cnt = r.uint32()
+	for i := uint32(0) ; i < cnt && r.err == nil ; i++ {
+		ret.Signatures = append(ret.Signatures, &pb.SignatureDistribution{
+			Id: string(r.bytes()),
+			FromParticipant: r.participant(),
+			Sig: r.bytes(),
+			PoolId: r.uint32(),
+			Epoch: r.uint32(),
+			SigningRoot: r.bytes(),
+			Signature: r.bytes(),
+		})
+	}
+	ret.DecryptionProof = r.bytes()
+	return ret
+}
+
+// 1 byte presence flag | id
+func (w *encodingWriter) participant(participant *pb.Participant) {
+	if participant == nil {
+		w.byte(0)
+		return
+	}
+	w.byte(1)
+	w.uint32(participant.Id)
+}
+
+func (r *encodingReader) participant() *pb.Participant {
+	if r.byte() == 0 {
+		return nil
+	}
+	return &pb.Participant{Id: r.uint32()}
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net/pb"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// two signatures of the same duty by from, only structurally valid
+func testDoubleSignature(from shared.ParticipantId, epoch shared.EpochNumber) *Evidence {
+	sig := func(b byte) *pb.SignatureDistribution {
+		return &pb.SignatureDistribution{
+			Id: "sig",
+			FromParticipant: &pb.Participant{Id: from},
+			Sig: []byte{b},
+			PoolId: 1,
+			Epoch: epoch,
+			SigningRoot: []byte{1, 2, 3},
+			Signature: []byte{b, b},
+		}
+	}
+	return &Evidence{Type: EvidenceDoubleSignature, Signatures: []*pb.SignatureDistribution{sig(1), sig(2)}}
+}
+
+func TestProcessEvidence(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+	s := NewInMemoryState(config)
+	newTestRegistryState(t, s)
+	require.NoError(t, s.SetParticipantBalance(2, config.SlashingPenalty + 5))
+	require.NoError(t, s.RequestExit(3))
+
+	require.NoError(t, s.ProcessEvidence(1, []*Evidence{testDoubleSignature(2, 1), testDoubleSignature(3, 0)}))
+	slashed := s.GetParticipant(2)
+	require.True(t, slashed.Slashed)
+	require.EqualValues(t, 5, slashed.Balance)
+	require.EqualValues(t, 1 + RegistryUpdateDelay + 1, slashed.ExitEpoch)
+	require.EqualValues(t, 0, s.GetParticipant(3).Balance)
+	// removed from the pools once the pools of the epoch after the block's finalization are shuffled
+	require.NoError(t, s.ProcessRegistryUpdates(1))
+	require.NoError(t, s.ProcessRegistryUpdates(2))
+	require.False(t, s.GetParticipant(3).ExitRequested)
+	require.EqualValues(t, 1 + RegistryUpdateDelay + 1, s.GetParticipant(3).ExitEpoch)
+	require.True(t, poolMembers(t, s, 3)[2])
+	require.False(t, poolMembers(t, s, 4)[2])
+	require.False(t, poolMembers(t, s, 4)[3])
+
+	// slashed once, no future or unknown offenders
+	require.Error(t, s.ProcessEvidence(2, []*Evidence{testDoubleSignature(2, 2)}))
+	require.Error(t, s.ProcessEvidence(2, []*Evidence{testDoubleSignature(1, 3)}))
+	require.Error(t, s.ProcessEvidence(2, []*Evidence{testDoubleSignature(9, 2)}))
+
+	// the slashing is persisted with the participant
+	data, err := EncodeParticipant(slashed)
+	require.NoError(t, err)
+	decoded, err := DecodeParticipant(data)
+	require.NoError(t, err)
+	require.True(t, decoded.Slashed)
+}
+
+func TestEvidenceValidate(t *testing.T) {
+	require.NoError(t, testDoubleSignature(1, 0).Validate())
+
+	e := testDoubleSignature(1, 0)
+	e.Signatures[1].FromParticipant.Id = 2
+	require.Error(t, e.Validate())
+	e = testDoubleSignature(1, 0)
+	e.Signatures[1].SigningRoot = []byte{1}
+	require.Error(t, e.Validate())
+	e = testDoubleSignature(1, 0)
+	e.Signatures = e.Signatures[:1]
+	require.Error(t, e.Validate())
+	e = testDoubleSignature(1, 0)
+	e.Type = EvidenceDoubleShare
+	require.Error(t, e.Validate())
+
+	share := &pb.ShareDistribution{
+		FromParticipant: &pb.Participant{Id: 1},
+		ToParticipant: &pb.Participant{Id: 2},
+		Share: []byte{1},
+		PoolId: 1,
+	}
+	require.Error(t, (&Evidence{Type: EvidenceBadShare, Shares: []*pb.ShareDistribution{share, share}, DecryptionProof: []byte{1}}).Validate())
+	require.NoError(t, (&Evidence{Type: EvidenceBadShare, Shares: []*pb.ShareDistribution{share}, DecryptionProof: []byte{1}}).Validate())
+	// a malformed ciphertext needs no proof
+	require.NoError(t, (&Evidence{Type: EvidenceBadShare, Shares: []*pb.ShareDistribution{share}}).Validate())
+}
@@ -0,0 +1,205 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+)
+
+/**
+	SSZ serialization and hash tree roots of the network state, two nodes hold the same state iff their states' roots
+	are equal. Blocks commit to the root of the state after them (Block.StateRoot).
+	Integers are uint64, keys are the serialized (compressed) points and missing ones are zero bytes.
+
+		Participant: id | encryption pk (Bytes48) | identity pk (Bytes48) | activation epoch | exit epoch |
+			exit requested (boolean) | balance | slashed (boolean)
+		Pool: id | size | pk (Bytes48) | stake | activation epoch | exit epoch | liquidation requested (boolean) |
+			exit signature (Bytes96)
+		PublicShare: participant id | share (Bytes48)
+		Epoch: number | seed (Bytes32) | public shares (List[PublicShare, ParticipantRegistryLimit])
+		Registry: List[Participant, ParticipantRegistryLimit]
+		State: participants (Registry) | pools (List[Pool, PoolRegistryLimit])
+
+	List elements are sorted by id. An epoch's root covers only what every node of the network agrees on, the
+	participant's share, the bad signers it found and its pool's reconstructed signatures are left out. The state's
+	latest block isn't part of its root either, the block commits to the root.
+	The db keeps its own encoding (see encoding.go), SSZ is only serialized.
+ */
+
+const (
+	ParticipantRegistryLimit = uint64(1) << 20
+	PoolRegistryLimit = uint64(1) << 20
+)
+
+const (
+	participantSSZSize = 8 + 48 + 48 + 8 + 8 + 1 + 8 + 1
+	poolSSZSize = 8 + 8 + 48 + 8 + 8 + 8 + 1 + 96
+	publicShareSSZSize = 8 + 48
+)
+
+func (p *Participant) MarshalSSZ() []byte {
+	w := &sszWriter{}
+	w.uint64(uint64(p.Id))
+	w.point(p.EncryptionPk != nil, p.EncryptionPk.Serialize, 48)
+	w.point(p.IdentityPk != nil, p.IdentityPk.Serialize, 48)
+	w.uint64(uint64(p.ActivationEpoch))
+	w.uint64(uint64(p.ExitEpoch))
+	w.bool(p.ExitRequested)
+	w.uint64(p.Balance)
+	w.bool(p.Slashed)
+	return w.buf.Bytes()
+}
+
+func (p *Participant) HashTreeRoot() eth2.Root {
+	data := p.MarshalSSZ()
+	return eth2.Merkleize(
+		eth2.Uint64Root(uint64(p.Id)),
+		eth2.BytesRoot(data[8:56]),
+		eth2.BytesRoot(data[56:104]),
+		eth2.Uint64Root(uint64(p.ActivationEpoch)),
+		eth2.Uint64Root(uint64(p.ExitEpoch)),
+		eth2.BoolRoot(p.ExitRequested),
+		eth2.Uint64Root(p.Balance),
+		eth2.BoolRoot(p.Slashed),
+		)
+}
+
+func (pool *Pool) MarshalSSZ() []byte {
+	w := &sszWriter{}
+	w.uint64(uint64(pool.Id))
+	w.uint64(uint64(pool.Size))
+	w.point(pool.Pk != nil, pool.Pk.Serialize, 48)
+	w.uint64(pool.Stake)
+	w.uint64(uint64(pool.ActivationEpoch))
+	w.uint64(uint64(pool.ExitEpoch))
+	w.bool(pool.LiquidationRequested)
+	w.point(pool.ExitSignature != nil, pool.ExitSignature.Serialize, 96)
+	return w.buf.Bytes()
+}
+
+func (pool *Pool) HashTreeRoot() eth2.Root {
+	data := pool.MarshalSSZ()
+	return eth2.Merkleize(
+		eth2.Uint64Root(uint64(pool.Id)),
+		eth2.Uint64Root(uint64(pool.Size)),
+		eth2.BytesRoot(data[16:64]),
+		eth2.Uint64Root(pool.Stake),
+		eth2.Uint64Root(uint64(pool.ActivationEpoch)),
+		eth2.Uint64Root(uint64(pool.ExitEpoch)),
+		eth2.BoolRoot(pool.LiquidationRequested),
+		eth2.BytesRoot(data[poolSSZSize - 96:]),
+		)
+}
+
+func (epoch *Epoch) MarshalSSZ() []byte {
+	w := &sszWriter{}
+	w.uint64(uint64(epoch.Number))
+	w.buf.Write(epoch.epochSeed[:])
+	w.offset(8 + 32 + 4)
+	for _, share := range epoch.sortedPublicShares() {
+		w.buf.Write(share)
+	}
+	return w.buf.Bytes()
+}
+
+func (epoch *Epoch) HashTreeRoot() eth2.Root {
+	roots := make([]eth2.Root, 0)
+	for _, share := range epoch.sortedPublicShares() {
+		roots = append(roots, eth2.Merkleize(eth2.BytesRoot(share[:8]), eth2.BytesRoot(share[8:])))
+	}
+	return eth2.Merkleize(
+		eth2.Uint64Root(uint64(epoch.Number)),
+		eth2.Root(epoch.epochSeed),
+		eth2.ListRoot(roots, ParticipantRegistryLimit),
+		)
+}
+
+// every public share serialized as a PublicShare, sorted by participant
+func (epoch *Epoch) sortedPublicShares() [][]byte {
+	epoch.publicSharesLock.RLock()
+	defer epoch.publicSharesLock.RUnlock()
+
+	ids := make([]uint32, 0, len(epoch.PublicShares))
+	for id := range epoch.PublicShares {
+		ids = append(ids, id)
+	}
+	sortIds(ids)
+	ret := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		w := &sszWriter{}
+		w.uint64(uint64(id))
+		w.buf.Write(epoch.PublicShares[id].Serialize())
+		ret = append(ret, w.buf.Bytes())
+	}
+	return ret
+}
+
+func (r *Registry) MarshalSSZ() []byte {
+	w := &sszWriter{}
+	for _, p := range r.Participants() {
+		w.buf.Write(p.MarshalSSZ())
+	}
+	return w.buf.Bytes()
+}
+
+func (r *Registry) HashTreeRoot() eth2.Root {
+	roots := make([]eth2.Root, 0)
+	for _, p := range r.Participants() {
+		roots = append(roots, p.HashTreeRoot())
+	}
+	return eth2.ListRoot(roots, ParticipantRegistryLimit)
+}
+
+func (s *State) MarshalSSZ() []byte {
+	participants := s.registry.MarshalSSZ()
+	w := &sszWriter{}
+	w.offset(4 + 4)
+	w.offset(4 + 4 + len(participants))
+	w.buf.Write(participants)
+	for _, pool := range s.Pools() {
+		w.buf.Write(pool.MarshalSSZ())
+	}
+	return w.buf.Bytes()
+}
+
+func (s *State) HashTreeRoot() eth2.Root {
+	roots := make([]eth2.Root, 0)
+	for _, pool := range s.Pools() {
+		roots = append(roots, pool.HashTreeRoot())
+	}
+	return eth2.Merkleize(s.registry.HashTreeRoot(), eth2.ListRoot(roots, PoolRegistryLimit))
+}
+
+type sszWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *sszWriter) uint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *sszWriter) bool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+// a variable size field's offset
+func (w *sszWriter) offset(v int) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(v))
+	w.buf.Write(b[:])
+}
+
+// a serialized key or signature, size zero bytes if missing
+func (w *sszWriter) point(present bool, serialize func() []byte, size int) {
+	if present {
+		w.buf.Write(serialize())
+	} else {
+		w.buf.Write(make([]byte, size))
+	}
+}
@@ -0,0 +1,95 @@
+package state
+
+import (
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStateHashTreeRoot(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+	s := NewInMemoryState(config)
+	newTestRegistryState(t, s)
+	other := NewInMemoryState(config)
+	for _, p := range s.Registry().Participants() {
+		require.NoError(t, other.SaveParticipant(NewParticipant(p.Id, p.EncryptionPk, p.IdentityPk)))
+	}
+
+	// same participants and pools, same root
+	require.Equal(t, s.HashTreeRoot(), other.HashTreeRoot())
+	data := s.MarshalSSZ()
+	require.Len(t, data, 8 + 6 * participantSSZSize + 2 * poolSSZSize)
+	require.Equal(t, data, other.MarshalSSZ())
+
+	p := s.GetParticipant(1)
+	require.Len(t, p.MarshalSSZ(), participantSSZSize)
+	require.Equal(t, eth2.Merkleize(
+		eth2.Uint64Root(1),
+		eth2.BytesRoot(p.EncryptionPk.Serialize()),
+		eth2.BytesRoot(p.IdentityPk.Serialize()),
+		eth2.Uint64Root(0),
+		eth2.Uint64Root(uint64(FarFutureEpoch)),
+		eth2.BoolRoot(false),
+		eth2.Uint64Root(0),
+		eth2.BoolRoot(false),
+		), p.HashTreeRoot())
+	roots := make([]eth2.Root, 0)
+	for _, p := range s.Registry().Participants() {
+		roots = append(roots, p.HashTreeRoot())
+	}
+	require.Equal(t, eth2.ListRoot(roots, ParticipantRegistryLimit), s.Registry().HashTreeRoot())
+
+	// a missing key is zero bytes
+	pool := s.GetPool(1)
+	require.Len(t, pool.MarshalSSZ(), poolSSZSize)
+	empty := pool.HashTreeRoot()
+	sk := &bls.SecretKey{}
+	sk.SetByCSPRNG()
+	require.NoError(t, s.SetPoolPk(1, 3, sk.GetPublicKey()))
+	require.NotEqual(t, empty, s.GetPool(1).HashTreeRoot())
+	require.NotEqual(t, s.HashTreeRoot(), other.HashTreeRoot())
+	require.NoError(t, other.SetPoolPk(1, 3, sk.GetPublicKey()))
+	require.Equal(t, s.HashTreeRoot(), other.HashTreeRoot())
+
+	require.NoError(t, s.RequestExit(2))
+	require.NotEqual(t, s.HashTreeRoot(), other.HashTreeRoot())
+}
+
+func TestEpochHashTreeRoot(t *testing.T) {
+	crypto.InitBLS()
+	config := net.NewTestNetworkConfig()
+
+	epoch := NewEpochInstance(5, [32]byte{1,2,3}, config, NewRegistry())
+	require.Len(t, epoch.MarshalSSZ(), 8 + 32 + 4)
+	require.Equal(t, eth2.Merkleize(
+		eth2.Uint64Root(5),
+		eth2.Root{1,2,3},
+		eth2.ListRoot(nil, ParticipantRegistryLimit),
+		), epoch.HashTreeRoot())
+
+	share := &bls.Fr{}
+	share.SetByCSPRNG()
+	publicShare := bls.CastFromPublicKey(bls.CastToSecretKey(share).GetPublicKey())
+	epoch.SetPublicShare(3, publicShare)
+	epoch.SetPublicShare(1, publicShare)
+	root := epoch.HashTreeRoot()
+	require.Len(t, epoch.MarshalSSZ(), 8 + 32 + 4 + 2 * publicShareSSZSize)
+
+	// the participant's own view isn't part of the root
+	epoch.ParticipantShare = share
+	epoch.BadSigners[2] = true
+	epoch.ReconstructedSignatures[eth2.Root{1}] = crypto.Sign(share, []byte("hello"))
+	epoch.EpochSigVerified = true
+	require.Equal(t, root, epoch.HashTreeRoot())
+
+	other := NewEpochInstance(5, [32]byte{1,2,3}, config, NewRegistry())
+	other.SetPublicShare(1, publicShare)
+	require.NotEqual(t, root, other.HashTreeRoot())
+	other.SetPublicShare(3, publicShare)
+	require.Equal(t, root, other.HashTreeRoot())
+	require.Equal(t, epoch.MarshalSSZ(), other.MarshalSSZ())
+}
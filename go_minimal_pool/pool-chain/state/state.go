@@ -1,48 +1,175 @@
 package state
 
 import (
+	"fmt"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/crypto"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/pool-chain/net"
 	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/shared"
+	"github.com/herumi/bls-eth-go-binary/bls"
+	"io"
 )
 
 type DB interface {
 	// will return nil,nil if epoch not found
 	GetEpoch(number shared.EpochNumber) (*Epoch,error)
 	SaveEpoch(epoch *Epoch) error
+	SavePool(pool *Pool) error
+	GetPools() (map[shared.PoolId]*Pool, error)
+	SaveParticipant(participant *Participant) error
+	GetParticipants() (map[shared.ParticipantId]*Participant, error)
+	BlockStore
 }
 
 type State struct {
 	db           DB
-	Pools        map[shared.PoolId]*Pool
-	seed         [32]byte
+	registry     *Registry
+	config       *net.NetworkConfig
+	// if set, participant shares are kept encrypted in it
+	shareKeystore *ShareKeystore
+
+	// the last block processed (ProcessBlock), nil for the genesis state and states that don't follow blocks
+	latestBlock *Block
 }
 
-func NewInMemoryState(seed [32]byte) *State {
-	return & State{
+// the state of config's network, epochs are derived from its genesis seed and pools
+func NewInMemoryState(config *net.NetworkConfig) *State {
+	ret := & State{
 		db:           NewInMemoryDb(),
-		Pools:        make(map[shared.PoolId]*Pool),
-		seed:         seed,
+		registry:     NewRegistry(),
+		config:       config,
 	}
+	// the in memory db doesn't fail
+	ret.registerGenesisPools()
+	return ret
+}
+
+// state backed by a db file at path, epochs (including the participant's share), pools and the participant
+// registry survive a restart
+func NewPersistentState(path string, config *net.NetworkConfig) (*State, error) {
+	registry := NewRegistry()
+	db, err := NewPersistentDb(path, config, registry)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := db.GetPools()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	participants, err := db.GetParticipants()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, participant := range participants {
+		registry.save(participant)
+	}
+	for _, pool := range pools {
+		registry.savePool(pool)
+	}
+
+	ret := &State{
+		db:           db,
+		registry:     registry,
+		config:       config,
+	}
+	err = ret.registerGenesisPools()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return ret, nil
+}
+
+// registers config's genesis pools, active from epoch 0 and waiting for the genesis DKG, unless registered already
+func (s *State) registerGenesisPools() error {
+	for id := shared.PoolId(1) ; id <= s.config.NumberOfPools ; id++ {
+		if s.registry.GetPool(id) != nil {
+			continue
+		}
+		err := s.SavePool(NewPool(id, 0, nil))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closes the underlying db if it needs closing
+func (s *State) Close() error {
+	if closer, ok := s.db.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (s *State) SetShareKeystore(keystore *ShareKeystore) {
+	s.shareKeystore = keystore
 }
 
 func (s *State) SaveEpoch(epoch *Epoch) error {
+	if s.shareKeystore != nil && epoch.ParticipantShare != nil {
+		err := s.shareKeystore.SaveShare(epoch.Number, epoch.ParticipantShare)
+		if err != nil {
+			return err
+		}
+	}
 	return s.db.SaveEpoch(epoch)
 }
 
-func (s *State) GetEpoch(number shared.EpochNumber) *Epoch {
+// clears the epoch's share from memory and deletes it from the keystore, called once the share was redistributed
+func (s *State) DeleteParticipantShare(number shared.EpochNumber) error {
+	epoch := s.GetEpoch(number)
+	if epoch == nil {
+		return fmt.Errorf("epoch %d not found", number)
+	}
+	if epoch.ParticipantShare != nil {
+		epoch.ParticipantShare.Clear()
+		epoch.ParticipantShare = nil
+	}
+	err := s.db.SaveEpoch(epoch)
+	if err != nil {
+		return err
+	}
+	if s.shareKeystore != nil {
+		return s.shareKeystore.DeleteShare(number)
+	}
+	return nil
+}
+
+// like GetEpoch but doesn't create a missing epoch, will return nil,nil if not found
+func (s *State) FindEpoch(number shared.EpochNumber) (*Epoch, error) {
 	e, err := s.db.GetEpoch(number)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	// the share is not kept in the db
+	if e.ParticipantShare == nil && s.shareKeystore != nil {
+		share, err := s.shareKeystore.LoadShare(number)
+		if err != nil {
+			return nil, err
+		}
+		e.ParticipantShare = share
+	}
+	return e, nil
+}
+
+func (s *State) GetEpoch(number shared.EpochNumber) *Epoch {
+	e, err := s.FindEpoch(number)
 	if err != nil {
 		return nil
 	}
 
 	// epoch not found, create new
 	if e == nil {
-		epochSeed, err := crypto.MixSeed(s.seed, number)
+		epochSeed, err := crypto.MixSeed(s.config.GenesisSeed, number)
 		if err != nil {
 			return nil
 		}
 
-		e = NewEpochInstance(number, epochSeed)
+		e = NewEpochInstance(number, epochSeed, s.config, s.registry)
 		err = s.SaveEpoch(e)
 		if err != nil {
 			return nil
@@ -53,9 +180,233 @@ func (s *State) GetEpoch(number shared.EpochNumber) *Epoch {
 }
 
 func (s *State) GetPool(poolId shared.PoolId) *Pool {
-	return s.Pools[poolId]
+	return s.registry.GetPool(poolId)
+}
+
+// all pools, in every status, sorted by id
+func (s *State) Pools() []*Pool {
+	return s.registry.Pools()
+}
+
+// registers (or updates) a pool as is, genesis pools are registered with it
+func (s *State) SavePool(pool *Pool) error {
+	err := s.db.SavePool(pool)
+	if err != nil {
+		return err
+	}
+	s.registry.savePool(pool)
+	return nil
+}
+
+// creates a pending pool with an unused id (e.g. Registry.NextPoolId), it's activated at an epoch boundary once
+// PoolStake was deposited to it (AddPoolStake) and there are enough active participants for another pool
+func (s *State) CreatePool(id shared.PoolId) (*Pool, error) {
+	pool, err := s.registry.createPool(id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.SavePool(pool)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// deposits gwei to a pending pool
+func (s *State) AddPoolStake(id shared.PoolId, gwei uint64) error {
+	pool, err := s.registry.updatePool(id, func(pool *Pool) error {
+		if pool.ActivationEpoch != FarFutureEpoch {
+			return fmt.Errorf("pool %d is not pending", id)
+		}
+		pool.Stake += gwei
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.SavePool(pool)
+}
+
+// requests an active pool's liquidation, processed at an epoch boundary. The epoch after the one it's processed
+// in is the pool's last (liquidating) epoch.
+func (s *State) RequestPoolLiquidation(id shared.PoolId) error {
+	pool, err := s.registry.requestLiquidation(id)
+	if err != nil {
+		return err
+	}
+	return s.db.SavePool(pool)
+}
+
+// sets the pool's public key once its DKG is done, size is the number of members the key was shared between
+func (s *State) SetPoolPk(id shared.PoolId, size shared.PoolSize, pk *bls.PublicKey) error {
+	pool, err := s.registry.updatePool(id, func(pool *Pool) error {
+		pool.Size = size
+		pool.Pk = pk
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.SavePool(pool)
+}
+
+// records the liquidating pool's exit signature, the pool is liquidated
+func (s *State) SetPoolExitSignature(id shared.PoolId, sig *bls.Sign) error {
+	pool, err := s.registry.updatePool(id, func(pool *Pool) error {
+		if pool.ExitEpoch == FarFutureEpoch {
+			return fmt.Errorf("pool %d is not liquidating", id)
+		}
+		pool.ExitSignature = sig
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.db.SavePool(pool)
+}
+
+func (s *State) Registry() *Registry {
+	return s.registry
+}
+
+func (s *State) GetParticipant(id shared.ParticipantId) *Participant {
+	return s.registry.Get(id)
 }
 
-func (s *State) SavePool(pool *Pool) {
-	s.Pools[pool.Id] = pool
-}
\ No newline at end of file
+// registers (or updates) a participant as is, genesis participants are registered with it
+func (s *State) SaveParticipant(participant *Participant) error {
+	err := s.db.SaveParticipant(participant)
+	if err != nil {
+		return err
+	}
+	s.registry.save(participant)
+	return nil
+}
+
+// registers a new, pending, participant. It's activated RegistryUpdateDelay epochs after the epoch it's processed
+// in (see ProcessRegistryUpdates).
+// The network's registry changes with the requests of finalized blocks only, see requests.go.
+func (s *State) RequestJoin(participant *Participant) error {
+	if s.registry.Get(participant.Id) != nil {
+		return fmt.Errorf("participant %d already registered", participant.Id)
+	}
+	if participant.ActivationEpoch != FarFutureEpoch || participant.ExitEpoch != FarFutureEpoch {
+		return fmt.Errorf("participant %d is not pending", participant.Id)
+	}
+	if participant.EncryptionPk == nil || participant.IdentityPk == nil {
+		return fmt.Errorf("participant %d has no keys", participant.Id)
+	}
+	return s.SaveParticipant(participant)
+}
+
+// requests an active (or activation scheduled) participant's voluntary exit, processed like joins
+func (s *State) RequestExit(id shared.ParticipantId) error {
+	participant, err := s.registry.requestExit(id)
+	if err != nil {
+		return err
+	}
+	return s.db.SaveParticipant(participant)
+}
+
+// called at the end of epoch number, schedules pending joins, exits, pool activations and liquidations. Exits and
+// activations are delayed while they would leave less than PoolSize active participants per pool.
+func (s *State) ProcessRegistryUpdates(number shared.EpochNumber) error {
+	participants, pools := s.registry.processUpdates(number, int(s.config.PoolSize))
+	for _, participant := range participants {
+		err := s.db.SaveParticipant(participant)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pool := range pools {
+		err := s.db.SavePool(pool)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// takes over finalized's registry, the network's registry only changes with finalized blocks. A pool's DKG result
+// and exit signature are kept until a finalized block includes them.
+func (s *State) SyncRegistry(finalized *State) error {
+	for _, p := range finalized.registry.Participants() {
+		copied := *p
+		err := s.SaveParticipant(&copied)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pool := range finalized.Pools() {
+		copied := *pool
+		if local := s.GetPool(pool.Id); local != nil {
+			if copied.Pk == nil {
+				copied.Pk, copied.Size = local.Pk, local.Size
+			}
+			if copied.ExitSignature == nil {
+				copied.ExitSignature = local.ExitSignature
+			}
+		}
+		err := s.SavePool(&copied)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *State) SaveBlock(block *Block) error {
+	return s.db.SaveBlock(block)
+}
+
+// will return nil,nil if not found
+func (s *State) GetBlock(epoch shared.EpochNumber) (*Block, error) {
+	return s.db.GetBlock(epoch)
+}
+
+// will return nil,nil if not found
+func (s *State) GetBlockByRoot(root eth2.Root) (*Block, error) {
+	return s.db.GetBlockByRoot(root)
+}
+
+// nil for the genesis state
+func (s *State) LatestBlock() *Block {
+	return s.latestBlock
+}
+
+// the next block's parent root, the zero root for the genesis state
+func (s *State) LatestBlockRoot() eth2.Root {
+	if s.latestBlock == nil {
+		return eth2.Root{}
+	}
+	return BlockRoot(s.latestBlock)
+}
+
+// the network's genesis state, config's genesis pools (their keys are block 0's DKG results) and the participants
+// active from epoch 0
+func (s *State) Genesis() *State {
+	ret := NewInMemoryState(s.config)
+	for _, p := range s.registry.Participants() {
+		if p.ActivationEpoch == 0 {
+			// the in memory db doesn't fail
+			ret.SaveParticipant(NewParticipant(p.Id, p.EncryptionPk, p.IdentityPk))
+		}
+	}
+	return ret
+}
+
+// an in memory copy of the network state, the registry and the latest block. Epochs, shares and blocks aren't
+// copied.
+func (s *State) Copy() *State {
+	ret := NewInMemoryState(s.config)
+	for _, p := range s.registry.Participants() {
+		copied := *p
+		ret.SaveParticipant(&copied)
+	}
+	for _, pool := range s.Pools() {
+		copied := *pool
+		ret.SavePool(&copied)
+	}
+	ret.latestBlock = s.latestBlock
+	return ret
+}
@@ -0,0 +1,141 @@
+package state
+
+import (
+	"fmt"
+	"github.com/bloxapp/eth2-staking-pools-research/minimal_pool/eth2"
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+/**
+	The pool chain's state transition. A block applies its epoch's registry requests (see requests.go) and DKG
+	results to the state of its parent (the genesis state for block 0) in the order they're listed in the body, then
+	the epoch's registry updates are processed (State.ProcessRegistryUpdates). Nodes' registries only change with
+	finalized blocks.
+	Disqualified participants are only recorded, pools' duty signatures only change the state if they're a
+	liquidating pool's voluntary exit signature. The participants' contributions update their balances,
+	evidence slashes the offenders.
+ */
+
+// applies block to s, returning the new state. s is not changed, the new state is an in memory copy.
+func TransitionState(s *State, block *Block) (*State, error) {
+	if block.Body == nil {
+		return nil, fmt.Errorf("block has no body")
+	}
+	if s.latestBlock == nil && block.Epoch != 0 {
+		return nil, fmt.Errorf("expected block 0, got %d", block.Epoch)
+	}
+	if s.latestBlock != nil && block.Epoch != s.latestBlock.Epoch + 1 {
+		return nil, fmt.Errorf("expected block %d, got %d", s.latestBlock.Epoch + 1, block.Epoch)
+	}
+	if block.ParentRoot != s.LatestBlockRoot() {
+		return nil, fmt.Errorf("block %d parent root doesn't match", block.Epoch)
+	}
+
+	ret := s.Copy()
+	body := block.Body
+	ids := make(map[string]bool)
+	for _, req := range body.Requests {
+		if ids[req.Id] {
+			return nil, fmt.Errorf("request %s included twice", req.Id)
+		}
+		ids[req.Id] = true
+		err := ret.ProcessRegistryRequest(block.Epoch, req)
+		if err != nil {
+			return nil, fmt.Errorf("%s request: %s", req.Type.String(), err.Error())
+		}
+	}
+	for _, key := range body.PoolKeys {
+		err := processPoolKey(ret, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, sig := range body.Signatures {
+		err := processExitSignature(ret, block, sig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the epoch's pools are shuffled from the registry before its updates
+	err := ret.ProcessContributions(block.Epoch, body.Contributions)
+	if err != nil {
+		return nil, fmt.Errorf("contributions: %s", err.Error())
+	}
+	err = ret.ProcessEvidence(block.Epoch, body.Evidence)
+	if err != nil {
+		return nil, fmt.Errorf("evidence: %s", err.Error())
+	}
+
+	err = ret.ProcessRegistryUpdates(block.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	ret.latestBlock = block
+	return ret, nil
+}
+
+// TransitionState that also checks the block's state root (the new state's HashTreeRoot)
+func ProcessBlock(s *State, block *Block) (*State, error) {
+	ret, err := TransitionState(s, block)
+	if err != nil {
+		return nil, err
+	}
+	if ret.HashTreeRoot() != block.StateRoot {
+		return nil, fmt.Errorf("block %d state root doesn't match", block.Epoch)
+	}
+	return ret, nil
+}
+
+// replays the consecutive blocks from block 0 stored in blocks on the genesis state, returns the state after the
+// last one
+func Replay(genesis *State, blocks BlockStore) (*State, error) {
+	ret := genesis
+	for epoch := uint32(0) ; ; epoch++ {
+		block, err := blocks.GetBlock(epoch)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return ret, nil
+		}
+		ret, err = ProcessBlock(ret, block)
+		if err != nil {
+			return nil, fmt.Errorf("could not replay block %d: %s", epoch, err.Error())
+		}
+	}
+}
+
+// a pool's DKG result, the pool's key doesn't change once set
+func processPoolKey(s *State, key *PoolKey) error {
+	pool := s.GetPool(key.PoolId)
+	if pool == nil {
+		return fmt.Errorf("key of unknown pool %d", key.PoolId)
+	}
+	if pool.Pk != nil {
+		if !pool.Pk.IsEqual(key.Pk) || pool.Size != key.Size {
+			return fmt.Errorf("pool %d has a different key", key.PoolId)
+		}
+		return nil
+	}
+	return s.SetPoolPk(key.PoolId, key.Size, key.Pk)
+}
+
+// a liquidating pool's voluntary exit signature is verified and recorded on the pool, other duty signatures don't
+// change the state
+func processExitSignature(s *State, block *Block, sig *PoolSignature) error {
+	pool := s.GetPool(sig.PoolId)
+	if pool == nil || pool.Pk == nil || pool.ExitEpoch != block.Epoch + 1 {
+		return nil
+	}
+	root, err := s.config.Fork().SigningRoot(eth2.NewVoluntaryExitDuty(pool.VoluntaryExit()))
+	if err != nil {
+		return err
+	}
+	if root != sig.SigningRoot {
+		return nil
+	}
+	if !bls.CastToSign(sig.Sig).VerifyByte(pool.Pk, root[:]) {
+		return fmt.Errorf("invalid pool %d exit signature", sig.PoolId)
+	}
+	return s.SetPoolExitSignature(sig.PoolId, bls.CastToSign(sig.Sig))
+}